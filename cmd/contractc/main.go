@@ -0,0 +1,87 @@
+/*
+Command contractc compiles a contract written in the
+language of package chain/protocol/contract.
+
+Usage:
+
+	contractc file [arg...]
+
+With no args, contractc prints the JSON description of the
+compiled contract: its parameters, the arguments each of its
+clauses expects in an input witness, and its program body.
+
+With args, one for each contract parameter, contractc prints
+the hex-encoded control program for the contract with those
+arguments. Integer, Amount and Time arguments are given in
+decimal, Boolean arguments as true or false, and all others
+in hex.
+
+If file is -, contractc reads the contract from stdin.
+*/
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"chain/errors"
+	"chain/protocol/contract"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("contractc: ")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: contractc file [arg...]")
+		os.Exit(2)
+	}
+
+	var (
+		src []byte
+		err error
+	)
+	if os.Args[1] == "-" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(os.Args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := contract.Compile(string(src))
+	if err != nil {
+		log.Fatal(errors.Detail(err))
+	}
+
+	args := os.Args[2:]
+	if len(args) == 0 {
+		b, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	if len(args) != len(c.Params) {
+		log.Fatalf("contract %s takes %d arguments, got %d", c.Name, len(c.Params), len(args))
+	}
+	var values [][]byte
+	for i, arg := range args {
+		v, err := c.Params[i].Type.Encode(arg)
+		if err != nil {
+			log.Fatalf("%s: %s", c.Params[i].Name, errors.Detail(err))
+		}
+		values = append(values, v)
+	}
+	prog, err := c.Program(values...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(hex.EncodeToString(prog))
+}
@@ -42,6 +42,9 @@ with the alias "block_key".
 
     corectl create-block-keypair
 
+If the MockHSM's keys are encrypted, set MOCKHSM_PASSPHRASE
+to unlock them.
+
 Create Access Token
 
 Subcommand 'create-token' generates a new access token with the given name.
@@ -50,6 +53,43 @@ otherwise it will create a client token.
 
     corectl create-token [-net] [name]
 
+Rotate HSM Passphrase
+
+Subcommand 'rotate-hsm-passphrase' changes the passphrase that
+encrypts the MockHSM's keys. It prompts for the current passphrase,
+if one is set, and the new one. If no passphrase was set, it
+encrypts the keys stored in plaintext.
+
+    corectl rotate-hsm-passphrase
+
+Export Keys
+
+Subcommand 'export-keys' writes an encrypted backup of the MockHSM's
+keys with the given aliases, or of all its keys, to file. It prompts
+for a passphrase to encrypt the backup. If the MockHSM has a
+passphrase, export-keys requires it too, from MOCKHSM_PASSPHRASE
+or a prompt.
+
+    corectl export-keys [file] [alias]...
+
+Import Keys
+
+Subcommand 'import-keys' restores the keys in a backup written by
+export-keys, possibly on another Core, and prints their xpubs. It
+prompts for the backup's passphrase.
+
+    corectl import-keys [file]
+
+Restore Key
+
+Subcommand 'restore-key' recreates a key created with a mnemonic
+and prints its xpub. It prompts for the mnemonic.
+
+    corectl restore-key [alias]
+
+If the MockHSM's keys are encrypted, set MOCKHSM_PASSPHRASE
+to unlock them for these commands.
+
 Reset
 
 Subcommand 'reset' resets the database so the Chain Core can be configured again.
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"golang.org/x/crypto/ssh/terminal"
+
 	"chain/core/accesstoken"
 	"chain/core/config"
 	"chain/core/migrate"
@@ -23,7 +28,8 @@ import (
 
 // config vars
 var (
-	dbURL = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	dbURL             = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	mockhsmPassphrase = env.String("MOCKHSM_PASSPHRASE", "")
 )
 
 // We collect log output in this buffer,
@@ -35,11 +41,15 @@ type command struct {
 }
 
 var commands = map[string]*command{
-	"config-generator":     {configGenerator},
-	"create-block-keypair": {createBlockKeyPair},
-	"create-token":         {createToken},
-	"config":               {configNongenerator},
-	"reset":                {reset},
+	"config-generator":      {configGenerator},
+	"create-block-keypair":  {createBlockKeyPair},
+	"create-token":          {createToken},
+	"config":                {configNongenerator},
+	"export-keys":           {exportKeys},
+	"import-keys":           {importKeys},
+	"reset":                 {reset},
+	"restore-key":           {restoreKey},
+	"rotate-hsm-passphrase": {rotateHSMPassphrase},
 }
 
 func main() {
@@ -126,7 +136,7 @@ func configGenerator(db *sql.DB, args []string) {
 	}
 
 	ctx := context.Background()
-	err = config.Configure(ctx, db, conf)
+	err = config.Configure(ctx, db, newMockHSM(ctx, db), conf)
 	if err != nil {
 		fatalln("error:", err)
 	}
@@ -139,9 +149,8 @@ func createBlockKeyPair(db *sql.DB, args []string) {
 		fatalln("error: create-block-keypair takes no args")
 	}
 
-	hsm := mockhsm.New(db)
 	ctx := context.Background()
-	pub, err := hsm.Create(ctx, "block_key")
+	pub, err := newMockHSM(ctx, db).Create(ctx, "block_key")
 	if err != nil {
 		fatalln("error:", err)
 	}
@@ -200,12 +209,139 @@ func configNongenerator(db *sql.DB, args []string) {
 	conf.BlockPub = *flagK
 
 	ctx := context.Background()
-	err = config.Configure(ctx, db, &conf)
+	err = config.Configure(ctx, db, newMockHSM(ctx, db), &conf)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+func rotateHSMPassphrase(db *sql.DB, args []string) {
+	if len(args) != 0 {
+		fatalln("error: rotate-hsm-passphrase takes no args")
+	}
+
+	ctx := context.Background()
+	hsm := mockhsm.New(db)
+	ok, err := hsm.HasPassphrase(ctx)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	var old string
+	if ok {
+		old = readPassphrase("Current passphrase: ")
+	}
+	next := readPassphrase("New passphrase: ")
+	if readPassphrase("Repeat new passphrase: ") != next {
+		fatalln("error: passphrases do not match")
+	}
+	err = hsm.ChangePassphrase(ctx, old, next)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+func exportKeys(db *sql.DB, args []string) {
+	if len(args) < 1 {
+		fatalln("usage: corectl export-keys [file] [alias]...")
+	}
+
+	ctx := context.Background()
+	hsm := mockhsm.New(db)
+	ok, err := hsm.HasPassphrase(ctx)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	hsmPass := *mockhsmPassphrase
+	if ok && hsmPass == "" {
+		hsmPass = readPassphrase("HSM passphrase: ")
+	}
+	pass := readPassphrase("Backup passphrase: ")
+	if readPassphrase("Repeat backup passphrase: ") != pass {
+		fatalln("error: passphrases do not match")
+	}
+	backup, err := hsm.ExportKeys(ctx, args[1:], nil, hsmPass, pass)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	err = ioutil.WriteFile(args[0], backup, 0600)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+func importKeys(db *sql.DB, args []string) {
+	if len(args) != 1 {
+		fatalln("usage: corectl import-keys [file]")
+	}
+
+	backup, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	pass := readPassphrase("Backup passphrase: ")
+	ctx := context.Background()
+	xpubs, err := newMockHSM(ctx, db).ImportKeys(ctx, backup, pass)
 	if err != nil {
 		fatalln("error:", err)
 	}
+	for _, xpub := range xpubs {
+		fmt.Println(xpub.XPub)
+	}
 }
 
+func restoreKey(db *sql.DB, args []string) {
+	if len(args) > 1 {
+		fatalln("usage: corectl restore-key [alias]")
+	}
+	var alias string
+	if len(args) == 1 {
+		alias = args[0]
+	}
+
+	mnemonic := readPassphrase("Mnemonic: ")
+	ctx := context.Background()
+	xpub, err := newMockHSM(ctx, db).XRestore(ctx, alias, mnemonic)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println(xpub.XPub)
+}
+
+// newMockHSM returns the mockhsm, unlocked with
+// MOCKHSM_PASSPHRASE if that is set.
+func newMockHSM(ctx context.Context, db *sql.DB) *mockhsm.HSM {
+	hsm := mockhsm.New(db)
+	if *mockhsmPassphrase != "" {
+		err := hsm.Unlock(ctx, *mockhsmPassphrase)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
+	return hsm
+}
+
+// readPassphrase prompts for and reads a line from stdin,
+// without echoing it if stdin is a terminal.
+func readPassphrase(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			fatalln("error: reading passphrase:", err)
+		}
+		return strings.TrimSuffix(line, "\n")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fatalln("error: reading passphrase:", err)
+	}
+	return string(b)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
 func fatalln(v ...interface{}) {
 	io.Copy(os.Stderr, &logbuf)
 	fmt.Fprintln(os.Stderr, v...)
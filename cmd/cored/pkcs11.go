@@ -0,0 +1,21 @@
+//+build pkcs11
+
+package main
+
+import (
+	"chain/core/hsm"
+	"chain/core/hsm/pkcs11"
+)
+
+// This file adds the pkcs11 HSM backend, which needs cgo
+// and so is left out of default builds.
+
+func init() {
+	newPKCS11HSM = func() (hsm.HSM, error) {
+		return pkcs11.New(pkcs11.Config{
+			Module:     *pkcs11Module,
+			TokenLabel: *pkcs11TokenLabel,
+			PIN:        *pkcs11PIN,
+		})
+	}
+}
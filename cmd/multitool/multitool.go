@@ -24,6 +24,7 @@ import (
 	"chain/crypto/ed25519/chainkd"
 	"chain/protocol/bc"
 	"chain/protocol/vm"
+	"chain/protocol/vm/analysis"
 )
 
 // A timed reader times out its Read() operation after a specified
@@ -69,6 +70,7 @@ type command struct {
 }
 
 var subcommands = map[string]command{
+	"analyze":     command{analyze, "analyze a program without running it", "PROG"},
 	"assetid":     command{assetid, "compute asset id", "ISSUANCEPROG GENESISHASH"},
 	"block":       command{block, "decode and pretty-print a block", "BLOCK"},
 	"blockheader": command{blockheader, "decode and pretty-print a block header", "BLOCKHEADER"},
@@ -295,7 +297,7 @@ func script(args []string) {
 	inp, _ := input(args, 0, false)
 	b, err := decodeHex(inp)
 	if err == nil {
-		dis, err := vm.Disassemble(b)
+		dis, err := vm.DisassembleIndent(b)
 		if err == nil {
 			fmt.Println(dis)
 			return
@@ -312,6 +314,55 @@ func script(args []string) {
 	errorf("could not parse input")
 }
 
+func analyze(args []string) {
+	inp, _ := input(args, 0, false)
+	prog, err := decodeHex(inp)
+	if err != nil {
+		prog, err = vm.Assemble(inp)
+		if err != nil {
+			errorf("could not parse input")
+		}
+	}
+	a, err := analysis.Analyze(prog)
+	if err != nil {
+		errorf("%s", err)
+	}
+	var ops, disallowed []string
+	for _, op := range a.Ops {
+		ops = append(ops, op.String())
+	}
+	for _, op := range a.Disallowed {
+		disallowed = append(disallowed, op.String())
+	}
+	fmt.Printf("ops: %s\n", strings.Join(ops, " "))
+	if len(disallowed) > 0 {
+		fmt.Printf("disallowed in version 1 transactions: %s\n", strings.Join(disallowed, " "))
+	}
+	if a.StackUnbounded {
+		fmt.Println("max stack depth: unbounded")
+	} else {
+		fmt.Printf("max stack depth: %d\n", a.MaxStackDepth)
+	}
+	switch {
+	case a.CostUnbounded:
+		fmt.Println("max cost: unbounded")
+	case a.DataDependentCost:
+		fmt.Printf("max cost: %d plus data-dependent costs\n", a.MaxCost)
+	default:
+		fmt.Printf("max cost: %d\n", a.MaxCost)
+	}
+	fmt.Printf("can succeed: %t\n", a.CanSucceed)
+	if t := a.Template; t != nil {
+		fmt.Printf("template: %s, %d of %d keys\n", t.Kind, t.Quorum, len(t.PublicKeys))
+		for _, k := range t.PublicKeys {
+			fmt.Printf("  key: %x\n", []byte(k))
+		}
+		if t.Kind == analysis.AssetDefinition {
+			fmt.Printf("  definition: %x\n", t.Definition)
+		}
+	}
+}
+
 func sha3Cmd(args []string) {
 	inp, _ := input(args, 0, false)
 	b := mustDecodeHex(inp)
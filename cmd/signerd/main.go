@@ -0,0 +1,148 @@
+/*
+
+Command signerd serves signing requests from Chain Cores, so that
+keys can be kept on a host separate from the Cores that use them.
+It keeps its keys in a mockhsm in its own database.
+
+	signerd
+	signerd create-key [alias]
+
+With no arguments, signerd serves signing requests at /sign.
+Subcommand 'create-key' creates a key and prints its xpub.
+
+Environment variables configure signerd:
+
+	LISTEN             address to listen on; the default is ":8080"
+	DATABASE_URL       database holding the keys
+	ACCESS_TOKEN       token clients must present, of the form name:secret
+	POLICY_FILE        JSON file of signing policies (required to serve)
+	MOCKHSM_PASSPHRASE passphrase that unlocks encrypted keys
+	TLSCRT, TLSKEY     TLS certificate and key, in PEM format
+
+signerd signs only with keys that have a policy in POLICY_FILE,
+a JSON array of objects like this:
+
+	{
+		"xpub": "...",
+		"assets": ["<asset id>", ...],
+		"accounts": ["<account derivation path>", ...],
+		"max_amount": 1000
+	}
+
+Empty or missing fields other than xpub impose no limit.
+See package chain/core/signerd for details.
+
+To have a Core delegate signing to signerd, set its SIGNERD_URLS.
+
+*/
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"chain/core/migrate"
+	"chain/core/mockhsm"
+	"chain/core/signerd"
+	"chain/database/sql"
+	"chain/env"
+	"chain/errors"
+	"chain/log"
+)
+
+const (
+	httpReadTimeout  = time.Minute
+	httpWriteTimeout = time.Minute
+)
+
+var (
+	listenAddr        = env.String("LISTEN", ":8080")
+	dbURL             = env.String("DATABASE_URL", "postgres:///signerd?sslmode=disable")
+	accessToken       = env.String("ACCESS_TOKEN", "")
+	policyFile        = env.String("POLICY_FILE", "")
+	mockhsmPassphrase = env.String("MOCKHSM_PASSPHRASE", "")
+	tlsCrt            = env.String("TLSCRT", "")
+	tlsKey            = env.String("TLSKEY", "")
+)
+
+func main() {
+	ctx := context.Background()
+	env.Parse()
+
+	db, err := sql.Open("hapg", *dbURL)
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+	err = migrate.Run(db)
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+
+	hsm := mockhsm.New(db)
+	if *mockhsmPassphrase != "" {
+		err = hsm.Unlock(ctx, *mockhsmPassphrase)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+	}
+
+	if len(os.Args) > 1 {
+		if os.Args[1] != "create-key" || len(os.Args) > 3 {
+			fmt.Fprintln(os.Stderr, "usage: signerd [create-key [alias]]")
+			os.Exit(2)
+		}
+		var alias string
+		if len(os.Args) == 3 {
+			alias = os.Args[2]
+		}
+		xpub, err := hsm.XCreate(ctx, alias)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+		fmt.Println(xpub.XPub.String())
+		return
+	}
+
+	if *accessToken == "" {
+		log.Fatal(ctx, log.KeyError, errors.New("ACCESS_TOKEN is not set"))
+	}
+	if *policyFile == "" {
+		log.Fatal(ctx, log.KeyError, errors.New("POLICY_FILE is not set"))
+	}
+	f, err := os.Open(*policyFile)
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+	policies, err := signerd.ReadPolicies(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+
+	server := &http.Server{
+		Addr: *listenAddr,
+		Handler: &signerd.Server{
+			HSM:         hsm,
+			Policies:    policies,
+			AccessToken: *accessToken,
+		},
+		ReadTimeout:  httpReadTimeout,
+		WriteTimeout: httpWriteTimeout,
+	}
+	log.Messagef(ctx, "signerd listening at %s with %d key policies", *listenAddr, len(policies))
+	if *tlsCrt != "" {
+		cert, err := tls.X509KeyPair([]byte(*tlsCrt), []byte(*tlsKey))
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, errors.Wrap(err, "parsing tls X509 key pair"))
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	log.Fatal(ctx, log.KeyError, err)
+}
@@ -0,0 +1,261 @@
+/*
+Command vmdebug steps through the execution of a
+transaction input's program or a block's consensus program.
+
+Usage:
+
+	vmdebug tx <raw-tx-hex> <input-index>
+	vmdebug block <prev-block-header-hex> <block-hex>
+
+It stops before the first instruction and reads commands
+from stdin:
+
+	s, step [n]         execute n instructions (default 1)
+	c, continue         run until a breakpoint or the end
+	b, break <pc|op>    stop before the instruction at pc,
+	                    or before every instruction with opcode op
+	d, delete <n>       delete breakpoint n
+	breaks              list breakpoints
+	st, stack           print the data and alt stacks, top first
+	l, list             list the running program
+	q, quit             exit
+
+Breakpoints on pc apply to the program at any
+CHECKPREDICATE depth. An empty line repeats the
+last command.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+const usage = `usage: vmdebug tx <raw-tx-hex> <input-index>
+       vmdebug block <prev-block-header-hex> <block-hex>`
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vmdebug: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+type breakpoint struct {
+	pc    uint32
+	op    string // if set, pc is ignored
+	valid bool
+}
+
+func (b breakpoint) String() string {
+	if b.op != "" {
+		return "op " + b.op
+	}
+	return fmt.Sprintf("pc %d", b.pc)
+}
+
+type debugger struct {
+	in          *bufio.Scanner
+	steps       int // instructions left to execute before stopping; 0 means run to a breakpoint
+	breakpoints []breakpoint
+	last        string
+}
+
+func main() {
+	if len(os.Args) != 4 {
+		fatalf("%s", usage)
+	}
+
+	d := &debugger{in: bufio.NewScanner(os.Stdin), steps: 1}
+	var (
+		ok  bool
+		err error
+	)
+	switch os.Args[1] {
+	case "tx":
+		var tx bc.Tx
+		err = tx.UnmarshalText([]byte(os.Args[2]))
+		if err != nil {
+			fatalf("decoding tx: %s", err)
+		}
+		var index int
+		index, err = strconv.Atoi(os.Args[3])
+		if err != nil {
+			fatalf("bad input index %s", os.Args[3])
+		}
+		ok, err = vm.VerifyTxInputTrace(&tx, index, d.trace)
+	case "block":
+		var prev bc.BlockHeader
+		err = prev.Scan(mustDecodeHex(os.Args[2]))
+		if err != nil {
+			fatalf("decoding block header: %s", err)
+		}
+		var block bc.Block
+		err = block.Scan(mustDecodeHex(os.Args[3]))
+		if err != nil {
+			fatalf("decoding block: %s", err)
+		}
+		ok, err = vm.VerifyBlockHeaderTrace(&prev, &block, d.trace)
+	default:
+		fatalf("%s", usage)
+	}
+
+	if err != nil {
+		fmt.Printf("program failed: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("program returned %v\n", ok)
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		fatalf("decoding hex: %s", err)
+	}
+	return b
+}
+
+// trace is the vm.Tracer that decides whether to stop
+// before each step and, if so, reads commands until
+// one of them resumes execution.
+func (d *debugger) trace(step vm.Step) {
+	if !d.shouldStop(step) {
+		return
+	}
+	printStep(step)
+	for {
+		fmt.Print("(vmdebug) ")
+		if !d.in.Scan() {
+			fmt.Println()
+			os.Exit(0)
+		}
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+		if d.command(step, strings.Fields(line)) {
+			return
+		}
+	}
+}
+
+func (d *debugger) shouldStop(step vm.Step) bool {
+	if d.steps > 0 {
+		d.steps--
+		if d.steps == 0 {
+			return true
+		}
+	}
+	for _, b := range d.breakpoints {
+		if !b.valid {
+			continue
+		}
+		if b.op != "" && b.op == step.Op.String() || b.op == "" && b.pc == step.PC {
+			return true
+		}
+	}
+	return false
+}
+
+// command runs the command in args and
+// reports whether execution should resume.
+func (d *debugger) command(step vm.Step, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "s", "step":
+		d.steps = 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Printf("bad step count %s\n", args[1])
+				return false
+			}
+			d.steps = n
+		}
+		return true
+	case "c", "continue":
+		d.steps = 0
+		return true
+	case "b", "break":
+		if len(args) != 2 {
+			fmt.Println("usage: break <pc|op>")
+			return false
+		}
+		var b breakpoint
+		if pc, err := strconv.ParseUint(args[1], 10, 32); err == nil {
+			b = breakpoint{pc: uint32(pc), valid: true}
+		} else {
+			b = breakpoint{op: strings.ToUpper(args[1]), valid: true}
+		}
+		d.breakpoints = append(d.breakpoints, b)
+		fmt.Printf("breakpoint %d at %s\n", len(d.breakpoints), b)
+	case "d", "delete":
+		n := 0
+		if len(args) == 2 {
+			n, _ = strconv.Atoi(args[1])
+		}
+		if n < 1 || n > len(d.breakpoints) || !d.breakpoints[n-1].valid {
+			fmt.Println("usage: delete <breakpoint number>")
+			return false
+		}
+		d.breakpoints[n-1].valid = false
+	case "breaks":
+		for i, b := range d.breakpoints {
+			if b.valid {
+				fmt.Printf("%d: %s\n", i+1, b)
+			}
+		}
+	case "st", "stack":
+		printStack("data", step.DataStack)
+		printStack("alt", step.AltStack)
+	case "l", "list":
+		printProgram(step)
+	case "q", "quit":
+		os.Exit(0)
+	default:
+		fmt.Printf("unknown command %s\n", args[0])
+	}
+	return false
+}
+
+func printStep(step vm.Step) {
+	fmt.Printf("depth %d pc %d limit %d: %s\n", step.Depth, step.PC, step.RunLimit, instString(step.Op, step.Data))
+}
+
+func instString(op vm.Op, data []byte) string {
+	if len(data) > 0 {
+		return fmt.Sprintf("%s 0x%x", op, data)
+	}
+	return op.String()
+}
+
+func printStack(name string, stack [][]byte) {
+	fmt.Printf("%s stack (%d items)\n", name, len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		fmt.Printf("  %d: %x\n", len(stack)-1-i, stack[i])
+	}
+}
+
+func printProgram(step vm.Step) {
+	for pc := uint32(0); pc < uint32(len(step.Program)); {
+		inst, err := vm.ParseOp(step.Program, pc)
+		if err != nil {
+			fmt.Printf("  %d: %s\n", pc, err)
+			return
+		}
+		mark := "  "
+		if pc == step.PC {
+			mark = "=>"
+		}
+		fmt.Printf("%s %d: %s\n", mark, pc, instString(inst.Op, inst.Data))
+		pc += inst.Len
+	}
+}
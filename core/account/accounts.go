@@ -31,7 +31,7 @@ func NewManager(db *sql.DB, chain *protocol.Chain, pinStore *pin.Store) *Manager
 	return &Manager{
 		db:          db,
 		chain:       chain,
-		utxoDB:      newReserver(db, chain, pinStore),
+		utxoDB:      newReserver(db, pinStore),
 		pinStore:    pinStore,
 		cache:       lru.New(maxAccountCache),
 		aliasCache:  lru.New(maxAccountCache),
@@ -242,6 +242,40 @@ func (m *Manager) insertAccountControlProgram(ctx context.Context, progs ...*con
 	return errors.Wrap(err)
 }
 
+// ControlPrograms returns every control program
+// created for an account, including change programs.
+func (m *Manager) ControlPrograms(ctx context.Context) ([][]byte, error) {
+	const q = `SELECT control_program FROM account_control_programs`
+	var progs [][]byte
+	err := pg.ForQueryRows(ctx, m.db, q, func(program []byte) {
+		progs = append(progs, program)
+	})
+	return progs, errors.Wrap(err)
+}
+
+// ProgramAccounts returns the ID of the account of each of
+// progs that was created for an account, keyed by the program.
+func (m *Manager) ProgramAccounts(ctx context.Context, progs [][]byte) (map[string]string, error) {
+	const q = `
+		SELECT control_program, signer_id FROM account_control_programs
+		WHERE control_program=ANY($1::bytea[])
+	`
+	accounts := make(map[string]string)
+	err := pg.ForQueryRows(ctx, m.db, q, pq.ByteaArray(progs), func(program []byte, accountID string) {
+		accounts[string(program)] = accountID
+	})
+	return accounts, errors.Wrap(err)
+}
+
+// CountControlPrograms returns the number of control
+// programs created for accounts.
+func (m *Manager) CountControlPrograms(ctx context.Context) (int, error) {
+	const q = `SELECT count(*) FROM account_control_programs`
+	var n int
+	err := m.db.QueryRow(ctx, q).Scan(&n)
+	return n, errors.Wrap(err)
+}
+
 func (m *Manager) nextIndex(ctx context.Context) (uint64, error) {
 	m.acpMu.Lock()
 	defer m.acpMu.Unlock()
@@ -35,6 +35,10 @@ type spendAction struct {
 	AccountID     string        `json:"account_id"`
 	ReferenceData chainjson.Map `json:"reference_data"`
 	ClientToken   *string       `json:"client_token"`
+
+	// SelectionStrategy names the strategy used to choose which of
+	// the account's outputs to spend. See SelectionStrategy.
+	SelectionStrategy string `json:"selection_strategy"`
 }
 
 func (a *spendAction) Build(ctx context.Context, maxTime time.Time, b *txbuilder.TemplateBuilder) error {
@@ -49,6 +53,11 @@ func (a *spendAction) Build(ctx context.Context, maxTime time.Time, b *txbuilder
 		return txbuilder.MissingFieldsError(missing...)
 	}
 
+	strategy, err := selectionStrategy(a.SelectionStrategy)
+	if err != nil {
+		return err
+	}
+
 	acct, err := a.accounts.findByID(ctx, a.AccountID)
 	if err != nil {
 		return errors.Wrap(err, "get account info")
@@ -58,7 +67,7 @@ func (a *spendAction) Build(ctx context.Context, maxTime time.Time, b *txbuilder
 		AssetID:   a.AssetID,
 		AccountID: a.AccountID,
 	}
-	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, a.ClientToken, maxTime)
+	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, strategy, a.ClientToken, maxTime)
 	if err != nil {
 		return errors.Wrap(err, "reserving utxos")
 	}
@@ -199,3 +199,63 @@ func programInAccount(ctx context.Context, t testing.TB, db pg.DB, program []byt
 	}
 	return in
 }
+
+func TestBuildConsolidation(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+		indexer  = query.NewIndexer(db, c, pinStore)
+
+		accID = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		asset = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	)
+	for i := uint64(1); i <= 3; i++ {
+		coretest.IssueAssets(ctx, t, c, assets, accounts, asset, i, accID)
+	}
+
+	coretest.CreatePins(ctx, t, pinStore)
+	assets.IndexAssets(indexer)
+	accounts.IndexAccounts(indexer)
+	go accounts.ProcessBlocks(ctx)
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	frags, err := accounts.FindFragmented(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frags) != 1 || frags[0].AccountID != accID || frags[0].AssetID != asset || frags[0].UTXOs != 3 {
+		t.Errorf("FindFragmented = %+v, want 3 outputs of %s in %s", frags, asset, accID)
+	}
+
+	cons, err := accounts.BuildConsolidation(ctx, accID, asset, 2, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := cons.Template.Transaction
+	if len(tx.Inputs) != 2 || cons.Inputs != 2 {
+		t.Errorf("got %d inputs, want 2", len(tx.Inputs))
+	}
+	if len(tx.Outputs) != 1 || tx.Outputs[0].Amount != 3 || cons.Amount != 3 {
+		t.Errorf("got outputs %+v, want one output of the two smallest amounts", tx.Outputs)
+	}
+	if !programInAccount(ctx, t, db, tx.Outputs[0].ControlProgram, accID) {
+		t.Errorf("expected consolidated output to belong to account")
+	}
+
+	// Only one unreserved output remains.
+	_, err = accounts.BuildConsolidation(ctx, accID, asset, 2, time.Now().Add(time.Minute))
+	if errors.Root(err) != account.ErrTooFewUTXOs {
+		t.Errorf("got error %v, want %v", err, account.ErrTooFewUTXOs)
+	}
+
+	cons.Cancel()
+	_, err = accounts.BuildConsolidation(ctx, accID, asset, 2, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Errorf("after cancel: %v", err)
+	}
+}
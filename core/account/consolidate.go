@@ -0,0 +1,229 @@
+package account
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrTooFewUTXOs is returned when an account doesn't have
+// enough unreserved outputs of an asset to consolidate.
+var ErrTooFewUTXOs = errors.New("too few outputs to consolidate")
+
+// ErrBadConsolidationPolicy is returned by Validate
+// for a policy that can't consolidate any outputs.
+var ErrBadConsolidationPolicy = errors.New("invalid consolidation policy")
+
+// ConsolidationPolicy describes when and how
+// an account's outputs are consolidated.
+type ConsolidationPolicy struct {
+	// MinUTXOs is the number of outputs of an asset an
+	// account must have before they are consolidated.
+	MinUTXOs int
+
+	// MaxInputs is the largest number of outputs
+	// merged by a single consolidating transaction.
+	MaxInputs int
+}
+
+// Validate returns an error if p can't consolidate any outputs,
+// since a consolidating transaction merges at least two.
+func (p ConsolidationPolicy) Validate() error {
+	if p.MaxInputs < 2 {
+		return errors.WithDetailf(ErrBadConsolidationPolicy, "max inputs %d is less than 2", p.MaxInputs)
+	}
+	return nil
+}
+
+// Fragmented identifies an account holding
+// many outputs of an asset.
+type Fragmented struct {
+	AccountID string
+	AssetID   bc.AssetID
+	UTXOs     int
+}
+
+// FindFragmented returns the accounts and assets
+// with at least minUTXOs confirmed outputs.
+func (m *Manager) FindFragmented(ctx context.Context, minUTXOs int) ([]Fragmented, error) {
+	const q = `
+		SELECT account_id, asset_id, count(*)
+		FROM account_utxos
+		WHERE confirmed_in IS NOT NULL
+		GROUP BY account_id, asset_id
+		HAVING count(*) >= $1
+	`
+	var res []Fragmented
+	err := pg.ForQueryRows(ctx, m.db, q, minUTXOs, func(accountID string, assetID bc.AssetID, n int) {
+		res = append(res, Fragmented{AccountID: accountID, AssetID: assetID, UTXOs: n})
+	})
+	return res, errors.Wrap(err, "finding fragmented accounts")
+}
+
+// Consolidation is an unsigned transaction merging
+// several of an account's outputs into one.
+type Consolidation struct {
+	Template *txbuilder.Template
+	Inputs   int
+	Amount   uint64
+
+	// XPubs and Quorum describe the keys needed
+	// to sign each of the transaction's inputs.
+	XPubs  []string
+	Quorum int
+
+	cancel func()
+}
+
+// Cancel releases the outputs reserved for c,
+// if it won't be submitted.
+func (c *Consolidation) Cancel() {
+	c.cancel()
+}
+
+// BuildConsolidation builds a transaction spending up to maxInputs of
+// the account's smallest unreserved outputs of the asset to a single
+// new output in the same account. The outputs are reserved until
+// maxTime, so that transactions built concurrently don't try to spend
+// them. It returns ErrTooFewUTXOs if fewer than two outputs are
+// available.
+func (m *Manager) BuildConsolidation(ctx context.Context, accountID string, assetID bc.AssetID, maxInputs int, maxTime time.Time) (*Consolidation, error) {
+	if maxInputs < 2 {
+		return nil, errors.WithDetailf(ErrTooFewUTXOs, "max inputs %d is less than 2", maxInputs)
+	}
+	c := new(Consolidation)
+	a := &consolidateAction{
+		accounts:  m,
+		accountID: accountID,
+		assetID:   assetID,
+		maxInputs: maxInputs,
+		result:    c,
+	}
+	tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{a}, maxTime)
+	if errors.Root(err) == txbuilder.ErrAction {
+		if errs, ok := errors.Data(err)["actions"].([]error); ok && len(errs) == 1 {
+			err = errs[0]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Template = tpl
+	return c, nil
+}
+
+type consolidateAction struct {
+	accounts  *Manager
+	accountID string
+	assetID   bc.AssetID
+	maxInputs int
+	result    *Consolidation
+}
+
+func (a *consolidateAction) Build(ctx context.Context, maxTime time.Time, b *txbuilder.TemplateBuilder) error {
+	acct, err := a.accounts.findByID(ctx, a.accountID)
+	if err != nil {
+		return errors.Wrap(err, "get account info")
+	}
+
+	src := source{AssetID: a.assetID, AccountID: a.accountID}
+	res, err := a.accounts.utxoDB.ReserveSmallest(ctx, src, a.maxInputs, maxTime)
+	if err != nil {
+		return errors.Wrap(err, "reserving utxos")
+	}
+	cancel := canceler(ctx, a.accounts, res.ID)
+	if len(res.UTXOs) < 2 {
+		cancel()
+		return errors.WithDetailf(ErrTooFewUTXOs, "account %s has %d unreserved outputs of asset %s", a.accountID, len(res.UTXOs), a.assetID)
+	}
+	b.OnRollback(cancel)
+
+	var total uint64
+	for _, u := range res.UTXOs {
+		txInput, sigInst, err := utxoToInputs(ctx, acct, u, nil)
+		if err != nil {
+			return errors.Wrap(err, "creating inputs")
+		}
+		err = b.AddInput(txInput, sigInst)
+		if err != nil {
+			return errors.Wrap(err, "adding inputs")
+		}
+		total += u.Amount
+	}
+
+	acp, err := a.accounts.createControlProgram(ctx, a.accountID, true)
+	if err != nil {
+		return errors.Wrap(err, "creating control program")
+	}
+	a.accounts.insertControlProgramDelayed(ctx, b, acp)
+	err = b.AddOutput(bc.NewTxOutput(a.assetID, total, acp.controlProgram, nil))
+	if err != nil {
+		return errors.Wrap(err, "adding output")
+	}
+
+	a.result.Inputs = len(res.UTXOs)
+	a.result.Amount = total
+	for _, xpub := range acct.XPubs {
+		a.result.XPubs = append(a.result.XPubs, xpub.String())
+	}
+	a.result.Quorum = acct.Quorum
+	a.result.cancel = cancel
+	return nil
+}
+
+// ReserveSmallest reserves up to max of the smallest unreserved
+// UTXOs matching src. The resulting reservation expires at exp.
+func (re *reserver) ReserveSmallest(ctx context.Context, src source, max int, exp time.Time) (*reservation, error) {
+	sr := re.source(src)
+	err := sr.refillCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		reserved, err := re.reservedOutpoints(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		res := &reservation{
+			Source: src,
+			UTXOs:  sr.chooseSmallest(reserved, max),
+			Expiry: exp,
+		}
+		err = re.insert(ctx, res)
+		if err == errReservationConflict && attempt < maxReserveAttempts {
+			err = sr.evictSpent(ctx)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err == errReservationConflict {
+			return nil, ErrReserved
+		}
+		return res, err
+	}
+}
+
+// chooseSmallest returns up to max of the smallest
+// cached UTXOs that are not in reserved.
+func (sr *sourceReserver) chooseSmallest(reserved map[bc.Outpoint]bool, max int) []*utxo {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	candidates, _, _ := sr.candidates(reserved)
+	sort.Sort(byAmountAsc(candidates))
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	var chosen []*utxo
+	for _, c := range candidates {
+		chosen = append(chosen, sr.cached[c.Outpoint])
+	}
+	return chosen
+}
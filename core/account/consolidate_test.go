@@ -0,0 +1,62 @@
+package account
+
+import (
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestConsolidationPolicyValidate(t *testing.T) {
+	cases := []struct {
+		maxInputs int
+		wantErr   error
+	}{
+		{-1, ErrBadConsolidationPolicy},
+		{0, ErrBadConsolidationPolicy},
+		{1, ErrBadConsolidationPolicy},
+		{2, nil},
+		{50, nil},
+	}
+	for _, c := range cases {
+		p := ConsolidationPolicy{MinUTXOs: 10, MaxInputs: c.maxInputs}
+		if err := p.Validate(); errors.Root(err) != c.wantErr {
+			t.Errorf("Validate() with max inputs %d = %v, want %v", c.maxInputs, err, c.wantErr)
+		}
+	}
+}
+
+func TestChooseSmallest(t *testing.T) {
+	utxos := testUTXOs(7, 1, 9, 3, 5)
+	sr := &sourceReserver{
+		cached: make(map[bc.Outpoint]*utxo),
+	}
+	reserved := map[bc.Outpoint]bool{utxos[1].Outpoint: true}
+	for _, u := range utxos {
+		sr.cached[u.Outpoint] = u
+	}
+
+	got := sr.chooseSmallest(reserved, 3)
+	var gotAmounts []uint64
+	for _, u := range got {
+		gotAmounts = append(gotAmounts, u.Amount)
+	}
+	// The output with amount 1 is already reserved.
+	want := []uint64{3, 5, 7}
+	if len(gotAmounts) != len(want) {
+		t.Fatalf("chose %v, want %v", gotAmounts, want)
+	}
+	for i := range want {
+		if gotAmounts[i] != want[i] {
+			t.Fatalf("chose %v, want %v", gotAmounts, want)
+		}
+	}
+	for _, u := range got {
+		reserved[u.Outpoint] = true
+	}
+
+	got = sr.chooseSmallest(reserved, 3)
+	if len(got) != 1 || got[0].Amount != 9 {
+		t.Errorf("got %v, want only the remaining output", got)
+	}
+}
@@ -0,0 +1,78 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Reservation describes the outputs of an account
+// held for a transaction that is being built.
+type Reservation struct {
+	ID          uint64        `json:"id"`
+	AccountID   string        `json:"account_id"`
+	AssetID     bc.AssetID    `json:"asset_id"`
+	Amount      uint64        `json:"amount"`
+	Change      uint64        `json:"change"`
+	Expiry      time.Time     `json:"expiry"`
+	ClientToken *string       `json:"client_token"`
+	Outputs     []bc.Outpoint `json:"outputs"`
+}
+
+// ListReservations returns up to limit unexpired reservations
+// with IDs greater than after, in order of ID. It also returns
+// the ID of the last reservation, to use as the next after.
+func (m *Manager) ListReservations(ctx context.Context, after uint64, limit int) ([]*Reservation, uint64, error) {
+	const q = `
+		SELECT r.reservation_id, r.account_id, r.asset_id, r.amount, r.change, r.expiry, r.client_token,
+			COALESCE(array_agg(u.tx_hash ORDER BY u.tx_hash, u.index) FILTER (WHERE u.tx_hash IS NOT NULL), '{}'),
+			COALESCE(array_agg(u.index ORDER BY u.tx_hash, u.index) FILTER (WHERE u.tx_hash IS NOT NULL), '{}')
+		FROM reservations r
+		LEFT JOIN reserved_utxos u ON u.reservation_id = r.reservation_id
+		WHERE r.reservation_id > $1 AND r.expiry > now()
+		GROUP BY r.reservation_id
+		ORDER BY r.reservation_id
+		LIMIT $2
+	`
+	var res []*Reservation
+	err := pg.ForQueryRows(ctx, m.db, q, after, limit,
+		func(id uint64, accountID string, assetID bc.AssetID, amount, change uint64, expiry time.Time, clientToken *string, hashes pq.StringArray, indexes pg.Uint32s) error {
+			r := &Reservation{
+				ID:          id,
+				AccountID:   accountID,
+				AssetID:     assetID,
+				Amount:      amount,
+				Change:      change,
+				Expiry:      expiry,
+				ClientToken: clientToken,
+				Outputs:     make([]bc.Outpoint, 0, len(hashes)),
+			}
+			for i, h := range hashes {
+				var o bc.Outpoint
+				err := o.Hash.UnmarshalText([]byte(h))
+				if err != nil {
+					return err
+				}
+				o.Index = indexes[i]
+				r.Outputs = append(r.Outputs, o)
+			}
+			res = append(res, r)
+			after = id
+			return nil
+		})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "listing reservations")
+	}
+	return res, after, nil
+}
+
+// CancelReservation cancels the reservation with the given ID,
+// so that its outputs may be spent by other transactions.
+func (m *Manager) CancelReservation(ctx context.Context, id uint64) error {
+	return m.utxoDB.Cancel(ctx, id)
+}
@@ -5,16 +5,14 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
 
+	"github.com/lib/pq"
+
 	"chain/core/pin"
 	"chain/database/pg"
 	"chain/errors"
-	"chain/protocol"
 	"chain/protocol/bc"
-	"chain/protocol/state"
-	"chain/sync/idempotency"
 )
 
 var (
@@ -31,8 +29,17 @@ var (
 	// new change outputs will be created
 	// in sufficient amounts to satisfy the request.
 	ErrReserved = errors.New("reservation found outputs already reserved")
+
+	// errReservationConflict indicates that another reservation
+	// took some of the chosen UTXOs first, or that some of them
+	// have been spent since they were cached.
+	errReservationConflict = errors.New("outputs reserved concurrently")
 )
 
+// maxReserveAttempts is the number of times a reservation
+// is retried after conflicting with another reservation.
+const maxReserveAttempts = 3
+
 // utxo describes an individual account utxo.
 type utxo struct {
 	bc.Outpoint
@@ -41,6 +48,7 @@ type utxo struct {
 
 	AccountID           string
 	ControlProgramIndex uint64
+	ConfirmedIn         uint64
 }
 
 func (u *utxo) source() source {
@@ -64,137 +72,143 @@ type reservation struct {
 	ClientToken *string
 }
 
-func newReserver(db pg.DB, c *protocol.Chain, pinStore *pin.Store) *reserver {
+func newReserver(db pg.DB, pinStore *pin.Store) *reserver {
 	return &reserver{
-		c:            c,
-		db:           db,
-		pinStore:     pinStore,
-		reservations: make(map[uint64]*reservation),
-		sources:      make(map[source]*sourceReserver),
+		db:       db,
+		pinStore: pinStore,
+		sources:  make(map[source]*sourceReserver),
 	}
 }
 
-// reserver implements a utxo reserver that stores reservations
-// in-memory. It relies on the account_utxos table for the source of
-// truth of valid UTXOs but tracks which of those UTXOs are reserved
-// in-memory.
+// reserver implements a utxo reserver that stores reservations in
+// the database, so that every cored process in a Core shares them
+// and they survive a change of leader. It relies on the account_utxos
+// table for the source of truth of valid UTXOs, and caches them
+// in-memory for selection. Because the account_utxos table is
+// shared, a process need not be the leader, nor have the state
+// tree, to reserve UTXOs: insert reserves only UTXOs that are
+// still in account_utxos, and the cache drops the others.
+//
+// Each reservation is a lease on its UTXOs. Once it expires, its
+// UTXOs may be reserved again by any process, and the leader's
+// ExpireReservations eventually deletes it.
 //
 // To reduce latency and prevent deadlock, no two mutexes (either on
-// reserver or sourceReserver) should be held at the same time
+// reserver or sourceReserver) should be held at the same time.
 //
 // reserver ensures idempotency of reservations until the reservation
 // expiration.
 type reserver struct {
-	c                 *protocol.Chain
-	db                pg.DB
-	pinStore          *pin.Store
-	nextReservationID uint64
-	idempotency       idempotency.Group
-
-	reservationsMu sync.Mutex
-	reservations   map[uint64]*reservation
+	db       pg.DB
+	pinStore *pin.Store
 
 	sourcesMu sync.Mutex
 	sources   map[source]*sourceReserver
 }
 
 // Reserve selects and reserves UTXOs according to the critera provided
-// in source. The resulting reservation expires at exp.
-func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (*reservation, error) {
-	if clientToken == nil {
-		return re.reserve(ctx, src, amount, clientToken, exp)
-	}
-
-	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
-		return re.reserve(ctx, src, amount, clientToken, exp)
-	})
-	return untypedRes.(*reservation), err
-}
-
-func (re *reserver) reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (res *reservation, err error) {
-	sourceReserver := re.source(src)
-
-	// Try to reserve the right amount.
-	rid := atomic.AddUint64(&re.nextReservationID, 1)
-	reserved, total, err := sourceReserver.reserve(ctx, rid, amount)
-	if err != nil {
-		return nil, err
-	}
-
-	res = &reservation{
-		ID:          rid,
-		Source:      src,
-		UTXOs:       reserved,
-		Expiry:      exp,
-		ClientToken: clientToken,
+// in source, choosing among them with strategy. The resulting
+// reservation expires at exp.
+func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, strategy SelectionStrategy, clientToken *string, exp time.Time) (*reservation, error) {
+	if clientToken != nil {
+		res, err := re.findByClientToken(ctx, *clientToken)
+		if err != nil || res != nil {
+			return res, err
+		}
 	}
 
-	// Save the successful reservation.
-	re.reservationsMu.Lock()
-	defer re.reservationsMu.Unlock()
-	re.reservations[rid] = res
+	sr := re.source(src)
+	for attempt := 0; ; attempt++ {
+		reserved, err := re.reservedOutpoints(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		utxos, total, err := sr.choose(reserved, amount, strategy)
+		if err == ErrInsufficient || err == ErrReserved {
+			// Find any UTXOs that arrived since we last looked.
+			err = sr.refillCache(ctx)
+			if err != nil {
+				return nil, err
+			}
+			utxos, total, err = sr.choose(reserved, amount, strategy)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	// Make change if necessary
-	if total > amount {
-		res.Change = total - amount
+		res := &reservation{
+			Source:      src,
+			UTXOs:       utxos,
+			Expiry:      exp,
+			ClientToken: clientToken,
+		}
+		if total > amount {
+			res.Change = total - amount
+		}
+		err = re.insert(ctx, res)
+		if err == errReservationConflict && attempt < maxReserveAttempts {
+			// Another process reserved some of the same UTXOs
+			// since we looked, or they were spent. Choose again.
+			err = sr.evictSpent(ctx)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err == errReservationConflict {
+			return nil, ErrReserved
+		}
+		return res, err
 	}
-	return res, nil
 }
 
 // ReserveUTXO reserves a specific utxo for spending. The resulting
 // reservation expires at exp.
 func (re *reserver) ReserveUTXO(ctx context.Context, out bc.Outpoint, clientToken *string, exp time.Time) (*reservation, error) {
-	if clientToken == nil {
-		return re.reserveUTXO(ctx, out, exp, nil)
+	if clientToken != nil {
+		res, err := re.findByClientToken(ctx, *clientToken)
+		if err != nil || res != nil {
+			return res, err
+		}
 	}
 
-	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
-		return re.reserveUTXO(ctx, out, exp, clientToken)
-	})
-	return untypedRes.(*reservation), err
-}
-
-func (re *reserver) reserveUTXO(ctx context.Context, out bc.Outpoint, exp time.Time, clientToken *string) (*reservation, error) {
 	u, err := findSpecificUTXO(ctx, re.db, out)
 	if err != nil {
 		return nil, err
 	}
-	if !re.checkUTXO(u) {
-		return nil, pg.ErrUserInputNotFound
-	}
-
-	rid := atomic.AddUint64(&re.nextReservationID, 1)
-	err = re.source(u.source()).reserveUTXO(rid, u)
-	if err != nil {
-		return nil, err
-	}
 
 	res := &reservation{
-		ID:          rid,
 		Source:      u.source(),
 		UTXOs:       []*utxo{u},
 		Expiry:      exp,
 		ClientToken: clientToken,
 	}
-	re.reservationsMu.Lock()
-	re.reservations[rid] = res
-	re.reservationsMu.Unlock()
-	return res, nil
+	err = re.insert(ctx, res)
+	if err == errReservationConflict {
+		return nil, ErrReserved
+	}
+	return res, err
 }
 
 // Cancel makes a best-effort attempt at canceling the reservation with
 // the provided ID.
 func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
-	re.reservationsMu.Lock()
-	res, ok := re.reservations[rid]
-	delete(re.reservations, rid)
-	re.reservationsMu.Unlock()
-	if !ok {
-		return fmt.Errorf("couldn't find reservation %d", rid)
-	}
-	re.source(res.Source).cancel(res)
-	if res.ClientToken != nil {
-		re.idempotency.Forget(*res.ClientToken)
+	const q = `
+		WITH res AS (
+			DELETE FROM reservations WHERE reservation_id = $1
+			RETURNING reservation_id
+		), utxos AS (
+			DELETE FROM reserved_utxos WHERE reservation_id = $1
+		)
+		SELECT count(*) FROM res
+	`
+	var n int
+	err := re.db.QueryRow(ctx, q, rid).Scan(&n)
+	if err != nil {
+		return errors.Wrap(err, "canceling reservation")
+	}
+	if n == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "reservation %d", rid)
 	}
 	return nil
 }
@@ -202,36 +216,164 @@ func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
 // ExpireReservations cleans up all reservations that have expired,
 // making their UTXOs available for reservation again.
 func (re *reserver) ExpireReservations(ctx context.Context) error {
-	// Remove records of any reservations that have expired.
-	now := time.Now()
-	var canceled []*reservation
-	re.reservationsMu.Lock()
-	for rid, res := range re.reservations {
-		if res.Expiry.Before(now) {
-			canceled = append(canceled, res)
-			delete(re.reservations, rid)
+	const q = `
+		WITH expired AS (
+			DELETE FROM reservations WHERE expiry < now()
+		)
+		DELETE FROM reserved_utxos WHERE expiry < now()
+	`
+	_, err := re.db.Exec(ctx, q)
+	return errors.Wrap(err, "expiring reservations")
+}
+
+// insert saves res, setting its ID, unless another unexpired
+// reservation holds any of its UTXOs, or any of them is no longer
+// in account_utxos, in which case it returns errReservationConflict.
+// UTXOs held by expired reservations are taken over. If res has a
+// client token that is already in use by an unexpired reservation,
+// insert sets *res to the existing reservation instead.
+func (re *reserver) insert(ctx context.Context, res *reservation) error {
+	if res.ClientToken != nil {
+		// Free the client token of an expired reservation.
+		const q = `
+			WITH res AS (
+				DELETE FROM reservations WHERE client_token = $1 AND expiry < now()
+				RETURNING reservation_id
+			)
+			DELETE FROM reserved_utxos WHERE reservation_id IN (SELECT reservation_id FROM res)
+		`
+		_, err := re.db.Exec(ctx, q, *res.ClientToken)
+		if err != nil {
+			return errors.Wrap(err, "deleting expired reservation")
 		}
 	}
-	re.reservationsMu.Unlock()
 
-	// If we removed any expired reservations, update the corresponding
-	// source reservers.
-	for _, res := range canceled {
-		re.source(res.Source).cancel(res)
-		if res.ClientToken != nil {
-			re.idempotency.Forget(*res.ClientToken)
-		}
+	var (
+		hashes  pq.StringArray
+		indexes pg.Uint32s
+		amount  uint64
+	)
+	for _, u := range res.UTXOs {
+		hashes = append(hashes, u.Hash.String())
+		indexes = append(indexes, u.Index)
+		amount += u.Amount
 	}
 
-	// TODO(jackson): Cleanup any source reservers that don't have
-	// anything reserved. It'll be a little tricky because of our
-	// locking scheme.
+	const q = `
+		WITH res AS (
+			INSERT INTO reservations (account_id, asset_id, amount, change, expiry, client_token)
+			VALUES ($1, $2, $8, $3, $4, $5)
+			ON CONFLICT (client_token) DO NOTHING
+			RETURNING reservation_id
+		), utxos AS (
+			INSERT INTO reserved_utxos (tx_hash, index, reservation_id, account_id, asset_id, expiry)
+			SELECT u.tx_hash, u.index, res.reservation_id, $1, $2, $4
+			FROM res, unnest($6::text[], $7::integer[]) AS u(tx_hash, index)
+			WHERE EXISTS (
+				SELECT 1 FROM account_utxos a
+				WHERE (a.tx_hash, a.index) = (u.tx_hash, u.index)
+			)
+			ON CONFLICT (tx_hash, index) DO UPDATE
+				SET reservation_id = EXCLUDED.reservation_id,
+					account_id = EXCLUDED.account_id,
+					asset_id = EXCLUDED.asset_id,
+					expiry = EXCLUDED.expiry
+				WHERE reserved_utxos.expiry < now()
+			RETURNING 1
+		)
+		SELECT reservation_id, (SELECT count(*) FROM utxos) FROM res
+	`
+	var (
+		rid      uint64
+		inserted int
+	)
+	err := re.db.QueryRow(ctx, q, res.Source.AccountID, res.Source.AssetID, res.Change, res.Expiry, res.ClientToken, hashes, indexes, amount).Scan(&rid, &inserted)
+	if err == sql.ErrNoRows && res.ClientToken != nil {
+		// Another request with the same client token won the race.
+		existing, err := re.findByClientToken(ctx, *res.ClientToken)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.Wrap(fmt.Errorf("couldn't find reservation with client token %q", *res.ClientToken))
+		}
+		*res = *existing
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "inserting reservation")
+	}
+	if inserted < len(res.UTXOs) {
+		err = re.Cancel(ctx, rid)
+		if err != nil {
+			return err
+		}
+		return errReservationConflict
+	}
+	res.ID = rid
 	return nil
 }
 
-func (re *reserver) checkUTXO(u *utxo) bool {
-	_, s := re.c.State()
-	return s.Tree.ContainsKey(state.OutputKey(u.Outpoint))
+// reservedOutpoints returns the outpoints of src's UTXOs
+// held by unexpired reservations.
+func (re *reserver) reservedOutpoints(ctx context.Context, src source) (map[bc.Outpoint]bool, error) {
+	const q = `
+		SELECT tx_hash, index FROM reserved_utxos
+		WHERE account_id = $1 AND asset_id = $2 AND expiry > now()
+	`
+	reserved := make(map[bc.Outpoint]bool)
+	err := pg.ForQueryRows(ctx, re.db, q, src.AccountID, src.AssetID, func(hash bc.Hash, index uint32) {
+		reserved[bc.Outpoint{Hash: hash, Index: index}] = true
+	})
+	return reserved, errors.Wrap(err, "finding reserved outputs")
+}
+
+// findByClientToken returns the unexpired reservation with
+// the given client token, or nil if there is none.
+func (re *reserver) findByClientToken(ctx context.Context, clientToken string) (*reservation, error) {
+	const q = `
+		SELECT reservation_id, account_id, asset_id, change, expiry
+		FROM reservations
+		WHERE client_token = $1 AND expiry > now()
+	`
+	res := &reservation{ClientToken: &clientToken}
+	err := re.db.QueryRow(ctx, q, clientToken).Scan(&res.ID, &res.Source.AccountID, &res.Source.AssetID, &res.Change, &res.Expiry)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "finding reservation")
+	}
+	res.UTXOs, err = reservedUTXOs(ctx, re.db, res.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// reservedUTXOs returns the UTXOs held by a reservation.
+func reservedUTXOs(ctx context.Context, db pg.DB, rid uint64) ([]*utxo, error) {
+	const q = `
+		SELECT u.tx_hash, u.index, u.asset_id, u.amount, u.account_id,
+			u.control_program_index, u.control_program, COALESCE(u.confirmed_in, 0)
+		FROM reserved_utxos r
+		JOIN account_utxos u ON (u.tx_hash, u.index) = (r.tx_hash, r.index)
+		WHERE r.reservation_id = $1
+		ORDER BY u.tx_hash, u.index
+	`
+	var utxos []*utxo
+	err := pg.ForQueryRows(ctx, db, q, rid,
+		func(hash bc.Hash, index uint32, assetID bc.AssetID, amount uint64, accountID string, cpIndex uint64, prog []byte, confirmedIn uint64) {
+			utxos = append(utxos, &utxo{
+				Outpoint:            bc.Outpoint{Hash: hash, Index: index},
+				AssetAmount:         bc.AssetAmount{AssetID: assetID, Amount: amount},
+				ControlProgram:      prog,
+				AccountID:           accountID,
+				ControlProgramIndex: cpIndex,
+				ConfirmedIn:         confirmedIn,
+			})
+		})
+	return utxos, errors.Wrap(err, "finding reserved utxos")
 }
 
 func (re *reserver) source(src source) *sourceReserver {
@@ -244,114 +386,92 @@ func (re *reserver) source(src source) *sourceReserver {
 	}
 
 	sr = &sourceReserver{
-		db:      re.db,
-		src:     src,
-		validFn: re.checkUTXO,
+		db:  re.db,
+		src: src,
 		heightFn: func() uint64 {
 			return re.pinStore.Height(PinName)
 		},
-		cached:   make(map[bc.Outpoint]*utxo),
-		reserved: make(map[bc.Outpoint]uint64),
+		cached: make(map[bc.Outpoint]*utxo),
 	}
 	re.sources[src] = sr
 	return sr
 }
 
+// sourceReserver caches the UTXOs matching a source
+// and chooses among them for reservations.
 type sourceReserver struct {
 	db       pg.DB
 	src      source
-	validFn  func(u *utxo) bool
 	heightFn func() uint64
 
 	mu         sync.Mutex
 	cached     map[bc.Outpoint]*utxo
-	reserved   map[bc.Outpoint]uint64
 	lastHeight uint64
 }
 
-func (sr *sourceReserver) reserve(ctx context.Context, rid uint64, amount uint64) ([]*utxo, uint64, error) {
-	reservedUTXOs, reservedAmount, err := sr.reserveFromCache(rid, amount)
-	if err == nil {
-		return reservedUTXOs, reservedAmount, nil
-	}
-
-	// Find the set of UTXOs that match this source.
-	err = sr.refillCache(ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	return sr.reserveFromCache(rid, amount)
-}
-
-func (sr *sourceReserver) reserveFromCache(rid uint64, amount uint64) ([]*utxo, uint64, error) {
-	var (
-		reserved, unavailable uint64
-		reservedUTXOs         []*utxo
-	)
+// choose selects cached UTXOs that are not in reserved, totaling at
+// least amount, using strategy. It returns the UTXOs and their total.
+func (sr *sourceReserver) choose(reserved map[bc.Outpoint]bool, amount uint64, strategy SelectionStrategy) ([]*utxo, uint64, error) {
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
-	for o, u := range sr.cached {
-		// If the UTXO is already reserved, skip it.
-		if _, ok := sr.reserved[u.Outpoint]; ok {
-			unavailable += u.Amount
-			continue
-		}
-		// Cached utxos aren't guaranteed to still be valid; they may
-		// have been spent. Verify that that the outputs are still in
-		// the state tree.
-		if !sr.validFn(u) {
-			delete(sr.cached, o)
-			continue
-		}
-
-		reserved += u.Amount
-		reservedUTXOs = append(reservedUTXOs, u)
-		if reserved >= amount {
-			break
-		}
-	}
-	if reserved+unavailable < amount {
+	candidates, available, unavailable := sr.candidates(reserved)
+	if available+unavailable < amount {
 		// Even if everything was available, this account wouldn't have
 		// enough to satisfy the request.
 		return nil, 0, ErrInsufficient
 	}
-	if reserved < amount {
+	if available < amount {
 		// The account has enough for the request, but some is tied up in
 		// other reservations.
 		return nil, 0, ErrReserved
 	}
 
-	// We've found enough to satisfy the request.
-	for _, u := range reservedUTXOs {
-		sr.reserved[u.Outpoint] = rid
+	var (
+		total  uint64
+		chosen []*utxo
+		seen   = make(map[bc.Outpoint]bool)
+	)
+	for _, c := range strategy.Select(candidates, amount) {
+		u, ok := sr.cached[c.Outpoint]
+		if !ok || reserved[c.Outpoint] {
+			return nil, 0, errors.Wrap(fmt.Errorf("selection strategy chose unavailable output %v", c.Outpoint))
+		}
+		if seen[c.Outpoint] {
+			return nil, 0, errors.Wrap(fmt.Errorf("selection strategy chose output %v twice", c.Outpoint))
+		}
+		seen[c.Outpoint] = true
+		total += u.Amount
+		chosen = append(chosen, u)
 	}
-
-	return reservedUTXOs, reserved, nil
-}
-
-func (sr *sourceReserver) reserveUTXO(rid uint64, utxo *utxo) error {
-	sr.mu.Lock()
-	defer sr.mu.Unlock()
-
-	_, isReserved := sr.reserved[utxo.Outpoint]
-	if isReserved {
-		return ErrReserved
+	if total < amount {
+		return nil, 0, errors.Wrap(fmt.Errorf("selection strategy chose %d of %d units", total, amount))
 	}
-
-	sr.reserved[utxo.Outpoint] = rid
-	return nil
+	return chosen, total, nil
 }
 
-func (sr *sourceReserver) cancel(res *reservation) {
-	sr.mu.Lock()
-	defer sr.mu.Unlock()
-	for _, utxo := range res.UTXOs {
-		delete(sr.reserved, utxo.Outpoint)
+// candidates returns the cached UTXOs that are not in reserved,
+// with their total amount, and the total amount of those that are.
+// The caller must hold sr.mu.
+func (sr *sourceReserver) candidates(reserved map[bc.Outpoint]bool) (candidates []Candidate, available, unavailable uint64) {
+	for o, u := range sr.cached {
+		// If the UTXO is already reserved, skip it.
+		if reserved[o] {
+			unavailable += u.Amount
+			continue
+		}
+		available += u.Amount
+		candidates = append(candidates, Candidate{
+			Outpoint:    u.Outpoint,
+			Amount:      u.Amount,
+			ConfirmedIn: u.ConfirmedIn,
+		})
 	}
+	return candidates, available, unavailable
 }
 
+// refillCache adds the UTXOs that arrived since the cache was
+// last filled, and removes those that have been spent since.
 func (sr *sourceReserver) refillCache(ctx context.Context) error {
 	sr.mu.Lock()
 	lastHeight := sr.lastHeight
@@ -362,6 +482,11 @@ func (sr *sourceReserver) refillCache(ctx context.Context) error {
 		return nil
 	}
 
+	err := sr.evictSpent(ctx)
+	if err != nil {
+		return err
+	}
+
 	utxos, err := findMatchingUTXOs(ctx, sr.db, sr.src, lastHeight)
 	if err != nil {
 		return errors.Wrap(err)
@@ -379,15 +504,56 @@ func (sr *sourceReserver) refillCache(ctx context.Context) error {
 	return nil
 }
 
+// evictSpent removes the cached UTXOs that are
+// no longer in account_utxos.
+func (sr *sourceReserver) evictSpent(ctx context.Context) error {
+	var (
+		hashes  pq.StringArray
+		indexes pg.Uint32s
+	)
+	sr.mu.Lock()
+	for o := range sr.cached {
+		hashes = append(hashes, o.Hash.String())
+		indexes = append(indexes, o.Index)
+	}
+	sr.mu.Unlock()
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	const q = `
+		SELECT u.tx_hash, u.index
+		FROM unnest($1::text[], $2::integer[]) AS u(tx_hash, index)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM account_utxos a
+			WHERE (a.tx_hash, a.index) = (u.tx_hash, u.index)
+		)
+	`
+	var spent []bc.Outpoint
+	err := pg.ForQueryRows(ctx, sr.db, q, hashes, indexes, func(hash bc.Hash, index uint32) {
+		spent = append(spent, bc.Outpoint{Hash: hash, Index: index})
+	})
+	if err != nil {
+		return errors.Wrap(err, "finding spent utxos")
+	}
+
+	sr.mu.Lock()
+	for _, o := range spent {
+		delete(sr.cached, o)
+	}
+	sr.mu.Unlock()
+	return nil
+}
+
 func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64) ([]*utxo, error) {
 	const q = `
-		SELECT tx_hash, index, amount, control_program_index, control_program
+		SELECT tx_hash, index, amount, control_program_index, control_program, confirmed_in
 		FROM account_utxos
 		WHERE account_id = $1 AND asset_id = $2 AND confirmed_in > $3
 	`
 	var utxos []*utxo
 	err := pg.ForQueryRows(ctx, db, q, src.AccountID, src.AssetID, height,
-		func(txHash bc.Hash, index uint32, amount uint64, cpIndex uint64, controlProg []byte) {
+		func(txHash bc.Hash, index uint32, amount uint64, cpIndex uint64, controlProg []byte, confirmedIn uint64) {
 			utxos = append(utxos, &utxo{
 				Outpoint: bc.Outpoint{
 					Hash:  txHash,
@@ -400,6 +566,7 @@ func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64)
 				ControlProgram:      controlProg,
 				AccountID:           src.AccountID,
 				ControlProgramIndex: cpIndex,
+				ConfirmedIn:         confirmedIn,
 			})
 		})
 	if err != nil {
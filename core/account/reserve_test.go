@@ -8,7 +8,6 @@ import (
 	"chain/database/pg/pgtest"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
-	"chain/protocol/state"
 )
 
 const sampleAccountUTXOs = `
@@ -23,7 +22,6 @@ const sampleAccountUTXOs = `
 
 func TestCancelReservation(t *testing.T) {
 	ctx := context.Background()
-	c := prottest.NewChain(t)
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	_, err := db.Exec(ctx, sampleAccountUTXOs)
@@ -43,21 +41,14 @@ func TestCancelReservation(t *testing.T) {
 	}
 	out := bc.Outpoint{Hash: h, Index: 0}
 
-	// Fake the output in the state tree.
-	_, s := c.State()
-	err = s.Tree.Insert(state.OutputKey(out), []byte{0xc0, 0x01, 0xca, 0xfe})
-	if err != nil {
-		t.Error(err)
-	}
-
-	utxoDB := newReserver(db, c, nil)
-	res, err := utxoDB.ReserveUTXO(ctx, out, nil, time.Now())
+	utxoDB := newReserver(db, nil)
+	res, err := utxoDB.ReserveUTXO(ctx, out, nil, time.Now().Add(time.Minute))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Verify that the UTXO is reserved.
-	_, err = utxoDB.ReserveUTXO(ctx, out, nil, time.Now())
+	_, err = utxoDB.ReserveUTXO(ctx, out, nil, time.Now().Add(time.Minute))
 	if err != ErrReserved {
 		t.Fatalf("got=%s want=%s", err, ErrReserved)
 	}
@@ -74,3 +65,154 @@ func TestCancelReservation(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestListReservations(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+
+	_, err := db.Exec(ctx, sampleAccountUTXOs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h bc.Hash
+	err = h.UnmarshalText([]byte("270b725a94429496a178c56b390a89d03f801fe2ee992d90cf4fdf7d7855318e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bc.Outpoint{Hash: h, Index: 0}
+
+	m := NewManager(db, c, nil)
+	token := "a-client-token"
+	res, err := m.utxoDB.ReserveUTXO(ctx, out, &token, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, after, err := m.ListReservations(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d reservations, want 1", len(got))
+	}
+	r := got[0]
+	if r.ID != res.ID || r.AccountID != "accEXAMPLE" || r.Amount != 1000 || r.ClientToken == nil || *r.ClientToken != token {
+		t.Errorf("got reservation %+v", r)
+	}
+	if len(r.Outputs) != 1 || r.Outputs[0] != out {
+		t.Errorf("got outputs %v, want [%v]", r.Outputs, out)
+	}
+	if after != res.ID {
+		t.Errorf("got after %d, want %d", after, res.ID)
+	}
+
+	err = m.CancelReservation(ctx, res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = m.ListReservations(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d reservations after cancel, want 0", len(got))
+	}
+
+	// Expired reservations aren't listed.
+	_, err = m.utxoDB.ReserveUTXO(ctx, out, nil, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = m.ListReservations(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d expired reservations, want 0", len(got))
+	}
+}
+
+func TestReserveSpentUTXO(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+
+	_, err := db.Exec(ctx, sampleAccountUTXOs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var src source
+	src.AccountID = "accEXAMPLE"
+	err = src.AssetID.UnmarshalText([]byte("df1df9d4f66437ab5be715e4d1faeb29d24c80a6dc8276d6a630f05c5f1f7693"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cache the UTXO, then spend it, as another process's
+	// indexer would.
+	re := newReserver(db, nil)
+	sr := re.source(src)
+	sr.heightFn = func() uint64 { return 1 }
+	err = sr.refillCache(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sr.cached) != 1 {
+		t.Fatalf("got %d cached utxos, want 1", len(sr.cached))
+	}
+	_, err = db.Exec(ctx, `DELETE FROM account_utxos`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = re.Reserve(ctx, src, 1000, SmallestFirst, nil, time.Now().Add(time.Minute))
+	if err != ErrInsufficient {
+		t.Errorf("reserving spent utxo: got error %v, want %v", err, ErrInsufficient)
+	}
+	if len(sr.cached) != 0 {
+		t.Errorf("got %d cached utxos after spend, want 0", len(sr.cached))
+	}
+}
+
+func TestReserveExpiredUTXO(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+
+	_, err := db.Exec(ctx, sampleAccountUTXOs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h bc.Hash
+	err = h.UnmarshalText([]byte("270b725a94429496a178c56b390a89d03f801fe2ee992d90cf4fdf7d7855318e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bc.Outpoint{Hash: h, Index: 0}
+
+	re := newReserver(db, nil)
+	token := "a-client-token"
+	expired, err := re.ReserveUTXO(ctx, out, &token, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired reservation no longer holds the UTXO or the
+	// client token, even though it hasn't been deleted yet.
+	res, err := re.ReserveUTXO(ctx, out, &token, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID == expired.ID {
+		t.Errorf("got expired reservation %d, want a new one", res.ID)
+	}
+	if len(res.UTXOs) != 1 || res.UTXOs[0].Outpoint != out {
+		t.Errorf("got utxos %v, want [%v]", res.UTXOs, out)
+	}
+
+	// The new reservation holds the UTXO.
+	_, err = re.ReserveUTXO(ctx, out, nil, time.Now().Add(time.Minute))
+	if err != ErrReserved {
+		t.Errorf("got error %v, want %v", err, ErrReserved)
+	}
+}
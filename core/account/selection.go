@@ -0,0 +1,212 @@
+package account
+
+import (
+	"bytes"
+	"sort"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrBadSelectionStrategy is returned when a spend
+// action names an unknown selection strategy.
+var ErrBadSelectionStrategy = errors.New("unknown selection strategy")
+
+// maxBranchAndBoundTries limits the number of subsets
+// BranchAndBound examines before falling back.
+const maxBranchAndBoundTries = 100000
+
+// Candidate is an unreserved output that
+// a SelectionStrategy may select.
+type Candidate struct {
+	bc.Outpoint
+	Amount uint64
+
+	// ConfirmedIn is the height of the block
+	// that included the output.
+	ConfirmedIn uint64
+}
+
+// A SelectionStrategy chooses which of an account's outputs
+// to spend in order to satisfy a request for some amount of
+// an asset.
+type SelectionStrategy interface {
+	// Select returns a subset of available whose amounts total
+	// at least amount. Select is only called when the amounts
+	// of all of available total at least amount. The order of
+	// available is unspecified, and Select may reorder it.
+	Select(available []Candidate, amount uint64) []Candidate
+}
+
+// SelectionStrategyFunc adapts an ordinary
+// function to a SelectionStrategy.
+type SelectionStrategyFunc func(available []Candidate, amount uint64) []Candidate
+
+// Select calls f(available, amount).
+func (f SelectionStrategyFunc) Select(available []Candidate, amount uint64) []Candidate {
+	return f(available, amount)
+}
+
+var (
+	// AnyFirst selects outputs in whatever order they are
+	// found until it has enough. It is the default strategy.
+	AnyFirst SelectionStrategy = SelectionStrategyFunc(anyFirst)
+
+	// LargestFirst selects the largest outputs first,
+	// minimizing the number of inputs.
+	LargestFirst SelectionStrategy = SelectionStrategyFunc(largestFirst)
+
+	// SmallestFirst selects the smallest outputs first,
+	// consolidating small outputs into change.
+	SmallestFirst SelectionStrategy = SelectionStrategyFunc(smallestFirst)
+
+	// OldestFirst selects the outputs confirmed
+	// earliest on the blockchain first.
+	OldestFirst SelectionStrategy = SelectionStrategyFunc(oldestFirst)
+
+	// BranchAndBound searches for a set of outputs totaling
+	// exactly the requested amount, so that no change output is
+	// needed. If it finds none, it selects like LargestFirst.
+	BranchAndBound SelectionStrategy = SelectionStrategyFunc(branchAndBound)
+)
+
+var selectionStrategies = map[string]SelectionStrategy{
+	"":                 AnyFirst,
+	"any":              AnyFirst,
+	"largest_first":    LargestFirst,
+	"smallest_first":   SmallestFirst,
+	"oldest_first":     OldestFirst,
+	"exact_match":      BranchAndBound,
+	"branch_and_bound": BranchAndBound,
+}
+
+// RegisterSelectionStrategy makes a selection strategy available to
+// spend actions under the given name. It is not safe to call
+// concurrently with building transactions; call it during
+// initialization.
+func RegisterSelectionStrategy(name string, s SelectionStrategy) {
+	selectionStrategies[name] = s
+}
+
+func selectionStrategy(name string) (SelectionStrategy, error) {
+	s, ok := selectionStrategies[name]
+	if !ok {
+		return nil, errors.WithDetailf(ErrBadSelectionStrategy, "unknown selection_strategy %q", name)
+	}
+	return s, nil
+}
+
+func anyFirst(available []Candidate, amount uint64) []Candidate {
+	return takeUntil(available, amount)
+}
+
+func largestFirst(available []Candidate, amount uint64) []Candidate {
+	sort.Sort(byAmountDesc(available))
+	return takeUntil(available, amount)
+}
+
+func smallestFirst(available []Candidate, amount uint64) []Candidate {
+	sort.Sort(byAmountAsc(available))
+	return takeUntil(available, amount)
+}
+
+func oldestFirst(available []Candidate, amount uint64) []Candidate {
+	sort.Sort(byAge(available))
+	return takeUntil(available, amount)
+}
+
+func branchAndBound(available []Candidate, amount uint64) []Candidate {
+	sort.Sort(byAmountDesc(available))
+
+	// remaining[i] is the total amount of available[i:].
+	remaining := make([]uint64, len(available)+1)
+	for i := len(available) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + available[i].Amount
+	}
+
+	var (
+		selected []int
+		tries    int
+	)
+	var search func(i int, total uint64) bool
+	search = func(i int, total uint64) bool {
+		if total == amount {
+			return true
+		}
+		tries++
+		if i == len(available) || total > amount || total+remaining[i] < amount || tries > maxBranchAndBoundTries {
+			return false
+		}
+		// Try including available[i], then excluding it.
+		selected = append(selected, i)
+		if search(i+1, total+available[i].Amount) {
+			return true
+		}
+		selected = selected[:len(selected)-1]
+		return search(i+1, total)
+	}
+	if !search(0, 0) {
+		return takeUntil(available, amount)
+	}
+
+	result := make([]Candidate, 0, len(selected))
+	for _, i := range selected {
+		result = append(result, available[i])
+	}
+	return result
+}
+
+// takeUntil returns the shortest prefix
+// of cs whose amounts total at least amount.
+func takeUntil(cs []Candidate, amount uint64) []Candidate {
+	var total uint64
+	for i, c := range cs {
+		total += c.Amount
+		if total >= amount {
+			return cs[:i+1]
+		}
+	}
+	return cs
+}
+
+// byAmountDesc sorts by amount, largest first,
+// then by age, so that selection is deterministic.
+type byAmountDesc []Candidate
+
+func (a byAmountDesc) Len() int      { return len(a) }
+func (a byAmountDesc) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAmountDesc) Less(i, j int) bool {
+	if a[i].Amount != a[j].Amount {
+		return a[i].Amount > a[j].Amount
+	}
+	return byAge(a).Less(i, j)
+}
+
+// byAmountAsc sorts by amount, smallest first,
+// then by age, so that selection is deterministic.
+type byAmountAsc []Candidate
+
+func (a byAmountAsc) Len() int      { return len(a) }
+func (a byAmountAsc) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAmountAsc) Less(i, j int) bool {
+	if a[i].Amount != a[j].Amount {
+		return a[i].Amount < a[j].Amount
+	}
+	return byAge(a).Less(i, j)
+}
+
+// byAge sorts by confirmation height, oldest first,
+// then by outpoint, so that selection is deterministic.
+type byAge []Candidate
+
+func (a byAge) Len() int      { return len(a) }
+func (a byAge) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAge) Less(i, j int) bool {
+	if a[i].ConfirmedIn != a[j].ConfirmedIn {
+		return a[i].ConfirmedIn < a[j].ConfirmedIn
+	}
+	if c := bytes.Compare(a[i].Hash[:], a[j].Hash[:]); c != 0 {
+		return c < 0
+	}
+	return a[i].Index < a[j].Index
+}
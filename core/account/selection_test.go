@@ -0,0 +1,107 @@
+package account
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// testUTXOs returns utxos with the given amounts. The
+// first is the oldest and the last is the newest.
+func testUTXOs(amounts ...uint64) []*utxo {
+	var utxos []*utxo
+	for i, amt := range amounts {
+		utxos = append(utxos, &utxo{
+			Outpoint:    bc.Outpoint{Hash: bc.Hash{byte(i)}, Index: uint32(i)},
+			AssetAmount: bc.AssetAmount{Amount: amt},
+			ConfirmedIn: uint64(i + 1),
+		})
+	}
+	return utxos
+}
+
+func TestSelectionStrategies(t *testing.T) {
+	cases := []struct {
+		strategy    string
+		amounts     []uint64
+		amount      uint64
+		wantAmounts []uint64
+		wantChange  uint64
+	}{
+		{"largest_first", []uint64{1, 5, 10, 3}, 12, []uint64{10, 5}, 3},
+		{"largest_first", []uint64{1, 5, 10, 3}, 10, []uint64{10}, 0},
+		{"smallest_first", []uint64{1, 5, 10, 3}, 6, []uint64{1, 3, 5}, 3},
+		{"smallest_first", []uint64{2, 2, 2, 20}, 5, []uint64{2, 2, 2}, 1},
+		{"oldest_first", []uint64{4, 5, 10, 3}, 8, []uint64{4, 5}, 1},
+		{"oldest_first", []uint64{4, 5, 10, 3}, 4, []uint64{4}, 0},
+		{"exact_match", []uint64{8, 5, 4, 3}, 7, []uint64{4, 3}, 0},
+		{"exact_match", []uint64{8, 5, 4, 3}, 12, []uint64{8, 4}, 0},
+		{"branch_and_bound", []uint64{10, 6, 5}, 11, []uint64{6, 5}, 0},
+		// No exact match; falls back to largest first.
+		{"exact_match", []uint64{10, 6, 5}, 14, []uint64{10, 6}, 2},
+	}
+
+	for _, c := range cases {
+		strategy, err := selectionStrategy(c.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sr := &sourceReserver{
+			cached: make(map[bc.Outpoint]*utxo),
+		}
+		for _, u := range testUTXOs(c.amounts...) {
+			sr.cached[u.Outpoint] = u
+		}
+
+		got, total, err := sr.choose(nil, c.amount, strategy)
+		if err != nil {
+			t.Errorf("%s(%v, %d): unexpected error %s", c.strategy, c.amounts, c.amount, err)
+			continue
+		}
+		var gotAmounts []uint64
+		for _, u := range got {
+			gotAmounts = append(gotAmounts, u.Amount)
+		}
+		if !reflect.DeepEqual(gotAmounts, c.wantAmounts) {
+			t.Errorf("%s(%v, %d) selected %v, want %v", c.strategy, c.amounts, c.amount, gotAmounts, c.wantAmounts)
+		}
+		if change := total - c.amount; change != c.wantChange {
+			t.Errorf("%s(%v, %d) change = %d, want %d", c.strategy, c.amounts, c.amount, change, c.wantChange)
+		}
+	}
+}
+
+func TestSelectionStrategyReserved(t *testing.T) {
+	utxos := testUTXOs(10, 5)
+	sr := &sourceReserver{
+		cached: map[bc.Outpoint]*utxo{utxos[0].Outpoint: utxos[0], utxos[1].Outpoint: utxos[1]},
+	}
+	reserved := map[bc.Outpoint]bool{utxos[0].Outpoint: true}
+
+	_, _, err := sr.choose(reserved, 8, LargestFirst)
+	if err != ErrReserved {
+		t.Errorf("got error %v, want %v", err, ErrReserved)
+	}
+	_, _, err = sr.choose(reserved, 16, LargestFirst)
+	if err != ErrInsufficient {
+		t.Errorf("got error %v, want %v", err, ErrInsufficient)
+	}
+
+	got, _, err := sr.choose(reserved, 5, LargestFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != utxos[1] {
+		t.Errorf("got %v, want only the unreserved output", got)
+	}
+}
+
+func TestUnknownSelectionStrategy(t *testing.T) {
+	_, err := selectionStrategy("most_expensive_first")
+	if errors.Root(err) != ErrBadSelectionStrategy {
+		t.Errorf("got error %v, want %v", err, ErrBadSelectionStrategy)
+	}
+}
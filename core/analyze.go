@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/vm"
+	"chain/protocol/vm/analysis"
+)
+
+type programTemplate struct {
+	Kind       string               `json:"kind"`
+	PublicKeys []chainjson.HexBytes `json:"public_keys"`
+	Quorum     int                  `json:"quorum"`
+	Definition chainjson.HexBytes   `json:"definition,omitempty"`
+}
+
+type programAnalysis struct {
+	Ops               []string         `json:"ops"`
+	DisallowedOps     []string         `json:"disallowed_ops"`
+	MaxStackDepth     int              `json:"max_stack_depth"`
+	StackUnbounded    bool             `json:"stack_unbounded"`
+	MaxCost           int64            `json:"max_cost"`
+	DataDependentCost bool             `json:"data_dependent_cost"`
+	CostUnbounded     bool             `json:"cost_unbounded"`
+	CanSucceed        bool             `json:"can_succeed"`
+	Template          *programTemplate `json:"template"`
+}
+
+// POST /analyze-program
+//
+// analyzeProgram examines a control program or issuance
+// program without running it, so that it can be vetted
+// before value is locked with it.
+// See package chain/protocol/vm/analysis.
+func (h *Handler) analyzeProgram(ctx context.Context, in struct {
+	Program chainjson.HexBytes `json:"program"`
+}) (*programAnalysis, error) {
+	a, err := analysis.Analyze(in.Program)
+	if err != nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, err.Error())
+	}
+	resp := &programAnalysis{
+		Ops:               opNames(a.Ops),
+		DisallowedOps:     opNames(a.Disallowed),
+		MaxStackDepth:     a.MaxStackDepth,
+		StackUnbounded:    a.StackUnbounded,
+		MaxCost:           a.MaxCost,
+		DataDependentCost: a.DataDependentCost,
+		CostUnbounded:     a.CostUnbounded,
+		CanSucceed:        a.CanSucceed,
+	}
+	if t := a.Template; t != nil {
+		resp.Template = &programTemplate{
+			Kind:       t.Kind,
+			Quorum:     t.Quorum,
+			Definition: t.Definition,
+		}
+		for _, k := range t.PublicKeys {
+			resp.Template.PublicKeys = append(resp.Template.PublicKeys, chainjson.HexBytes(k))
+		}
+	}
+	return resp, nil
+}
+
+func opNames(ops []vm.Op) []string {
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		names = append(names, op.String())
+	}
+	return names
+}
@@ -14,11 +14,13 @@ import (
 	"chain/core/account"
 	"chain/core/asset"
 	"chain/core/config"
+	"chain/core/hsm"
 	"chain/core/leader"
-	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/rpc"
+	"chain/core/signerd"
+	"chain/core/signpolicy"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
@@ -56,7 +58,8 @@ type Handler struct {
 	PinStore      *pin.Store
 	Assets        *asset.Registry
 	Accounts      *account.Manager
-	HSM           *mockhsm.HSM
+	HSM           hsm.HSM
+	RemoteSigners []*signerd.Client
 	Indexer       *query.Indexer
 	TxFeeds       *txfeed.Tracker
 	AccessTokens  *accesstoken.CredentialStore
@@ -67,6 +70,10 @@ type Handler struct {
 	Signer        func(context.Context, *bc.Block) ([]byte, error)
 	RequestLimits []RequestLimit
 
+	// SigningPolicies, if set, are checked before
+	// the Core signs transaction templates.
+	SigningPolicies *signpolicy.Store
+
 	once           sync.Once
 	handler        http.Handler
 	actionDecoders map[string]func(data []byte) (txbuilder.Action, error)
@@ -119,28 +126,57 @@ func (h *Handler) init() {
 	m.Handle("/create-asset", needConfig(h.createAsset))
 	m.Handle("/build-transaction", needConfig(h.build))
 	m.Handle("/submit-transaction", needConfig(h.submit))
+	m.Handle("/simulate-transaction", needConfig(h.simulateTxs))
 	m.Handle("/create-control-program", needConfig(h.createControlProgram))
+	m.Handle("/analyze-program", needConfig(h.analyzeProgram))
 	m.Handle("/create-transaction-feed", needConfig(h.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(h.getTxFeed))
 	m.Handle("/update-transaction-feed", needConfig(h.updateTxFeed))
 	m.Handle("/delete-transaction-feed", needConfig(h.deleteTxFeed))
-	m.Handle("/mockhsm/create-key", needConfig(h.mockhsmCreateKey))
-	m.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
-	m.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
-	m.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
+	m.Handle("/acknowledge-transaction-feed", needConfig(h.ackTxFeed))
+	m.Handle("/stream-transaction-feed", http.HandlerFunc(h.streamTxFeed))
+	m.Handle("/consolidate-account", needConfig(h.consolidateAccount))
+	m.Handle("/list-transaction-feed-dead-letters", needConfig(h.listTxFeedDeadLetters))
+	m.Handle("/hsm/create-key", needConfig(h.hsmCreateKey))
+	m.Handle("/hsm/list-keys", needConfig(h.hsmListKeys))
+	m.Handle("/hsm/delete-key", needConfig(h.hsmDelKey))
+	m.Handle("/hsm/sign-transaction", needConfig(h.hsmSignTemplates))
+	m.Handle("/hsm/restore-key", needConfig(h.hsmRestoreKey))
+	m.Handle("/hsm/export-keys", needConfig(h.hsmExportKeys))
+	m.Handle("/hsm/import-keys", needConfig(h.hsmImportKeys))
+	m.Handle("/create-signing-policy", needConfig(h.createSigningPolicy))
+	m.Handle("/list-signing-policies", needConfig(h.listSigningPolicies))
+	m.Handle("/delete-signing-policy", needConfig(h.deleteSigningPolicy))
+
+	// The /mockhsm endpoints predate pluggable HSMs and
+	// are kept for existing clients.
+	m.Handle("/mockhsm/create-key", needConfig(h.hsmCreateKey))
+	m.Handle("/mockhsm/list-keys", needConfig(h.hsmListKeys))
+	m.Handle("/mockhsm/delkey", needConfig(h.hsmDelKey))
+	m.Handle("/mockhsm/sign-transaction", needConfig(h.hsmSignTemplates))
 	m.Handle("/list-accounts", needConfig(h.listAccounts))
 	m.Handle("/list-assets", needConfig(h.listAssets))
 	m.Handle("/list-transaction-feeds", needConfig(h.listTxFeeds))
 	m.Handle("/list-transactions", needConfig(h.listTransactions))
 	m.Handle("/list-balances", needConfig(h.listBalances))
 	m.Handle("/list-unspent-outputs", needConfig(h.listUnspentOutputs))
+	m.Handle("/get-transaction-proof", needConfig(h.getTxProof))
+	m.Handle("/get-output-proof", needConfig(h.getOutputProof))
+	m.Handle("/list-reservations", needConfig(h.listReservations))
+	m.Handle("/cancel-reservation", needConfig(h.cancelReservation))
+	m.Handle("/list-pending-transactions", needConfig(h.listPendingTxs))
+	m.Handle("/get-pending-transaction", needConfig(h.getPendingTx))
+	m.Handle("/remove-pending-transaction", needConfig(h.removePendingTx))
+	m.Handle("/replace-pending-transaction", needConfig(h.replacePendingTx))
 	m.Handle("/reset", needConfig(h.reset))
 
 	m.Handle(networkRPCPrefix+"submit", needConfig(h.Chain.AddTx))
 	m.Handle(networkRPCPrefix+"get-blocks", needConfig(h.getBlocksRPC)) // DEPRECATED: use get-block instead
 	m.Handle(networkRPCPrefix+"get-block", needConfig(h.getBlockRPC))
+	m.Handle(networkRPCPrefix+"get-filtered-block", needConfig(h.getFilteredBlockRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot-info", needConfig(h.getSnapshotInfoRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot", http.HandlerFunc(h.getSnapshotRPC))
+	m.Handle(networkRPCPrefix+"get-snapshot-deltas", needConfig(h.getSnapshotDeltasRPC))
 	m.Handle(networkRPCPrefix+"signer/sign-block", needConfig(h.leaderSignHandler(h.Signer)))
 	m.Handle(networkRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
 		h := h.Chain.Height()
@@ -153,6 +189,7 @@ func (h *Handler) init() {
 	m.Handle("/list-access-tokens", jsonHandler(h.listAccessTokens))
 	m.Handle("/delete-access-token", jsonHandler(h.deleteAccessToken))
 	m.Handle("/configure", jsonHandler(h.configure))
+	m.Handle("/hsm/unlock", jsonHandler(h.hsmUnlock))
 	m.Handle("/info", jsonHandler(h.info))
 
 	m.Handle("/debug/vars", http.HandlerFunc(expvarHandler))
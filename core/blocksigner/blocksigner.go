@@ -6,7 +6,7 @@ import (
 	"context"
 	"fmt"
 
-	"chain/core/mockhsm"
+	"chain/core/hsm"
 	"chain/crypto/ed25519"
 	"chain/database/pg"
 	"chain/errors"
@@ -20,24 +20,24 @@ var ErrConsensusChange = errors.New("consensus program has changed")
 
 // ErrInvalidKey is returned from SignBlock when the
 // key specified on the Signer is invalid. It may be
-// not found by the mock HSM or not paired to a valid
+// not found by the HSM or not paired to a valid
 // private key.
 var ErrInvalidKey = errors.New("misconfigured signer public key")
 
 // Signer validates and signs blocks.
 type Signer struct {
 	Pub ed25519.PublicKey
-	hsm *mockhsm.HSM
+	hsm hsm.HSM
 	db  pg.DB
 	c   *protocol.Chain
 }
 
 // New returns a new Signer that validates blocks with c and signs
 // them with k.
-func New(pub ed25519.PublicKey, hsm *mockhsm.HSM, db pg.DB, c *protocol.Chain) *Signer {
+func New(pub ed25519.PublicKey, h hsm.HSM, db pg.DB, c *protocol.Chain) *Signer {
 	return &Signer{
 		Pub: pub,
-		hsm: hsm,
+		hsm: h,
 		db:  db,
 		c:   c,
 	}
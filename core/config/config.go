@@ -10,7 +10,7 @@ import (
 	"net/url"
 	"time"
 
-	"chain/core/mockhsm"
+	"chain/core/hsm"
 	"chain/core/rpc"
 	"chain/core/txdb"
 	"chain/crypto/ed25519"
@@ -105,14 +105,16 @@ func Load(ctx context.Context, db pg.DB) (*Config, error) {
 // the caller must ensure that the new configuration is properly reloaded,
 // for example by restarting the process.
 //
-// If c.IsSigner is true, Configure generates a new mockhsm keypair
-// for signing blocks, and assigns it to c.BlockPub.
+// If c.IsSigner is true and c.BlockPub is empty, Configure generates
+// a new keypair in keys for signing blocks, and assigns it to c.BlockPub.
+// If keys can't create Ed25519 keys, c.BlockPub must be set to a key
+// already in keys.
 //
 // If c.IsGenerator is true, Configure creates an initial block,
 // saves it, and assigns its hash to c.BlockchainID.
 // Otherwise, c.IsGenerator is false, and Configure makes a test request
 // to GeneratorURL to detect simple configuration mistakes.
-func Configure(ctx context.Context, db pg.DB, c *Config) error {
+func Configure(ctx context.Context, db pg.DB, keys hsm.HSM, c *Config) error {
 	var err error
 	if !c.IsGenerator {
 		err = tryGenerator(
@@ -130,12 +132,15 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 	if c.IsSigner {
 		var blockPub ed25519.PublicKey
 		if c.BlockPub == "" {
-			hsm := mockhsm.New(db)
-			corePub, created, err := hsm.GetOrCreate(ctx, autoBlockKeyAlias)
+			creator, ok := keys.(hsm.Ed25519Creator)
+			if !ok {
+				return errors.WithDetail(hsm.ErrUnsupported, "this HSM can't create a block-signing key; configure the core with block_pub")
+			}
+			var created bool
+			blockPub, created, err = creator.GetOrCreateEd25519(ctx, autoBlockKeyAlias)
 			if err != nil {
 				return err
 			}
-			blockPub = corePub.Pub
 			blockPubStr := hex.EncodeToString(blockPub)
 			if created {
 				log.Messagef(ctx, "Generated new block-signing key %s\n", blockPubStr)
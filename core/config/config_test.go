@@ -0,0 +1,25 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/hsm"
+	"chain/errors"
+)
+
+// chainkdOnlyHSM is an HSM that can't create Ed25519 keys.
+type chainkdOnlyHSM struct {
+	hsm.HSM
+}
+
+func TestConfigureSignerWithoutBlockPub(t *testing.T) {
+	c := &Config{IsGenerator: true, IsSigner: true, Quorum: 1}
+	err := Configure(context.Background(), nil, chainkdOnlyHSM{}, c)
+	if errors.Root(err) != hsm.ErrUnsupported {
+		t.Errorf("Configure error = %v want %v", err, hsm.ErrUnsupported)
+	}
+	if c.BlockPub != "" {
+		t.Errorf("BlockPub = %q want empty", c.BlockPub)
+	}
+}
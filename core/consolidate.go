@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+
+	"chain/core/account"
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+const (
+	defaultConsolidateMaxInputs = 50
+	defaultConsolidateMaxTxs    = 10
+
+	// consolidateKeysRetry is how long ConsolidateAccounts skips
+	// an account whose keys were unavailable, unless the HSM's
+	// keys change first. Keys held by remote signers can't be
+	// listed, so the account is eventually retried regardless.
+	consolidateKeysRetry = time.Hour
+)
+
+// errConsolidateKeys is returned when a consolidating transaction
+// can't be signed because the account's keys aren't in the mock HSM.
+var errConsolidateKeys = errors.New("account keys unavailable for consolidation")
+
+type consolidateResp struct {
+	ID     bc.Hash `json:"id"`
+	Inputs int     `json:"inputs"`
+	Amount uint64  `json:"amount"`
+}
+
+// POST /consolidate-account
+//
+// consolidateAccount merges an account's outputs of an asset into
+// fewer outputs. It builds, signs and submits up to max_transactions
+// transactions, each spending up to max_inputs of the account's
+// smallest unreserved outputs to a single new output in the account.
+// The account's keys must be in the mock HSM.
+func (h *Handler) consolidateAccount(ctx context.Context, in struct {
+	AccountID       string     `json:"account_id"`
+	AccountAlias    string     `json:"account_alias"`
+	AssetID         bc.AssetID `json:"asset_id"`
+	AssetAlias      string     `json:"asset_alias"`
+	MaxInputs       int        `json:"max_inputs"`
+	MaxTransactions int        `json:"max_transactions"`
+}) (interface{}, error) {
+	// Consolidating transactions are submitted by the leader process.
+	if !leader.IsLeading() {
+		var resp interface{}
+		err := h.forwardToLeader(ctx, "/consolidate-account", in, &resp)
+		return resp, err
+	}
+
+	if in.AccountAlias != "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, errors.Wrap(err, "looking up account")
+		}
+		in.AccountID = acc.ID
+	}
+	if in.AssetAlias != "" {
+		asset, err := h.Assets.FindByAlias(ctx, in.AssetAlias)
+		if err != nil {
+			return nil, errors.Wrap(err, "looking up asset")
+		}
+		in.AssetID = asset.AssetID
+	}
+	var missing []string
+	if in.AccountID == "" {
+		missing = append(missing, "account_id")
+	}
+	if in.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return nil, txbuilder.MissingFieldsError(missing...)
+	}
+	if in.MaxInputs == 0 {
+		in.MaxInputs = defaultConsolidateMaxInputs
+	}
+	if in.MaxInputs < 2 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "max_inputs must be at least 2")
+	}
+	if in.MaxTransactions <= 0 {
+		in.MaxTransactions = defaultConsolidateMaxTxs
+	}
+
+	resp := make([]consolidateResp, 0, in.MaxTransactions)
+	for len(resp) < in.MaxTransactions {
+		r, err := h.consolidate(ctx, in.AccountID, in.AssetID, in.MaxInputs)
+		if errors.Root(err) == account.ErrTooFewUTXOs {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, *r)
+	}
+	return resp, nil
+}
+
+// consolidate builds, signs and submits one transaction
+// merging the account's outputs of the asset.
+func (h *Handler) consolidate(ctx context.Context, accountID string, assetID bc.AssetID, maxInputs int) (*consolidateResp, error) {
+	c, err := h.Accounts.BuildConsolidation(ctx, accountID, assetID, maxInputs, time.Now().Add(defaultTxTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	var signed int
+	sign := h.signFunc(c.Template)
+	signFn := func(ctx context.Context, xpub string, path [][]byte, data [32]byte) ([]byte, error) {
+		sig, err := sign(ctx, xpub, path, data)
+		if sig != nil {
+			signed++
+		}
+		return sig, err
+	}
+	err = h.signWithPolicies(ctx, c.Template, c.XPubs, func(ctx context.Context) error {
+		err := txbuilder.Sign(ctx, c.Template, c.XPubs, signFn)
+		if err == nil && signed < c.Inputs*c.Quorum {
+			err = errors.WithDetailf(errConsolidateKeys, "account %s", accountID)
+		}
+		return err
+	})
+	if err != nil {
+		c.Cancel()
+		return nil, errors.Wrap(err, "signing consolidation")
+	}
+
+	err = h.finalizeTxWait(ctx, c.Template, "none")
+	if err != nil {
+		c.Cancel()
+		return nil, errors.Wrapf(err, "tx %s", c.Template.Transaction.Hash())
+	}
+	return &consolidateResp{
+		ID:     c.Template.Transaction.Hash(),
+		Inputs: c.Inputs,
+		Amount: c.Amount,
+	}, nil
+}
+
+// ConsolidateAccounts periodically consolidates the outputs of
+// every account that has at least policy.MinUTXOs outputs of an
+// asset, until ctx is canceled. It should only run on the leader.
+//
+// Accounts whose consolidations can't be signed are skipped until
+// the HSM's keys change, so that their outputs aren't reserved and
+// released again on every tick.
+func (h *Handler) ConsolidateAccounts(ctx context.Context, period time.Duration, policy account.ConsolidationPolicy) {
+	skipped := make(map[string]skippedAccount)
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, ConsolidateAccounts exiting")
+			return
+		case <-ticks:
+		}
+
+		frags, err := h.Accounts.FindFragmented(ctx, policy.MinUTXOs)
+		if err != nil {
+			log.Error(ctx, err)
+			continue
+		}
+		if len(frags) == 0 {
+			continue
+		}
+		keys, err := h.hsmKeysHash(ctx)
+		if err != nil {
+			log.Error(ctx, err)
+			continue
+		}
+		for _, f := range frags {
+			if s, ok := skipped[f.AccountID]; ok {
+				if s.keys == keys && time.Since(s.at) < consolidateKeysRetry {
+					continue
+				}
+				delete(skipped, f.AccountID)
+			}
+
+			// Consolidate until fewer than MinUTXOs outputs would remain.
+			for n := f.UTXOs; n >= policy.MinUTXOs; {
+				r, err := h.consolidate(ctx, f.AccountID, f.AssetID, policy.MaxInputs)
+				if errors.Root(err) == account.ErrTooFewUTXOs {
+					break
+				}
+				if errors.Root(err) == errConsolidateKeys {
+					log.Error(ctx, err, "account", f.AccountID, "asset", f.AssetID)
+					skipped[f.AccountID] = skippedAccount{keys: keys, at: time.Now()}
+					break
+				}
+				if err != nil {
+					log.Error(ctx, err, "account", f.AccountID, "asset", f.AssetID)
+					break
+				}
+				n -= r.Inputs - 1
+			}
+		}
+	}
+}
+
+// skippedAccount records when ConsolidateAccounts last
+// found an account's keys unavailable, and the hash of
+// the HSM's keys at the time.
+type skippedAccount struct {
+	keys [32]byte
+	at   time.Time
+}
+
+// hsmKeysHash returns a hash of the xpubs of
+// the chainkd keys held by the HSM.
+func (h *Handler) hsmKeysHash(ctx context.Context) ([32]byte, error) {
+	const pageSize = 100
+	hash := sha256.New()
+	var after string
+	for {
+		xpubs, next, err := h.HSM.ListKeys(ctx, nil, after, pageSize)
+		if err != nil {
+			return [32]byte{}, errors.Wrap(err, "listing hsm keys")
+		}
+		for _, xpub := range xpubs {
+			hash.Write(xpub.XPub[:])
+		}
+		if len(xpubs) < pageSize {
+			break
+		}
+		after = next
+	}
+	var sum [32]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
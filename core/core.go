@@ -143,7 +143,7 @@ func (h *Handler) configure(ctx context.Context, x *config.Config) error {
 		x.MaxIssuanceWindow = 24 * time.Hour
 	}
 
-	err := config.Configure(ctx, h.DB, x)
+	err := config.Configure(ctx, h.DB, h.HSM, x)
 	if err != nil {
 		return err
 	}
@@ -18,7 +18,7 @@ import (
 )
 
 var (
-	persistBlockchainReset = []string{"mockhsm", "access_tokens"}
+	persistBlockchainReset = []string{"mockhsm", "mockhsm_passphrase", "access_tokens"}
 	neverReset             = []string{"migrations"}
 )
 
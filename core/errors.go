@@ -8,17 +8,22 @@ import (
 	"chain/core/asset"
 	"chain/core/blocksigner"
 	"chain/core/config"
-	"chain/core/mockhsm"
+	"chain/core/fetch"
+	"chain/core/hsm"
 	"chain/core/query"
 	"chain/core/query/filter"
 	"chain/core/rpc"
+	"chain/core/signerd"
 	"chain/core/signers"
+	"chain/core/signpolicy"
 	"chain/core/txbuilder"
 	"chain/core/txfeed"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/net/http/httpjson"
 	"chain/protocol"
+	"chain/protocol/mempool"
+	"chain/protocol/proof"
 )
 
 // errorInfo contains a set of error codes to send to the user.
@@ -41,6 +46,8 @@ func isTemporary(info errorInfo, err error) bool {
 		return true
 	case "CH001": // request timed out
 		return true
+	case "CH738": // pending transaction pool full
+		return true
 	case "CH761": // outputs currently reserved
 		return true
 	case "CH706": // 1 or more action errors
@@ -77,7 +84,8 @@ var (
 		asset.ErrDuplicateAlias:      errorInfo{400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:    errorInfo{400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:     errorInfo{400, "CH050", "Alias already exists"},
-		mockhsm.ErrDuplicateKeyAlias: errorInfo{400, "CH050", "Alias already exists"},
+		hsm.ErrDuplicateKeyAlias:     errorInfo{400, "CH050", "Alias already exists"},
+		signpolicy.ErrDuplicateAlias: errorInfo{400, "CH050", "Alias already exists"},
 
 		// Core error namespace
 		errUnconfigured:                errorInfo{400, "CH100", "This core still needs to be configured"},
@@ -90,6 +98,8 @@ var (
 		config.ErrBadSignerPubkey:      errorInfo{400, "CH107", "Block signer pubkey is invalid"},
 		config.ErrBadQuorum:            errorInfo{400, "CH108", "Quorum must be greater than 0 if there are signers"},
 		errProdReset:                   errorInfo{400, "CH110", "Reset can only be called in a development system"},
+		fetch.ErrBadFilter:             errorInfo{400, "CH111", "Invalid light client program filter"},
+		proof.ErrNoAbsenceProof:        errorInfo{400, "CH112", "Output is not unspent; the state tree can't prove that it is absent"},
 		errNoClientTokens:              errorInfo{400, "CH120", "Cannot enable client authentication with no client tokens"},
 		blocksigner.ErrConsensusChange: errorInfo{400, "CH150", "Refuse to sign block with consensus change"},
 
@@ -110,6 +120,7 @@ var (
 		query.ErrBadAfter:               errorInfo{400, "CH600", "Malformed pagination parameter `after`"},
 		query.ErrParameterCountMismatch: errorInfo{400, "CH601", "Incorrect number of parameters to filter"},
 		filter.ErrBadFilter:             errorInfo{400, "CH602", "Malformed query filter"},
+		txfeed.ErrBadWebhookURL:         errorInfo{400, "CH610", "Invalid transaction feed webhook URL"},
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
@@ -128,14 +139,29 @@ var (
 		txbuilder.ErrBadWitnessComponent:   errorInfo{400, "CH733", "Invalid witness component"},
 		txbuilder.ErrRejected:              errorInfo{400, "CH735", "Transaction rejected"},
 		txbuilder.ErrNoTxSighashCommitment: errorInfo{400, "CH736", "Transaction is not final, additional actions still allowed"},
+		mempool.ErrConflict:                errorInfo{400, "CH737", "Transaction conflicts with a pending transaction"},
+		mempool.ErrPoolFull:                errorInfo{400, "CH738", "Pending transaction pool is full; try again"},
+		mempool.ErrNotFound:                errorInfo{400, "CH739", "Transaction is not pending"},
 
 		// account action error namespace (76x)
-		account.ErrInsufficient: errorInfo{400, "CH760", "Insufficient funds for tx"},
-		account.ErrReserved:     errorInfo{400, "CH761", "Some outputs are reserved; try again"},
-
-		// Mock HSM error namespace (80x)
-		mockhsm.ErrInvalidAfter:         errorInfo{400, "CH801", "Invalid `after` in query"},
-		mockhsm.ErrTooManyAliasesToList: errorInfo{400, "CH802", "Too many aliases to list"},
+		account.ErrInsufficient:         errorInfo{400, "CH760", "Insufficient funds for tx"},
+		account.ErrReserved:             errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrBadSelectionStrategy: errorInfo{400, "CH762", "Unknown selection strategy"},
+		account.ErrTooFewUTXOs:          errorInfo{400, "CH763", "Too few outputs to consolidate"},
+		errConsolidateKeys:              errorInfo{400, "CH764", "Account keys are not available to sign consolidation"},
+
+		// HSM error namespace (80x)
+		hsm.ErrInvalidAfter:         errorInfo{400, "CH801", "Invalid `after` in query"},
+		hsm.ErrTooManyAliasesToList: errorInfo{400, "CH802", "Too many aliases to list"},
+		hsm.ErrLocked:               errorInfo{400, "CH803", "HSM is locked; unlock it with its passphrase"},
+		hsm.ErrBadPassphrase:        errorInfo{400, "CH804", "Invalid HSM passphrase"},
+		signerd.ErrRejected:         errorInfo{400, "CH805", "Remote signer rejected the transaction"},
+		signerd.ErrBadHash:          errorInfo{400, "CH806", "Remote signer did not recognize the hash to sign"},
+		signpolicy.ErrBadPolicy:     errorInfo{400, "CH807", "Invalid signing policy"},
+		signpolicy.ErrRejected:      errorInfo{400, "CH808", "Transaction rejected by signing policy: see detail and data"},
+		hsm.ErrUnsupported:          errorInfo{400, "CH809", "Operation not supported by this HSM"},
+		hsm.ErrBadBackup:            errorInfo{400, "CH810", "Invalid key backup"},
+		hsm.ErrBadMnemonic:          errorInfo{400, "CH811", "Invalid mnemonic"},
 	}
 )
 
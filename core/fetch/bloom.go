@@ -0,0 +1,105 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"math"
+
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// Limits on the filters a generator accepts.
+const (
+	maxFilterBytes  = 1 << 20 // about 870,000 programs at 1% false positives
+	maxFilterHashes = 32
+)
+
+// falsePositiveRate is the rate at which a light client's filter
+// matches programs it doesn't watch.
+const falsePositiveRate = 0.01
+
+// ErrBadFilter is returned for a ProgramFilter
+// that is empty or exceeds the generator's limits.
+var ErrBadFilter = errors.New("invalid program filter")
+
+// ProgramFilter is a Bloom filter of control programs. A light
+// client sends one in each request for a filtered block, in
+// place of the programs themselves. It is a fraction of their
+// size, the generator tests a program against it in constant
+// time, and its false positives keep the generator from
+// learning exactly which programs are the client's.
+type ProgramFilter struct {
+	Bits   chainjson.HexBytes `json:"bits"`
+	Hashes uint8              `json:"hashes"`
+}
+
+// NewProgramFilter returns a filter of programs,
+// sized for falsePositiveRate.
+func NewProgramFilter(programs [][]byte) *ProgramFilter {
+	n := float64(len(programs))
+	if n < 1 {
+		n = 1
+	}
+	bits := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	nbytes := int(math.Ceil(bits / 8))
+	if nbytes > maxFilterBytes {
+		nbytes = maxFilterBytes
+	}
+	hashes := math.Ceil(float64(nbytes*8) / n * math.Ln2)
+	if hashes > maxFilterHashes {
+		hashes = maxFilterHashes
+	}
+
+	f := &ProgramFilter{
+		Bits:   make([]byte, nbytes),
+		Hashes: uint8(hashes),
+	}
+	for _, p := range programs {
+		f.add(p)
+	}
+	return f
+}
+
+func (f *ProgramFilter) add(prog []byte) {
+	f.each(prog, func(i uint64) {
+		f.Bits[i/8] |= 1 << (i % 8)
+	})
+}
+
+// Match reports whether prog may be in f.
+func (f *ProgramFilter) Match(prog []byte) bool {
+	match := true
+	f.each(prog, func(i uint64) {
+		if f.Bits[i/8]&(1<<(i%8)) == 0 {
+			match = false
+		}
+	})
+	return match
+}
+
+// each calls fn with the index of each bit of f for prog.
+// The indexes come from two halves of prog's hash, as
+// h1 + i*h2, so that prog is hashed only once.
+func (f *ProgramFilter) each(prog []byte, fn func(i uint64)) {
+	var h [32]byte
+	sha3pool.Sum256(h[:], prog)
+	h1 := binary.LittleEndian.Uint64(h[0:8])
+	h2 := binary.LittleEndian.Uint64(h[8:16]) | 1
+	m := uint64(len(f.Bits)) * 8
+	for i := uint64(0); i < uint64(f.Hashes); i++ {
+		fn((h1 + i*h2) % m)
+	}
+}
+
+// validate returns ErrBadFilter if f is empty
+// or exceeds the generator's limits.
+func (f *ProgramFilter) validate() error {
+	if len(f.Bits) == 0 || len(f.Bits) > maxFilterBytes {
+		return errors.WithDetailf(ErrBadFilter, "filter size %d bytes is not in 1..%d", len(f.Bits), maxFilterBytes)
+	}
+	if f.Hashes == 0 || f.Hashes > maxFilterHashes {
+		return errors.WithDetailf(ErrBadFilter, "filter hash count %d is not in 1..%d", f.Hashes, maxFilterHashes)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package fetch
+
+import (
+	"fmt"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestProgramFilter(t *testing.T) {
+	var progs [][]byte
+	for i := 0; i < 1000; i++ {
+		progs = append(progs, []byte(fmt.Sprintf("program %d", i)))
+	}
+	f := NewProgramFilter(progs)
+	if err := f.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Bits) >= 1000*8 {
+		t.Errorf("filter is %d bytes, want less than the programs' size", len(f.Bits))
+	}
+	for _, p := range progs {
+		if !f.Match(p) {
+			t.Fatalf("filter doesn't match %q", p)
+		}
+	}
+
+	var fp int
+	const trials = 10000
+	for i := 0; i < trials; i++ {
+		if f.Match([]byte(fmt.Sprintf("other %d", i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / trials; rate > 3*falsePositiveRate {
+		t.Errorf("false positive rate %f, want about %f", rate, falsePositiveRate)
+	}
+}
+
+func TestFilterBlock(t *testing.T) {
+	f := NewProgramFilter([][]byte{{1}})
+	b := &bc.Block{Transactions: []*bc.Tx{
+		bc.NewTx(bc.TxData{Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, []byte{2}, nil)}}),
+		bc.NewTx(bc.TxData{Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, []byte{1}, nil)}}),
+		bc.NewTx(bc.TxData{Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, []byte{1}, nil)}}),
+	}}
+	fb, err := FilterBlock(b, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fb.Block.Transactions) != 2 || fb.Block.Transactions[0] != b.Transactions[1] || fb.Block.Transactions[1] != b.Transactions[2] {
+		t.Errorf("FilterBlock kept %d transactions, want the last 2", len(fb.Block.Transactions))
+	}
+
+	_, err = FilterBlock(b, &ProgramFilter{Bits: make([]byte, maxFilterBytes+1), Hashes: 1})
+	if errors.Root(err) != ErrBadFilter {
+		t.Errorf("oversized filter: error = %v want %v", err, ErrBadFilter)
+	}
+	_, err = FilterBlock(b, nil)
+	if errors.Root(err) != ErrBadFilter {
+		t.Errorf("no filter: error = %v want %v", err, ErrBadFilter)
+	}
+}
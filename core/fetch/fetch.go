@@ -15,6 +15,7 @@ import (
 
 	"chain/core/rpc"
 	"chain/core/txdb"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
 	"chain/protocol"
@@ -329,6 +330,13 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, atte
 	if err != nil {
 		return err
 	}
+
+	// Bring the snapshot up to date with the generator's state deltas.
+	height, err := fetchDeltas(ctx, peer, snapshot, info.Height)
+	if err != nil {
+		return err
+	}
+
 	// Delete the snapshot issuances because we don't have any commitment
 	// to them in the block. This means that Cores bootstrapping from a
 	// snapshot cannot guarantee uniqueness of issuances until the max
@@ -346,7 +354,7 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, atte
 	}
 
 	// Also get the corresponding block.
-	snapshotBlock, err := getBlock(ctx, peer, info.Height, getBlockTimeout)
+	snapshotBlock, err := getBlock(ctx, peer, height, getBlockTimeout)
 	if err != nil {
 		return err
 	}
@@ -371,6 +379,34 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, atte
 	return errors.Wrap(err, "saving bootstrap snaphot")
 }
 
+// fetchDeltas applies to snapshot, the state at the given height,
+// the state deltas of the peer's blocks after it. It returns the
+// height of the updated snapshot. The caller must check the
+// snapshot against the block at that height.
+func fetchDeltas(ctx context.Context, peer *rpc.Client, snapshot *state.Snapshot, height uint64) (uint64, error) {
+	for {
+		var deltas []chainjson.HexBytes
+		err := peer.Call(ctx, "/rpc/get-snapshot-deltas", height, &deltas)
+		if err != nil {
+			return 0, errors.Wrap(err, "getting state deltas")
+		}
+		if len(deltas) == 0 {
+			return height, nil
+		}
+		for _, data := range deltas {
+			d, err := txdb.DecodeDelta(data)
+			if err != nil {
+				return 0, err
+			}
+			err = snapshot.ApplyDelta(d)
+			if err != nil {
+				return 0, errors.Wrapf(err, "applying state delta %d", height+1)
+			}
+			height++
+		}
+	}
+}
+
 type progressReader struct {
 	reader io.Reader
 	read   uint64
@@ -0,0 +1,190 @@
+package fetch
+
+import (
+	"context"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/proof"
+	"chain/protocol/validation"
+)
+
+// BlockFilter is a request for the block at Height,
+// keeping only the transactions that pay to or spend
+// from a control program matching Programs.
+type BlockFilter struct {
+	Height   uint64         `json:"height"`
+	Programs *ProgramFilter `json:"programs"`
+}
+
+// FilteredBlock is a block holding only some of its
+// transactions, with a proof for each that it is in the
+// block. Its header, and so its hash and signatures,
+// are those of the full block.
+type FilteredBlock struct {
+	Block  *bc.Block        `json:"block"`
+	Proofs []*proof.TxProof `json:"proofs"`
+}
+
+// FilterBlock returns the block b with only the transactions
+// that have an output with a control program matching programs,
+// or spend an output with one. It returns ErrBadFilter if
+// programs is not a valid filter.
+func FilterBlock(b *bc.Block, programs *ProgramFilter) (*FilteredBlock, error) {
+	if programs == nil {
+		return nil, errors.WithDetail(ErrBadFilter, "no program filter")
+	}
+	err := programs.validate()
+	if err != nil {
+		return nil, err
+	}
+	fb := &FilteredBlock{
+		Block:  &bc.Block{BlockHeader: b.BlockHeader},
+		Proofs: []*proof.TxProof{},
+	}
+	for i, tx := range b.Transactions {
+		if matchTx(tx, programs) {
+			fb.Block.Transactions = append(fb.Block.Transactions, tx)
+			fb.Proofs = append(fb.Proofs, validation.TxProof(b.Transactions, i))
+		}
+	}
+	return fb, nil
+}
+
+func matchTx(tx *bc.Tx, programs *ProgramFilter) bool {
+	for _, in := range tx.Inputs {
+		if !in.IsIssuance() && programs.Match(in.ControlProgram()) {
+			return true
+		}
+	}
+	for _, out := range tx.Outputs {
+		if programs.Match(out.ControlProgram) {
+			return true
+		}
+	}
+	return false
+}
+
+// A ProgramSource lists the control programs a light client
+// watches. Programs are never removed, so a change in their
+// count means new programs.
+type ProgramSource interface {
+	ControlPrograms(context.Context) ([][]byte, error)
+	CountControlPrograms(context.Context) (int, error)
+}
+
+// programWatcher keeps a filter of the programs of a
+// ProgramSource, rebuilding it only when there are new ones.
+type programWatcher struct {
+	src    ProgramSource
+	n      int
+	filter *ProgramFilter
+}
+
+func (w *programWatcher) current(ctx context.Context) (*ProgramFilter, error) {
+	n, err := w.src.CountControlPrograms(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting control programs")
+	}
+	if w.filter != nil && n == w.n {
+		return w.filter, nil
+	}
+	progs, err := w.src.ControlPrograms(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing control programs")
+	}
+	w.filter = NewProgramFilter(progs)
+	w.n = len(progs)
+	return w.filter, nil
+}
+
+// FetchFiltered is like Fetch, for a light client. It downloads
+// each block from the peer with only the transactions that
+// pay to or spend from the control programs of programs (and
+// some others, the false positives of its ProgramFilter),
+// checks each block's header and the proofs of its
+// transactions, and commits the filtered block to the local
+// Chain. It keeps no blockchain state.
+//
+// See validation.ValidateFilteredBlock for what a light
+// client can and cannot check.
+func FetchFiltered(ctx context.Context, c *protocol.Chain, peer *rpc.Client, programs ProgramSource, health func(error)) {
+	// Fetch the generator height periodically.
+	go pollGeneratorHeight(ctx, peer)
+
+	prev, err := c.RecoverFiltered(ctx)
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+
+	var (
+		height    uint64 = 1
+		nfailures uint
+		ntimeouts uint
+		watcher   = &programWatcher{src: programs}
+	)
+	if prev != nil {
+		height = prev.Height + 1
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, FetchFiltered exiting")
+			return
+		default:
+		}
+
+		filter, err := watcher.current(ctx)
+		var fb *FilteredBlock
+		if err == nil {
+			fb, err = getFilteredBlock(ctx, peer, height, filter, timeoutBackoffDur(ntimeouts))
+		}
+		if err == nil && fb == nil {
+			// Request time out. There might not have been
+			// any blocks published yet.
+			ntimeouts++
+			continue
+		}
+		if err == nil {
+			err = validation.ValidateFilteredBlock(c.InitialBlockHash, prev, fb.Block, fb.Proofs)
+			if err != nil {
+				log.Fatal(ctx, log.KeyError, errors.Wrapf(err, "block %d", height))
+			}
+			err = c.CommitFilteredBlock(ctx, fb.Block)
+		}
+		if err != nil {
+			health(err)
+			logNetworkError(ctx, err)
+			nfailures++
+			time.Sleep(backoffDur(nfailures))
+			continue
+		}
+
+		prev = fb.Block
+		height++
+		health(nil)
+		ntimeouts, nfailures = 0, 0
+	}
+}
+
+// getFilteredBlock sends a get-filtered-block RPC request
+// to another Core for the block at the given height.
+func getFilteredBlock(ctx context.Context, peer *rpc.Client, height uint64, programs *ProgramFilter, timeout time.Duration) (*FilteredBlock, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var fb *FilteredBlock
+	filter := BlockFilter{Height: height, Programs: programs}
+	err := peer.Call(ctx, "/rpc/get-filtered-block", filter, &fb)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, nil
+	}
+	if err == nil && (fb == nil || fb.Block == nil || fb.Block.Height != height) {
+		err = errors.New("unexpected response from generator")
+	}
+	return fb, errors.Wrap(err, "get filtered block rpc")
+}
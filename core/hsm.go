@@ -3,22 +3,92 @@ package core
 import (
 	"context"
 
-	"chain/core/mockhsm"
+	"chain/core/hsm"
+	"chain/core/leader"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/net/http/httpjson"
 )
 
-func (h *Handler) mockhsmCreateKey(ctx context.Context, in struct{ Alias string }) (result *mockhsm.XPub, err error) {
-	result, err = h.HSM.XCreate(ctx, in.Alias)
+// hsmCreateKey creates a chainkd key. If in.Mnemonic is set, the
+// key is created from a new mnemonic, returned along with the key,
+// from which hsmRestoreKey can recreate it.
+func (h *Handler) hsmCreateKey(ctx context.Context, in struct {
+	Alias    string
+	Mnemonic bool
+}) (result *createKeyResponse, err error) {
+	if !in.Mnemonic {
+		xpub, err := h.HSM.XCreate(ctx, in.Alias)
+		if err != nil {
+			return nil, err
+		}
+		return &createKeyResponse{XPub: xpub}, nil
+	}
+	m, ok := h.HSM.(hsm.MnemonicCreator)
+	if !ok {
+		return nil, errors.WithDetail(hsm.ErrUnsupported, "mnemonic keys")
+	}
+	xpub, mnemonic, err := m.XCreateMnemonic(ctx, in.Alias)
+	if err != nil {
+		return nil, err
+	}
+	return &createKeyResponse{XPub: xpub, Mnemonic: mnemonic}, nil
+}
+
+type createKeyResponse struct {
+	*hsm.XPub
+	Mnemonic string `json:"mnemonic,omitempty"`
+}
+
+// hsmRestoreKey recreates a key created by hsmCreateKey
+// from its mnemonic.
+func (h *Handler) hsmRestoreKey(ctx context.Context, in struct {
+	Alias    string
+	Mnemonic string
+}) (*hsm.XPub, error) {
+	m, ok := h.HSM.(hsm.MnemonicCreator)
+	if !ok {
+		return nil, errors.WithDetail(hsm.ErrUnsupported, "mnemonic keys")
+	}
+	return m.XRestore(ctx, in.Alias, in.Mnemonic)
+}
+
+// hsmExportKeys returns an encrypted backup of the keys with the
+// given aliases or xpubs, or of all keys if none are given. If the
+// HSM has a passphrase, the request must include it.
+func (h *Handler) hsmExportKeys(ctx context.Context, in struct {
+	Aliases       []string
+	XPubs         []chainkd.XPub
+	HSMPassphrase string `json:"hsm_passphrase"`
+	Passphrase    string
+}) (interface{}, error) {
+	b, ok := h.HSM.(hsm.Backuper)
+	if !ok {
+		return nil, errors.WithDetail(hsm.ErrUnsupported, "key backups")
+	}
+	backup, err := b.ExportKeys(ctx, in.Aliases, in.XPubs, in.HSMPassphrase, in.Passphrase)
 	if err != nil {
-		return result, err
+		return nil, err
 	}
-	return result, nil
+	return map[string]interface{}{"backup": chainjson.HexBytes(backup)}, nil
 }
 
-func (h *Handler) mockhsmListKeys(ctx context.Context, query requestQuery) (page, error) {
+// hsmImportKeys restores the keys in a backup made by
+// hsmExportKeys, possibly in another Core.
+func (h *Handler) hsmImportKeys(ctx context.Context, in struct {
+	Backup     chainjson.HexBytes
+	Passphrase string
+}) ([]*hsm.XPub, error) {
+	b, ok := h.HSM.(hsm.Backuper)
+	if !ok {
+		return nil, errors.WithDetail(hsm.ErrUnsupported, "key backups")
+	}
+	return b.ImportKeys(ctx, in.Backup, in.Passphrase)
+}
+
+func (h *Handler) hsmListKeys(ctx context.Context, query requestQuery) (page, error) {
 	limit := defGenericPageSize
 
 	xpubs, after, err := h.HSM.ListKeys(ctx, query.Aliases, query.After, limit)
@@ -40,17 +110,39 @@ func (h *Handler) mockhsmListKeys(ctx context.Context, query requestQuery) (page
 	}, nil
 }
 
-func (h *Handler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) error {
-	return h.HSM.DeleteChainKDKey(ctx, xpub)
+func (h *Handler) hsmDelKey(ctx context.Context, xpub chainkd.XPub) error {
+	return h.HSM.Delete(ctx, xpub)
+}
+
+// hsmUnlock unlocks an HSM that keeps keys encrypted with a
+// passphrase. It is available before the Core is configured,
+// so that Configure can create a block-signing key.
+//
+// Each process of a Core has its own HSM to unlock. Once the
+// Core is configured, a process that isn't the leader also
+// forwards the request to the leader, which signs blocks.
+// Other processes must be unlocked with their own requests.
+func (h *Handler) hsmUnlock(ctx context.Context, in struct{ Passphrase string }) error {
+	l, ok := h.HSM.(hsm.Locker)
+	if !ok {
+		return nil // nothing to unlock
+	}
+	err := l.Unlock(ctx, in.Passphrase)
+	if err != nil || h.Config == nil || leader.IsLeading() {
+		return err
+	}
+	return h.forwardToLeader(ctx, "/hsm/unlock", in, nil)
 }
 
-func (h *Handler) mockhsmSignTemplates(ctx context.Context, x struct {
+func (h *Handler) hsmSignTemplates(ctx context.Context, x struct {
 	Txs   []*txbuilder.Template `json:"transactions"`
 	XPubs []string              `json:"xpubs"`
 }) []interface{} {
 	resp := make([]interface{}, 0, len(x.Txs))
 	for _, tx := range x.Txs {
-		err := txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
+		err := h.signWithPolicies(ctx, tx, x.XPubs, func(ctx context.Context) error {
+			return txbuilder.Sign(ctx, tx, x.XPubs, h.signFunc(tx))
+		})
 		if err != nil {
 			info, _ := errInfo(err)
 			resp = append(resp, info)
@@ -61,14 +153,33 @@ func (h *Handler) mockhsmSignTemplates(ctx context.Context, x struct {
 	return resp
 }
 
-func (h *Handler) mockhsmSignTemplate(ctx context.Context, xpubstr string, path [][]byte, data [32]byte) ([]byte, error) {
+// signFunc returns a txbuilder.SignFunc that signs tpl with the
+// keys in h.HSM and then with those of h.RemoteSigners, in order.
+func (h *Handler) signFunc(tpl *txbuilder.Template) txbuilder.SignFunc {
+	return func(ctx context.Context, xpub string, path [][]byte, data [32]byte) ([]byte, error) {
+		sig, err := h.hsmSignTemplate(ctx, xpub, path, data)
+		if sig != nil || err != nil {
+			return sig, err
+		}
+		for _, r := range h.RemoteSigners {
+			sig, err = r.Sign(ctx, tpl, xpub, path, data)
+			if errors.Root(err) == hsm.ErrNoKey {
+				continue
+			}
+			return sig, err
+		}
+		return nil, nil
+	}
+}
+
+func (h *Handler) hsmSignTemplate(ctx context.Context, xpubstr string, path [][]byte, data [32]byte) ([]byte, error) {
 	var xpub chainkd.XPub
 	err := xpub.UnmarshalText([]byte(xpubstr))
 	if err != nil {
 		return nil, errors.Wrap(err, "parsing xpub")
 	}
 	sigBytes, err := h.HSM.XSign(ctx, xpub, path, data[:])
-	if err == mockhsm.ErrNoKey {
+	if err == hsm.ErrNoKey {
 		return nil, nil
 	}
 	return sigBytes, err
@@ -0,0 +1,127 @@
+// Package hsm defines the interface between Chain Core and the
+// hardware security module (HSM) that keeps its private keys.
+//
+// Package chain/core/mockhsm implements it with keys stored in
+// the Core's database, for development. Package
+// chain/core/hsm/pkcs11 implements it with keys kept in a
+// PKCS#11 token.
+package hsm
+
+import (
+	"context"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+// Errors returned by implementations of HSM.
+var (
+	ErrBadBackup            = errors.New("invalid key backup")
+	ErrBadMnemonic          = errors.New("invalid mnemonic")
+	ErrBadPassphrase        = errors.New("bad passphrase")
+	ErrDuplicateKeyAlias    = errors.New("duplicate key alias")
+	ErrInvalidAfter         = errors.New("invalid after")
+	ErrLocked               = errors.New("hsm is locked")
+	ErrNoKey                = errors.New("key not found")
+	ErrTooManyAliasesToList = errors.New("requested aliases exceeds limit")
+
+	// ErrUnsupported is returned by callers of the optional
+	// interfaces below when the HSM doesn't implement them.
+	ErrUnsupported = errors.New("operation not supported by hsm")
+)
+
+// An HSM creates chainkd keys and signs with them,
+// and signs with Ed25519 keys, such as block-signing
+// keys, that it holds.
+type HSM interface {
+	// XCreate creates a new chainkd key with the given alias,
+	// which may be empty. Aliases must be unique.
+	XCreate(ctx context.Context, alias string) (*XPub, error)
+
+	// ListKeys returns a page of at most limit chainkd keys,
+	// those with the given aliases if any are given, and the
+	// value of after to use for the next page.
+	ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*XPub, string, error)
+
+	// XSign signs msg with the xprv of xpub, derived
+	// with path. It returns ErrNoKey if the HSM
+	// doesn't have the xprv.
+	XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error)
+
+	// Sign signs msg with the Ed25519 private key of pub.
+	// It returns ErrNoKey if the HSM doesn't have the key.
+	Sign(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error)
+
+	// Delete deletes the chainkd key with the given xpub.
+	Delete(ctx context.Context, xpub chainkd.XPub) error
+}
+
+// A Locker is an HSM whose keys can't be used until it is
+// unlocked with a passphrase. While locked, its methods
+// that use keys return ErrLocked.
+type Locker interface {
+	HSM
+
+	// Unlock unlocks the HSM, or returns ErrBadPassphrase.
+	Unlock(ctx context.Context, passphrase string) error
+
+	// Lock locks the HSM, forgetting any keys in memory.
+	Lock()
+}
+
+// A Backuper is an HSM whose keys can be exported
+// in an encrypted backup and imported into another HSM.
+type Backuper interface {
+	HSM
+
+	// ExportKeys returns a backup of the keys with the given
+	// aliases or xpubs, or of all keys if none are given,
+	// encrypted with passphrase. If the HSM's keys are protected
+	// by a passphrase, hsmPassphrase must be it; otherwise
+	// ExportKeys returns ErrBadPassphrase.
+	ExportKeys(ctx context.Context, aliases []string, xpubs []chainkd.XPub, hsmPassphrase, passphrase string) ([]byte, error)
+
+	// ImportKeys stores the keys in backup, which was made by
+	// ExportKeys with passphrase, and returns their xpubs.
+	// Keys that are already stored keep their aliases.
+	// It returns ErrBadBackup if backup is corrupt and
+	// ErrBadPassphrase if passphrase is wrong.
+	ImportKeys(ctx context.Context, backup []byte, passphrase string) ([]*XPub, error)
+}
+
+// A MnemonicCreator is an HSM that can create chainkd keys
+// from mnemonic phrases, so that keys can be restored from
+// the phrase alone.
+type MnemonicCreator interface {
+	HSM
+
+	// XCreateMnemonic is like XCreate, and also returns
+	// the mnemonic phrase that XRestore takes to
+	// recreate the key.
+	XCreateMnemonic(ctx context.Context, alias string) (*XPub, string, error)
+
+	// XRestore stores the chainkd key of mnemonic with
+	// the given alias. It returns ErrBadMnemonic if
+	// mnemonic is not valid.
+	XRestore(ctx context.Context, alias, mnemonic string) (*XPub, error)
+}
+
+// An Ed25519Creator is an HSM that can create Ed25519 keys,
+// such as the block-signing key that config.Configure creates
+// for a block signer configured without one. Other HSMs sign
+// only with Ed25519 keys created by their own tools.
+type Ed25519Creator interface {
+	HSM
+
+	// GetOrCreateEd25519 returns the public key of the Ed25519
+	// key with the given alias, creating the key if there is
+	// none. It also reports whether the key was created.
+	GetOrCreateEd25519(ctx context.Context, alias string) (ed25519.PublicKey, bool, error)
+}
+
+// XPub is a chainkd public key kept by an HSM.
+type XPub struct {
+	Alias *string      `json:"alias"`
+	XPub  chainkd.XPub `json:"xpub"`
+}
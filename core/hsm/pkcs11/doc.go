@@ -0,0 +1,22 @@
+/*
+Package pkcs11 implements hsm.HSM with keys kept in a PKCS#11
+token, such as a network HSM or, for testing, SoftHSM.
+
+It requires cgo and is built only with the build tag pkcs11.
+The token's PKCS#11 module is loaded at run time.
+
+Ed25519 keys, such as block-signing keys, are created with
+the token's own tools and never leave it; Sign uses the
+CKM_EDDSA mechanism of PKCS#11 version 3.0.
+
+Chainkd keys, such as account and asset keys, are not protected
+by the token. Standard PKCS#11 mechanisms can't derive or sign with
+them, so each chainkd xprv is kept in the token as a generic secret
+key, readable after logging in with the token's PIN. It can't be
+sensitive, since it must be read to sign, but it is unextractable
+where the token allows it, so it can't be wrapped and taken out.
+To sign, the xprv is read from the token into memory, where it is
+kept for later use, and derived and used there. Anyone who can log
+in to the token, or read the Core's memory, can read it.
+*/
+package pkcs11
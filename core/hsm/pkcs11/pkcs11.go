@@ -0,0 +1,707 @@
+//+build pkcs11
+
+package pkcs11
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef unsigned long CK_ULONG;
+typedef CK_ULONG CK_RV;
+typedef unsigned char CK_BYTE;
+
+typedef struct {
+	CK_BYTE major;
+	CK_BYTE minor;
+} CK_VERSION;
+
+typedef struct {
+	CK_ULONG type;
+	void *pValue;
+	CK_ULONG ulValueLen;
+} CK_ATTRIBUTE;
+
+typedef struct {
+	CK_ULONG mechanism;
+	void *pParameter;
+	CK_ULONG ulParameterLen;
+} CK_MECHANISM;
+
+typedef struct {
+	void *CreateMutex;
+	void *DestroyMutex;
+	void *LockMutex;
+	void *UnlockMutex;
+	CK_ULONG flags;
+	void *pReserved;
+} CK_C_INITIALIZE_ARGS;
+
+// CK_FUNCTION_LIST holds the functions of a PKCS#11 module,
+// in the order fixed by the standard. Only the functions
+// used here have types, and those after C_Sign are omitted.
+typedef struct {
+	CK_VERSION version;
+	CK_RV (*C_Initialize)(void *);
+	CK_RV (*C_Finalize)(void *);
+	void *C_GetInfo;
+	void *C_GetFunctionList;
+	CK_RV (*C_GetSlotList)(CK_BYTE, CK_ULONG *, CK_ULONG *);
+	void *C_GetSlotInfo;
+	CK_RV (*C_GetTokenInfo)(CK_ULONG, void *);
+	void *C_GetMechanismList;
+	void *C_GetMechanismInfo;
+	void *C_InitToken;
+	void *C_InitPIN;
+	void *C_SetPIN;
+	CK_RV (*C_OpenSession)(CK_ULONG, CK_ULONG, void *, void *, CK_ULONG *);
+	CK_RV (*C_CloseSession)(CK_ULONG);
+	void *C_CloseAllSessions;
+	void *C_GetSessionInfo;
+	void *C_GetOperationState;
+	void *C_SetOperationState;
+	CK_RV (*C_Login)(CK_ULONG, CK_ULONG, CK_BYTE *, CK_ULONG);
+	void *C_Logout;
+	CK_RV (*C_CreateObject)(CK_ULONG, CK_ATTRIBUTE *, CK_ULONG, CK_ULONG *);
+	void *C_CopyObject;
+	CK_RV (*C_DestroyObject)(CK_ULONG, CK_ULONG);
+	void *C_GetObjectSize;
+	CK_RV (*C_GetAttributeValue)(CK_ULONG, CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	void *C_SetAttributeValue;
+	CK_RV (*C_FindObjectsInit)(CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*C_FindObjects)(CK_ULONG, CK_ULONG *, CK_ULONG, CK_ULONG *);
+	CK_RV (*C_FindObjectsFinal)(CK_ULONG);
+	void *C_EncryptInit;
+	void *C_Encrypt;
+	void *C_EncryptUpdate;
+	void *C_EncryptFinal;
+	void *C_DecryptInit;
+	void *C_Decrypt;
+	void *C_DecryptUpdate;
+	void *C_DecryptFinal;
+	void *C_DigestInit;
+	void *C_Digest;
+	void *C_DigestUpdate;
+	void *C_DigestKey;
+	void *C_DigestFinal;
+	CK_RV (*C_SignInit)(CK_ULONG, CK_MECHANISM *, CK_ULONG);
+	CK_RV (*C_Sign)(CK_ULONG, CK_BYTE *, CK_ULONG, CK_BYTE *, CK_ULONG *);
+} CK_FUNCTION_LIST;
+
+static CK_FUNCTION_LIST *load(const char *path, void **handle) {
+	CK_RV (*getFunctionList)(CK_FUNCTION_LIST **);
+	CK_FUNCTION_LIST *p = NULL;
+
+	*handle = dlopen(path, RTLD_NOW);
+	if (*handle == NULL) {
+		return NULL;
+	}
+	getFunctionList = dlsym(*handle, "C_GetFunctionList");
+	if (getFunctionList == NULL || getFunctionList(&p) != 0) {
+		return NULL;
+	}
+	return p;
+}
+
+static void unload(void *handle) {
+	dlclose(handle);
+}
+
+static CK_RV initialize(CK_FUNCTION_LIST *p) {
+	CK_C_INITIALIZE_ARGS args;
+	memset(&args, 0, sizeof(args));
+	args.flags = 0x2; // CKF_OS_LOCKING_OK
+	return p->C_Initialize(&args);
+}
+
+static CK_RV finalize(CK_FUNCTION_LIST *p) {
+	return p->C_Finalize(NULL);
+}
+
+static CK_RV getSlotList(CK_FUNCTION_LIST *p, CK_ULONG *slots, CK_ULONG *n) {
+	return p->C_GetSlotList(1, slots, n);
+}
+
+static CK_RV getTokenInfo(CK_FUNCTION_LIST *p, CK_ULONG slot, void *info) {
+	return p->C_GetTokenInfo(slot, info);
+}
+
+static CK_RV openSession(CK_FUNCTION_LIST *p, CK_ULONG slot, CK_ULONG flags, CK_ULONG *session) {
+	return p->C_OpenSession(slot, flags, NULL, NULL, session);
+}
+
+static CK_RV closeSession(CK_FUNCTION_LIST *p, CK_ULONG session) {
+	return p->C_CloseSession(session);
+}
+
+static CK_RV login(CK_FUNCTION_LIST *p, CK_ULONG session, CK_BYTE *pin, CK_ULONG n) {
+	return p->C_Login(session, 1, pin, n); // CKU_USER
+}
+
+static CK_RV createObject(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ATTRIBUTE *t, CK_ULONG n, CK_ULONG *obj) {
+	return p->C_CreateObject(session, t, n, obj);
+}
+
+static CK_RV destroyObject(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ULONG obj) {
+	return p->C_DestroyObject(session, obj);
+}
+
+static CK_RV getAttributeValue(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ULONG obj, CK_ATTRIBUTE *t) {
+	return p->C_GetAttributeValue(session, obj, t, 1);
+}
+
+static CK_RV findObjectsInit(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ATTRIBUTE *t, CK_ULONG n) {
+	return p->C_FindObjectsInit(session, t, n);
+}
+
+static CK_RV findObjects(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ULONG *objs, CK_ULONG max, CK_ULONG *n) {
+	return p->C_FindObjects(session, objs, max, n);
+}
+
+static CK_RV findObjectsFinal(CK_FUNCTION_LIST *p, CK_ULONG session) {
+	return p->C_FindObjectsFinal(session);
+}
+
+static CK_RV sign(CK_FUNCTION_LIST *p, CK_ULONG session, CK_ULONG mech, CK_ULONG key, CK_BYTE *msg, CK_ULONG n, CK_BYTE *sig, CK_ULONG *sigLen) {
+	CK_MECHANISM m = {mech, NULL, 0};
+	CK_RV rv = p->C_SignInit(session, &m, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return p->C_Sign(session, msg, n, sig, sigLen);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"chain/core/hsm"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+// Constants from the PKCS#11 standard.
+const (
+	ckrOK                         = 0x0
+	ckrAttributeTypeInvalid       = 0x12
+	ckrAttributeValueInvalid      = 0x13
+	ckrTemplateInconsistent       = 0xd1
+	ckrUserAlreadyLoggedIn        = 0x100
+	ckrCryptokiAlreadyInitialized = 0x191
+	ckfRWSession                  = 0x2
+	ckfSerialSession              = 0x4
+	ckoPublicKey                  = 0x2
+	ckoPrivateKey                 = 0x3
+	ckoSecretKey                  = 0x4
+	ckaClass                      = 0x0
+	ckaToken                      = 0x1
+	ckaPrivate                    = 0x2
+	ckaLabel                      = 0x3
+	ckaValue                      = 0x11
+	ckaKeyType                    = 0x100
+	ckaID                         = 0x102
+	ckaSensitive                  = 0x103
+	ckaExtractable                = 0x162
+	ckaECPoint                    = 0x181
+	ckkGenericSecret              = 0x10
+	ckkECEdwards                  = 0x40
+	ckmEdDSA                      = 0x1057
+)
+
+const (
+	tokenInfoSize      = 512 // more than sizeof(CK_TOKEN_INFO)
+	tokenLabelSize     = 32
+	findBatchSize      = 64
+	listKeyMaxAliases  = 200
+	xkeyIDPrefix       = "chainkd:"
+	xpubSize           = 64
+	xprvSize           = 64
+	ed25519PointPrefix = "\x04\x20" // DER OCTET STRING of 32 bytes
+)
+
+// Error is an error code returned by a PKCS#11 module.
+type Error uint
+
+func (e Error) Error() string {
+	return fmt.Sprintf("pkcs11: error 0x%x", uint(e))
+}
+
+func rvErr(rv C.CK_RV) error {
+	if rv == ckrOK {
+		return nil
+	}
+	return Error(rv)
+}
+
+// Config configures the connection to a PKCS#11 token.
+type Config struct {
+	Module     string // path of the token's PKCS#11 module
+	TokenLabel string
+	PIN        string
+}
+
+// HSM is an hsm.HSM that keeps keys in a PKCS#11 token.
+type HSM struct {
+	mu      sync.Mutex // serializes use of the session
+	handle  unsafe.Pointer
+	p       *C.CK_FUNCTION_LIST
+	session C.CK_ULONG
+	kdCache map[chainkd.XPub]chainkd.XPrv
+}
+
+var _ hsm.HSM = (*HSM)(nil)
+
+// New loads the PKCS#11 module c.Module, and logs in to
+// the token labeled c.TokenLabel with c.PIN.
+func New(c Config) (*HSM, error) {
+	h := &HSM{kdCache: make(map[chainkd.XPub]chainkd.XPrv)}
+
+	path := C.CString(c.Module)
+	defer C.free(unsafe.Pointer(path))
+	h.p = C.load(path, &h.handle)
+	if h.p == nil {
+		if h.handle != nil {
+			C.unload(h.handle)
+		}
+		return nil, fmt.Errorf("pkcs11: can't load module %s", c.Module)
+	}
+
+	rv := C.initialize(h.p)
+	if rv != ckrOK && rv != ckrCryptokiAlreadyInitialized {
+		C.unload(h.handle)
+		return nil, errors.Wrap(rvErr(rv), "initializing module")
+	}
+
+	err := h.open(c.TokenLabel, c.PIN)
+	if err != nil {
+		C.finalize(h.p)
+		C.unload(h.handle)
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *HSM) open(label, pin string) error {
+	var n C.CK_ULONG
+	rv := C.getSlotList(h.p, nil, &n)
+	if rv != ckrOK {
+		return errors.Wrap(rvErr(rv), "listing slots")
+	}
+	if n == 0 {
+		return errors.New("pkcs11: no tokens present")
+	}
+	slots := (*C.CK_ULONG)(C.malloc(C.size_t(n) * C.sizeof_CK_ULONG))
+	defer C.free(unsafe.Pointer(slots))
+	rv = C.getSlotList(h.p, slots, &n)
+	if rv != ckrOK {
+		return errors.Wrap(rvErr(rv), "listing slots")
+	}
+
+	info := C.malloc(tokenInfoSize)
+	defer C.free(info)
+	for _, slot := range (*[1 << 20]C.CK_ULONG)(unsafe.Pointer(slots))[:n:n] {
+		rv = C.getTokenInfo(h.p, slot, info)
+		if rv != ckrOK {
+			return errors.Wrap(rvErr(rv), "getting token info")
+		}
+		// The label is the first field of CK_TOKEN_INFO,
+		// padded with spaces.
+		l := C.GoBytes(info, tokenLabelSize)
+		if strings.TrimRight(string(l), " ") != label {
+			continue
+		}
+
+		rv = C.openSession(h.p, slot, ckfSerialSession|ckfRWSession, &h.session)
+		if rv != ckrOK {
+			return errors.Wrap(rvErr(rv), "opening session")
+		}
+		cpin := C.CBytes([]byte(pin))
+		defer C.free(cpin)
+		rv = C.login(h.p, h.session, (*C.CK_BYTE)(cpin), C.CK_ULONG(len(pin)))
+		if rv != ckrOK && rv != ckrUserAlreadyLoggedIn {
+			C.closeSession(h.p, h.session)
+			return errors.Wrap(rvErr(rv), "logging in")
+		}
+		return nil
+	}
+	return fmt.Errorf("pkcs11: no token labeled %q", label)
+}
+
+// Close logs out of the token and unloads the module.
+func (h *HSM) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	C.closeSession(h.p, h.session)
+	rv := C.finalize(h.p)
+	C.unload(h.handle)
+	return rvErr(rv)
+}
+
+// XCreate creates a new xprv and stores it in the token.
+func (h *HSM) XCreate(ctx context.Context, alias string) (*hsm.XPub, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if alias != "" {
+		objs, err := h.find(xkeyTemplate(attribute{ckaLabel, []byte(alias)}))
+		if err != nil {
+			return nil, err
+		}
+		if len(objs) > 0 {
+			return nil, errors.WithDetailf(hsm.ErrDuplicateKeyAlias, "value: %q", alias)
+		}
+	}
+
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		return nil, err
+	}
+	// XSign reads the xprv back from the token, so it can't be
+	// sensitive. It is made unextractable, so that it can't be
+	// wrapped and taken out of the token, if the token allows it.
+	t := xkeyTemplate(
+		attribute{ckaID, xkeyID(xpub)},
+		attribute{ckaToken, []byte{1}},
+		attribute{ckaPrivate, []byte{1}},
+		attribute{ckaSensitive, []byte{0}},
+		attribute{ckaLabel, []byte(alias)},
+		attribute{ckaValue, xprv.Bytes()},
+	)
+	rv := h.createObject(append(t, attribute{ckaExtractable, []byte{0}}))
+	switch rv {
+	case ckrAttributeTypeInvalid, ckrAttributeValueInvalid, ckrTemplateInconsistent:
+		rv = h.createObject(t)
+	}
+	if rv != ckrOK {
+		return nil, errors.Wrap(rvErr(rv), "storing xprv")
+	}
+	h.kdCache[xpub] = xprv
+
+	result := &hsm.XPub{XPub: xpub}
+	if alias != "" {
+		result.Alias = &alias
+	}
+	return result, nil
+}
+
+// ListKeys returns the xpubs of the xprvs in the token,
+// ordered by xpub.
+func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*hsm.XPub, string, error) {
+	if len(aliases) > listKeyMaxAliases {
+		return nil, "", errors.WithDetailf(hsm.ErrTooManyAliasesToList, "max: %d", listKeyMaxAliases)
+	}
+	if after != "" {
+		var x chainkd.XPub
+		if x.UnmarshalText([]byte(after)) != nil {
+			return nil, "", errors.WithDetailf(hsm.ErrInvalidAfter, "value: %q", after)
+		}
+	}
+
+	h.mu.Lock()
+	objs, err := h.find(xkeyTemplate())
+	var xpubs []*hsm.XPub
+	for _, obj := range objs {
+		if err != nil {
+			break
+		}
+		var label, id []byte
+		label, err = h.attribute(obj, ckaLabel)
+		if err != nil {
+			break
+		}
+		id, err = h.attribute(obj, ckaID)
+		if err != nil {
+			break
+		}
+		if len(id) != len(xkeyIDPrefix)+xpubSize || !bytes.HasPrefix(id, []byte(xkeyIDPrefix)) {
+			continue
+		}
+		xpub := &hsm.XPub{}
+		copy(xpub.XPub[:], id[len(xkeyIDPrefix):])
+		if len(label) > 0 {
+			alias := string(label)
+			xpub.Alias = &alias
+		}
+		if hasAlias(xpub, aliases) && xpub.XPub.String() > after {
+			xpubs = append(xpubs, xpub)
+		}
+	}
+	h.mu.Unlock()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing keys")
+	}
+
+	sort.Sort(byXPub(xpubs))
+	if len(xpubs) > limit {
+		xpubs = xpubs[:limit]
+	}
+	if len(xpubs) > 0 {
+		after = xpubs[len(xpubs)-1].XPub.String()
+	}
+	return xpubs, after, nil
+}
+
+func hasAlias(xpub *hsm.XPub, aliases []string) bool {
+	if len(aliases) == 0 {
+		return true
+	}
+	for _, a := range aliases {
+		if xpub.Alias != nil && *xpub.Alias == a {
+			return true
+		}
+	}
+	return false
+}
+
+type byXPub []*hsm.XPub
+
+func (a byXPub) Len() int           { return len(a) }
+func (a byXPub) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byXPub) Less(i, j int) bool { return bytes.Compare(a[i].XPub[:], a[j].XPub[:]) < 0 }
+
+// XSign signs msg with the xprv of xpub, derived with path.
+func (h *HSM) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	xprv, ok := h.kdCache[xpub]
+	if !ok {
+		obj, err := h.findXKey(xpub)
+		if err != nil {
+			return nil, err
+		}
+		value, err := h.attribute(obj, ckaValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading xprv")
+		}
+		if len(value) != xprvSize {
+			zero(value)
+			return nil, fmt.Errorf("pkcs11: xprv of %s has %d bytes", xpub, len(value))
+		}
+		copy(xprv[:], value)
+		zero(value)
+		h.kdCache[xpub] = xprv
+	}
+	if len(path) > 0 {
+		xprv = xprv.Derive(path)
+	}
+	return xprv.Sign(msg), nil
+}
+
+// Delete deletes the xprv of xpub from the token.
+func (h *HSM) Delete(ctx context.Context, xpub chainkd.XPub) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.kdCache, xpub)
+	obj, err := h.findXKey(xpub)
+	if errors.Root(err) == hsm.ErrNoKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return errors.Wrap(rvErr(C.destroyObject(h.p, h.session, obj)), "deleting xprv")
+}
+
+// findXKey returns the secret key object holding the xprv of xpub.
+func (h *HSM) findXKey(xpub chainkd.XPub) (C.CK_ULONG, error) {
+	objs, err := h.find(xkeyTemplate(attribute{ckaID, xkeyID(xpub)}))
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) == 0 {
+		return 0, hsm.ErrNoKey
+	}
+	return objs[0], nil
+}
+
+// Sign signs msg with the Ed25519 private key of pub,
+// using the token's EdDSA mechanism.
+func (h *HSM) Sign(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key, err := h.findEd25519Key(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	cmsg := C.CBytes(msg)
+	defer C.free(cmsg)
+	sig := C.malloc(ed25519.SignatureSize)
+	defer C.free(sig)
+	n := C.CK_ULONG(ed25519.SignatureSize)
+	rv := C.sign(h.p, h.session, ckmEdDSA, key, (*C.CK_BYTE)(cmsg), C.CK_ULONG(len(msg)), (*C.CK_BYTE)(sig), &n)
+	if rv != ckrOK {
+		return nil, errors.Wrap(rvErr(rv), "signing")
+	}
+	return C.GoBytes(sig, C.int(n)), nil
+}
+
+// findEd25519Key returns the private key object paired,
+// by CKA_ID, with the public key object for pub.
+func (h *HSM) findEd25519Key(pub ed25519.PublicKey) (C.CK_ULONG, error) {
+	pubs, err := h.find([]attribute{
+		{ckaClass, ulong(ckoPublicKey)},
+		{ckaKeyType, ulong(ckkECEdwards)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, obj := range pubs {
+		point, err := h.attribute(obj, ckaECPoint)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(point, pub) && !bytes.Equal(point, append([]byte(ed25519PointPrefix), pub...)) {
+			continue
+		}
+		id, err := h.attribute(obj, ckaID)
+		if err != nil {
+			return 0, err
+		}
+		prvs, err := h.find([]attribute{
+			{ckaClass, ulong(ckoPrivateKey)},
+			{ckaKeyType, ulong(ckkECEdwards)},
+			{ckaID, id},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(prvs) > 0 {
+			return prvs[0], nil
+		}
+	}
+	return 0, errors.WithDetailf(hsm.ErrNoKey, "pub: %x", []byte(pub))
+}
+
+type attribute struct {
+	typ   uint
+	value []byte
+}
+
+// xkeyTemplate returns a template matching the generic secret
+// key objects that hold xprvs, with the given attributes.
+func xkeyTemplate(attrs ...attribute) []attribute {
+	return append([]attribute{
+		{ckaClass, ulong(ckoSecretKey)},
+		{ckaKeyType, ulong(ckkGenericSecret)},
+	}, attrs...)
+}
+
+// xkeyID returns the CKA_ID of the object holding the xprv of xpub.
+func xkeyID(xpub chainkd.XPub) []byte {
+	return append([]byte(xkeyIDPrefix), xpub[:]...)
+}
+
+// ulong returns the encoding of v as a CK_ULONG attribute value.
+func ulong(v uint) []byte {
+	b := make([]byte, C.sizeof_CK_ULONG)
+	*(*C.CK_ULONG)(unsafe.Pointer(&b[0])) = C.CK_ULONG(v)
+	return b
+}
+
+// cTemplate copies attrs to C memory, as the module
+// may not be given pointers to Go memory holding
+// pointers. The returned function frees the copy.
+func cTemplate(attrs []attribute) (*C.CK_ATTRIBUTE, C.CK_ULONG, func()) {
+	n := len(attrs)
+	if n == 0 {
+		return nil, 0, func() {}
+	}
+	p := (*C.CK_ATTRIBUTE)(C.malloc(C.size_t(n) * C.sizeof_CK_ATTRIBUTE))
+	t := (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(p))[:n:n]
+	for i, a := range attrs {
+		t[i]._type = C.CK_ULONG(a.typ)
+		t[i].pValue = nil
+		t[i].ulValueLen = C.CK_ULONG(len(a.value))
+		if len(a.value) > 0 {
+			t[i].pValue = C.CBytes(a.value)
+		}
+	}
+	return p, C.CK_ULONG(n), func() {
+		for i := range t {
+			if t[i].pValue != nil {
+				C.memset(t[i].pValue, 0, C.size_t(t[i].ulValueLen))
+				C.free(t[i].pValue)
+			}
+		}
+		C.free(unsafe.Pointer(p))
+	}
+}
+
+// createObject creates an object with the given attributes.
+func (h *HSM) createObject(attrs []attribute) C.CK_RV {
+	tmpl, n, free := cTemplate(attrs)
+	defer free()
+	var obj C.CK_ULONG
+	return C.createObject(h.p, h.session, tmpl, n, &obj)
+}
+
+// find returns the objects matching the template.
+func (h *HSM) find(attrs []attribute) ([]C.CK_ULONG, error) {
+	tmpl, n, free := cTemplate(attrs)
+	defer free()
+	rv := C.findObjectsInit(h.p, h.session, tmpl, n)
+	if rv != ckrOK {
+		return nil, errors.Wrap(rvErr(rv), "finding objects")
+	}
+	defer C.findObjectsFinal(h.p, h.session)
+
+	buf := (*C.CK_ULONG)(C.malloc(findBatchSize * C.sizeof_CK_ULONG))
+	defer C.free(unsafe.Pointer(buf))
+	var objs []C.CK_ULONG
+	for {
+		var found C.CK_ULONG
+		rv = C.findObjects(h.p, h.session, buf, findBatchSize, &found)
+		if rv != ckrOK {
+			return nil, errors.Wrap(rvErr(rv), "finding objects")
+		}
+		if found == 0 {
+			return objs, nil
+		}
+		objs = append(objs, (*[findBatchSize]C.CK_ULONG)(unsafe.Pointer(buf))[:found]...)
+	}
+}
+
+// attribute returns the value of an attribute of obj.
+func (h *HSM) attribute(obj C.CK_ULONG, typ uint) ([]byte, error) {
+	var a C.CK_ATTRIBUTE
+	a._type = C.CK_ULONG(typ)
+	pa := (*C.CK_ATTRIBUTE)(C.malloc(C.sizeof_CK_ATTRIBUTE))
+	defer C.free(unsafe.Pointer(pa))
+	*pa = a
+	rv := C.getAttributeValue(h.p, h.session, obj, pa)
+	if rv != ckrOK {
+		return nil, errors.Wrap(rvErr(rv), "getting attribute size")
+	}
+	if pa.ulValueLen == 0 {
+		return nil, nil
+	}
+	n := pa.ulValueLen
+	pa.pValue = C.malloc(C.size_t(n))
+	defer func() {
+		C.memset(pa.pValue, 0, C.size_t(n))
+		C.free(pa.pValue)
+	}()
+	rv = C.getAttributeValue(h.p, h.session, obj, pa)
+	if rv != ckrOK {
+		return nil, errors.Wrap(rvErr(rv), "getting attribute")
+	}
+	return C.GoBytes(pa.pValue, C.int(pa.ulValueLen)), nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,123 @@
+//+build pkcs11
+
+package pkcs11
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"chain/core/hsm"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+// newTestHSM connects to the token given by the environment,
+// such as a SoftHSM token created with
+//
+//	softhsm2-util --init-token --free --label test --pin 1234 --so-pin 1234
+//
+// and PKCS11_TEST_MODULE=/usr/lib/softhsm/libsofthsm2.so.
+func newTestHSM(t *testing.T) *HSM {
+	module := os.Getenv("PKCS11_TEST_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_TEST_MODULE not set")
+	}
+	h, err := New(Config{
+		Module:     module,
+		TokenLabel: os.Getenv("PKCS11_TEST_TOKEN_LABEL"),
+		PIN:        os.Getenv("PKCS11_TEST_PIN"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestChainKDKeys(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHSM(t)
+	defer h.Close()
+
+	xpub, err := h.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Delete(ctx, xpub.XPub)
+	xpub2, err := h.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Delete(ctx, xpub2.XPub)
+
+	// Sign with keys read back from the token.
+	h.kdCache = make(map[chainkd.XPub]chainkd.XPrv)
+
+	msg := []byte("In the face of ignorance and resistance I wrote financial systems into existence")
+	sig, err := h.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("expected verify to succeed")
+	}
+	if xpub2.XPub.Verify(msg, sig) {
+		t.Error("expected verify with wrong pubkey to fail")
+	}
+	path := [][]byte{{3, 2, 6, 3, 8, 2, 7}}
+	sig, err = h.XSign(ctx, xpub2.XPub, path, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub2.XPub.Derive(path).Verify(msg, sig) {
+		t.Error("expected verify with derived pubkey of sig from derived privkey to succeed")
+	}
+
+	xpubs, _, err := h.ListKeys(ctx, nil, "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, x := range xpubs {
+		if x.XPub == xpub.XPub || x.XPub == xpub2.XPub {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("listed %d of the 2 new keys", found)
+	}
+
+	err = h.Delete(ctx, xpub.XPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != hsm.ErrNoKey {
+		t.Errorf("XSign after Delete error = %v want %v", err, hsm.ErrNoKey)
+	}
+}
+
+func TestKeyWithAlias(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHSM(t)
+	defer h.Close()
+
+	xpub, err := h.XCreate(ctx, "pkcs11-test-alias")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Delete(ctx, xpub.XPub)
+
+	_, err = h.XCreate(ctx, "pkcs11-test-alias")
+	if errors.Root(err) != hsm.ErrDuplicateKeyAlias {
+		t.Errorf("error = %v want %v", err, hsm.ErrDuplicateKeyAlias)
+	}
+
+	xpubs, _, err := h.ListKeys(ctx, []string{"pkcs11-test-alias"}, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 || xpubs[0].XPub != xpub.XPub || *xpubs[0].Alias != "pkcs11-test-alias" {
+		t.Errorf("ListKeys = %v, want only %v", xpubs, xpub)
+	}
+}
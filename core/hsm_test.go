@@ -2,18 +2,24 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/config"
 	"chain/core/coretest"
+	"chain/core/leader"
 	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
+	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -83,7 +89,7 @@ func TestMockHSM(t *testing.T) {
 	}
 
 	h := &Handler{HSM: mockhsm}
-	outTmpls := h.mockhsmSignTemplates(ctx, struct {
+	outTmpls := h.hsmSignTemplates(ctx, struct {
 		Txs   []*txbuilder.Template `json:"transactions"`
 		XPubs []string              `json:"xpubs"`
 	}{[]*txbuilder.Template{tmpl}, []string{xpub1.XPub.String()}})
@@ -123,3 +129,48 @@ func inspectSigInst(t *testing.T, si *txbuilder.SigningInstruction, expectSig bo
 		}
 	}
 }
+
+func TestHSMUnlockForwardsToLeader(t *testing.T) {
+	if leader.IsLeading() {
+		t.Skip("this process is the leader")
+	}
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	keys := mockhsm.New(db)
+	err := keys.ChangePassphrase(ctx, "", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var forwarded string
+	leaderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var in struct{ Passphrase string }
+		err := json.NewDecoder(req.Body).Decode(&in)
+		if err != nil || req.URL.Path != "/hsm/unlock" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		forwarded = in.Passphrase
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer leaderServer.Close()
+	const q = `INSERT INTO leader (leader_key, address, expiry) VALUES ('other', $1, now() + '1 minute'::interval)`
+	_, err = db.Exec(ctx, q, leaderServer.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{DB: db, HSM: keys, Config: new(config.Config), Addr: ":1999"}
+	ctx = httpjson.WithRequest(ctx, httptest.NewRequest("POST", "/hsm/unlock", nil))
+	err = h.hsmUnlock(ctx, struct{ Passphrase string }{"passphrase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwarded != "passphrase" {
+		t.Errorf("leader got passphrase %q, want %q", forwarded, "passphrase")
+	}
+	_, err = keys.XCreate(ctx, "")
+	if err != nil {
+		t.Errorf("XCreate after unlock: %v", err)
+	}
+}
@@ -72,4 +72,98 @@ var migrations = []migration{
 			ALTER COLUMN tx_id SET DATA TYPE bytea USING decode(tx_id,'hex');
 		ALTER TABLE submitted_txs RENAME COLUMN tx_id TO tx_hash;
 	`},
+	{Name: "2016-12-01.0.txfeed.webhooks.sql", SQL: `
+		ALTER TABLE txfeeds
+			ADD COLUMN webhook_url text,
+			ADD COLUMN webhook_secret text,
+			ADD COLUMN webhook_attempts integer DEFAULT 0 NOT NULL,
+			ADD COLUMN webhook_last_error text;
+		CREATE SEQUENCE txfeed_dead_letters_id_seq;
+		CREATE TABLE txfeed_dead_letters (
+			id bigint DEFAULT nextval('txfeed_dead_letters_id_seq'::regclass) NOT NULL PRIMARY KEY,
+			feed_id text NOT NULL,
+			tx_id text NOT NULL,
+			after text NOT NULL,
+			payload jsonb NOT NULL,
+			attempts integer NOT NULL,
+			last_error text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX txfeed_dead_letters_feed_id_id_idx ON txfeed_dead_letters USING btree (feed_id, id);
+	`},
+	{Name: "2016-12-02.0.account.reservations.sql", SQL: `
+		CREATE TABLE reservations (
+			reservation_id bigint DEFAULT nextval('reservation_seq'::regclass) NOT NULL PRIMARY KEY,
+			account_id text NOT NULL,
+			asset_id text NOT NULL,
+			change bigint DEFAULT 0 NOT NULL,
+			expiry timestamp with time zone NOT NULL,
+			client_token text UNIQUE
+		);
+		CREATE INDEX reservations_expiry_idx ON reservations USING btree (expiry);
+		CREATE TABLE reserved_utxos (
+			tx_hash text NOT NULL,
+			index integer NOT NULL,
+			reservation_id bigint NOT NULL,
+			account_id text NOT NULL,
+			asset_id text NOT NULL,
+			expiry timestamp with time zone NOT NULL,
+			PRIMARY KEY (tx_hash, index)
+		);
+		CREATE INDEX reserved_utxos_reservation_id_idx ON reserved_utxos USING btree (reservation_id);
+		CREATE INDEX reserved_utxos_account_id_asset_id_idx ON reserved_utxos USING btree (account_id, asset_id);
+	`},
+	{Name: "2016-12-05.0.account.reservation-amount.sql", SQL: `
+		ALTER TABLE reservations ADD COLUMN amount bigint DEFAULT 0 NOT NULL;
+	`},
+	{Name: "2016-12-06.0.txdb.state-tree-nodes.sql", SQL: `
+		CREATE TABLE state_tree_nodes (
+			id bytea NOT NULL PRIMARY KEY,
+			data bytea NOT NULL,
+			left_id bytea,
+			right_id bytea
+		);
+		ALTER TABLE snapshots ADD COLUMN tree_root bytea;
+	`},
+	{Name: "2016-12-07.0.txdb.state-deltas.sql", SQL: `
+		CREATE TABLE state_deltas (
+			height bigint NOT NULL PRIMARY KEY,
+			data bytea NOT NULL
+		);
+	`},
+	{Name: "2016-12-08.0.mockhsm.passphrase.sql", SQL: `
+		ALTER TABLE mockhsm ADD COLUMN encrypted boolean DEFAULT false NOT NULL;
+		CREATE TABLE mockhsm_passphrase (
+			singleton boolean DEFAULT true NOT NULL PRIMARY KEY,
+			salt bytea NOT NULL,
+			n integer NOT NULL,
+			r integer NOT NULL,
+			p integer NOT NULL,
+			data_key bytea NOT NULL,
+			CONSTRAINT mockhsm_passphrase_singleton CHECK (singleton)
+		);
+	`},
+	{Name: "2016-12-09.0.core.signing-policies.sql", SQL: `
+		CREATE TABLE signing_policies (
+			id text DEFAULT next_chain_id('sp'::text) NOT NULL PRIMARY KEY,
+			alias text UNIQUE,
+			xpub text,
+			account_id text,
+			rules jsonb NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX signing_policies_xpub_idx ON signing_policies USING btree (xpub);
+		CREATE INDEX signing_policies_account_id_idx ON signing_policies USING btree (account_id);
+		CREATE TABLE signing_policy_spends (
+			policy_id text NOT NULL,
+			asset_id text NOT NULL,
+			tx_hash text NOT NULL,
+			amount bigint NOT NULL,
+			signed_at timestamp with time zone NOT NULL,
+			PRIMARY KEY (policy_id, asset_id, tx_hash)
+		);
+	`},
+	{Name: "2016-12-10.0.txdb.state-tree-node-heights.sql", SQL: `
+		ALTER TABLE state_tree_nodes ADD COLUMN height bigint DEFAULT 0 NOT NULL;
+	`},
 }
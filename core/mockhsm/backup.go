@@ -0,0 +1,332 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+
+	"chain/core/hsm"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// A key backup, as made by ExportKeys, is
+//
+//	magic     8 bytes, "chainkey"
+//	version   1 byte, backupVersion
+//	logN      1 byte, log2 of the scrypt parameter N
+//	r, p      1 byte each, the other scrypt parameters
+//	salt      16 bytes, the scrypt salt
+//	sealed    the JSON backupData, encrypted with the key
+//	          derived from the passphrase as by seal, with
+//	          the bytes above as additional data
+//	checksum  32 bytes, the SHA3-256 hash of the bytes above
+//
+// The checksum detects corruption before decryption is
+// tried, so that it can be told apart from a wrong passphrase.
+
+const (
+	backupMagic   = "chainkey"
+	backupVersion = 1
+
+	backupLogN = 15 // scryptN
+	saltSize   = 16
+
+	backupHeaderSize = len(backupMagic) + 4 + saltSize
+	checksumSize     = 32
+)
+
+type backupData struct {
+	Keys []backupKey `json:"keys"`
+}
+
+type backupKey struct {
+	Type  string             `json:"type"`
+	Alias *string            `json:"alias,omitempty"`
+	Pub   chainjson.HexBytes `json:"pub"`
+	Prv   chainjson.HexBytes `json:"prv"`
+}
+
+// ExportKeys returns a backup of the keys with the given aliases
+// or xpubs, or of all keys if none are given, encrypted with
+// passphrase. The backup includes Ed25519 keys, such as
+// block-signing keys, selected by alias.
+//
+// A backup takes the keys out from under h's passphrase, so if
+// one is set, hsmPassphrase must be it, even while h is unlocked.
+func (h *HSM) ExportKeys(ctx context.Context, aliases []string, xpubs []chainkd.XPub, hsmPassphrase, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.WithDetail(hsm.ErrBadPassphrase, "backup passphrase is empty")
+	}
+	aead, err := h.exportAEAD(ctx, hsmPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	pubs := make([][]byte, 0, len(xpubs))
+	for _, xpub := range xpubs {
+		pubs = append(pubs, xpub.Bytes())
+	}
+	const q = `
+		SELECT pub, prv, alias, key_type, encrypted FROM mockhsm
+		WHERE $1 OR alias=ANY($2::text[]) OR (key_type='chain_kd' AND pub=ANY($3::bytea[]))
+		ORDER BY sort_id
+	`
+	all := len(aliases) == 0 && len(xpubs) == 0
+	var data backupData
+	err = pg.ForQueryRows(ctx, h.db, q, all, pq.StringArray(aliases), pq.ByteaArray(pubs), func(pub, prv []byte, alias sql.NullString, typ string, encrypted bool) error {
+		if encrypted {
+			var err error
+			prv, err = open(aead, pub, prv)
+			if err != nil {
+				return errors.Wrap(err, "decrypting key")
+			}
+		}
+		k := backupKey{Type: typ, Pub: pub, Prv: prv}
+		if alias.Valid {
+			k.Alias = &alias.String
+		}
+		data.Keys = append(data.Keys, k)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading keys")
+	}
+
+	err = checkExported(data.Keys, aliases, xpubs)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return sealBackup(plaintext, passphrase)
+}
+
+// exportAEAD returns the cipher that decrypts h's keys, keyed
+// with the data key opened with hsmPassphrase. It returns nil
+// if no passphrase is set.
+func (h *HSM) exportAEAD(ctx context.Context, hsmPassphrase string) (cipher.AEAD, error) {
+	p, err := h.loadPassphrase(ctx)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	dataKey, err := p.open(hsmPassphrase)
+	if err != nil {
+		return nil, errors.WithDetail(err, "exporting keys requires the HSM passphrase")
+	}
+	return newAEAD(dataKey)
+}
+
+// checkExported returns an error if keys don't
+// include one with each of aliases and xpubs.
+func checkExported(keys []backupKey, aliases []string, xpubs []chainkd.XPub) error {
+	found := make(map[string]bool)
+	for _, k := range keys {
+		found[string(k.Pub)] = true
+		if k.Alias != nil {
+			found["alias:"+*k.Alias] = true
+		}
+	}
+	for _, alias := range aliases {
+		if !found["alias:"+alias] {
+			return errors.WithDetailf(pg.ErrUserInputNotFound, "no key with alias %q", alias)
+		}
+	}
+	for _, xpub := range xpubs {
+		if !found[string(xpub.Bytes())] {
+			return errors.WithDetailf(pg.ErrUserInputNotFound, "no key with xpub %s", xpub)
+		}
+	}
+	return nil
+}
+
+// ImportKeys stores the keys in backup, made by ExportKeys with
+// passphrase, and returns the xpubs of its chainkd keys. Keys h
+// already has are left as they are. If any key can't be stored,
+// none are.
+func (h *HSM) ImportKeys(ctx context.Context, backup []byte, passphrase string) ([]*hsm.XPub, error) {
+	plaintext, err := openBackup(backup, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var data backupData
+	err = json.Unmarshal(plaintext, &data)
+	if err != nil {
+		return nil, errors.WithDetail(hsm.ErrBadBackup, "invalid key data")
+	}
+	for _, k := range data.Keys {
+		err = k.check()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		pubs, prvs     pq.ByteaArray
+		aliases, types pq.StringArray
+		encrypted      pq.BoolArray
+		xpubs          []*hsm.XPub
+	)
+	for _, k := range data.Keys {
+		stored, enc, err := h.sealKey(ctx, k.Pub, k.Prv)
+		if err != nil {
+			return nil, err
+		}
+		var alias string
+		if k.Alias != nil {
+			alias = *k.Alias
+		}
+		pubs = append(pubs, k.Pub)
+		prvs = append(prvs, stored)
+		aliases = append(aliases, alias)
+		types = append(types, k.Type)
+		encrypted = append(encrypted, enc)
+		if k.Type == "chain_kd" {
+			xpub := &hsm.XPub{Alias: k.Alias}
+			copy(xpub.XPub[:], k.Pub)
+			xpubs = append(xpubs, xpub)
+		}
+	}
+
+	// One statement stores all the keys or none. Keys already
+	// stored are kept, and are returned with their stored
+	// aliases, as XRestore does. The SELECT sees only those
+	// keys, not the ones the statement inserts.
+	const q = `
+		WITH inserted AS (
+			INSERT INTO mockhsm (pub, prv, alias, key_type, encrypted)
+			SELECT pub, prv, NULLIF(alias, ''), key_type, encrypted
+			FROM unnest($1::bytea[], $2::bytea[], $3::text[], $4::text[], $5::boolean[])
+				AS u(pub, prv, alias, key_type, encrypted)
+			ON CONFLICT (pub) DO NOTHING
+		)
+		SELECT pub, alias FROM mockhsm
+		WHERE pub=ANY($1::bytea[]) AND key_type='chain_kd'
+	`
+	existing := make(map[string]*string)
+	err = pg.ForQueryRows(ctx, h.db, q, pubs, prvs, aliases, types, encrypted, func(pub []byte, alias sql.NullString) {
+		existing[string(pub)] = nil
+		if alias.Valid {
+			existing[string(pub)] = &alias.String
+		}
+	})
+	if pg.IsUniqueViolation(errors.Root(err)) {
+		return nil, errors.WithDetail(ErrDuplicateKeyAlias, "a key in the backup has an alias already in use")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "storing keys")
+	}
+	for _, xpub := range xpubs {
+		if alias, ok := existing[string(xpub.XPub.Bytes())]; ok {
+			xpub.Alias = alias
+		}
+	}
+	return xpubs, nil
+}
+
+// check returns ErrBadBackup if k's private
+// key doesn't match its public key.
+func (k *backupKey) check() error {
+	var ok bool
+	switch k.Type {
+	case "chain_kd":
+		var xprv chainkd.XPrv
+		if len(k.Prv) == len(xprv) {
+			copy(xprv[:], k.Prv)
+			xpub := xprv.XPub()
+			ok = bytes.Equal(xpub.Bytes(), k.Pub)
+		}
+	case "ed25519":
+		if len(k.Prv) == ed25519.PrivateKeySize {
+			pub := ed25519.PrivateKey(k.Prv).Public().(ed25519.PublicKey)
+			ok = bytes.Equal(pub, k.Pub)
+		}
+	}
+	if !ok {
+		return errors.WithDetailf(hsm.ErrBadBackup, "invalid %s key %x", k.Type, []byte(k.Pub))
+	}
+	return nil
+}
+
+// sealBackup returns a backup of plaintext,
+// encrypted with a key derived from pass.
+func sealBackup(plaintext []byte, pass string) ([]byte, error) {
+	p := &passphrase{
+		salt: make([]byte, saltSize),
+		n:    1 << backupLogN,
+		r:    scryptR,
+		p:    scryptP,
+	}
+	_, err := rand.Read(p.salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := p.aead(pass)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte(backupMagic)
+	b = append(b, backupVersion, backupLogN, byte(p.r), byte(p.p))
+	b = append(b, p.salt...)
+	sealed, err := seal(aead, b, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, sealed...)
+
+	var checksum [checksumSize]byte
+	sha3pool.Sum256(checksum[:], b)
+	return append(b, checksum[:]...), nil
+}
+
+// openBackup returns the plaintext of backup b. It returns
+// ErrBadBackup if b is corrupt or in an unknown format,
+// and ErrBadPassphrase if pass is wrong.
+func openBackup(b []byte, pass string) ([]byte, error) {
+	if len(b) < backupHeaderSize+checksumSize || string(b[:len(backupMagic)]) != backupMagic {
+		return nil, errors.WithDetail(hsm.ErrBadBackup, "not a key backup")
+	}
+	body, sum := b[:len(b)-checksumSize], b[len(b)-checksumSize:]
+	var checksum [checksumSize]byte
+	sha3pool.Sum256(checksum[:], body)
+	if !bytes.Equal(checksum[:], sum) {
+		return nil, errors.WithDetail(hsm.ErrBadBackup, "checksum mismatch")
+	}
+
+	header := body[:backupHeaderSize]
+	v := header[len(backupMagic):]
+	if v[0] != backupVersion {
+		return nil, errors.WithDetailf(hsm.ErrBadBackup, "unsupported version %d", v[0])
+	}
+	if v[1] == 0 || v[1] > 20 {
+		return nil, errors.WithDetailf(hsm.ErrBadBackup, "invalid scrypt parameter logN=%d", v[1])
+	}
+	p := &passphrase{
+		salt: header[backupHeaderSize-saltSize:],
+		n:    1 << v[1],
+		r:    int(v[2]),
+		p:    int(v[3]),
+	}
+	aead, err := p.aead(pass)
+	if err != nil {
+		return nil, errors.WithDetail(hsm.ErrBadBackup, err.Error())
+	}
+	plaintext, err := open(aead, header, body[backupHeaderSize:])
+	if err != nil {
+		return nil, hsm.ErrBadPassphrase
+	}
+	return plaintext, nil
+}
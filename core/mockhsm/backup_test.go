@@ -0,0 +1,192 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/core/hsm"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestExportImportKeys(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	h := New(db)
+	xpub, err := h.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.Create(ctx, "block_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.XCreate(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = h.ExportKeys(ctx, []string{"nonexistent"}, nil, "", "backup")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("ExportKeys with unknown alias: error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+	backup, err := h.ExportKeys(ctx, []string{"treasury", "block_key"}, nil, "", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore into another Core.
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	h2 := New(db2)
+	_, err = h2.ImportKeys(ctx, backup, "wrong")
+	if errors.Root(err) != hsm.ErrBadPassphrase {
+		t.Errorf("ImportKeys with wrong passphrase: error = %v want %v", err, hsm.ErrBadPassphrase)
+	}
+	xpubs, err := h2.ImportKeys(ctx, backup, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 || xpubs[0].XPub != xpub.XPub || *xpubs[0].Alias != "treasury" {
+		t.Fatalf("ImportKeys = %+v, want %s (treasury)", xpubs, xpub.XPub)
+	}
+	keys, _, err := h2.ListKeys(ctx, nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("got %d keys after import, want 1", len(keys))
+	}
+
+	msg := []byte("backup")
+	sig, err := h2.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("signature by imported key did not verify")
+	}
+
+	// Importing again leaves the keys as they are, and
+	// returns them with their stored aliases.
+	_, err = db2.Exec(ctx, `UPDATE mockhsm SET alias='renamed' WHERE pub=$1`, xpub.XPub.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpubs, err = h2.ImportKeys(ctx, backup, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 || xpubs[0].Alias == nil || *xpubs[0].Alias != "renamed" {
+		t.Errorf("ImportKeys of stored key = %+v, want alias renamed", xpubs)
+	}
+}
+
+func TestOpenBackup(t *testing.T) {
+	plaintext := []byte(`{"keys":[]}`)
+	backup, err := sealBackup(plaintext, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := openBackup(backup, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("openBackup = %q want %q", got, plaintext)
+	}
+
+	_, err = openBackup(backup, "wrong")
+	if errors.Root(err) != hsm.ErrBadPassphrase {
+		t.Errorf("wrong passphrase: error = %v want %v", err, hsm.ErrBadPassphrase)
+	}
+
+	corrupt := append([]byte(nil), backup...)
+	corrupt[len(corrupt)/2] ^= 1
+	_, err = openBackup(corrupt, "backup")
+	if errors.Root(err) != hsm.ErrBadBackup {
+		t.Errorf("corrupt backup: error = %v want %v", err, hsm.ErrBadBackup)
+	}
+
+	_, err = openBackup(backup[:10], "backup")
+	if errors.Root(err) != hsm.ErrBadBackup {
+		t.Errorf("truncated backup: error = %v want %v", err, hsm.ErrBadBackup)
+	}
+}
+
+func TestExportKeysPassphrase(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	h := New(db)
+	xpub, err := h.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.ChangePassphrase(ctx, "", "hsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Unlock(ctx, "hsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unlocking isn't enough to export keys.
+	for _, pass := range []string{"", "wrong"} {
+		_, err = h.ExportKeys(ctx, nil, nil, pass, "backup")
+		if errors.Root(err) != hsm.ErrBadPassphrase {
+			t.Errorf("ExportKeys with HSM passphrase %q: error = %v want %v", pass, err, hsm.ErrBadPassphrase)
+		}
+	}
+
+	h.Lock()
+	backup, err := h.ExportKeys(ctx, nil, nil, "hsm", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	xpubs, err := New(db2).ImportKeys(ctx, backup, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 || xpubs[0].XPub != xpub.XPub {
+		t.Errorf("ImportKeys = %+v, want %s", xpubs, xpub.XPub)
+	}
+}
+
+func TestImportKeysAtomic(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	h := New(db)
+	for _, alias := range []string{"a", "b", "c"} {
+		_, err := h.XCreate(ctx, alias)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	backup, err := h.ExportKeys(ctx, nil, nil, "", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second key's alias is taken, so
+	// none of the keys are imported.
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	h2 := New(db2)
+	_, err = h2.XCreate(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h2.ImportKeys(ctx, backup, "backup")
+	if errors.Root(err) != ErrDuplicateKeyAlias {
+		t.Errorf("ImportKeys with taken alias: error = %v want %v", err, ErrDuplicateKeyAlias)
+	}
+	keys, _, err := h2.ListKeys(ctx, nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("got %d keys after failed import, want 1", len(keys))
+	}
+}
@@ -0,0 +1,83 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+
+	"chain/core/hsm"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+)
+
+// mnemonicEntropyBits is the entropy of new
+// mnemonics, which have 24 words.
+const mnemonicEntropyBits = 256
+
+// XCreateMnemonic creates a chainkd key from a new random
+// BIP39 mnemonic, and returns the mnemonic, from which
+// XRestore can recreate the key in any mock HSM.
+func (h *HSM) XCreateMnemonic(ctx context.Context, alias string) (*hsm.XPub, string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, "", err
+	}
+	xprv, err := mnemonicXPrv(mnemonic)
+	if err != nil {
+		return nil, "", err
+	}
+	xpub, _, err := h.storeChainKDKey(ctx, alias, xprv, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return xpub, mnemonic, nil
+}
+
+// XRestore stores the chainkd key of a mnemonic
+// returned by XCreateMnemonic. If h already has the key,
+// XRestore returns it, with its existing alias.
+func (h *HSM) XRestore(ctx context.Context, alias, mnemonic string) (*hsm.XPub, error) {
+	xprv, err := mnemonicXPrv(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	xpub := xprv.XPub()
+
+	var existing sql.NullString
+	const q = `SELECT alias FROM mockhsm WHERE pub=$1 AND key_type='chain_kd'`
+	err = h.db.QueryRow(ctx, q, xpub.Bytes()).Scan(&existing)
+	if err == nil {
+		result := &hsm.XPub{XPub: xpub}
+		if existing.Valid {
+			result.Alias = &existing.String
+		}
+		return result, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "looking up key")
+	}
+
+	result, _, err := h.storeChainKDKey(ctx, alias, xprv, false)
+	return result, err
+}
+
+// mnemonicXPrv returns the xprv of mnemonic, derived
+// from the mnemonic's BIP39 seed with no passphrase.
+func mnemonicXPrv(mnemonic string) (xprv chainkd.XPrv, err error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return xprv, errors.WithDetail(hsm.ErrBadMnemonic, err.Error())
+	}
+	var entropy [32]byte
+	sha3pool.Sum256(entropy[:], seed)
+	return chainkd.NewXPrv(bytes.NewReader(entropy[:]))
+}
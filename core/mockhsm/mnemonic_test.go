@@ -0,0 +1,66 @@
+package mockhsm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chain/core/hsm"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestMnemonicXPrv(t *testing.T) {
+	xprv1, err := mnemonicXPrv(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xprv2, err := mnemonicXPrv("  " + strings.Replace(testMnemonic, " ", "\n ", 3) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xprv1 != xprv2 {
+		t.Error("same mnemonic gave different keys")
+	}
+
+	_, err = mnemonicXPrv(strings.Replace(testMnemonic, "about", "abandon", 1))
+	if errors.Root(err) != hsm.ErrBadMnemonic {
+		t.Errorf("bad checksum: error = %v want %v", err, hsm.ErrBadMnemonic)
+	}
+	_, err = mnemonicXPrv("not a mnemonic")
+	if errors.Root(err) != hsm.ErrBadMnemonic {
+		t.Errorf("bad words: error = %v want %v", err, hsm.ErrBadMnemonic)
+	}
+}
+
+func TestXRestore(t *testing.T) {
+	ctx := context.Background()
+	h := New(pgtest.NewTx(t))
+	xpub, mnemonic, err := h.XCreateMnemonic(ctx, "cold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(strings.Fields(mnemonic)); n != 24 {
+		t.Errorf("mnemonic has %d words, want 24", n)
+	}
+
+	h2 := New(pgtest.NewTx(t))
+	got, err := h2.XRestore(ctx, "restored", mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.XPub != xpub.XPub {
+		t.Errorf("XRestore = %s want %s", got.XPub, xpub.XPub)
+	}
+
+	// Restoring a key h already has returns it with its alias.
+	got, err = h.XRestore(ctx, "restored", mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.XPub != xpub.XPub || *got.Alias != "cold" {
+		t.Errorf("XRestore = %+v want %s (cold)", got, xpub.XPub)
+	}
+}
@@ -1,16 +1,23 @@
 // Package mockhsm provides a mock HSM for development environments.
-// It is unsafe for use in production.
+// It is unsafe for use in production: it stores private keys in the
+// Core's database. See package chain/core/hsm/pkcs11 for production.
+//
+// Once a passphrase is set with ChangePassphrase, keys are stored
+// encrypted, and the HSM must be unlocked before it can use them.
 package mockhsm
 
 import (
 	"context"
+	"crypto/cipher"
 	"database/sql"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/lib/pq"
 
+	"chain/core/hsm"
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
@@ -21,26 +28,35 @@ import (
 const listKeyMaxAliases = 200
 
 var (
-	ErrDuplicateKeyAlias    = errors.New("duplicate key alias")
-	ErrInvalidAfter         = errors.New("invalid after")
-	ErrNoKey                = errors.New("key not found")
+	ErrDuplicateKeyAlias    = hsm.ErrDuplicateKeyAlias
+	ErrInvalidAfter         = hsm.ErrInvalidAfter
+	ErrNoKey                = hsm.ErrNoKey
 	ErrInvalidKeySize       = errors.New("key invalid size")
-	ErrTooManyAliasesToList = errors.New("requested aliases exceeds limit")
+	ErrTooManyAliasesToList = hsm.ErrTooManyAliasesToList
 )
 
 type HSM struct {
 	db pg.DB
 
-	cacheMu sync.Mutex
-	kdCache map[chainkd.XPub]chainkd.XPrv
-	edCache map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
-}
+	// AutoLock, if nonzero, is how long h stays
+	// unlocked after a call to Unlock. See Unlock
+	// for the keys it keeps once it has locked.
+	AutoLock time.Duration
 
-type XPub struct {
-	Alias *string      `json:"alias"`
-	XPub  chainkd.XPub `json:"xpub"`
+	cacheMu   sync.Mutex
+	kdCache   map[chainkd.XPub]chainkd.XPrv
+	edCache   map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+	aead      cipher.AEAD                   // nil when locked
+	lockTimer *time.Timer
 }
 
+var (
+	_ hsm.Locker          = (*HSM)(nil)
+	_ hsm.Backuper        = (*HSM)(nil)
+	_ hsm.MnemonicCreator = (*HSM)(nil)
+	_ hsm.Ed25519Creator  = (*HSM)(nil)
+)
+
 type Pub struct {
 	Alias *string           `json:"alias"`
 	Pub   ed25519.PublicKey `json:"pub"`
@@ -55,23 +71,32 @@ func New(db pg.DB) *HSM {
 }
 
 // XCreate produces a new random xprv and stores it in the db.
-func (h *HSM) XCreate(ctx context.Context, alias string) (*XPub, error) {
+func (h *HSM) XCreate(ctx context.Context, alias string) (*hsm.XPub, error) {
 	xpub, _, err := h.createChainKDKey(ctx, alias, false)
 	return xpub, err
 }
 
-func (h *HSM) createChainKDKey(ctx context.Context, alias string, get bool) (*XPub, bool, error) {
-	xprv, xpub, err := chainkd.NewXKeys(nil)
+func (h *HSM) createChainKDKey(ctx context.Context, alias string, get bool) (*hsm.XPub, bool, error) {
+	xprv, err := chainkd.NewXPrv(nil)
 	if err != nil {
 		return nil, false, err
 	}
+	return h.storeChainKDKey(ctx, alias, xprv, get)
+}
+
+func (h *HSM) storeChainKDKey(ctx context.Context, alias string, xprv chainkd.XPrv, get bool) (*hsm.XPub, bool, error) {
+	xpub := xprv.XPub()
 	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
 	var ptrAlias *string
 	if alias != "" {
 		ptrAlias = &alias
 	}
-	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, 'chain_kd')`
-	_, err = h.db.Exec(ctx, q, xpub.Bytes(), xprv.Bytes(), sqlAlias)
+	prv, encrypted, err := h.sealKey(ctx, xpub.Bytes(), xprv.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type, encrypted) VALUES ($1, $2, $3, 'chain_kd', $4)`
+	_, err = h.db.Exec(ctx, q, xpub.Bytes(), prv, sqlAlias, encrypted)
 	if err != nil {
 		if pg.IsUniqueViolation(err) {
 			if !get {
@@ -85,11 +110,11 @@ func (h *HSM) createChainKDKey(ctx context.Context, alias string, get bool) (*XP
 			}
 			var existingXPub chainkd.XPub
 			copy(existingXPub[:], xpubBytes)
-			return &XPub{XPub: existingXPub, Alias: ptrAlias}, false, nil
+			return &hsm.XPub{XPub: existingXPub, Alias: ptrAlias}, false, nil
 		}
 		return nil, false, errors.Wrap(err, "storing new xpub")
 	}
-	return &XPub{XPub: xpub, Alias: ptrAlias}, true, nil
+	return &hsm.XPub{XPub: xpub, Alias: ptrAlias}, true, nil
 }
 
 // Create produces a new random prv and stores it in the db.
@@ -104,6 +129,16 @@ func (h *HSM) GetOrCreate(ctx context.Context, alias string) (*Pub, bool, error)
 	return h.createEd25519Key(ctx, alias, true)
 }
 
+// GetOrCreateEd25519 is like GetOrCreate, but returns only
+// the public key. It implements hsm.Ed25519Creator.
+func (h *HSM) GetOrCreateEd25519(ctx context.Context, alias string) (ed25519.PublicKey, bool, error) {
+	pub, created, err := h.GetOrCreate(ctx, alias)
+	if err != nil {
+		return nil, false, err
+	}
+	return pub.Pub, created, nil
+}
+
 func (h *HSM) createEd25519Key(ctx context.Context, alias string, get bool) (*Pub, bool, error) {
 	pub, prv, err := ed25519.GenerateKey(nil)
 	if err != nil {
@@ -115,8 +150,12 @@ func (h *HSM) createEd25519Key(ctx context.Context, alias string, get bool) (*Pu
 	if alias != "" {
 		ptrAlias = &alias
 	}
-	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, 'ed25519')`
-	_, err = h.db.Exec(ctx, q, []byte(pub), []byte(prv), sqlAlias)
+	stored, encrypted, err := h.sealKey(ctx, pub, prv)
+	if err != nil {
+		return nil, false, err
+	}
+	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type, encrypted) VALUES ($1, $2, $3, 'ed25519', $4)`
+	_, err = h.db.Exec(ctx, q, []byte(pub), stored, sqlAlias, encrypted)
 	if err != nil {
 		if pg.IsUniqueViolation(err) {
 			if !get {
@@ -132,11 +171,14 @@ func (h *HSM) createEd25519Key(ctx context.Context, alias string, get bool) (*Pu
 		}
 		return nil, false, errors.Wrap(err, "storing new pub")
 	}
+	h.cacheMu.Lock()
+	h.edCache[string(pub)] = prv
+	h.cacheMu.Unlock()
 	return &Pub{Pub: pub, Alias: ptrAlias}, true, nil
 }
 
 // ListKeys returns a list of all xpubs from the db.
-func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*XPub, string, error) {
+func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*hsm.XPub, string, error) {
 	if len(aliases) > listKeyMaxAliases {
 		return nil, "", errors.WithDetailf(ErrTooManyAliasesToList, "max: %d", listKeyMaxAliases)
 	}
@@ -154,7 +196,7 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 	}
 
 	var (
-		xpubs  []*XPub
+		xpubs  []*hsm.XPub
 		params []interface{}
 	)
 	q := `
@@ -177,7 +219,7 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 	consumeRow := func(b []byte, alias sql.NullString, sortID int64) {
 		var hdxpub chainkd.XPub
 		copy(hdxpub[:], b)
-		xpub := &XPub{XPub: hdxpub}
+		xpub := &hsm.XPub{XPub: hdxpub}
 		if alias.Valid {
 			xpub.Alias = &alias.String
 		}
@@ -202,14 +244,21 @@ func (h *HSM) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (xprv chain
 		return xprv, nil
 	}
 
-	var b []byte
-	err = h.db.QueryRow(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes()).Scan(&b)
+	var (
+		b         []byte
+		encrypted bool
+	)
+	err = h.db.QueryRow(ctx, "SELECT prv, encrypted FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes()).Scan(&b, &encrypted)
 	if err == sql.ErrNoRows {
 		return xprv, ErrNoKey
 	}
 	if err != nil {
 		return xprv, err
 	}
+	b, err = h.openKey(xpub.Bytes(), b, encrypted)
+	if err != nil {
+		return xprv, err
+	}
 	copy(xprv[:], b)
 	h.kdCache[xpub] = xprv
 	return xprv, nil
@@ -229,7 +278,8 @@ func (h *HSM) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg [
 	return xprv.Sign(msg), nil
 }
 
-func (h *HSM) DeleteChainKDKey(ctx context.Context, xpub chainkd.XPub) error {
+// Delete deletes the xprv of the given xpub.
+func (h *HSM) Delete(ctx context.Context, xpub chainkd.XPub) error {
 	h.cacheMu.Lock()
 	delete(h.kdCache, xpub)
 	h.cacheMu.Unlock()
@@ -247,13 +297,22 @@ func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (prv ed
 		return prv, nil
 	}
 
-	err = h.db.QueryRow(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='ed25519'", []byte(pub)).Scan(&prv)
+	var (
+		b         []byte
+		encrypted bool
+	)
+	err = h.db.QueryRow(ctx, "SELECT prv, encrypted FROM mockhsm WHERE pub = $1 AND key_type='ed25519'", []byte(pub)).Scan(&b, &encrypted)
 	if err == sql.ErrNoRows {
 		return prv, ErrNoKey
 	}
 	if err != nil {
 		return prv, err
 	}
+	b, err = h.openKey(pub, b, encrypted)
+	if err != nil {
+		return prv, err
+	}
+	prv = ed25519.PrivateKey(b)
 	h.edCache[pubStr] = prv
 	return prv, nil
 }
@@ -0,0 +1,356 @@
+package mockhsm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"chain/core/hsm"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// Private keys are encrypted at rest with a random data key,
+// which is itself stored encrypted with a key derived from the
+// operator's passphrase. Changing the passphrase re-encrypts
+// only the data key.
+//
+// Until a passphrase is set, keys are stored in plaintext,
+// as in earlier versions, and the HSM is never locked.
+
+// scrypt parameters for new passphrases.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const dataKeySize = 32
+
+// dataKeyAD is the additional data authenticated
+// with the encrypted data key.
+var dataKeyAD = []byte("chain mockhsm data key")
+
+// Lock locks h, forgetting the data key and any private
+// keys in memory. It has no effect if no passphrase is set.
+func (h *HSM) Lock() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	h.lock()
+}
+
+func (h *HSM) lock() {
+	h.aead = nil
+	h.kdCache = make(map[chainkd.XPub]chainkd.XPrv)
+	h.edCache = make(map[string]ed25519.PrivateKey)
+	if h.lockTimer != nil {
+		h.lockTimer.Stop()
+		h.lockTimer = nil
+	}
+}
+
+// autoLock locks h like Lock, but keeps its
+// Ed25519 keys in memory.
+func (h *HSM) autoLock() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	edCache := h.edCache
+	h.lock()
+	h.edCache = edCache
+}
+
+// Unlock unlocks h with the passphrase, and reads its Ed25519
+// keys into memory. If h.AutoLock is nonzero, h locks itself
+// again after that long, forgetting its chainkd keys. It keeps
+// the Ed25519 keys, such as the block-signing key, so that a
+// block signer goes on signing blocks; only Lock forgets them.
+//
+// Unlock also encrypts any keys stored in plaintext,
+// such as those created before the passphrase was set.
+func (h *HSM) Unlock(ctx context.Context, passphrase string) error {
+	p, err := h.loadPassphrase(ctx)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.WithDetail(hsm.ErrBadPassphrase, "no passphrase is set")
+	}
+	dataKey, err := p.open(passphrase)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	type storedKey struct {
+		pub, prv  []byte
+		encrypted bool
+	}
+	var edKeys []storedKey
+	const q = `SELECT pub, prv, encrypted FROM mockhsm WHERE key_type='ed25519'`
+	err = pg.ForQueryRows(ctx, h.db, q, func(pub, prv []byte, encrypted bool) {
+		edKeys = append(edKeys, storedKey{pub, prv, encrypted})
+	})
+	if err != nil {
+		return errors.Wrap(err, "reading ed25519 keys")
+	}
+
+	h.cacheMu.Lock()
+	h.aead = aead
+	for _, k := range edKeys {
+		prv, err := h.openKey(k.pub, k.prv, k.encrypted)
+		if err != nil {
+			h.lock()
+			h.cacheMu.Unlock()
+			return err
+		}
+		h.edCache[string(k.pub)] = prv
+	}
+	if h.lockTimer != nil {
+		h.lockTimer.Stop()
+		h.lockTimer = nil
+	}
+	if h.AutoLock > 0 {
+		h.lockTimer = time.AfterFunc(h.AutoLock, h.autoLock)
+	}
+	h.cacheMu.Unlock()
+
+	return h.encryptPlaintextKeys(ctx, aead)
+}
+
+// ChangePassphrase sets the passphrase that unlocks h,
+// replacing oldPassphrase. If no passphrase is set yet,
+// oldPassphrase must be empty, and ChangePassphrase
+// encrypts all keys stored in plaintext and locks h.
+//
+// Otherwise, ChangePassphrase doesn't lock or unlock h.
+func (h *HSM) ChangePassphrase(ctx context.Context, oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return errors.WithDetail(hsm.ErrBadPassphrase, "new passphrase is empty")
+	}
+	old, err := h.loadPassphrase(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dataKey []byte
+	if old == nil {
+		if oldPassphrase != "" {
+			return errors.WithDetail(hsm.ErrBadPassphrase, "no passphrase is set")
+		}
+		dataKey = make([]byte, dataKeySize)
+		_, err = rand.Read(dataKey)
+	} else {
+		dataKey, err = old.open(oldPassphrase)
+	}
+	if err != nil {
+		return err
+	}
+
+	p := &passphrase{
+		salt: make([]byte, 16),
+		n:    scryptN,
+		r:    scryptR,
+		p:    scryptP,
+	}
+	_, err = rand.Read(p.salt)
+	if err != nil {
+		return err
+	}
+	kek, err := p.aead(newPassphrase)
+	if err != nil {
+		return err
+	}
+	p.dataKey, err = seal(kek, dataKeyAD, dataKey)
+	if err != nil {
+		return err
+	}
+
+	var q string
+	args := []interface{}{p.salt, p.n, p.r, p.p, p.dataKey}
+	if old == nil {
+		q = `
+			INSERT INTO mockhsm_passphrase (salt, n, r, p, data_key)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (singleton) DO NOTHING
+		`
+	} else {
+		// Fail if the passphrase changed since we read it.
+		q = `
+			UPDATE mockhsm_passphrase SET salt = $1, n = $2, r = $3, p = $4, data_key = $5
+			WHERE data_key = $6
+		`
+		args = append(args, old.dataKey)
+	}
+	res, err := h.db.Exec(ctx, q, args...)
+	if err != nil {
+		return errors.Wrap(err, "storing passphrase")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing passphrase")
+	}
+	if n == 0 {
+		return errors.New("passphrase changed concurrently")
+	}
+
+	if old == nil {
+		aead, err := newAEAD(dataKey)
+		if err != nil {
+			return err
+		}
+		err = h.encryptPlaintextKeys(ctx, aead)
+		// Keys read while they were in plaintext
+		// must not sign until h is unlocked.
+		h.Lock()
+		return err
+	}
+	return nil
+}
+
+// HasPassphrase returns whether a passphrase is set for h.
+func (h *HSM) HasPassphrase(ctx context.Context) (bool, error) {
+	p, err := h.loadPassphrase(ctx)
+	return p != nil, err
+}
+
+// encryptPlaintextKeys encrypts the keys stored in plaintext.
+func (h *HSM) encryptPlaintextKeys(ctx context.Context, aead cipher.AEAD) error {
+	const q = `SELECT pub, prv FROM mockhsm WHERE NOT encrypted`
+	var pubs, prvs [][]byte
+	err := pg.ForQueryRows(ctx, h.db, q, func(pub, prv []byte) error {
+		sealed, err := seal(aead, pub, prv)
+		pubs = append(pubs, pub)
+		prvs = append(prvs, sealed)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "reading plaintext keys")
+	}
+
+	const updateQ = `
+		UPDATE mockhsm SET prv = $2, encrypted = true
+		WHERE pub = $1 AND NOT encrypted
+	`
+	for i := range pubs {
+		_, err = h.db.Exec(ctx, updateQ, pubs[i], prvs[i])
+		if err != nil {
+			return errors.Wrap(err, "encrypting key")
+		}
+	}
+	return nil
+}
+
+// sealKey returns prv as it is to be stored for pub,
+// encrypted unless no passphrase is set.
+func (h *HSM) sealKey(ctx context.Context, pub, prv []byte) (stored []byte, encrypted bool, err error) {
+	h.cacheMu.Lock()
+	aead := h.aead
+	h.cacheMu.Unlock()
+	if aead != nil {
+		stored, err = seal(aead, pub, prv)
+		return stored, true, err
+	}
+
+	p, err := h.loadPassphrase(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if p != nil {
+		return nil, false, hsm.ErrLocked
+	}
+	return prv, false, nil
+}
+
+// openKey returns the private key of pub, stored as b.
+// The caller must hold h.cacheMu.
+func (h *HSM) openKey(pub, b []byte, encrypted bool) ([]byte, error) {
+	if !encrypted {
+		return b, nil
+	}
+	if h.aead == nil {
+		return nil, hsm.ErrLocked
+	}
+	prv, err := open(h.aead, pub, b)
+	return prv, errors.Wrap(err, "decrypting key")
+}
+
+// passphrase holds the stored data key, encrypted
+// with a key derived from the passphrase.
+type passphrase struct {
+	salt    []byte
+	n, r, p int
+	dataKey []byte
+}
+
+func (h *HSM) loadPassphrase(ctx context.Context) (*passphrase, error) {
+	const q = `SELECT salt, n, r, p, data_key FROM mockhsm_passphrase`
+	p := new(passphrase)
+	err := h.db.QueryRow(ctx, q).Scan(&p.salt, &p.n, &p.r, &p.p, &p.dataKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading passphrase")
+	}
+	return p, nil
+}
+
+// aead returns the cipher keyed with the key derived from
+// passphrase.
+func (p *passphrase) aead(passphrase string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), p.salt, p.n, p.r, p.p, dataKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving key")
+	}
+	return newAEAD(key)
+}
+
+// open returns the data key, decrypted with passphrase.
+func (p *passphrase) open(passphrase string) ([]byte, error) {
+	kek, err := p.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(kek, dataKeyAD, p.dataKey)
+	if err != nil {
+		return nil, hsm.ErrBadPassphrase
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts and authenticates plaintext and
+// authenticates ad, returning the nonce followed
+// by the ciphertext.
+func seal(aead cipher.AEAD, ad, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, ad, b []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(b) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, b[:n], b[n:], ad)
+}
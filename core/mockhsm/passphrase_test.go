@@ -0,0 +1,167 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/hsm"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestPassphrase(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	msg := []byte("In the face of ignorance and resistance I wrote financial systems into existence")
+
+	// A key created before any passphrase is stored in plaintext.
+	h := New(db)
+	xpub, err := h.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xprv, err := h.loadChainKDKey(ctx, xpub.XPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = h.ChangePassphrase(ctx, "wrong", "passphrase")
+	if errors.Root(err) != hsm.ErrBadPassphrase {
+		t.Fatalf("ChangePassphrase with no passphrase set: error = %v want %v", err, hsm.ErrBadPassphrase)
+	}
+	err = h.ChangePassphrase(ctx, "", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		stored    []byte
+		encrypted bool
+	)
+	err = db.QueryRow(ctx, `SELECT prv, encrypted FROM mockhsm WHERE pub = $1`, xpub.XPub.Bytes()).Scan(&stored, &encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encrypted || bytes.Contains(stored, xprv[:]) {
+		t.Fatal("key stored in plaintext after setting passphrase")
+	}
+
+	// The key read while in plaintext can't
+	// sign until the HSM is unlocked.
+	_, err = h.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("XSign with cached key after setting passphrase: error = %v want %v", err, hsm.ErrLocked)
+	}
+
+	h = New(db)
+	_, err = h.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("XSign while locked: error = %v want %v", err, hsm.ErrLocked)
+	}
+	_, err = h.XCreate(ctx, "")
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("XCreate while locked: error = %v want %v", err, hsm.ErrLocked)
+	}
+	err = h.Unlock(ctx, "wrong")
+	if errors.Root(err) != hsm.ErrBadPassphrase {
+		t.Errorf("Unlock with wrong passphrase: error = %v want %v", err, hsm.ErrBadPassphrase)
+	}
+
+	err = h.Unlock(ctx, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := h.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("expected verify to succeed")
+	}
+	pub, err := h.Create(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating the passphrase leaves unlocked HSMs unlocked.
+	err = h.ChangePassphrase(ctx, "passphrase", "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Lock()
+	err = h.Unlock(ctx, "passphrase")
+	if errors.Root(err) != hsm.ErrBadPassphrase {
+		t.Errorf("Unlock with old passphrase: error = %v want %v", err, hsm.ErrBadPassphrase)
+	}
+	h.AutoLock = 50 * time.Millisecond
+	err = h.Unlock(ctx, "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.Sign(ctx, pub.Pub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = h.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("XSign after auto-lock: error = %v want %v", err, hsm.ErrLocked)
+	}
+
+	// Auto-locking keeps the Ed25519 keys, which are read when
+	// the HSM is unlocked, even in a process that hasn't used
+	// them yet, so block signers go on signing blocks.
+	h2 := New(db)
+	h2.AutoLock = 50 * time.Millisecond
+	err = h2.Unlock(ctx, "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, err = h2.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("XSign after auto-lock: error = %v want %v", err, hsm.ErrLocked)
+	}
+	_, err = h2.Sign(ctx, pub.Pub, msg)
+	if err != nil {
+		t.Errorf("Sign after auto-lock: error = %v", err)
+	}
+	h2.Lock()
+	_, err = h2.Sign(ctx, pub.Pub, msg)
+	if errors.Root(err) != hsm.ErrLocked {
+		t.Errorf("Sign after Lock: error = %v want %v", err, hsm.ErrLocked)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := make([]byte, dataKeySize)
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, prv := []byte("pub"), []byte("prv")
+	b, err := seal(aead, pub, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := open(aead, pub, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, prv) {
+		t.Errorf("open(seal(%x)) = %x", prv, got)
+	}
+
+	// The ciphertext is bound to the public key.
+	_, err = open(aead, []byte("other pub"), b)
+	if err == nil {
+		t.Error("open with wrong public key succeeded")
+	}
+	b[len(b)-1] ^= 1
+	_, err = open(aead, pub, b)
+	if err == nil {
+		t.Error("open of modified ciphertext succeeded")
+	}
+}
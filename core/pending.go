@@ -0,0 +1,218 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chain/core/leader"
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// pendingCursor identifies a position in the pending
+// transaction pool, ordered oldest first.
+type pendingCursor struct {
+	submitted time.Time
+	hash      bc.Hash
+}
+
+func (c pendingCursor) String() string {
+	return fmt.Sprintf("%d:%s", c.submitted.UnixNano(), c.hash)
+}
+
+func (c pendingCursor) before(d pendingCursor) bool {
+	if !c.submitted.Equal(d.submitted) {
+		return c.submitted.Before(d.submitted)
+	}
+	return c.hash.String() < d.hash.String()
+}
+
+func decodePendingCursor(s string) (c pendingCursor, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return c, errors.Wrap(query.ErrBadAfter, "format must be '<submitted>:<id>'")
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return c, errors.Wrap(query.ErrBadAfter, err.Error())
+	}
+	err = c.hash.UnmarshalText([]byte(parts[1]))
+	if err != nil {
+		return c, errors.Wrap(query.ErrBadAfter, err.Error())
+	}
+	c.submitted = time.Unix(0, ns)
+	return c, nil
+}
+
+// POST /list-pending-transactions
+//
+// listPendingTxs lists the transactions in the pending
+// transaction pool that have not yet been included in
+// a block, oldest first, that match the filter. The pool
+// is held by the leader process of the generator; on other
+// cores it is empty.
+func (h *Handler) listPendingTxs(ctx context.Context, in requestQuery) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp interface{}
+		err := h.forwardToLeader(ctx, "/list-pending-transactions", in, &resp)
+		return resp, err
+	}
+
+	p, err := filter.Parse(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.FilterParams) != p.Parameters {
+		return nil, query.ErrParameterCountMismatch
+	}
+
+	var after pendingCursor
+	if in.After != "" {
+		after, err = decodePendingCursor(in.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hashes, err := h.Chain.PendingTxs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := defGenericPageSize
+	var (
+		items []map[string]interface{}
+		last  pendingCursor
+	)
+	for _, hash := range hashes {
+		if len(items) == limit {
+			break
+		}
+		tx, t, err := h.Chain.PendingTx(ctx, hash)
+		if err != nil {
+			// It left the pool since we listed it.
+			continue
+		}
+		c := pendingCursor{submitted: t, hash: hash}
+		if in.After != "" && !after.before(c) {
+			continue
+		}
+		annotated, err := h.annotatePendingTxs(ctx, []*bc.Tx{tx}, []time.Time{t})
+		if err != nil {
+			return nil, err
+		}
+		ok, err := matchPendingTx(p, in.FilterParams, annotated[0])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		items = append(items, annotated[0])
+		last = c
+	}
+
+	out := in
+	if len(items) > 0 {
+		out.After = last.String()
+	}
+	return page{
+		Items:    httpjson.Array(items),
+		LastPage: len(items) < limit,
+		Next:     out,
+	}, nil
+}
+
+// POST /get-pending-transaction
+func (h *Handler) getPendingTx(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp interface{}
+		err := h.forwardToLeader(ctx, "/get-pending-transaction", in, &resp)
+		return resp, err
+	}
+
+	tx, submitted, err := h.Chain.PendingTx(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.annotatePendingTxs(ctx, []*bc.Tx{tx}, []time.Time{submitted})
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+// POST /remove-pending-transaction
+//
+// removePendingTx removes a transaction from the pending
+// transaction pool, along with any pending transactions that
+// spend its outputs, so that it won't be included in a block.
+// It does not release the outputs reserved for the transaction.
+func (h *Handler) removePendingTx(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) error {
+	if !leader.IsLeading() {
+		return h.forwardToLeader(ctx, "/remove-pending-transaction", in, nil)
+	}
+	return h.Chain.RemovePendingTx(ctx, in.ID)
+}
+
+// POST /replace-pending-transaction
+//
+// replacePendingTx replaces a transaction in the pending
+// transaction pool, along with any pending transactions that
+// spend its outputs, with a new signed transaction, which may
+// spend the same outputs. Use it to raise the priority of a
+// pending transaction before it is included in a block.
+func (h *Handler) replacePendingTx(ctx context.Context, in struct {
+	ID          bc.Hash    `json:"id"`
+	Transaction *bc.TxData `json:"raw_transaction"`
+}) error {
+	if in.Transaction == nil {
+		return errors.WithDetail(httpjson.ErrBadRequest, "missing raw_transaction")
+	}
+	if !leader.IsLeading() {
+		return h.forwardToLeader(ctx, "/replace-pending-transaction", in, nil)
+	}
+	return h.Chain.ReplaceTx(ctx, in.ID, bc.NewTx(*in.Transaction))
+}
+
+// matchPendingTx reports whether the annotated pending
+// transaction tx satisfies the predicate p. Like the
+// transactions in annotated_txs, tx is evaluated in its
+// JSON form.
+func matchPendingTx(p filter.Predicate, vals []interface{}, tx map[string]interface{}) (bool, error) {
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return false, errors.Wrap(err, "encoding pending transaction")
+	}
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err = dec.Decode(&obj)
+	if err != nil {
+		return false, errors.Wrap(err, "decoding pending transaction")
+	}
+	return filter.Eval(p, vals, obj)
+}
+
+func (h *Handler) annotatePendingTxs(ctx context.Context, txs []*bc.Tx, submitted []time.Time) ([]map[string]interface{}, error) {
+	items, err := h.Indexer.AnnotatePendingTxs(ctx, txs)
+	if err != nil {
+		return nil, errors.Wrap(err, "annotating pending transactions")
+	}
+	for i, item := range items {
+		item["submitted_at"] = submitted[i].UTC().Format(time.RFC3339Nano)
+	}
+	return items, nil
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/leader"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+	"chain/protocol/mempool"
+	"chain/protocol/prottest"
+)
+
+var leadOnce sync.Once
+
+// lead makes this process the leader, using db
+// only the first time it is called.
+// TODO(jackson): Replace this with a mock leader.
+func lead(db *sql.DB) {
+	leadOnce.Do(func() {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go leader.Run(db, ":1999", func(ctx context.Context) {
+			wg.Done()
+		})
+		wg.Wait()
+	})
+}
+
+func TestReplacePendingTx(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	h := &Handler{
+		Chain:    c,
+		Assets:   asset.NewRegistry(db, c, pinStore),
+		Accounts: account.NewManager(db, c, pinStore),
+		DB:       db,
+	}
+
+	lead(db)
+
+	assetID := coretest.CreateAsset(ctx, t, h.Assets, nil, "", nil)
+	accountID := coretest.CreateAccount(ctx, t, h.Accounts, "", nil)
+	issue := func() *bc.Tx {
+		amt := bc.AssetAmount{AssetID: assetID, Amount: 100}
+		tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			h.Assets.NewIssueAction(amt, nil),
+			h.Accounts.NewControlAction(amt, accountID, nil),
+		}, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		coretest.SignTxTemplate(t, ctx, tpl, nil)
+		return bc.NewTx(*tpl.Transaction)
+	}
+	tx1, tx2 := issue(), issue()
+
+	err := c.AddTx(ctx, tx1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type replaceReq struct {
+		ID          bc.Hash    `json:"id"`
+		Transaction *bc.TxData `json:"raw_transaction"`
+	}
+	err = h.replacePendingTx(ctx, replaceReq{ID: tx1.Hash, Transaction: &tx2.TxData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := c.PendingTxs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != tx2.Hash {
+		t.Errorf("pending txs = %x want [%x]", pending, tx2.Hash)
+	}
+
+	err = h.replacePendingTx(ctx, replaceReq{ID: tx1.Hash, Transaction: &tx1.TxData})
+	if errors.Root(err) != mempool.ErrNotFound {
+		t.Errorf("replacing removed tx: error = %v want %v", err, mempool.ErrNotFound)
+	}
+	err = h.replacePendingTx(ctx, replaceReq{ID: tx2.Hash})
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("replacing with no tx: error = %v want %v", err, httpjson.ErrBadRequest)
+	}
+}
+
+func TestListPendingTxs(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	h := &Handler{
+		Chain:    c,
+		Assets:   asset.NewRegistry(db, c, pinStore),
+		Accounts: account.NewManager(db, c, pinStore),
+		Indexer:  query.NewIndexer(db, c, pinStore),
+		DB:       db,
+	}
+	lead(db)
+
+	assetID := coretest.CreateAsset(ctx, t, h.Assets, nil, "", nil)
+	accountID := coretest.CreateAccount(ctx, t, h.Accounts, "", nil)
+	amt := bc.AssetAmount{AssetID: assetID, Amount: 100}
+	tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+		h.Assets.NewIssueAction(amt, nil),
+		h.Accounts.NewControlAction(amt, accountID, nil),
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coretest.SignTxTemplate(t, ctx, tpl, nil)
+	tx := bc.NewTx(*tpl.Transaction)
+	err = c.AddTx(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		filter string
+		params []interface{}
+		after  string
+		want   int
+	}{
+		{filter: "", want: 1},
+		{filter: "outputs(amount = $1)", params: []interface{}{json.Number("100")}, want: 1},
+		{filter: "outputs(amount > 100)", want: 0},
+		{filter: "outputs(amount > 100)", after: "0:" + tx.Hash.String(), want: 0},
+	}
+	for _, tc := range cases {
+		resp, err := h.listPendingTxs(ctx, requestQuery{Filter: tc.filter, FilterParams: tc.params, After: tc.after})
+		if err != nil {
+			t.Fatalf("listing %q: %s", tc.filter, err)
+		}
+		p := resp.(page)
+		if n := reflect.ValueOf(p.Items).Len(); n != tc.want {
+			t.Errorf("listing %q: got %d items, want %d", tc.filter, n, tc.want)
+		}
+		if tc.want == 0 && p.Next.After != tc.after {
+			t.Errorf("listing %q: empty page after = %q, want %q", tc.filter, p.Next.After, tc.after)
+		}
+	}
+
+	_, err = h.listPendingTxs(ctx, requestQuery{Filter: "outputs(amount = $1)"})
+	if errors.Root(err) != query.ErrParameterCountMismatch {
+		t.Errorf("listing with missing params: error = %v, want %v", err, query.ErrParameterCountMismatch)
+	}
+}
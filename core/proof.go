@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/proof"
+	"chain/protocol/state"
+	"chain/protocol/validation"
+)
+
+type txProof struct {
+	BlockHeight            uint64         `json:"block_height"`
+	BlockID                bc.Hash        `json:"block_id"`
+	TransactionsMerkleRoot bc.Hash        `json:"transactions_merkle_root"`
+	Proof                  *proof.TxProof `json:"proof"`
+}
+
+type outputProof struct {
+	BlockHeight      uint64           `json:"block_height"`
+	BlockID          bc.Hash          `json:"block_id"`
+	AssetsMerkleRoot bc.Hash          `json:"assets_merkle_root"`
+	Proof            *proof.TreeProof `json:"proof"`
+}
+
+// POST /get-transaction-proof
+//
+// getTxProof returns a proof that a confirmed transaction
+// is in its block, to be checked with proof.VerifyTx against
+// the block's transactions merkle root. If the block height
+// is omitted, it is looked up among the indexed transactions.
+func (h *Handler) getTxProof(ctx context.Context, in struct {
+	ID          bc.Hash `json:"id"`
+	BlockHeight *uint64 `json:"block_height"`
+}) (*txProof, error) {
+	var height uint64
+	if in.BlockHeight != nil {
+		height = *in.BlockHeight
+	} else {
+		const q = `SELECT block_height FROM annotated_txs WHERE data @> $1::jsonb`
+		filter, err := json.Marshal(map[string]bc.Hash{"id": in.ID})
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		err = h.DB.QueryRow(ctx, q, filter).Scan(&height)
+		if err == sql.ErrNoRows {
+			return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s not found", in.ID)
+		} else if err != nil {
+			return nil, errors.Wrap(err, "looking up transaction")
+		}
+	}
+
+	if height < 1 || height > h.Chain.Height() {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "block %d not found", height)
+	}
+	b, err := h.Chain.GetBlock(ctx, height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting block %d", height)
+	}
+	if validation.CalcMerkleRoot(b.Transactions) != b.TransactionsMerkleRoot {
+		// A light client stores only some transactions of each block.
+		return nil, errors.New("block is incomplete")
+	}
+	for i, tx := range b.Transactions {
+		if tx.Hash == in.ID {
+			return &txProof{
+				BlockHeight:            b.Height,
+				BlockID:                b.Hash(),
+				TransactionsMerkleRoot: b.TransactionsMerkleRoot,
+				Proof:                  validation.TxProof(b.Transactions, i),
+			}, nil
+		}
+	}
+	return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s not found in block %d", in.ID, height)
+}
+
+// POST /get-output-proof
+//
+// getOutputProof returns a proof that an output is unspent in
+// the current blockchain state, to be checked with
+// proof.VerifyIncluded against the assets merkle root of the
+// latest block. The state tree can't prove that an output is
+// absent, so for a spent or unknown output it returns
+// proof.ErrNoAbsenceProof.
+func (h *Handler) getOutputProof(ctx context.Context, in struct {
+	TransactionID bc.Hash `json:"transaction_id"`
+	Position      uint32  `json:"position"`
+}) (*outputProof, error) {
+	b, snapshot := h.Chain.State()
+	if b == nil {
+		return nil, errors.New("no blockchain state")
+	}
+	key := state.OutputKey(bc.Outpoint{Hash: in.TransactionID, Index: in.Position})
+	p, err := snapshot.Tree.Prove(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "proving output")
+	}
+	if p == nil {
+		return nil, errors.WithDetailf(proof.ErrNoAbsenceProof, "output %s:%d is not in the state at height %d", in.TransactionID, in.Position, b.Height)
+	}
+	return &outputProof{
+		BlockHeight:      b.Height,
+		BlockID:          b.Hash(),
+		AssetsMerkleRoot: b.AssetsMerkleRoot,
+		Proof:            p,
+	}, nil
+}
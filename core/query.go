@@ -110,105 +110,115 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 
 	resp := make([]*txResp, 0, len(txns))
 	for _, t := range txns {
-		tjson, ok := t.(*json.RawMessage)
-		if !ok {
-			return result, fmt.Errorf("unexpected type %T in Indexer.Transactions output", t)
-		}
-		if tjson == nil {
-			return result, fmt.Errorf("unexpected nil in Indexer.Transactions output")
-		}
-		var tx map[string]interface{}
-		err = json.Unmarshal(*tjson, &tx)
+		r, err := txResponse(t)
 		if err != nil {
-			return result, errors.Wrap(err, "decoding Indexer.Transactions output")
+			return result, err
 		}
+		resp = append(resp, r)
+	}
 
-		inp, ok := tx["inputs"].([]interface{})
-		if !ok {
-			return result, fmt.Errorf("unexpected type %T for inputs in Indexer.Transactions output", tx["inputs"])
-		}
+	out := in
+	out.After = nextAfter.String()
+	return page{
+		Items:    httpjson.Array(resp),
+		LastPage: len(resp) < limit,
+		Next:     out,
+	}, nil
+}
 
-		var inputs []map[string]interface{}
-		for i, in := range inp {
-			input, ok := in.(map[string]interface{})
-			if !ok {
-				return result, fmt.Errorf("unexpected type %T for input %d in Indexer.Transactions output", in, i)
-			}
-			inputs = append(inputs, input)
-		}
+// txResponse converts an item of Indexer.Transactions output
+// to its API representation.
+func txResponse(t interface{}) (*txResp, error) {
+	tjson, ok := t.(*json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T in Indexer.Transactions output", t)
+	}
+	if tjson == nil {
+		return nil, fmt.Errorf("unexpected nil in Indexer.Transactions output")
+	}
+	var tx map[string]interface{}
+	err := json.Unmarshal(*tjson, &tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding Indexer.Transactions output")
+	}
+
+	inp, ok := tx["inputs"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for inputs in Indexer.Transactions output", tx["inputs"])
+	}
 
-		outp, ok := tx["outputs"].([]interface{})
+	var inputs []map[string]interface{}
+	for i, in := range inp {
+		input, ok := in.(map[string]interface{})
 		if !ok {
-			return result, fmt.Errorf("unexpected type %T for outputs in Indexer.Transactions output", tx["outputs"])
+			return nil, fmt.Errorf("unexpected type %T for input %d in Indexer.Transactions output", in, i)
 		}
+		inputs = append(inputs, input)
+	}
 
-		var outputs []map[string]interface{}
-		for i, out := range outp {
-			output, ok := out.(map[string]interface{})
-			if !ok {
-				return result, fmt.Errorf("unexpected type %T for output %d in Indexer.Transactions output", out, i)
-			}
-			outputs = append(outputs, output)
-		}
+	outp, ok := tx["outputs"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for outputs in Indexer.Transactions output", tx["outputs"])
+	}
 
-		inResps := make([]*txinResp, 0, len(inputs))
-		for _, in := range inputs {
-			r := &txinResp{
-				Type:            in["type"],
-				AssetID:         in["asset_id"],
-				AssetAlias:      in["asset_alias"],
-				AssetDefinition: in["asset_definition"],
-				AssetTags:       in["asset_tags"],
-				AssetIsLocal:    in["asset_is_local"],
-				Amount:          in["amount"],
-				IssuanceProgram: in["issuance_program"],
-				SpentOutput:     in["spent_output"],
-				txAccount:       txAccountFromMap(in),
-				ReferenceData:   in["reference_data"],
-				IsLocal:         in["is_local"],
-			}
-			inResps = append(inResps, r)
+	var outputs []map[string]interface{}
+	for i, out := range outp {
+		output, ok := out.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T for output %d in Indexer.Transactions output", out, i)
 		}
-		outResps := make([]*txoutResp, 0, len(outputs))
-		for _, out := range outputs {
-			r := &txoutResp{
-				Type:            out["type"],
-				Purpose:         out["purpose"],
-				Position:        out["position"],
-				AssetID:         out["asset_id"],
-				AssetAlias:      out["asset_alias"],
-				AssetDefinition: out["asset_definition"],
-				AssetTags:       out["asset_tags"],
-				AssetIsLocal:    out["asset_is_local"],
-				Amount:          out["amount"],
-				txAccount:       txAccountFromMap(out),
-				ControlProgram:  out["control_program"],
-				ReferenceData:   out["reference_data"],
-				IsLocal:         out["is_local"],
-			}
-			outResps = append(outResps, r)
+		outputs = append(outputs, output)
+	}
+
+	inResps := make([]*txinResp, 0, len(inputs))
+	for _, in := range inputs {
+		r := &txinResp{
+			Type:            in["type"],
+			AssetID:         in["asset_id"],
+			AssetAlias:      in["asset_alias"],
+			AssetDefinition: in["asset_definition"],
+			AssetTags:       in["asset_tags"],
+			AssetIsLocal:    in["asset_is_local"],
+			Amount:          in["amount"],
+			IssuanceProgram: in["issuance_program"],
+			SpentOutput:     in["spent_output"],
+			txAccount:       txAccountFromMap(in),
+			ReferenceData:   in["reference_data"],
+			IsLocal:         in["is_local"],
 		}
-		r := &txResp{
-			ID:            tx["id"],
-			Timestamp:     tx["timestamp"],
-			BlockID:       tx["block_id"],
-			BlockHeight:   tx["block_height"],
-			Position:      tx["position"],
-			ReferenceData: tx["reference_data"],
-			IsLocal:       tx["is_local"],
-			Inputs:        inResps,
-			Outputs:       outResps,
+		inResps = append(inResps, r)
+	}
+	outResps := make([]*txoutResp, 0, len(outputs))
+	for _, out := range outputs {
+		r := &txoutResp{
+			Type:            out["type"],
+			Purpose:         out["purpose"],
+			Position:        out["position"],
+			AssetID:         out["asset_id"],
+			AssetAlias:      out["asset_alias"],
+			AssetDefinition: out["asset_definition"],
+			AssetTags:       out["asset_tags"],
+			AssetIsLocal:    out["asset_is_local"],
+			Amount:          out["amount"],
+			txAccount:       txAccountFromMap(out),
+			ControlProgram:  out["control_program"],
+			ReferenceData:   out["reference_data"],
+			IsLocal:         out["is_local"],
 		}
-		resp = append(resp, r)
+		outResps = append(outResps, r)
 	}
-
-	out := in
-	out.After = nextAfter.String()
-	return page{
-		Items:    httpjson.Array(resp),
-		LastPage: len(resp) < limit,
-		Next:     out,
-	}, nil
+	r := &txResp{
+		ID:            tx["id"],
+		Timestamp:     tx["timestamp"],
+		BlockID:       tx["block_id"],
+		BlockHeight:   tx["block_height"],
+		Position:      tx["position"],
+		ReferenceData: tx["reference_data"],
+		IsLocal:       tx["is_local"],
+		Inputs:        inResps,
+		Outputs:       outResps,
+	}
+	return r, nil
 }
 
 // listAccounts is an http handler for listing accounts matching
@@ -14,12 +14,19 @@ import (
 )
 
 func transactionObject(orig *bc.Tx, b *bc.Block, indexInBlock uint32) map[string]interface{} {
+	m := pendingTransactionObject(orig)
+	m["timestamp"] = b.Time().Format(time.RFC3339)
+	m["block_id"] = b.Hash().String()
+	m["block_height"] = b.Height
+	m["position"] = indexInBlock
+	return m
+}
+
+// pendingTransactionObject is like transactionObject
+// for a transaction that isn't in a block yet.
+func pendingTransactionObject(orig *bc.Tx) map[string]interface{} {
 	m := map[string]interface{}{
 		"id":             orig.Hash.String(),
-		"timestamp":      b.Time().Format(time.RFC3339),
-		"block_id":       b.Hash().String(),
-		"block_height":   b.Height,
-		"position":       indexInBlock,
 		"reference_data": unmarshalReferenceData(orig.ReferenceData),
 	}
 
@@ -5,16 +5,24 @@ values ($1, $2, etc) that are initially unconstrained. The
 predicate is evaluated in an environment (such as a transaction
 object or a UTXO) that determines the value of all non-placeholder
 terms. The predicate and its fixed values together constrain the
-placeholders. Function Eval finds the set of all placeholder values
-that satisfy the predicate.
+placeholders. Function Eval evaluates a predicate directly against
+an environment, and function AsSQL translates it to a SQL condition
+over a jsonb column.
 
 Expressions in a filter expression have the following forms:
 
   Form                     Type     Subexpression types
   expr1 "OR" expr2         bool     bool, bool
   expr1 "AND" expr2        bool     bool, bool
+  "NOT" expr               bool     bool
   ident "(" expr ")"       bool     list, bool
-  expr1 "=" expr2          bool     any (must match)
+  expr1 "=" expr2          bool     scalar (must match)
+  expr1 "!=" expr2         bool     scalar (must match)
+  expr1 "<" expr2          bool     int, int
+  expr1 "<=" expr2         bool     int, int
+  expr1 ">" expr2          bool     int, int
+  expr1 ">=" expr2         bool     int, int
+  expr "IN" "(" exprs ")"  bool     scalar (must match)
   expr "." ident           any      object
   "(" expr ")"             any      any
   ident                    any      n/a
@@ -28,6 +36,15 @@ Expressions in a filter expression have the following forms:
   string is single-quoted, and cannot contain backslash
   int is decimal or hexadecimal (with prefix "0x")
   list is a slice of environments
+  exprs is one or more comma-separated exprs
+
+NOT binds more tightly than AND and OR, and more loosely than
+the comparison operators, so 'NOT a = 1 AND b = 2' means
+'(NOT (a = 1)) AND b = 2'.
+
+A missing attribute is not equal to any value, so 'a != 1' is
+true when a is missing. Ordering comparisons are true only when
+the attribute is present and is a number.
 
 The environment is a map from names to values. Identifier
 expressions get their values from the environment map.
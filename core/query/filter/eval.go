@@ -0,0 +1,181 @@
+package filter
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"chain/errors"
+)
+
+// Eval evaluates the predicate p against obj, an environment such
+// as a decoded annotated transaction, using values for the
+// placeholders. It agrees with the SQL produced by AsSQL: a
+// missing attribute is never equal to, less than or greater than
+// anything, and comparisons with a non-number attribute are false.
+func Eval(p Predicate, values []interface{}, obj map[string]interface{}) (ok bool, err error) {
+	defer func() {
+		r := recover()
+		if e, ok := r.(error); ok {
+			err = e
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	if p.expr == nil {
+		// An empty expression matches everything.
+		return true, nil
+	}
+
+	pvals := map[int]interface{}{}
+	for i, v := range values {
+		if v != nil {
+			pvals[i+1] = v
+		}
+	}
+	return evalBool(p.expr, pvals, obj), nil
+}
+
+func evalBool(expr expr, pvals map[int]interface{}, env map[string]interface{}) bool {
+	switch e := expr.(type) {
+	case parenExpr:
+		return evalBool(e.inner, pvals, env)
+	case notExpr:
+		return !evalBool(e.inner, pvals, env)
+	case envExpr:
+		list, _ := env[e.ident].([]interface{})
+		for _, item := range list {
+			subenv, _ := item.(map[string]interface{})
+			if evalBool(e.expr, pvals, subenv) {
+				return true
+			}
+		}
+		return false
+	case binaryExpr:
+		switch e.op.name {
+		case "OR":
+			return evalBool(e.l, pvals, env) || evalBool(e.r, pvals, env)
+		case "AND":
+			return evalBool(e.l, pvals, env) && evalBool(e.r, pvals, env)
+		case "=":
+			return evalEqual(e.l, e.r, pvals, env)
+		case "!=":
+			return !evalEqual(e.l, e.r, pvals, env)
+		case "IN":
+			for _, item := range e.r.(listExpr).items {
+				if evalEqual(e.l, item, pvals, env) {
+					return true
+				}
+			}
+			return false
+		case "<", "<=", ">", ">=":
+			op, v, path := comparisonOperands(e, pvals)
+			r, _ := toNumber(v)
+			l, ok := toNumber(lookupPath(env, path))
+			if !ok {
+				return false
+			}
+			c := l.Cmp(r)
+			switch op {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			default:
+				return c >= 0
+			}
+		}
+		panic(fmt.Errorf("unknown operator %q", e.op.name))
+	}
+	panic(errors.WithDetailf(ErrBadFilter, "unsupported expression %s", expr))
+}
+
+func evalEqual(l, r expr, pvals map[int]interface{}, env map[string]interface{}) bool {
+	lv := evalOperand(l, pvals, env, "=")
+	rv := evalOperand(r, pvals, env, "=")
+	if ln, ok := toNumber(lv); ok {
+		rn, ok := toNumber(rv)
+		return ok && ln.Cmp(rn) == 0
+	}
+	if ls, ok := toString(lv); ok {
+		rs, ok := toString(rv)
+		return ok && ls == rs
+	}
+	return false
+}
+
+// evalOperand returns the value of an operand of op. Exactly one
+// operand of a comparison must be an attribute; the other must be
+// a literal or a placeholder, as with AsSQL.
+func evalOperand(expr expr, pvals map[int]interface{}, env map[string]interface{}, op string) interface{} {
+	switch e := expr.(type) {
+	case parenExpr:
+		return evalOperand(e.inner, pvals, env, op)
+	case placeholderExpr:
+		v, ok := pvals[e.num]
+		if !ok {
+			panic(errors.WithDetailf(ErrBadFilter, "unsupported operands for %s", op))
+		}
+		return v
+	case valueExpr:
+		v, _ := jsonValue(e, pvals)
+		return v
+	case attrExpr:
+		return env[e.attr]
+	case selectorExpr:
+		obj, _ := evalOperand(e.objExpr, pvals, env, op).(map[string]interface{})
+		return obj[e.ident]
+	}
+	panic(errors.WithDetailf(ErrBadFilter, "unsupported operands for %s", op))
+}
+
+// lookupPath returns the attribute of env at path,
+// which lists the attribute's names innermost first.
+func lookupPath(env map[string]interface{}, path []string) interface{} {
+	var v interface{} = env
+	for i := len(path) - 1; i >= 0; i-- {
+		obj, _ := v.(map[string]interface{})
+		v = obj[path[i]]
+	}
+	return v
+}
+
+// toNumber converts any Go or JSON numeric value to a big.Rat,
+// so that large amounts compare exactly.
+func toNumber(v interface{}) (*big.Rat, bool) {
+	if n, ok := v.(json.Number); ok {
+		return new(big.Rat).SetString(string(n))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		r := new(big.Rat)
+		if r.SetFloat64(rv.Float()) == nil {
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+// toString converts strings, and values that marshal to
+// JSON strings (such as hashes), to a string.
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case encoding.TextMarshaler:
+		b, err := s.MarshalText()
+		return string(b), err == nil
+	}
+	return "", false
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestEval(t *testing.T) {
+	var hash bc.Hash
+	hash[0] = 0xab
+	tx := map[string]interface{}{
+		"id":             hash,
+		"reference_data": map[string]interface{}{"bank": "acme"},
+		"inputs": []interface{}{
+			map[string]interface{}{"asset_alias": "usd", "amount": uint64(50)},
+		},
+		"outputs": []interface{}{
+			map[string]interface{}{"asset_alias": "usd", "amount": uint64(10)},
+			map[string]interface{}{"asset_alias": "eur", "amount": uint64(1 << 62)},
+		},
+	}
+
+	testCases := []struct {
+		p      string
+		values []interface{}
+		want   bool
+	}{
+		{p: ``, want: true},
+		{p: `id = $1`, values: []interface{}{hash.String()}, want: true},
+		{p: `reference_data.bank = 'acme'`, want: true},
+		{p: `reference_data.bank != 'acme'`, want: false},
+		{p: `reference_data.missing != 'acme'`, want: true},
+		{p: `reference_data.missing < 5`, want: false},
+		{p: `NOT reference_data.missing < 5`, want: true},
+		{p: `inputs(amount > 40 AND asset_alias = 'usd')`, want: true},
+		{p: `inputs(amount > 50)`, want: false},
+		{p: `inputs(amount >= $1)`, values: []interface{}{json.Number("50")}, want: true},
+		{p: `outputs(amount > $1)`, values: []interface{}{json.Number("4611686018427387903")}, want: true},
+		{p: `outputs(amount < $1)`, values: []interface{}{float64(10)}, want: false},
+		{p: `outputs(amount > 0x0a)`, want: true},
+		{p: `outputs(asset_alias IN ('eur', 'gbp'))`, want: true},
+		{p: `NOT outputs(asset_alias IN ('gbp', 'jpy'))`, want: true},
+		{p: `inputs(asset_alias = 'usd') AND outputs(amount = 10)`, want: true},
+		{p: `outputs(amount = '10')`, want: false},
+		{p: `outputs(asset_alias = 'usd' AND amount > 10)`, want: false},
+	}
+
+	for _, tc := range testCases {
+		p, err := Parse(tc.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Eval(p, tc.values, tx)
+		if err != nil {
+			t.Fatalf("Eval(%q) error: %s", tc.p, err)
+		}
+		if got != tc.want {
+			t.Errorf("Eval(%q) = %t, want %t", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestEvalUnsupported(t *testing.T) {
+	p, err := Parse(`asset_alias = $1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Eval(p, nil, map[string]interface{}{})
+	if err == nil {
+		t.Error("Eval with missing placeholder value = nil error, want error")
+	}
+
+	p, err = Parse(`amount > $1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Eval(p, []interface{}{"100"}, map[string]interface{}{"amount": 200})
+	if errors.Root(err) != ErrBadFilter {
+		t.Errorf("Eval with string comparison value error = %v, want %v", err, ErrBadFilter)
+	}
+}
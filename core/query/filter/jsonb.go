@@ -10,7 +10,7 @@ import (
 func jsonValue(expr expr, pvals map[int]interface{}) (v interface{}, path []string) {
 	switch e := expr.(type) {
 	case parenExpr:
-		return jsonValue(expr, pvals)
+		return jsonValue(e.inner, pvals)
 	case placeholderExpr:
 		return pvals[e.num], nil
 	case attrExpr:
@@ -25,7 +25,10 @@ func jsonValue(expr expr, pvals map[int]interface{}) (v interface{}, path []stri
 			return strv, nil
 		}
 		if e.typ == tokInteger {
-			i, _ := strconv.Atoi(e.value) // err impossible; enforce at parser
+			i, err := strconv.ParseInt(e.value, 0, 64)
+			if err != nil {
+				panic(errors.WithDetailf(ErrBadFilter, "invalid integer %s", e.value))
+			}
 			return i, nil
 		}
 		panic(fmt.Errorf("value expr with invalid token type: %s", e.typ))
@@ -68,34 +71,69 @@ func matchingObjects(expr expr, pvals map[int]interface{}) []interface{} {
 		}
 
 		if e.op.name == "=" {
-			lv, lp := jsonValue(e.l, pvals)
-			rv, rp := jsonValue(e.r, pvals)
-			switch {
-			// left is a value, right is a path
-			case lv != nil && len(rp) > 0:
-				m := lv
-				for _, p := range rp {
-					m = map[string]interface{}{p: m}
-				}
-				return []interface{}{m}
-
-			// right is a value, left is a path
-			case rv != nil && len(lp) > 0:
-				m := rv
-				for _, p := range lp {
-					m = map[string]interface{}{p: m}
-				}
-				return []interface{}{m}
+			return []interface{}{equalityObject(e.l, e.r, pvals)}
+		}
 
-			default:
-				panic(errors.WithDetail(ErrBadFilter, "unsupported operands for ="))
+		if e.op.name == "IN" {
+			// x IN (a, b) is equivalent to x = a OR x = b.
+			var conds []interface{}
+			for _, item := range e.r.(listExpr).items {
+				conds = append(conds, equalityObject(e.l, item, pvals))
 			}
+			return conds
 		}
 		panic(fmt.Errorf("unknown operator %q", e.op.name))
 	}
 	panic(fmt.Errorf("unexpected expr type %T", expr))
 }
 
+// equalityObject returns the jsonb object that contains
+// exactly the objects satisfying l = r.
+func equalityObject(l, r expr, pvals map[int]interface{}) interface{} {
+	lv, lp := jsonValue(l, pvals)
+	rv, rp := jsonValue(r, pvals)
+	switch {
+	// left is a value, right is a path
+	case lv != nil && len(rp) > 0:
+		m := lv
+		for _, p := range rp {
+			m = map[string]interface{}{p: m}
+		}
+		return m
+
+	// right is a value, left is a path
+	case rv != nil && len(lp) > 0:
+		m := rv
+		for _, p := range lp {
+			m = map[string]interface{}{p: m}
+		}
+		return m
+
+	default:
+		panic(errors.WithDetail(ErrBadFilter, "unsupported operands for ="))
+	}
+}
+
+// containable returns whether expr can be expressed entirely
+// as jsonb containment queries (see matchingObjects). Containment
+// queries are able to use the GIN indexes on the data columns.
+func containable(expr expr) bool {
+	switch e := expr.(type) {
+	case parenExpr:
+		return containable(e.inner)
+	case envExpr:
+		return containable(e.expr)
+	case binaryExpr:
+		switch e.op.name {
+		case "AND", "OR":
+			return containable(e.l) && containable(e.r)
+		case "=", "IN":
+			return true
+		}
+	}
+	return false
+}
+
 func mergeObjects(o1, o2 interface{}) interface{} {
 	s1, ok1 := o1.([]interface{})
 	s2, ok2 := o2.([]interface{})
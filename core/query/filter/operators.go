@@ -8,5 +8,16 @@ type binaryOp struct {
 var binaryOps = map[string]*binaryOp{
 	"OR":  {1, "OR"},
 	"AND": {2, "AND"},
-	"=":   {3, "="},
+	"=":   {4, "="},
+	"!=":  {4, "!="},
+	"<":   {4, "<"},
+	"<=":  {4, "<="},
+	">":   {4, ">"},
+	">=":  {4, ">="},
+	"IN":  {4, "IN"},
 }
+
+// notPrecedence is the precedence of the unary NOT operator.
+// It binds more tightly than AND, but more loosely than the
+// comparison operators, so `NOT a = 1` is `NOT (a = 1)`.
+const notPrecedence = 3
@@ -157,7 +157,12 @@ func parseExprCont(p *parser, lhs expr, minPrecedence int) expr {
 		}
 		p.next()
 
-		rhs := parsePrimaryExpr(p)
+		var rhs expr
+		if op.name == "IN" {
+			rhs = parseListExpr(p)
+		} else {
+			rhs = parsePrimaryExpr(p)
+		}
 
 		for {
 			op2, ok := determineBinaryOp(p, op.precedence+1)
@@ -181,6 +186,10 @@ func parsePrimaryExpr(p *parser) expr {
 
 func parseOperand(p *parser) expr {
 	switch {
+	case p.tok == tokKeyword && p.lit == "NOT":
+		p.next()
+		inner := parsePrimaryExpr(p)
+		return notExpr{inner: parseExprCont(p, inner, notPrecedence+1)}
 	case p.lit == "(":
 		p.next()
 		expr := parseExpr(p)
@@ -218,6 +227,20 @@ func parseOperand(p *parser) expr {
 	}
 }
 
+func parseListExpr(p *parser) expr {
+	p.parseLit("(")
+	var items []expr
+	for {
+		items = append(items, parsePrimaryExpr(p))
+		if p.lit != "," {
+			break
+		}
+		p.next()
+	}
+	p.parseLit(")")
+	return listExpr{items: items}
+}
+
 func parseSelectorExpr(p *parser, objExpr expr) expr {
 	p.next() // move past the '.'
 
@@ -113,6 +113,47 @@ func TestParseValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			p: "amount >= 10 AND NOT asset_alias IN ('usd', $1)",
+			expr: binaryExpr{
+				op: binaryOps["AND"],
+				l: binaryExpr{
+					op: binaryOps[">="],
+					l:  attrExpr{attr: "amount"},
+					r:  valueExpr{typ: tokInteger, value: "10"},
+				},
+				r: notExpr{
+					inner: binaryExpr{
+						op: binaryOps["IN"],
+						l:  attrExpr{attr: "asset_alias"},
+						r: listExpr{items: []expr{
+							valueExpr{typ: tokString, value: "'usd'"},
+							placeholderExpr{num: 1},
+						}},
+					},
+				},
+			},
+		},
+		{
+			p: "NOT (a != 1 OR b < 2)",
+			expr: notExpr{
+				inner: parenExpr{
+					inner: binaryExpr{
+						op: binaryOps["OR"],
+						l: binaryExpr{
+							op: binaryOps["!="],
+							l:  attrExpr{attr: "a"},
+							r:  valueExpr{typ: tokInteger, value: "1"},
+						},
+						r: binaryExpr{
+							op: binaryOps["<"],
+							l:  attrExpr{attr: "b"},
+							r:  valueExpr{typ: tokInteger, value: "2"},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -141,6 +182,11 @@ func TestParseInvalid(t *testing.T) {
 		"an_identifier another_identifier",            // two identifiers w/o an operator (trailing garbage)
 		"inputs(account_tags.level = $1) or (1 == 1)", // lowercase 'or' (trailing garbage)
 		"reference.(recipient.email_address)`",        // expected ident, got paren expr
+		"a ! = 1",                                     // ! must be followed by =
+		"a IN ()",                                     // empty list
+		"a IN 1",                                      // IN without a list
+		"a IN (1, 2",                                  // unterminated list
+		"NOT",                                         // NOT without operand
 	}
 	for _, tc := range testCases {
 		expr, _, err := parse(tc)
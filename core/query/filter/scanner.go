@@ -171,7 +171,7 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 	case isLetter(ch):
 		lit = s.scanIdentifier()
 		switch lit {
-		case "AND", "OR":
+		case "AND", "OR", "NOT", "IN":
 			tok = tokKeyword
 		default:
 			tok = tokIdent
@@ -188,7 +188,18 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 		case '\'':
 			tok = tokString
 			s.scanString()
-		case '.', '(', ')', '=':
+		case '.', '(', ')', ',', '=':
+			tok = tokPunct
+		case '<', '>':
+			if s.ch == '=' {
+				s.next()
+			}
+			tok = tokPunct
+		case '!':
+			if s.ch != '=' {
+				s.error(pos, "illegal character '!'")
+			}
+			s.next()
 			tok = tokPunct
 		case '$':
 			s.scanMantissa(10)
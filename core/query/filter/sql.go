@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"github.com/lib/pq"
+
+	"chain/errors"
 )
 
 // AsSQL translates p to SQL.
@@ -75,32 +77,167 @@ func asSQL(e expr, dataColumn string, values []interface{}) (exp SQLExpr, err er
 		}
 	}
 
-	matches := matchingObjects(e, pvals)
+	g := &sqlGenerator{pvals: pvals}
+	err = g.writeExpr(e, dataColumn)
+	if err != nil {
+		return exp, err
+	}
+	return SQLExpr{
+		SQL:    g.buf.String(),
+		Values: g.params,
+	}, nil
+}
+
+// sqlGenerator accumulates the SQL text and parameter
+// values while translating an expression to SQL.
+type sqlGenerator struct {
+	pvals  map[int]interface{}
+	buf    bytes.Buffer
+	params []interface{}
+	depth  int // nesting depth of existential subqueries
+}
+
+// writeExpr writes SQL evaluating e against the jsonb value
+// in col. The generated SQL always evaluates to true or false,
+// never NULL, so that it composes with NOT.
+//
+// Subexpressions that consist only of =, IN, AND, OR and
+// existential quantifiers are translated to jsonb containment
+// queries, which can use the GIN indexes on the data columns.
+func (g *sqlGenerator) writeExpr(e expr, col string) error {
+	if containable(e) {
+		return g.writeContainment(e, col)
+	}
+
+	switch e := e.(type) {
+	case parenExpr:
+		return g.writeExpr(e.inner, col)
+	case notExpr:
+		g.buf.WriteString("NOT ")
+		return g.writeParenthesized(e.inner, col)
+	case envExpr:
+		g.depth++
+		elem := "e" + strconv.Itoa(g.depth)
+		arr := col + "->" + quoteString(e.ident)
+		g.buf.WriteString("EXISTS (SELECT 1 FROM jsonb_array_elements(")
+		g.buf.WriteString("CASE jsonb_typeof(" + arr + ") WHEN 'array' THEN " + arr + " ELSE '[]' END")
+		g.buf.WriteString(") AS " + elem + "(value) WHERE ")
+		err := g.writeExpr(e.expr, elem+".value")
+		g.depth--
+		g.buf.WriteString(")")
+		return err
+	case binaryExpr:
+		switch e.op.name {
+		case "AND", "OR":
+			g.buf.WriteString("(")
+			err := g.writeExpr(e.l, col)
+			if err != nil {
+				return err
+			}
+			g.buf.WriteString(" " + e.op.name + " ")
+			err = g.writeExpr(e.r, col)
+			if err != nil {
+				return err
+			}
+			g.buf.WriteString(")")
+			return nil
+		case "!=":
+			// x != y is NOT (x = y); a missing attribute is
+			// never equal to anything.
+			g.buf.WriteString("NOT ")
+			eq := binaryExpr{op: binaryOps["="], l: e.l, r: e.r}
+			return g.writeParenthesized(eq, col)
+		case "<", "<=", ">", ">=":
+			return g.writeComparison(e, col)
+		}
+	}
+	return errors.WithDetailf(ErrBadFilter, "unsupported expression %s", e)
+}
+
+func (g *sqlGenerator) writeParenthesized(e expr, col string) error {
+	g.buf.WriteString("(")
+	err := g.writeExpr(e, col)
+	g.buf.WriteString(")")
+	return err
+}
+
+func (g *sqlGenerator) writeContainment(e expr, col string) error {
+	matches := matchingObjects(e, g.pvals)
+	if len(matches) == 0 {
+		g.buf.WriteString("false")
+		return nil
+	}
 
-	var buf bytes.Buffer
-	var params []interface{}
 	if len(matches) > 1 {
-		buf.WriteString("(")
+		g.buf.WriteString("(")
 	}
 	for i, condition := range matches {
 		if i > 0 {
-			buf.WriteString(" OR ")
+			g.buf.WriteString(" OR ")
 		}
 
 		b, err := json.Marshal(condition)
 		if err != nil {
-			return exp, err
+			return err
 		}
 
-		params = append(params, string(b))
-		buf.WriteString("(" + dataColumn + " @> $" + strconv.Itoa(len(params)) + "::jsonb)")
+		g.params = append(g.params, string(b))
+		g.buf.WriteString("(" + col + " @> $" + strconv.Itoa(len(g.params)) + "::jsonb)")
 	}
 	if len(matches) > 1 {
-		buf.WriteString(")")
+		g.buf.WriteString(")")
 	}
+	return nil
+}
 
-	return SQLExpr{
-		SQL:    buf.String(),
-		Values: params,
-	}, nil
+// writeComparison writes an ordering comparison between an
+// attribute and a value. Attributes that are missing or are
+// not numbers never satisfy the comparison.
+func (g *sqlGenerator) writeComparison(e binaryExpr, col string) error {
+	op, v, path := comparisonOperands(e, g.pvals)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	g.params = append(g.params, string(b))
+
+	// jsonValue returns paths innermost first.
+	attr := col
+	for i := len(path) - 1; i >= 0; i-- {
+		attr += "->" + quoteString(path[i])
+	}
+	g.buf.WriteString("COALESCE(jsonb_typeof(" + attr + ") = 'number' AND ")
+	g.buf.WriteString(attr + " " + op + " $" + strconv.Itoa(len(g.params)) + "::jsonb, false)")
+	return nil
+}
+
+// comparisonOperands returns the operator, value and attribute
+// path of an ordering comparison, swapping the operands if
+// necessary so that the attribute is on the left. The value must
+// be a number: jsonb orders every number before every string, so
+// comparing a number attribute with a string is never useful.
+func comparisonOperands(e binaryExpr, pvals map[int]interface{}) (op string, v interface{}, path []string) {
+	op = e.op.name
+	lv, lp := jsonValue(e.l, pvals)
+	rv, rp := jsonValue(e.r, pvals)
+	switch {
+	case rv != nil && len(lp) > 0:
+		v, path = rv, lp
+	case lv != nil && len(rp) > 0:
+		v, path = lv, rp
+		op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}[op]
+	default:
+		panic(errors.WithDetailf(ErrBadFilter, "unsupported operands for %s", op))
+	}
+	if _, ok := toNumber(v); !ok {
+		panic(errors.WithDetailf(ErrBadFilter, "%s needs a number, not %v", op, v))
+	}
+	return op, v, path
+}
+
+// quoteString quotes an identifier from a filter as a SQL string
+// literal. Identifiers can only contain letters, digits and
+// underscores, so they never need escaping.
+func quoteString(ident string) string {
+	return "'" + ident + "'"
 }
@@ -3,6 +3,8 @@ package filter
 import (
 	"reflect"
 	"testing"
+
+	"chain/errors"
 )
 
 func TestAsSQL(t *testing.T) {
@@ -66,6 +68,17 @@ func TestAsSQL(t *testing.T) {
 				`{"inputs":[{"account_id":"xyz","ref":{"bank_id":"baz"}}]}`,
 			},
 		},
+		{
+			q:     `asset_alias IN ('usd', $1)`,
+			conds: []interface{}{`{"asset_alias":"usd"}`, `{"asset_alias":"foo"}`},
+		},
+		{
+			q: `inputs(account_id = $2 AND asset_id IN ($1, $3))`,
+			conds: []interface{}{
+				`{"inputs":[{"account_id":"bar","asset_id":"foo"}]}`,
+				`{"inputs":[{"account_id":"bar","asset_id":"baz"}]}`,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -83,3 +96,75 @@ func TestAsSQL(t *testing.T) {
 		}
 	}
 }
+
+func TestAsSQLNonContainment(t *testing.T) {
+	placeholderValues := []interface{}{"foo", 100}
+	testCases := []struct {
+		q      string
+		sql    string
+		values []interface{}
+	}{
+		{
+			q:      `amount > $2`,
+			sql:    `COALESCE(jsonb_typeof(data->'amount') = 'number' AND data->'amount' > $1::jsonb, false)`,
+			values: []interface{}{`100`},
+		},
+		{
+			q:      `10 <= ref.amount`,
+			sql:    `COALESCE(jsonb_typeof(data->'ref'->'amount') = 'number' AND data->'ref'->'amount' >= $1::jsonb, false)`,
+			values: []interface{}{`10`},
+		},
+		{
+			q:      `asset_alias != 'usd'`,
+			sql:    `NOT ((data @> $1::jsonb))`,
+			values: []interface{}{`{"asset_alias":"usd"}`},
+		},
+		{
+			q:      `amount > $2 AND NOT asset_alias IN ('usd', 'eur')`,
+			sql:    `(COALESCE(jsonb_typeof(data->'amount') = 'number' AND data->'amount' > $1::jsonb, false) AND NOT (((data @> $2::jsonb) OR (data @> $3::jsonb))))`,
+			values: []interface{}{`100`, `{"asset_alias":"usd"}`, `{"asset_alias":"eur"}`},
+		},
+		{
+			q: `inputs(amount < 5 AND asset_id = $1)`,
+			sql: `EXISTS (SELECT 1 FROM jsonb_array_elements(CASE jsonb_typeof(data->'inputs') WHEN 'array' THEN data->'inputs' ELSE '[]' END) AS e1(value) ` +
+				`WHERE (COALESCE(jsonb_typeof(e1.value->'amount') = 'number' AND e1.value->'amount' < $1::jsonb, false) AND (e1.value @> $2::jsonb)))`,
+			values: []interface{}{`5`, `{"asset_id":"foo"}`},
+		},
+		{
+			q:      `amount >= 0x64`,
+			sql:    `COALESCE(jsonb_typeof(data->'amount') = 'number' AND data->'amount' >= $1::jsonb, false)`,
+			values: []interface{}{`100`},
+		},
+	}
+
+	for _, tc := range testCases {
+		e, _, err := parse(tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sqlExpr, err := asSQL(e, "data", placeholderValues)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlExpr.SQL != tc.sql {
+			t.Errorf("AsSQL(%q).SQL = %s, want %s", tc.q, sqlExpr.SQL, tc.sql)
+		}
+		if !reflect.DeepEqual(sqlExpr.Values, tc.values) {
+			t.Errorf("AsSQL(%q).Values = %#v, want %#v", tc.q, sqlExpr.Values, tc.values)
+		}
+	}
+}
+
+func TestAsSQLNonNumericComparison(t *testing.T) {
+	for _, q := range []string{`amount > $1`, `$1 <= amount`} {
+		p, err := Parse(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = AsSQL(p, "data", []interface{}{"100"})
+		if errors.Root(err) != ErrBadFilter {
+			t.Errorf("AsSQL(%q) error = %v, want %v", q, err, ErrBadFilter)
+		}
+	}
+}
@@ -37,6 +37,9 @@ func typeCheckExpr(expr expr) (typ Type, err error) {
 		if err != nil {
 			return leftTyp, err
 		}
+		if e.op.name == "IN" {
+			return typeCheckIn(leftTyp, e.r)
+		}
 		rightTyp, err := typeCheckExpr(e.r)
 		if err != nil {
 			return rightTyp, err
@@ -48,7 +51,7 @@ func typeCheckExpr(expr expr) (typ Type, err error) {
 				return typ, fmt.Errorf("%s expects bool operands", e.op.name)
 			}
 			return Bool, nil
-		case "=":
+		case "=", "!=":
 			if !isType(leftTyp, String) && !isType(leftTyp, Integer) {
 				return typ, fmt.Errorf("%s expects integer or string operands", e.op.name)
 			}
@@ -59,9 +62,25 @@ func typeCheckExpr(expr expr) (typ Type, err error) {
 				return typ, fmt.Errorf("%s expects operands of matching types", e.op.name)
 			}
 			return Bool, nil
+		case "<", "<=", ">", ">=":
+			if !isType(leftTyp, Integer) || !isType(rightTyp, Integer) {
+				return typ, fmt.Errorf("%s expects integer operands", e.op.name)
+			}
+			return Bool, nil
 		default:
 			panic(fmt.Errorf("unsupported operator: %s", e.op.name))
 		}
+	case notExpr:
+		typ, err = typeCheckExpr(e.inner)
+		if err != nil {
+			return typ, err
+		}
+		if !isType(typ, Bool) {
+			return typ, errors.New("NOT expects a bool operand")
+		}
+		return Bool, nil
+	case listExpr:
+		return typ, errors.New("list can only be used on the right side of IN")
 	case placeholderExpr:
 		return Any, nil
 	case attrExpr:
@@ -97,3 +116,31 @@ func typeCheckExpr(expr expr) (typ Type, err error) {
 		panic(fmt.Errorf("unrecognized expr type %T", expr))
 	}
 }
+
+// typeCheckIn checks the operands of `lhs IN (item, ...)`. All of the
+// operands must be scalars of the same type.
+func typeCheckIn(leftTyp Type, rhs expr) (Type, error) {
+	list, ok := rhs.(listExpr)
+	if !ok {
+		return Any, errors.New("IN expects a list")
+	}
+	if !isType(leftTyp, String) && !isType(leftTyp, Integer) {
+		return Any, errors.New("IN expects integer or string operands")
+	}
+	for _, item := range list.items {
+		itemTyp, err := typeCheckExpr(item)
+		if err != nil {
+			return itemTyp, err
+		}
+		if !isType(itemTyp, String) && !isType(itemTyp, Integer) {
+			return Any, errors.New("IN expects integer or string operands")
+		}
+		if knownType(itemTyp) && knownType(leftTyp) && itemTyp != leftTyp {
+			return Any, errors.New("IN expects operands of matching types")
+		}
+		if knownType(itemTyp) {
+			leftTyp = itemTyp
+		}
+	}
+	return Bool, nil
+}
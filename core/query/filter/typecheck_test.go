@@ -10,6 +10,11 @@ func TestTypeCheckInvalid(t *testing.T) {
 		{p: `INPUTS('hello')`},
 		{p: `foo(1=1).bar`},
 		{p: `'hello'.foo`},
+		{p: `amount > 'ten'`},
+		{p: `NOT 1`},
+		{p: `a IN (1, 'one')`},
+		{p: `'a' IN (1, 2)`},
+		{p: `a != 1 = 1`},
 	}
 
 	for _, tc := range testCases {
@@ -38,6 +43,9 @@ func TestTypeCheckValid(t *testing.T) {
 		{p: `$1 = 'hello' OR account_tags.something = $1`, typ: Bool},
 		{p: `($1 = 'hello') OR (account_tags.something = $1)`, typ: Bool},
 		{p: `inputs(account_tags.domestic AND account_tags.type = 'revolving')`, typ: Bool},
+		{p: `amount > $1 AND NOT asset_alias IN ('usd', 'eur')`, typ: Bool},
+		{p: `outputs(amount <= 10 OR amount >= 0x64) AND ref.id != 'abc'`, typ: Bool},
+		{p: `NOT account_tags.domestic`, typ: Bool},
 	}
 
 	for _, tc := range testCases {
@@ -54,6 +54,24 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
 	return ind.insertAnnotatedOutputs(ctx, b, txs)
 }
 
+// AnnotatePendingTxs returns annotated transaction objects for txs,
+// which are not yet in a block. Unlike indexed transactions, they
+// have no timestamp, block or position.
+func (ind *Indexer) AnnotatePendingTxs(ctx context.Context, txs []*bc.Tx) ([]map[string]interface{}, error) {
+	annotated := make([]map[string]interface{}, 0, len(txs))
+	for _, tx := range txs {
+		annotated = append(annotated, pendingTransactionObject(tx))
+	}
+	for _, annotator := range ind.annotators {
+		err := annotator(ctx, annotated)
+		if err != nil {
+			return nil, errors.Wrap(err, "adding external annotations")
+		}
+	}
+	localAnnotator(ctx, annotated)
+	return annotated, nil
+}
+
 func (ind *Indexer) insertBlock(ctx context.Context, b *bc.Block) error {
 	const q = `
 		INSERT INTO query_blocks (height, timestamp) VALUES($1, $2)
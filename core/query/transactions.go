@@ -9,6 +9,7 @@ import (
 	"strconv"
 
 	"chain/core/query/filter"
+	"chain/database/pg"
 	"chain/errors"
 )
 
@@ -83,7 +84,7 @@ func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals [
 	queryStr, queryArgs := constructTransactionsQuery(expr, after, asc, limit)
 
 	if asc {
-		return ind.waitForAndFetchTransactions(ctx, queryStr, queryArgs, after, limit)
+		return ind.waitForAndFetchTransactions(ctx, p, vals, queryStr, queryArgs, after, limit)
 	}
 	return ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
 }
@@ -154,7 +155,12 @@ type fetchResp struct {
 	err   error
 }
 
-func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
+// waitForAndFetchTransactions returns the transactions matching p
+// that follow after, waiting for new blocks if there are none yet.
+// Once the indexed history has been searched, each new block's
+// transactions are evaluated in memory with filter.Eval rather
+// than by querying the whole of annotated_txs again.
+func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, p filter.Predicate, vals []interface{}, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
 	resp := make(chan fetchResp, 1)
 	go func() {
 		var (
@@ -163,14 +169,15 @@ func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, queryStr st
 			err error
 		)
 
-		for h := ind.c.Height(); len(txs) == 0; h++ {
+		start := ind.c.Height()
+		for h := start; len(txs) == 0; h++ {
 			<-ind.pinStore.PinWaiter(TxPinName, h)
-			if err != nil {
-				resp <- fetchResp{nil, nil, err}
-				return
-			}
 
-			txs, aft, err = ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+			if h == start {
+				txs, aft, err = ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+			} else {
+				txs, aft, err = ind.evalBlockTransactions(ctx, p, vals, h, after, limit)
+			}
 			if err != nil {
 				resp <- fetchResp{nil, nil, err}
 				return
@@ -190,3 +197,43 @@ func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, queryStr st
 		return r.txns, r.after, r.err
 	}
 }
+
+// evalBlockTransactions returns up to limit of the transactions
+// in the block at height that follow after and match p.
+func (ind *Indexer) evalBlockTransactions(ctx context.Context, p filter.Predicate, vals []interface{}, height uint64, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
+	if height > after.StopBlockHeight {
+		return nil, &after, nil
+	}
+
+	const q = `
+		SELECT tx_pos, data FROM annotated_txs
+		WHERE block_height = $1 AND (block_height, tx_pos) > ($2, $3)
+		ORDER BY tx_pos ASC
+	`
+	txns := make([]interface{}, 0, limit)
+	err := pg.ForQueryRows(ctx, ind.db, q, height, after.FromBlockHeight, after.FromPosition, func(pos uint32, data []byte) error {
+		if len(txns) >= limit {
+			return nil
+		}
+		var tx map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err := dec.Decode(&tx)
+		if err != nil {
+			return errors.Wrap(err, "decoding annotated transaction")
+		}
+		ok, err := filter.Eval(p, vals, tx)
+		if err != nil {
+			return errors.Wrap(err, "evaluating filter")
+		}
+		if ok {
+			txns = append(txns, (*json.RawMessage)(&data))
+			after.FromBlockHeight, after.FromPosition = height, pos
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return txns, &after, nil
+}
@@ -2,14 +2,22 @@ package query
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"reflect"
 	"testing"
 
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/pin"
 	"chain/core/query/filter"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
 )
 
 func TestDecodeTxAfter(t *testing.T) {
@@ -130,3 +138,86 @@ func TestConstructTransactionsQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestTransactionsStreaming(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	assets.IndexAssets(indexer)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset1, err := assets.Define(ctx, []string{testutil.TestXPub.String()}, 1, nil, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(TxPinName, c.Height())
+
+	p, err := filter.Parse(`outputs(account_id = $1 AND amount > $2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := []interface{}{acct.ID, json.Number("100")}
+	after := TxAfter{
+		FromBlockHeight: c.Height(),
+		FromPosition:    math.MaxInt32,
+		StopBlockHeight: math.MaxInt64,
+	}
+
+	type result struct {
+		txs   []interface{}
+		after *TxAfter
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		txs, aft, err := indexer.Transactions(ctx, p, vals, after, 10, true)
+		ch <- result{txs, aft, err}
+	}()
+
+	coretest.IssueAssets(ctx, t, c, assets, accounts, asset1.AssetID, 50, acct.ID)
+	prottest.MakeBlock(t, c)
+	out := coretest.IssueAssets(ctx, t, c, assets, accounts, asset1.AssetID, 867, acct.ID)
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(TxPinName, c.Height())
+
+	got := <-ch
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if len(got.txs) != 1 {
+		t.Fatalf("got %d transactions, want 1", len(got.txs))
+	}
+	var tx struct{ ID bc.Hash }
+	err = json.Unmarshal(*got.txs[0].(*json.RawMessage), &tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.ID != out.Outpoint.Hash {
+		t.Errorf("got transaction %s, want %s", tx.ID, out.Outpoint.Hash)
+	}
+	if got.after.FromBlockHeight != c.Height() {
+		t.Errorf("got after height %d, want %d", got.after.FromBlockHeight, c.Height())
+	}
+
+	// Evaluating the last block in memory agrees with the SQL query.
+	txs, _, err := indexer.evalBlockTransactions(ctx, p, vals, c.Height(), after, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(txs, got.txs) {
+		t.Errorf("evalBlockTransactions = %v, want %v", txs, got.txs)
+	}
+}
@@ -22,10 +22,11 @@ func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*tx
 	txfeeds := make([]*txfeed.TxFeed, 0, limit)
 	for rows.Next() {
 		var (
-			feed  txfeed.TxFeed
-			alias sql.NullString
+			feed       txfeed.TxFeed
+			alias      sql.NullString
+			webhookURL sql.NullString
 		)
-		err := rows.Scan(&feed.ID, &alias, &feed.Filter, &feed.After)
+		err := rows.Scan(&feed.ID, &alias, &feed.Filter, &feed.After, &webhookURL)
 		if err != nil {
 			return nil, "", errors.Wrap(err, "scanning txfeed row")
 		}
@@ -33,6 +34,7 @@ func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*tx
 		if alias.Valid {
 			feed.Alias = &alias.String
 		}
+		feed.WebhookURL = webhookURL.String
 
 		after = feed.ID
 		txfeeds = append(txfeeds, &feed)
@@ -48,7 +50,7 @@ func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*tx
 func constructTxFeedsQuery(after string, limit int) (string, []interface{}) {
 	var vals []interface{}
 
-	q := "SELECT id, alias, filter, after FROM txfeeds WHERE "
+	q := "SELECT id, alias, filter, after, webhook_url FROM txfeeds WHERE "
 	// add after conditions
 	q += fmt.Sprintf("($%d='' OR id < $%d) ", len(vals)+1, len(vals)+1)
 	vals = append(vals, after)
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"strconv"
+
+	"chain/core/query"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /list-reservations
+//
+// listReservations lists the reservations holding account outputs
+// for transactions being built, oldest first.
+func (h *Handler) listReservations(ctx context.Context, in requestQuery) (page, error) {
+	var (
+		after uint64
+		err   error
+	)
+	if in.After != "" {
+		after, err = strconv.ParseUint(in.After, 10, 64)
+		if err != nil {
+			return page{}, errors.Wrap(query.ErrBadAfter, err.Error())
+		}
+	}
+
+	limit := defGenericPageSize
+	reservations, after, err := h.Accounts.ListReservations(ctx, after, limit)
+	if err != nil {
+		return page{}, err
+	}
+
+	out := in
+	out.After = strconv.FormatUint(after, 10)
+	return page{
+		Items:    httpjson.Array(reservations),
+		LastPage: len(reservations) < limit,
+		Next:     out,
+	}, nil
+}
+
+// POST /cancel-reservation
+//
+// cancelReservation releases the outputs held by a reservation,
+// so that they may be spent by other transactions.
+func (h *Handler) cancelReservation(ctx context.Context, in struct {
+	ID uint64 `json:"id"`
+}) error {
+	return h.Accounts.CancelReservation(ctx, in.ID)
+}
@@ -1,12 +1,15 @@
 package core
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"net/http"
 
+	"chain/core/fetch"
 	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/log"
 	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 )
@@ -29,6 +32,24 @@ func (h *Handler) getBlockRPC(ctx context.Context, height uint64) (chainjson.Hex
 	return rawBlock, nil
 }
 
+// getFilteredBlockRPC is like getBlockRPC, but returns only the
+// transactions of the block that pay to or spend from control
+// programs matching the filter, with proofs that they are in
+// the block.
+// Light clients use it in place of getBlockRPC.
+func (h *Handler) getFilteredBlockRPC(ctx context.Context, filter fetch.BlockFilter) (*fetch.FilteredBlock, error) {
+	err := <-h.Chain.BlockSoonWaiter(ctx, filter.Height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for block at height %d", filter.Height)
+	}
+
+	b, err := h.Chain.GetBlock(ctx, filter.Height)
+	if err != nil {
+		return nil, err
+	}
+	return fetch.FilterBlock(b, filter.Programs)
+}
+
 // getBlocksRPC -- DEPRECATED: use getBlock instead
 func (h *Handler) getBlocksRPC(ctx context.Context, afterHeight uint64) ([]chainjson.HexBytes, error) {
 	block, err := h.getBlockRPC(ctx, afterHeight+1)
@@ -52,6 +73,22 @@ func (h *Handler) getSnapshotInfoRPC(ctx context.Context) (resp snapshotInfoResp
 	return resp, err
 }
 
+// getSnapshotDeltasRPC returns the raw protobuf state deltas
+// of the blocks after the provided height. Non-generators
+// bootstrapping from a snapshot call this endpoint to bring
+// the snapshot up to date without fetching every block.
+func (h *Handler) getSnapshotDeltasRPC(ctx context.Context, afterHeight uint64) ([]chainjson.HexBytes, error) {
+	deltas, err := h.Store.GetRawDeltas(ctx, afterHeight)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]chainjson.HexBytes, 0, len(deltas))
+	for _, d := range deltas {
+		resp = append(resp, d)
+	}
+	return resp, nil
+}
+
 // getSnapshotRPC returns the raw protobuf snapshot at the provided height.
 // Non-generators can call this endpoint to get raw data
 // that they can use to populate their own snapshot table.
@@ -71,11 +108,20 @@ func (h *Handler) getSnapshotRPC(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	data, err := h.Store.GetSnapshot(req.Context(), height)
+	snapshot, err := h.Store.GetSnapshot(req.Context(), height)
 	if err != nil {
 		WriteHTTPError(req.Context(), rw, err)
 		return
 	}
 	rw.Header().Set("Content-Type", "application/x-protobuf")
-	rw.Write(data)
+	w := bufio.NewWriter(rw)
+	_, err = snapshot.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// The response is already under way, so the
+		// peer sees only a truncated snapshot.
+		log.Error(req.Context(), err)
+	}
 }
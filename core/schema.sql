@@ -407,7 +407,23 @@ CREATE TABLE mockhsm (
     prv bytea NOT NULL,
     alias text,
     sort_id bigint DEFAULT nextval('mockhsm_sort_id_seq'::regclass) NOT NULL,
-    key_type text DEFAULT 'chain_kd'::text NOT NULL
+    key_type text DEFAULT 'chain_kd'::text NOT NULL,
+    encrypted boolean DEFAULT false NOT NULL
+);
+
+
+--
+-- Name: mockhsm_passphrase; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mockhsm_passphrase (
+    singleton boolean DEFAULT true NOT NULL,
+    salt bytea NOT NULL,
+    n integer NOT NULL,
+    r integer NOT NULL,
+    p integer NOT NULL,
+    data_key bytea NOT NULL,
+    CONSTRAINT mockhsm_passphrase_singleton CHECK (singleton)
 );
 
 
@@ -445,6 +461,35 @@ CREATE SEQUENCE reservation_seq
     CACHE 1;
 
 
+--
+-- Name: reservations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE reservations (
+    reservation_id bigint DEFAULT nextval('reservation_seq'::regclass) NOT NULL,
+    account_id text NOT NULL,
+    asset_id text NOT NULL,
+    change bigint DEFAULT 0 NOT NULL,
+    expiry timestamp with time zone NOT NULL,
+    client_token text,
+    amount bigint DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: reserved_utxos; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE reserved_utxos (
+    tx_hash text NOT NULL,
+    index integer NOT NULL,
+    reservation_id bigint NOT NULL,
+    account_id text NOT NULL,
+    asset_id text NOT NULL,
+    expiry timestamp with time zone NOT NULL
+);
+
+
 --
 -- Name: signed_blocks; Type: TABLE; Schema: public; Owner: -
 --
@@ -488,16 +533,67 @@ CREATE SEQUENCE signers_key_index_seq
 ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
 
 
+--
+-- Name: signing_policies; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signing_policies (
+    id text DEFAULT next_chain_id('sp'::text) NOT NULL,
+    alias text,
+    xpub text,
+    account_id text,
+    rules jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: signing_policy_spends; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signing_policy_spends (
+    policy_id text NOT NULL,
+    asset_id text NOT NULL,
+    tx_hash text NOT NULL,
+    amount bigint NOT NULL,
+    signed_at timestamp with time zone NOT NULL
+);
+
+
 --
 -- Name: snapshots; Type: TABLE; Schema: public; Owner: -
 --
 
 CREATE TABLE snapshots (
+    height bigint NOT NULL,
+    data bytea NOT NULL,
+    tree_root bytea
+);
+
+
+--
+-- Name: state_deltas; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE state_deltas (
     height bigint NOT NULL,
     data bytea NOT NULL
 );
 
 
+--
+-- Name: state_tree_nodes; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE state_tree_nodes (
+    id bytea NOT NULL,
+    data bytea NOT NULL,
+    left_id bytea,
+    right_id bytea,
+    height bigint DEFAULT 0 NOT NULL
+);
+
+
 --
 -- Name: submitted_txs; Type: TABLE; Schema: public; Owner: -
 --
@@ -509,6 +605,34 @@ CREATE TABLE submitted_txs (
 );
 
 
+--
+-- Name: txfeed_dead_letters_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE txfeed_dead_letters_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: txfeed_dead_letters; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE txfeed_dead_letters (
+    id bigint DEFAULT nextval('txfeed_dead_letters_id_seq'::regclass) NOT NULL,
+    feed_id text NOT NULL,
+    tx_id text NOT NULL,
+    after text NOT NULL,
+    payload jsonb NOT NULL,
+    attempts integer NOT NULL,
+    last_error text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: txfeeds; Type: TABLE; Schema: public; Owner: -
 --
@@ -518,7 +642,11 @@ CREATE TABLE txfeeds (
     alias text,
     filter text,
     after text,
-    client_token text
+    client_token text,
+    webhook_url text,
+    webhook_secret text,
+    webhook_attempts integer DEFAULT 0 NOT NULL,
+    webhook_last_error text
 );
 
 
@@ -705,6 +833,14 @@ ALTER TABLE ONLY mockhsm
     ADD CONSTRAINT mockhsm_pkey PRIMARY KEY (pub);
 
 
+--
+-- Name: mockhsm_passphrase_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mockhsm_passphrase
+    ADD CONSTRAINT mockhsm_passphrase_pkey PRIMARY KEY (singleton);
+
+
 --
 -- Name: query_blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -713,6 +849,30 @@ ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: reservations_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reservations
+    ADD CONSTRAINT reservations_client_token_key UNIQUE (client_token);
+
+
+--
+-- Name: reservations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reservations
+    ADD CONSTRAINT reservations_pkey PRIMARY KEY (reservation_id);
+
+
+--
+-- Name: reserved_utxos_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reserved_utxos
+    ADD CONSTRAINT reserved_utxos_pkey PRIMARY KEY (tx_hash, index);
+
+
 --
 -- Name: signers_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -729,6 +889,30 @@ ALTER TABLE ONLY signers
     ADD CONSTRAINT signers_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: signing_policies_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_policies
+    ADD CONSTRAINT signing_policies_alias_key UNIQUE (alias);
+
+
+--
+-- Name: signing_policies_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_policies
+    ADD CONSTRAINT signing_policies_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: signing_policy_spends_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_policy_spends
+    ADD CONSTRAINT signing_policy_spends_pkey PRIMARY KEY (policy_id, asset_id, tx_hash);
+
+
 --
 -- Name: sort_id_index; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -745,6 +929,22 @@ ALTER TABLE ONLY snapshots
     ADD CONSTRAINT state_trees_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: state_deltas_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY state_deltas
+    ADD CONSTRAINT state_deltas_pkey PRIMARY KEY (height);
+
+
+--
+-- Name: state_tree_nodes_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY state_tree_nodes
+    ADD CONSTRAINT state_tree_nodes_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: submitted_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -753,6 +953,14 @@ ALTER TABLE ONLY submitted_txs
     ADD CONSTRAINT submitted_txs_pkey PRIMARY KEY (tx_hash);
 
 
+--
+-- Name: txfeed_dead_letters_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY txfeed_dead_letters
+    ADD CONSTRAINT txfeed_dead_letters_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: txfeeds_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -847,6 +1055,27 @@ CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
+--
+-- Name: reservations_expiry_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX reservations_expiry_idx ON reservations USING btree (expiry);
+
+
+--
+-- Name: reserved_utxos_account_id_asset_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX reserved_utxos_account_id_asset_id_idx ON reserved_utxos USING btree (account_id, asset_id);
+
+
+--
+-- Name: reserved_utxos_reservation_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX reserved_utxos_reservation_id_idx ON reserved_utxos USING btree (reservation_id);
+
+
 --
 -- Name: signed_blocks_block_height_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -861,6 +1090,27 @@ CREATE UNIQUE INDEX signed_blocks_block_height_idx ON signed_blocks USING btree
 CREATE INDEX signers_type_id_idx ON signers USING btree (type, id);
 
 
+--
+-- Name: signing_policies_account_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX signing_policies_account_id_idx ON signing_policies USING btree (account_id);
+
+
+--
+-- Name: signing_policies_xpub_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX signing_policies_xpub_idx ON signing_policies USING btree (xpub);
+
+
+--
+-- Name: txfeed_dead_letters_feed_id_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX txfeed_dead_letters_feed_id_id_idx ON txfeed_dead_letters USING btree (feed_id, id);
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -876,3 +1126,11 @@ insert into migrations (filename, hash) values ('2016-11-18.0.account.confirmed-
 insert into migrations (filename, hash) values ('2016-11-22.0.account.utxos-indexes.sql', 'f3ea43f592cb06a36b040f0b0b9626ee9174d26d36abef44e68114d0c0aace98');
 insert into migrations (filename, hash) values ('2016-11-23.0.query.jsonb-path-ops.sql', 'adb15b9a6b7b223a17dbfd5f669e44c500b343568a563f87e1ae67ba0f938d55');
 insert into migrations (filename, hash) values ('2016-11-28.0.core.submitted-txs-hash.sql', 'cabbd7fd79a2b672b2d3c854783bde3b8245fe666c50261c3335a0c0501ff2ea');
+insert into migrations (filename, hash) values ('2016-12-01.0.txfeed.webhooks.sql', '80c9ebe9a3ce5b5c6be85486d35fd3cfcd191c741b9dfb0c7be5db1fafc3d6c8');
+insert into migrations (filename, hash) values ('2016-12-02.0.account.reservations.sql', 'd89388593f645e67a9b5da0b14f9807c69e1492ac768249ce0b06a74dfd9d131');
+insert into migrations (filename, hash) values ('2016-12-05.0.account.reservation-amount.sql', 'cbb8a5adec007fd4e03cef61dbf82381f31b6d5d01ad03a1888743783a9c134a');
+insert into migrations (filename, hash) values ('2016-12-06.0.txdb.state-tree-nodes.sql', '22d86fec11b88c7650c5da7ae89a1ffc3954a9debb5b6e40d6664834fd943f0d');
+insert into migrations (filename, hash) values ('2016-12-07.0.txdb.state-deltas.sql', '5b2083db78026299b6a7b98fc7d52c8bd222bcc7a741c10d243c656c319db2f5');
+insert into migrations (filename, hash) values ('2016-12-08.0.mockhsm.passphrase.sql', '2de75e500826f0574c04980db467661ba785870d82704038e270f5c4f62e757b');
+insert into migrations (filename, hash) values ('2016-12-09.0.core.signing-policies.sql', '5849aaa95c08a7e4eb32e86b844787af17ea6ff372d56f18c9ed8797f045afe3');
+insert into migrations (filename, hash) values ('2016-12-10.0.txdb.state-tree-node-heights.sql', '4253c3c25733d29289d7004c017b938066c5d97cd4ce25bb4964e66aad38100e');
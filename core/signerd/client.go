@@ -0,0 +1,54 @@
+package signerd
+
+import (
+	"context"
+
+	"chain/core/hsm"
+	"chain/core/rpc"
+	"chain/core/txbuilder"
+	"chain/errors"
+)
+
+// Client requests signatures from a Server.
+type Client struct {
+	BaseURL     string
+	AccessToken string // of the form "name:secret"
+}
+
+// Errors for the codes in responses.
+var codeErrors = map[string]error{
+	"no_key":   hsm.ErrNoKey,
+	"locked":   hsm.ErrLocked,
+	"bad_hash": ErrBadHash,
+	"rejected": ErrRejected,
+}
+
+// Sign asks the server to sign h with the key xpub, derived
+// with path, for tpl. Its parameters after tpl follow
+// txbuilder.SignFunc. It returns hsm.ErrNoKey if the server
+// doesn't have the key.
+func (c *Client) Sign(ctx context.Context, tpl *txbuilder.Template, xpub string, path [][]byte, h [32]byte) ([]byte, error) {
+	req := signRequest{
+		Template: tpl,
+		XPub:     xpub,
+		Hash:     h[:],
+	}
+	for _, p := range path {
+		req.Path = append(req.Path, p)
+	}
+
+	client := &rpc.Client{BaseURL: c.BaseURL, AccessToken: c.AccessToken}
+	var resp signResponse
+	err := client.Call(ctx, "/sign", req, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting signature from %s", c.BaseURL)
+	}
+	if resp.Code != "" {
+		err, ok := codeErrors[resp.Code]
+		if !ok {
+			err = ErrRemote
+		}
+		return nil, errors.WithDetailf(err, "%s: %s", c.BaseURL, resp.Message)
+	}
+	return resp.Signature, nil
+}
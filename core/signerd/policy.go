@@ -0,0 +1,116 @@
+package signerd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// A Policy limits the transactions that a Server signs
+// with a key. It applies to the inputs the key signs.
+type Policy struct {
+	XPub chainkd.XPub `json:"xpub"`
+
+	// Assets, if not empty, are the only assets
+	// the key may spend or issue.
+	Assets []bc.AssetID `json:"assets"`
+
+	// Accounts, if not empty, are the only accounts the key
+	// may spend from, each given by its account derivation
+	// path, as listed with the account's keys.
+	Accounts []chainjson.HexBytes `json:"accounts"`
+
+	// MaxAmount, if nonzero, limits the total amount of each
+	// asset in the inputs the key signs in one transaction.
+	MaxAmount uint64 `json:"max_amount"`
+}
+
+// ReadPolicies reads a JSON array of policies from r.
+func ReadPolicies(r io.Reader) (map[chainkd.XPub]*Policy, error) {
+	var a []*Policy
+	err := json.NewDecoder(r).Decode(&a)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading policies")
+	}
+	m := make(map[chainkd.XPub]*Policy, len(a))
+	for _, p := range a {
+		if _, ok := m[p.XPub]; ok {
+			return nil, errors.Wrapf(errors.New("duplicate policy"), "xpub %s", p.XPub)
+		}
+		m[p.XPub] = p
+	}
+	return m, nil
+}
+
+// Check returns ErrRejected, with a detail message
+// saying why, if tpl doesn't satisfy p.
+func (p *Policy) Check(tpl *txbuilder.Template) error {
+	xpub := p.XPub.String()
+	totals := make(map[bc.AssetID]uint64)
+	for _, si := range tpl.SigningInstructions {
+		path, ok := keyPath(si, xpub)
+		if !ok {
+			continue
+		}
+		in := tpl.Transaction.Inputs[si.Position]
+		assetID := in.AssetID()
+		if len(p.Assets) > 0 && !containsAsset(p.Assets, assetID) {
+			return errors.WithDetailf(ErrRejected, "input %d: asset %s is not allowed", si.Position, assetID)
+		}
+		if len(p.Accounts) > 0 && (in.IsIssuance() || len(path) == 0 || !containsPath(p.Accounts, path[0])) {
+			return errors.WithDetailf(ErrRejected, "input %d is not from an allowed account", si.Position)
+		}
+		total := totals[assetID] + in.Amount()
+		if total < totals[assetID] || p.MaxAmount > 0 && total > p.MaxAmount {
+			return errors.WithDetailf(ErrRejected, "amount of asset %s exceeds maximum %d", assetID, p.MaxAmount)
+		}
+		totals[assetID] = total
+	}
+	return nil
+}
+
+// keyPath returns the derivation path of xpub
+// in si's witness components, if xpub is there.
+func keyPath(si *txbuilder.SigningInstruction, xpub string) ([]chainjson.HexBytes, bool) {
+	for _, c := range si.WitnessComponents {
+		switch c := c.(type) {
+		case *txbuilder.SignatureWitness:
+			for _, k := range c.Keys {
+				if k.XPub == xpub {
+					return k.DerivationPath, true
+				}
+			}
+		case *txbuilder.ContractWitness:
+			for _, arg := range c.Args {
+				if arg.Key != nil && arg.Key.XPub == xpub {
+					return arg.Key.DerivationPath, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func containsAsset(a []bc.AssetID, id bc.AssetID) bool {
+	for _, x := range a {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPath(a []chainjson.HexBytes, p []byte) bool {
+	for _, x := range a {
+		if bytes.Equal(x, p) {
+			return true
+		}
+	}
+	return false
+}
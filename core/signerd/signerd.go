@@ -0,0 +1,213 @@
+// Package signerd implements a remote signing server, so that
+// keys can be kept on a host separate from the Core, and the
+// client the Core uses to request signatures from it.
+//
+// The protocol follows txbuilder.SignFunc: a request names
+// an xpub, a derivation path, and the 32-byte hash to sign.
+// It also carries the transaction template, so that the server
+// can check that the hash is one that txbuilder.Sign computes
+// for the template, and apply its policy for the key to the
+// decoded transaction.
+package signerd
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"chain/core/hsm"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// Errors returned by Server.Sign, and by Client.Sign when
+// returned by the server.
+var (
+	ErrBadHash  = errors.New("hash is not signed for transaction")
+	ErrRejected = errors.New("rejected by signing policy")
+
+	// ErrRemote is returned by Client.Sign for
+	// other errors reported by the server.
+	ErrRemote = errors.New("remote signer error")
+)
+
+const maxRequestSize = 1e6 // 1MB
+
+type signRequest struct {
+	Template *txbuilder.Template  `json:"transaction"`
+	XPub     string               `json:"xpub"`
+	Path     []chainjson.HexBytes `json:"derivation_path"`
+	Hash     chainjson.HexBytes   `json:"hash"`
+}
+
+type signResponse struct {
+	Signature chainjson.HexBytes `json:"signature,omitempty"`
+	Code      string             `json:"code,omitempty"`
+	Message   string             `json:"message,omitempty"`
+}
+
+// Error codes in responses.
+var errorCodes = map[error]string{
+	hsm.ErrNoKey:               "no_key",
+	hsm.ErrLocked:              "locked",
+	ErrBadHash:                 "bad_hash",
+	ErrRejected:                "rejected",
+	httpjson.ErrBadRequest:     "bad_request",
+	txbuilder.ErrMissingRawTx:  "bad_request",
+	txbuilder.ErrBadTxInputIdx: "bad_request",
+}
+
+// Server serves signing requests at /sign, signing with keys
+// in HSM that have a policy in Policies. It treats keys with
+// no policy as if HSM didn't have them.
+type Server struct {
+	HSM      hsm.HSM
+	Policies map[chainkd.XPub]*Policy
+
+	// AccessToken, of the form "name:secret", is the
+	// HTTP basic authentication clients must present.
+	AccessToken string
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if req.URL.Path != "/sign" {
+		http.NotFound(w, req)
+		return
+	}
+	if !s.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="signerd"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		in   signRequest
+		resp signResponse
+		sig  []byte
+	)
+	err := httpjson.Read(ctx, http.MaxBytesReader(w, req.Body, maxRequestSize), &in)
+	if err == nil {
+		var h [32]byte
+		if len(in.Hash) != len(h) {
+			err = errors.WithDetailf(httpjson.ErrBadRequest, "hash is %d bytes, want %d", len(in.Hash), len(h))
+		} else {
+			copy(h[:], in.Hash)
+			var path [][]byte
+			for _, p := range in.Path {
+				path = append(path, p)
+			}
+			sig, err = s.Sign(ctx, in.Template, in.XPub, path, h)
+		}
+	}
+	if err != nil {
+		code, ok := errorCodes[errors.Root(err)]
+		if !ok {
+			log.Error(ctx, err)
+			code = "internal"
+		}
+		resp.Code = code
+		resp.Message = errors.Root(err).Error()
+		if detail := errors.Detail(err); detail != resp.Message {
+			resp.Message += ": " + detail
+		}
+	}
+	resp.Signature = sig
+	httpjson.Write(ctx, w, http.StatusOK, resp)
+}
+
+func (s *Server) authorized(req *http.Request) bool {
+	user, pw, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(user+":"+pw), []byte(s.AccessToken)) == 1
+}
+
+// Sign signs h with the key xpub, derived with path, if
+// h is a hash that txbuilder.Sign would ask that key to
+// sign for tpl, and tpl satisfies the key's policy.
+func (s *Server) Sign(ctx context.Context, tpl *txbuilder.Template, xpubstr string, path [][]byte, h [32]byte) ([]byte, error) {
+	var xpub chainkd.XPub
+	err := xpub.UnmarshalText([]byte(xpubstr))
+	if err != nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "invalid xpub")
+	}
+	policy, ok := s.Policies[xpub]
+	if !ok {
+		return nil, hsm.ErrNoKey
+	}
+	if tpl == nil || tpl.Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	for _, si := range tpl.SigningInstructions {
+		if si.Position < 0 || si.Position >= len(tpl.Transaction.Inputs) {
+			return nil, errors.WithDetailf(txbuilder.ErrBadTxInputIdx, "position %d", si.Position)
+		}
+	}
+
+	if !signsHash(tpl, xpubstr, path, h) {
+		return nil, ErrBadHash
+	}
+	err = policy.Check(tpl)
+	if err != nil {
+		return nil, err
+	}
+	return s.HSM.XSign(ctx, xpub, path, h[:])
+}
+
+// signsHash reports whether txbuilder.Sign, given tpl,
+// would ask xpub, derived with path, to sign h.
+// Signature programs are computed from tpl, since
+// SignatureWitness doesn't include them in JSON.
+func signsHash(tpl *txbuilder.Template, xpub string, path [][]byte, h [32]byte) bool {
+	for _, si := range tpl.SigningInstructions {
+		for _, c := range si.WitnessComponents {
+			switch c := c.(type) {
+			case *txbuilder.SignatureWitness:
+				if !hasKey(c.Keys, xpub, path) {
+					continue
+				}
+				var want [32]byte
+				sha3pool.Sum256(want[:], txbuilder.BuildSigProgram(tpl, si.Position))
+				if want == h {
+					return true
+				}
+			case *txbuilder.ContractWitness:
+				for _, arg := range c.Args {
+					if arg.Key != nil && keyMatches(*arg.Key, xpub, path) && tpl.Hash(si.Position) == bc.Hash(h) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func hasKey(keys []txbuilder.KeyID, xpub string, path [][]byte) bool {
+	for _, k := range keys {
+		if keyMatches(k, xpub, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func keyMatches(k txbuilder.KeyID, xpub string, path [][]byte) bool {
+	if k.XPub != xpub || len(k.DerivationPath) != len(path) {
+		return false
+	}
+	for i, p := range k.DerivationPath {
+		if string(p) != string(path[i]) {
+			return false
+		}
+	}
+	return true
+}
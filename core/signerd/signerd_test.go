@@ -0,0 +1,145 @@
+package signerd
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"chain/core/hsm"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+type memHSM map[chainkd.XPub]chainkd.XPrv
+
+func (m memHSM) XCreate(context.Context, string) (*hsm.XPub, error) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		return nil, err
+	}
+	m[xpub] = xprv
+	return &hsm.XPub{XPub: xpub}, nil
+}
+
+func (m memHSM) ListKeys(context.Context, []string, string, int) ([]*hsm.XPub, string, error) {
+	return nil, "", nil
+}
+
+func (m memHSM) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
+	xprv, ok := m[xpub]
+	if !ok {
+		return nil, hsm.ErrNoKey
+	}
+	return xprv.Derive(path).Sign(msg), nil
+}
+
+func (m memHSM) Sign(context.Context, ed25519.PublicKey, []byte) ([]byte, error) {
+	return nil, hsm.ErrNoKey
+}
+
+func (m memHSM) Delete(ctx context.Context, xpub chainkd.XPub) error {
+	delete(m, xpub)
+	return nil
+}
+
+func TestSign(t *testing.T) {
+	ctx := context.Background()
+	keys := memHSM{}
+	xpub, _ := keys.XCreate(ctx, "")
+	other, _ := keys.XCreate(ctx, "")
+
+	account := chainjson.HexBytes{1, 2, 3}
+	path := [][]byte{account, {4}}
+	asset := bc.AssetID{1}
+
+	policy := &Policy{
+		XPub:      xpub.XPub,
+		Assets:    []bc.AssetID{asset},
+		Accounts:  []chainjson.HexBytes{account},
+		MaxAmount: 100,
+	}
+	s := &Server{
+		HSM:         keys,
+		Policies:    map[chainkd.XPub]*Policy{xpub.XPub: policy},
+		AccessToken: "core:secret",
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	client := &Client{BaseURL: srv.URL, AccessToken: "core:secret"}
+
+	newTemplate := func(assetID bc.AssetID, amounts ...uint64) *txbuilder.Template {
+		tpl := &txbuilder.Template{Transaction: new(bc.TxData)}
+		for i, amount := range amounts {
+			tpl.Transaction.Inputs = append(tpl.Transaction.Inputs, bc.NewSpendInput(bc.Hash{byte(i)}, 0, nil, assetID, amount, nil, nil))
+			si := &txbuilder.SigningInstruction{Position: i}
+			si.AddWitnessKeys(txbuilder.KeyIDs([]chainkd.XPub{xpub.XPub}, path), 1)
+			tpl.SigningInstructions = append(tpl.SigningInstructions, si)
+		}
+		tpl.Transaction.Outputs = []*bc.TxOutput{bc.NewTxOutput(assetID, 1, []byte{1}, nil)}
+		return tpl
+	}
+	sign := func(tpl *txbuilder.Template) error {
+		return txbuilder.Sign(ctx, tpl, []string{xpub.XPub.String()}, func(ctx context.Context, x string, p [][]byte, h [32]byte) ([]byte, error) {
+			return client.Sign(ctx, tpl, x, p, h)
+		})
+	}
+
+	tpl := newTemplate(asset, 60, 40)
+	err := sign(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := tpl.SigningInstructions[0].WitnessComponents[0].(*txbuilder.SignatureWitness)
+	var h [32]byte
+	sha3pool.Sum256(h[:], sw.Program)
+	if !xpub.XPub.Derive(path).Verify(h[:], sw.Sigs[0]) {
+		t.Error("remote signature does not verify")
+	}
+
+	cases := []struct {
+		tpl  *txbuilder.Template
+		want error
+	}{
+		{newTemplate(bc.AssetID{2}, 1), ErrRejected}, // asset not allowed
+		{newTemplate(asset, 60, 41), ErrRejected},    // more than MaxAmount
+	}
+	for i, c := range cases {
+		err = sign(c.tpl)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: error = %v want %v", i, err, c.want)
+		}
+	}
+
+	// An account not in the policy.
+	path = [][]byte{{9}, {4}}
+	err = sign(newTemplate(asset, 1))
+	if errors.Root(err) != ErrRejected {
+		t.Errorf("other account: error = %v want %v", err, ErrRejected)
+	}
+	path = [][]byte{account, {4}}
+
+	// A hash that doesn't belong to the template.
+	tpl = newTemplate(asset, 1)
+	_, err = client.Sign(ctx, tpl, xpub.XPub.String(), path, [32]byte{1})
+	if errors.Root(err) != ErrBadHash {
+		t.Errorf("wrong hash: error = %v want %v", err, ErrBadHash)
+	}
+
+	// A key with no policy.
+	_, err = client.Sign(ctx, tpl, other.XPub.String(), path, [32]byte{1})
+	if errors.Root(err) != hsm.ErrNoKey {
+		t.Errorf("key without policy: error = %v want %v", err, hsm.ErrNoKey)
+	}
+
+	// A client without the access token.
+	bad := &Client{BaseURL: srv.URL, AccessToken: "core:wrong"}
+	_, err = bad.Sign(ctx, tpl, xpub.XPub.String(), path, [32]byte{1})
+	if err == nil {
+		t.Error("expected error with wrong access token")
+	}
+}
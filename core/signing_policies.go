@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"chain/core/signpolicy"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /create-signing-policy
+func (h *Handler) createSigningPolicy(ctx context.Context, in struct {
+	Alias        string             `json:"alias"`
+	XPub         string             `json:"xpub"`
+	AccountID    string             `json:"account_id"`
+	AccountAlias string             `json:"account_alias"`
+	Rules        []*signpolicy.Rule `json:"rules"`
+}) (*signpolicy.Policy, error) {
+	if in.AccountAlias != "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, errors.Wrap(err, "looking up account")
+		}
+		in.AccountID = acc.ID
+	}
+	return h.SigningPolicies.Create(ctx, in.Alias, in.XPub, in.AccountID, in.Rules)
+}
+
+// POST /list-signing-policies
+func (h *Handler) listSigningPolicies(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	policies, next, err := h.SigningPolicies.List(ctx, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = next
+	return &page{
+		Items:    httpjson.Array(policies),
+		LastPage: len(policies) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /delete-signing-policy
+func (h *Handler) deleteSigningPolicy(ctx context.Context, in struct {
+	ID    string `json:"id"`
+	Alias string `json:"alias"`
+}) error {
+	return h.SigningPolicies.Delete(ctx, in.ID, in.Alias)
+}
+
+// signWithPolicies calls sign, which signs tpl with xpubs, unless
+// that breaks a signing policy, in which case it returns
+// signpolicy.ErrRejected. Spends count against the policies'
+// daily maximums only if sign succeeds. Sign must make any
+// requests to remote signers with the context it is given.
+func (h *Handler) signWithPolicies(ctx context.Context, tpl *txbuilder.Template, xpubs []string, sign func(context.Context) error) error {
+	if h.SigningPolicies == nil {
+		return sign(ctx)
+	}
+	return h.SigningPolicies.Check(ctx, tpl, h.Accounts, xpubs, time.Now(), sign)
+}
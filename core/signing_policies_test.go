@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/pin"
+	"chain/core/signpolicy"
+	"chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestSignWithAccountPolicy(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	go accounts.ProcessBlocks(ctx)
+
+	// No transaction annotators are registered,
+	// as when INDEX_TRANSACTIONS is false.
+	h := &Handler{Accounts: accounts, SigningPolicies: &signpolicy.Store{DB: db}}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	other := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	coretest.IssueAssets(ctx, t, c, assets, accounts, assetID, 100, acc)
+	coretest.IssueAssets(ctx, t, c, assets, accounts, assetID, 100, acc)
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	_, err := h.SigningPolicies.Create(ctx, "", "", acc, []*signpolicy.Rule{
+		{Type: signpolicy.AllowedDestinations, AccountIDs: []string{other}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each spend of 30 makes change back to acc.
+	amount := bc.AssetAmount{AssetID: assetID, Amount: 30}
+	sign := func(dest txbuilder.Action) (signed bool, err error) {
+		tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			accounts.NewSpendAction(amount, acc, nil, nil),
+			dest,
+		}, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = h.signWithPolicies(ctx, tpl, []string{testutil.TestXPub.String()}, func(context.Context) error {
+			signed = true
+			return nil
+		})
+		return signed, err
+	}
+
+	signed, err := sign(accounts.NewControlAction(amount, other, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed {
+		t.Error("spend to an allowed account was not signed")
+	}
+
+	external, err := txbuilder.DecodeControlProgramAction([]byte(fmt.Sprintf(
+		`{"asset_id": "%s", "amount": 30, "control_program": "51"}`, assetID,
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err = sign(external)
+	if errors.Root(err) != signpolicy.ErrRejected {
+		t.Errorf("spend to an external program: error = %v want %v", err, signpolicy.ErrRejected)
+	}
+	if signed {
+		t.Error("spend to an external program was signed")
+	}
+}
@@ -0,0 +1,472 @@
+package signpolicy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// spendRetention is how long Check keeps the amounts
+// transactions spent, for daily maximums. It is more than
+// a day, so that days in any time zone are covered.
+const spendRetention = 48 * time.Hour
+
+// maxSignTime is how long Check lets sign run
+// while it holds locks on policies.
+const maxSignTime = 30 * time.Second
+
+// An AccountFinder finds the accounts that control programs
+// belong to. It is implemented by account.Manager.
+type AccountFinder interface {
+	// ProgramAccounts returns the ID of the account of each of
+	// progs that belongs to one, keyed by the program.
+	ProgramAccounts(ctx context.Context, progs [][]byte) (map[string]string, error)
+}
+
+// Check calls sign if signing tpl with xpubs satisfies every
+// policy that applies, and otherwise returns ErrRejected. The
+// accounts of tpl's inputs and outputs are looked up in accounts
+// by their control programs.
+//
+// If sign succeeds, Check records the amounts tpl spends at time
+// now against the policies' daily maximums. Checking the same
+// transaction again doesn't count it twice. Check locks each
+// policy with a daily maximum from before it reads the policy's
+// spends until it records tpl's, so that concurrent signers
+// can't together exceed the maximum. Sign runs while the
+// policies are locked, and a database transaction is open, so
+// the context Check passes it expires after maxSignTime; sign
+// must use that context for any requests to remote signers.
+func (s *Store) Check(ctx context.Context, tpl *txbuilder.Template, accounts AccountFinder, xpubs []string, now time.Time, sign func(context.Context) error) error {
+	if tpl.Transaction == nil {
+		return errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	v, err := newTxView(ctx, tpl, accounts)
+	if err != nil {
+		return err
+	}
+	policies, err := s.applicable(ctx, xpubs, v.accountIDs())
+	if err != nil {
+		return err
+	}
+
+	var daily []string
+	for _, p := range policies {
+		if p.hasRule(DailyMaxAmount) {
+			daily = append(daily, p.ID)
+		}
+	}
+	if len(daily) == 0 {
+		for _, p := range policies {
+			_, err = p.check(v, xpubs, now, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return sign(ctx)
+	}
+
+	dbtx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer dbtx.Rollback(ctx)
+
+	err = lockPolicies(ctx, dbtx, daily)
+	if err != nil {
+		return err
+	}
+
+	txHash := tpl.Transaction.Hash().String()
+	spent := make(map[*Policy]map[bc.AssetID]uint64)
+	for _, p := range policies {
+		spentSince := func(assetID bc.AssetID, since time.Time) (uint64, error) {
+			return spentSince(ctx, dbtx, p.ID, assetID, txHash, since)
+		}
+		amounts, err := p.check(v, xpubs, now, spentSince)
+		if err != nil {
+			return err
+		}
+		if amounts != nil && p.hasRule(DailyMaxAmount) {
+			spent[p] = amounts
+		}
+	}
+
+	signCtx, cancel := context.WithTimeout(ctx, maxSignTime)
+	defer cancel()
+	err = sign(signCtx)
+	if err != nil {
+		return err
+	}
+	for p, amounts := range spent {
+		err = record(ctx, dbtx, p.ID, txHash, amounts, now)
+		if err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(dbtx.Commit(ctx), "committing signing policy spends")
+}
+
+// lockPolicies locks the policies with the given IDs
+// until the end of dbtx.
+func lockPolicies(ctx context.Context, dbtx pg.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `SELECT id FROM signing_policies WHERE id=ANY($1::text[]) ORDER BY id FOR UPDATE`
+	err := pg.ForQueryRows(ctx, dbtx, q, pq.StringArray(ids), func(string) {})
+	return errors.Wrap(err, "locking signing policies")
+}
+
+// spentSince returns the amount of assetID spent under policyID
+// since the given time, by transactions other than txHash.
+func spentSince(ctx context.Context, db pg.DB, policyID string, assetID bc.AssetID, txHash string, since time.Time) (uint64, error) {
+	const q = `
+		SELECT COALESCE(SUM(amount), 0) FROM signing_policy_spends
+		WHERE policy_id=$1 AND asset_id=$2 AND tx_hash<>$3 AND signed_at>=$4
+	`
+	var amount uint64
+	err := db.QueryRow(ctx, q, policyID, assetID.String(), txHash, since).Scan(&amount)
+	return amount, errors.Wrap(err, "querying signing policy spends")
+}
+
+func record(ctx context.Context, db pg.DB, policyID, txHash string, amounts map[bc.AssetID]uint64, now time.Time) error {
+	var (
+		assetIDs []string
+		values   []int64
+	)
+	for assetID, amount := range amounts {
+		if amount > math.MaxInt64 {
+			amount = math.MaxInt64
+		}
+		assetIDs = append(assetIDs, assetID.String())
+		values = append(values, int64(amount))
+	}
+
+	const q = `
+		INSERT INTO signing_policy_spends (policy_id, asset_id, tx_hash, amount, signed_at)
+		SELECT $1, unnest($2::text[]), $3, unnest($4::bigint[]), $5
+		ON CONFLICT (policy_id, asset_id, tx_hash) DO UPDATE SET amount=excluded.amount
+	`
+	_, err := db.Exec(ctx, q, policyID, pq.StringArray(assetIDs), txHash, pq.Int64Array(values), now)
+	if err != nil {
+		return errors.Wrap(err, "recording signing policy spends")
+	}
+
+	const expireQ = `DELETE FROM signing_policy_spends WHERE policy_id=$1 AND signed_at<$2`
+	_, err = db.Exec(ctx, expireQ, policyID, now.Add(-spendRetention))
+	return errors.Wrap(err, "expiring signing policy spends")
+}
+
+// check checks the rules of p against v, as signed by xpubs at
+// time now. SpentSince reports the amount of an asset spent
+// under p since a given time by other transactions. If p applies
+// to v, check returns the amount of each asset v spends under p;
+// otherwise it returns nil.
+func (p *Policy) check(v *txView, xpubs []string, now time.Time, spentSince func(bc.AssetID, time.Time) (uint64, error)) (map[bc.AssetID]uint64, error) {
+	positions := p.positions(v, xpubs)
+	if len(positions) == 0 {
+		return nil, nil
+	}
+	amounts, accounts := v.spent(positions)
+	for i, r := range p.Rules {
+		reason, err := r.check(v, amounts, accounts, now, spentSince)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			err = errors.WithDetailf(ErrRejected, "policy %s, rule %d (%s): %s", p.name(), i, r.Type, reason)
+			return nil, errors.WithData(err, "policy_id", p.ID, "rule_index", i, "rule_type", r.Type)
+		}
+	}
+	return amounts, nil
+}
+
+// positions returns the positions of the inputs in v that p
+// applies to, when signed by xpubs.
+func (p *Policy) positions(v *txView, xpubs []string) []int {
+	var positions []int
+	for _, si := range v.tpl.SigningInstructions {
+		if si.Position < 0 || si.Position >= len(v.tpl.Transaction.Inputs) {
+			continue // txbuilder.Sign reports this
+		}
+		signers := signingXPubs(si, xpubs)
+		if len(signers) == 0 {
+			continue
+		}
+		if p.XPub != "" && !containsString(signers, p.XPub) {
+			continue
+		}
+		if p.AccountID != "" && v.inputAccount(si.Position) != p.AccountID {
+			continue
+		}
+		positions = append(positions, si.Position)
+	}
+	return positions
+}
+
+func (p *Policy) hasRule(typ string) bool {
+	for _, r := range p.Rules {
+		if r.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) name() string {
+	if p.Alias != nil {
+		return *p.Alias
+	}
+	return p.ID
+}
+
+// check returns why v breaks r, or the empty string if it doesn't.
+func (r *Rule) check(v *txView, amounts map[bc.AssetID]uint64, accounts map[string]bool, now time.Time, spentSince func(bc.AssetID, time.Time) (uint64, error)) (string, error) {
+	switch r.Type {
+	case MaxAmount:
+		for assetID, amount := range amounts {
+			if r.appliesTo(assetID) && amount > r.Amount {
+				return fmt.Sprintf("spends %d of asset %s; maximum is %d", amount, assetID, r.Amount), nil
+			}
+		}
+
+	case DailyMaxAmount:
+		t := now.In(r.location())
+		dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		for assetID, amount := range amounts {
+			if !r.appliesTo(assetID) || amount == 0 {
+				continue
+			}
+			earlier, err := spentSince(assetID, dayStart)
+			if err != nil {
+				return "", err
+			}
+			total := earlier + amount
+			if total < earlier || total > r.Amount {
+				return fmt.Sprintf("spends %d of asset %s after %d today; daily maximum is %d", amount, assetID, earlier, r.Amount), nil
+			}
+		}
+
+	case AllowedDestinations:
+		for i, out := range v.tpl.Transaction.Outputs {
+			if _, ok := amounts[out.AssetID]; !ok {
+				continue
+			}
+			accountID := v.outputAccount(i)
+			if accounts[accountID] {
+				continue // change
+			}
+			if accountID != "" && containsString(r.AccountIDs, accountID) {
+				continue
+			}
+			if r.allowsProgram(out.ControlProgram) {
+				continue
+			}
+			return fmt.Sprintf("output %d is not to an allowed destination", i), nil
+		}
+
+	case RequiredReferenceData:
+		for _, f := range r.Fields {
+			if !hasField(v.refData, f) {
+				return fmt.Sprintf("reference data has no field %q", f), nil
+			}
+		}
+
+	case TimeWindow:
+		// Start and End are checked in validate.
+		start, _ := time.Parse(timeOfDay, r.Start)
+		end, _ := time.Parse(timeOfDay, r.End)
+		t := now.In(r.location())
+		m, s, e := minutes(t), minutes(start), minutes(end)
+		in := s <= m && m < e
+		if e < s {
+			in = s <= m || m < e
+		}
+		if !in {
+			return fmt.Sprintf("time %s is outside %s to %s", t.Format(timeOfDay+" MST"), r.Start, r.End), nil
+		}
+
+	default:
+		return fmt.Sprintf("unknown rule type %q", r.Type), nil
+	}
+	return "", nil
+}
+
+func (r *Rule) appliesTo(assetID bc.AssetID) bool {
+	return r.AssetID == nil || *r.AssetID == assetID
+}
+
+func (r *Rule) allowsProgram(prog []byte) bool {
+	for _, p := range r.ControlPrograms {
+		if bytes.Equal(p, prog) {
+			return true
+		}
+	}
+	return false
+}
+
+func minutes(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// txView is a template with the accounts
+// of its inputs and outputs.
+type txView struct {
+	tpl            *txbuilder.Template
+	inputAccounts  []string
+	outputAccounts []string
+	refData        map[string]interface{}
+}
+
+// newTxView looks up the accounts of tpl's inputs and
+// outputs in accounts, by their control programs.
+func newTxView(ctx context.Context, tpl *txbuilder.Template, accounts AccountFinder) (*txView, error) {
+	tx := tpl.Transaction
+	var progs [][]byte
+	for _, in := range tx.Inputs {
+		if prog := in.ControlProgram(); prog != nil {
+			progs = append(progs, prog)
+		}
+	}
+	for _, out := range tx.Outputs {
+		progs = append(progs, out.ControlProgram)
+	}
+	byProgram, err := accounts.ProgramAccounts(ctx, progs)
+	if err != nil {
+		return nil, errors.Wrap(err, "looking up accounts")
+	}
+
+	v := &txView{
+		tpl:            tpl,
+		inputAccounts:  make([]string, len(tx.Inputs)),
+		outputAccounts: make([]string, len(tx.Outputs)),
+	}
+	for i, in := range tx.Inputs {
+		if prog := in.ControlProgram(); prog != nil {
+			v.inputAccounts[i] = byProgram[string(prog)]
+		}
+	}
+	for i, out := range tx.Outputs {
+		v.outputAccounts[i] = byProgram[string(out.ControlProgram)]
+	}
+	if json.Unmarshal(tx.ReferenceData, &v.refData) != nil {
+		v.refData = nil // not a JSON object, so it has no fields
+	}
+	return v, nil
+}
+
+func (v *txView) inputAccount(i int) string {
+	return v.inputAccounts[i]
+}
+
+func (v *txView) outputAccount(i int) string {
+	return v.outputAccounts[i]
+}
+
+func (v *txView) accountIDs() []string {
+	var ids []string
+	for _, id := range v.inputAccounts {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// spent returns the amount of each asset that the inputs at
+// positions spend, less the amount of outputs back to their
+// accounts, and the set of those accounts.
+func (v *txView) spent(positions []int) (map[bc.AssetID]uint64, map[string]bool) {
+	amounts := make(map[bc.AssetID]uint64)
+	accounts := make(map[string]bool)
+	for _, pos := range positions {
+		in := v.tpl.Transaction.Inputs[pos]
+		total := amounts[in.AssetID()] + in.Amount()
+		if total < in.Amount() {
+			total = math.MaxUint64
+		}
+		amounts[in.AssetID()] = total
+		if id := v.inputAccount(pos); id != "" {
+			accounts[id] = true
+		}
+	}
+	for i, out := range v.tpl.Transaction.Outputs {
+		amount, ok := amounts[out.AssetID]
+		if !ok || !accounts[v.outputAccount(i)] {
+			continue
+		}
+		if out.Amount >= amount {
+			amounts[out.AssetID] = 0
+		} else {
+			amounts[out.AssetID] = amount - out.Amount
+		}
+	}
+	return amounts, accounts
+}
+
+// signingXPubs returns the xpubs among xpubs that
+// sign for si.
+func signingXPubs(si *txbuilder.SigningInstruction, xpubs []string) []string {
+	var signers []string
+	add := func(k txbuilder.KeyID) {
+		if containsString(xpubs, k.XPub) {
+			signers = append(signers, k.XPub)
+		}
+	}
+	for _, c := range si.WitnessComponents {
+		switch c := c.(type) {
+		case *txbuilder.SignatureWitness:
+			for _, k := range c.Keys {
+				add(k)
+			}
+		case *txbuilder.ContractWitness:
+			for _, arg := range c.Args {
+				if arg.Key != nil {
+					add(*arg.Key)
+				}
+			}
+		}
+	}
+	return signers
+}
+
+// hasField reports whether the JSON object m has a non-null
+// value for field, whose parts are separated by dots.
+func hasField(m map[string]interface{}, field string) bool {
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		x, ok := m[part]
+		if !ok || x == nil {
+			return false
+		}
+		if i == len(parts)-1 {
+			return true
+		}
+		m, ok = x.(map[string]interface{})
+		if !ok {
+			return false
+		}
+	}
+	return false
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
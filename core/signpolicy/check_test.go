@@ -0,0 +1,119 @@
+package signpolicy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// programAccounts is a signpolicy.AccountFinder
+// with a fixed map of programs to accounts.
+type programAccounts map[string]string
+
+func (a programAccounts) ProgramAccounts(ctx context.Context, progs [][]byte) (map[string]string, error) {
+	found := make(map[string]string)
+	for _, prog := range progs {
+		if id, ok := a[string(prog)]; ok {
+			found[string(prog)] = id
+		}
+	}
+	return found, nil
+}
+
+func TestPolicyCheck(t *testing.T) {
+	_, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := xpub.String()
+	asset := bc.AssetID{1}
+	other := bc.AssetID{2}
+
+	// Account acc1 spends 100 of asset: 60 to acc2, 30 to an
+	// external program, and 10 back to itself as change.
+	tpl := &txbuilder.Template{Transaction: &bc.TxData{
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(bc.Hash{}, 0, nil, asset, 100, []byte{1}, nil),
+		},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(asset, 60, []byte{2}, nil),
+			bc.NewTxOutput(asset, 30, []byte{3}, nil),
+			bc.NewTxOutput(asset, 10, []byte{1}, nil),
+		},
+		ReferenceData: []byte(`{"approval": {"ticket": "T-1"}}`),
+	}}
+	si := &txbuilder.SigningInstruction{Position: 0}
+	si.AddWitnessKeys(txbuilder.KeyIDs([]chainkd.XPub{xpub}, nil), 1)
+	tpl.SigningInstructions = []*txbuilder.SigningInstruction{si}
+	accounts := programAccounts{"\x01": "acc1", "\x02": "acc2"}
+	v, err := newTxView(context.Background(), tpl, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noon := time.Date(2016, 12, 9, 12, 0, 0, 0, time.UTC)
+	spent := func(bc.AssetID, time.Time) (uint64, error) { return 50, nil }
+
+	cases := []struct {
+		policy  Policy
+		xpubs   []string
+		want    error
+		wantAmt uint64
+	}{
+		{Policy{XPub: key, Rules: []*Rule{{Type: MaxAmount, AssetID: &asset, Amount: 90}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: MaxAmount, AssetID: &asset, Amount: 89}}}, []string{key}, ErrRejected, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: MaxAmount, AssetID: &other, Amount: 1}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: MaxAmount, Amount: 1}}}, nil, nil, 0}, // key not signing
+		{Policy{AccountID: "acc1", Rules: []*Rule{{Type: MaxAmount, Amount: 1}}}, []string{key}, ErrRejected, 0},
+		{Policy{AccountID: "acc2", Rules: []*Rule{{Type: MaxAmount, Amount: 1}}}, []string{key}, nil, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: DailyMaxAmount, Amount: 140}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: DailyMaxAmount, Amount: 139}}}, []string{key}, ErrRejected, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: AllowedDestinations, AccountIDs: []string{"acc2"}, ControlPrograms: []chainjson.HexBytes{{3}}}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: AllowedDestinations, AccountIDs: []string{"acc2"}}}}, []string{key}, ErrRejected, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: RequiredReferenceData, Fields: []string{"approval.ticket"}}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: RequiredReferenceData, Fields: []string{"approval.signer"}}}}, []string{key}, ErrRejected, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: TimeWindow, Start: "09:00", End: "17:00"}}}, []string{key}, nil, 90},
+		{Policy{XPub: key, Rules: []*Rule{{Type: TimeWindow, Start: "22:00", End: "06:00"}}}, []string{key}, ErrRejected, 0},
+		{Policy{XPub: key, Rules: []*Rule{{Type: TimeWindow, Start: "09:00", End: "17:00", TimeZone: "Asia/Tokyo"}}}, []string{key}, ErrRejected, 0},
+	}
+	for i, c := range cases {
+		amounts, err := c.policy.check(v, c.xpubs, noon, spent)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: error = %v want %v", i, err, c.want)
+			continue
+		}
+		if err == nil && amounts[asset] != c.wantAmt {
+			t.Errorf("case %d: amount = %d want %d", i, amounts[asset], c.wantAmt)
+		}
+		if err != nil && errors.Data(err)["rule_type"] != c.policy.Rules[0].Type {
+			t.Errorf("case %d: rule_type = %v want %s", i, errors.Data(err)["rule_type"], c.policy.Rules[0].Type)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	cases := []struct {
+		rule Rule
+		ok   bool
+	}{
+		{Rule{Type: MaxAmount, Amount: 10}, true},
+		{Rule{Type: AllowedDestinations}, false},
+		{Rule{Type: RequiredReferenceData}, false},
+		{Rule{Type: TimeWindow, Start: "09:00", End: "17:00"}, true},
+		{Rule{Type: TimeWindow, Start: "9am", End: "17:00"}, false},
+		{Rule{Type: TimeWindow, Start: "09:00", End: "09:00"}, false},
+		{Rule{Type: DailyMaxAmount, Amount: 10, TimeZone: "Nowhere/Special"}, false},
+		{Rule{Type: "max_fun"}, false},
+	}
+	for _, c := range cases {
+		err := c.rule.validate()
+		if (err == nil) != c.ok {
+			t.Errorf("validate(%+v) = %v, want ok %v", c.rule, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package signpolicy implements signing policies, declarative
+// limits that a Core checks before its HSM signs a transaction
+// template.
+//
+// A policy applies either to one key, named by its xpub, or to
+// one account. A key policy applies to the inputs the key is
+// asked to sign; an account policy applies to the account's
+// inputs, when any key is asked to sign them. A policy with no
+// inputs to apply to in a template is not checked.
+//
+// Each policy has a list of rules, all of which the template
+// must satisfy:
+//
+//	max_amount               limits the amount of an asset spent in one transaction
+//	daily_max_amount         limits the amount of an asset spent in one day
+//	allowed_destinations     limits the accounts and control programs receiving the assets spent
+//	required_reference_data  requires fields in the transaction's reference data
+//	time_window              limits the time of day at which to sign
+//
+// The amount a transaction spends is the total of the inputs a
+// policy applies to, less outputs back to the inputs' accounts.
+// Outputs back to those accounts are change; allowed_destinations
+// applies to the other outputs of the assets spent.
+package signpolicy
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/database/sql"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Rule types.
+const (
+	MaxAmount             = "max_amount"
+	DailyMaxAmount        = "daily_max_amount"
+	AllowedDestinations   = "allowed_destinations"
+	RequiredReferenceData = "required_reference_data"
+	TimeWindow            = "time_window"
+)
+
+// timeOfDay is the format of the start and end of a time window.
+const timeOfDay = "15:04"
+
+var (
+	// ErrBadPolicy is returned by Create for
+	// policies with no scope or with invalid rules.
+	ErrBadPolicy = errors.New("invalid signing policy")
+
+	// ErrDuplicateAlias is returned by Create
+	// when the alias is already in use.
+	ErrDuplicateAlias = errors.New("duplicate signing policy alias")
+
+	// ErrRejected is returned by Check when a template
+	// doesn't satisfy a rule. Its detail message and
+	// data say which.
+	ErrRejected = errors.New("rejected by signing policy")
+)
+
+// Policy is a set of rules for signing transactions
+// with a key or spending from an account.
+// Exactly one of XPub and AccountID is set.
+type Policy struct {
+	ID        string  `json:"id"`
+	Alias     *string `json:"alias"`
+	XPub      string  `json:"xpub,omitempty"`
+	AccountID string  `json:"account_id,omitempty"`
+	Rules     []*Rule `json:"rules"`
+}
+
+// Rule is one rule of a policy. Type says which of
+// the other fields apply.
+type Rule struct {
+	Type string `json:"type"`
+
+	// AssetID and Amount are the limit of rules of type
+	// max_amount and daily_max_amount. If AssetID is nil,
+	// Amount limits each asset.
+	AssetID *bc.AssetID `json:"asset_id,omitempty"`
+	Amount  uint64      `json:"amount,omitempty"`
+
+	// AccountIDs and ControlPrograms are the destinations
+	// allowed by a rule of type allowed_destinations.
+	AccountIDs      []string             `json:"account_ids,omitempty"`
+	ControlPrograms []chainjson.HexBytes `json:"control_programs,omitempty"`
+
+	// Fields are the reference data fields required by a rule
+	// of type required_reference_data. A field may name a
+	// nested field with dots, as in "approval.ticket".
+	Fields []string `json:"fields,omitempty"`
+
+	// Start and End, of the form "15:04", are the times
+	// of day of a rule of type time_window. If End is before
+	// Start, the window includes midnight.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+
+	// TimeZone is the location, as in the IANA Time Zone
+	// database, of the times of a time window, and of the
+	// days of a daily maximum. The default is UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// Store stores signing policies in a database
+// and checks templates against them.
+type Store struct {
+	DB *sql.DB
+}
+
+// Create creates a policy for xpub or accountID,
+// whichever is not empty.
+func (s *Store) Create(ctx context.Context, alias, xpub, accountID string, rules []*Rule) (*Policy, error) {
+	if (xpub == "") == (accountID == "") {
+		return nil, errors.WithDetail(ErrBadPolicy, "policy must have exactly one of xpub and account_id")
+	}
+	if xpub != "" {
+		var k chainkd.XPub
+		err := k.UnmarshalText([]byte(xpub))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadPolicy, "invalid xpub %q", xpub)
+		}
+	}
+	if len(rules) == 0 {
+		return nil, errors.WithDetail(ErrBadPolicy, "policy has no rules")
+	}
+	for i, r := range rules {
+		err := r.validate()
+		if err != nil {
+			return nil, errors.WithDetailf(err, "rule %d", i)
+		}
+	}
+
+	p := &Policy{
+		XPub:      xpub,
+		AccountID: accountID,
+		Rules:     rules,
+	}
+	if alias != "" {
+		p.Alias = &alias
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	const q = `
+		INSERT INTO signing_policies (alias, xpub, account_id, rules)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	err = s.DB.QueryRow(ctx, q, p.Alias, nullString(xpub), nullString(accountID), rulesJSON).Scan(&p.ID)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetailf(ErrDuplicateAlias, "alias %q already in use", alias)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting signing policy")
+	}
+	return p, nil
+}
+
+// List lists policies, most recent first.
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*Policy, string, error) {
+	const q = `
+		SELECT id, alias, xpub, account_id, rules FROM signing_policies
+		WHERE ($1='' OR id<$1)
+		ORDER BY id DESC
+		LIMIT $2
+	`
+	policies, err := s.query(ctx, q, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(policies) > 0 {
+		next = policies[len(policies)-1].ID
+	}
+	return policies, next, nil
+}
+
+// Delete deletes the policy with the given id or alias,
+// along with its record of daily spending.
+func (s *Store) Delete(ctx context.Context, id, alias string) error {
+	const q = `
+		DELETE FROM signing_policies
+		WHERE ($1<>'' AND id=$1) OR ($1='' AND alias=$2)
+		RETURNING id
+	`
+	err := s.DB.QueryRow(ctx, q, id, alias).Scan(&id)
+	if err == stdsql.ErrNoRows {
+		if id == "" {
+			id = alias
+		}
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "could not find signing policy with id/alias=%s", id)
+	}
+	if err != nil {
+		return errors.Wrap(err, "deleting signing policy")
+	}
+
+	const spendsQ = `DELETE FROM signing_policy_spends WHERE policy_id=$1`
+	_, err = s.DB.Exec(ctx, spendsQ, id)
+	return errors.Wrap(err, "deleting signing policy spends")
+}
+
+// applicable returns the policies for any of xpubs or accountIDs.
+func (s *Store) applicable(ctx context.Context, xpubs, accountIDs []string) ([]*Policy, error) {
+	const q = `
+		SELECT id, alias, xpub, account_id, rules FROM signing_policies
+		WHERE xpub=ANY($1::text[]) OR account_id=ANY($2::text[])
+		ORDER BY id
+	`
+	return s.query(ctx, q, pq.StringArray(xpubs), pq.StringArray(accountIDs))
+}
+
+func (s *Store) query(ctx context.Context, q string, args ...interface{}) ([]*Policy, error) {
+	var policies []*Policy
+	args = append(args, func(id string, alias, xpub, accountID stdsql.NullString, rules []byte) error {
+		p := &Policy{ID: id, XPub: xpub.String, AccountID: accountID.String}
+		if alias.Valid {
+			p.Alias = &alias.String
+		}
+		err := json.Unmarshal(rules, &p.Rules)
+		if err != nil {
+			return errors.Wrapf(err, "decoding rules of signing policy %s", id)
+		}
+		policies = append(policies, p)
+		return nil
+	})
+	err := pg.ForQueryRows(ctx, s.DB, q, args...)
+	return policies, errors.Wrap(err, "querying signing policies")
+}
+
+func (r *Rule) validate() error {
+	if r.TimeZone != "" {
+		_, err := time.LoadLocation(r.TimeZone)
+		if err != nil {
+			return errors.WithDetailf(ErrBadPolicy, "unknown time zone %q", r.TimeZone)
+		}
+	}
+	switch r.Type {
+	case MaxAmount, DailyMaxAmount:
+		if r.Amount > math.MaxInt64 {
+			return errors.WithDetailf(ErrBadPolicy, "amount %d is too large", r.Amount)
+		}
+	case AllowedDestinations:
+		if len(r.AccountIDs) == 0 && len(r.ControlPrograms) == 0 {
+			return errors.WithDetail(ErrBadPolicy, "allowed_destinations needs account_ids or control_programs")
+		}
+	case RequiredReferenceData:
+		if len(r.Fields) == 0 {
+			return errors.WithDetail(ErrBadPolicy, "required_reference_data needs fields")
+		}
+	case TimeWindow:
+		start, err := time.Parse(timeOfDay, r.Start)
+		if err != nil {
+			return errors.WithDetailf(ErrBadPolicy, "invalid start %q; want HH:MM", r.Start)
+		}
+		end, err := time.Parse(timeOfDay, r.End)
+		if err != nil {
+			return errors.WithDetailf(ErrBadPolicy, "invalid end %q; want HH:MM", r.End)
+		}
+		if start.Equal(end) {
+			return errors.WithDetail(ErrBadPolicy, "time window is empty")
+		}
+	default:
+		return errors.WithDetailf(ErrBadPolicy, "unknown rule type %q", r.Type)
+	}
+	return nil
+}
+
+func (r *Rule) location() *time.Location {
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return time.UTC // checked in validate
+	}
+	return loc
+}
+
+func nullString(s string) stdsql.NullString {
+	return stdsql.NullString{String: s, Valid: s != ""}
+}
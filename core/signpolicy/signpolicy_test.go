@@ -0,0 +1,139 @@
+package signpolicy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestCreateListDelete(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	s := &Store{DB: db}
+	_, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []*Rule{{Type: MaxAmount, Amount: 10}}
+
+	cases := []struct {
+		xpub, accountID string
+		rules           []*Rule
+		want            error
+	}{
+		{xpub.String(), "", rules, nil},
+		{"", "acc1", rules, nil},
+		{"", "", rules, ErrBadPolicy},
+		{xpub.String(), "acc1", rules, ErrBadPolicy},
+		{"not an xpub", "", rules, ErrBadPolicy},
+		{"", "acc1", nil, ErrBadPolicy},
+		{"", "acc1", []*Rule{{Type: "max_fun"}}, ErrBadPolicy},
+	}
+	for i, c := range cases {
+		_, err := s.Create(ctx, "", c.xpub, c.accountID, c.rules)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: Create error = %v want %v", i, err, c.want)
+		}
+	}
+
+	p, err := s.Create(ctx, "treasury", "", "acc2", rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, _, err := s.List(ctx, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 3 || policies[0].ID != p.ID || *policies[0].Alias != "treasury" {
+		t.Fatalf("List = %+v, want 3 policies starting with %s", policies, p.ID)
+	}
+
+	err = s.Delete(ctx, "", "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Delete(ctx, p.ID, "")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Delete deleted policy: error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestCheckDailyMaximum(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	s := &Store{DB: db}
+	_, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := xpub.String()
+	_, err = s.Create(ctx, "", key, "", []*Rule{{Type: DailyMaxAmount, Amount: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTemplate := func(amount uint64, refData string) *txbuilder.Template {
+		tpl := &txbuilder.Template{Transaction: &bc.TxData{
+			Inputs:        []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{1}, amount, []byte{1}, nil)},
+			Outputs:       []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{1}, amount, []byte{2}, nil)},
+			ReferenceData: []byte(refData),
+		}}
+		si := &txbuilder.SigningInstruction{Position: 0}
+		si.AddWitnessKeys(txbuilder.KeyIDs([]chainkd.XPub{xpub}, nil), 1)
+		tpl.SigningInstructions = []*txbuilder.SigningInstruction{si}
+		return tpl
+	}
+	check := func(tpl *txbuilder.Template, now time.Time) error {
+		return s.Check(ctx, tpl, programAccounts{}, []string{key}, now, func(context.Context) error { return nil })
+	}
+
+	day := time.Date(2016, 12, 9, 10, 0, 0, 0, time.UTC)
+	tpl1 := newTemplate(60, `{"n":1}`)
+	tpl2 := newTemplate(60, `{"n":2}`)
+
+	// A transaction that fails to sign doesn't count.
+	errSign := errors.New("signing failed")
+	err = s.Check(ctx, tpl2, programAccounts{}, []string{key}, day, func(context.Context) error { return errSign })
+	if err != errSign {
+		t.Fatalf("failed sign: error = %v want %v", err, errSign)
+	}
+
+	err = check(tpl1, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Checking the same transaction again doesn't count it twice.
+	err = check(tpl1, day.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = check(tpl2, day.Add(time.Hour))
+	if errors.Root(err) != ErrRejected {
+		t.Errorf("second transaction: error = %v want %v", err, ErrRejected)
+	}
+
+	err = check(tpl2, day.Add(24*time.Hour))
+	if err != nil {
+		t.Errorf("next day: error = %v", err)
+	}
+
+	// While the policy is locked, sign runs with a deadline.
+	err = s.Check(ctx, newTemplate(1, `{"n":3}`), programAccounts{}, []string{key}, day.Add(48*time.Hour), func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("sign context has no deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
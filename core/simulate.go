@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+)
+
+type simulatedInput struct {
+	Index    int    `json:"index"`
+	VMResult bool   `json:"vm_result"`
+	VMError  string `json:"vm_error,omitempty"`
+}
+
+type missingOutput struct {
+	InputIndex    int     `json:"input_index"`
+	TransactionID bc.Hash `json:"transaction_id"`
+	Position      uint32  `json:"position"`
+}
+
+type balanceChange struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias,omitempty"`
+	AssetID      string `json:"asset_id"`
+	Amount       int64  `json:"amount"`
+}
+
+type balanceChanges []*balanceChange
+
+func (a balanceChanges) Len() int      { return len(a) }
+func (a balanceChanges) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a balanceChanges) Less(i, j int) bool {
+	if a[i].AccountID != a[j].AccountID {
+		return a[i].AccountID < a[j].AccountID
+	}
+	return a[i].AssetID < a[j].AssetID
+}
+
+type simulation struct {
+	ID bc.Hash `json:"id"`
+
+	// Valid reports whether the transaction
+	// would be accepted if submitted now.
+	Valid bool `json:"valid"`
+
+	// Errors holds the detail of each
+	// reason the transaction is invalid.
+	Errors []string `json:"errors"`
+
+	Inputs         []simulatedInput `json:"inputs"`
+	MissingOutputs []missingOutput  `json:"missing_outputs"`
+	TimeErrors     []string         `json:"time_errors"`
+	BalanceChanges balanceChanges   `json:"balance_changes"`
+}
+
+// POST /simulate-transaction
+//
+// simulateTxs reports, for each transaction template, whether
+// its transaction would be accepted if submitted now, and what
+// it would do. It checks the transaction against the current
+// blockchain state and a block made now, as the generator
+// would, but does not submit it.
+func (h *Handler) simulateTxs(ctx context.Context, x struct {
+	Transactions []txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp json.RawMessage
+		err := h.forwardToLeader(ctx, "/simulate-transaction", x, &resp)
+		return resp, err
+	}
+
+	responses := make([]interface{}, len(x.Transactions))
+	for i := range responses {
+		func() {
+			defer batchRecover(ctx, &responses[i])
+			sim, err := h.simulateTx(ctx, &x.Transactions[i])
+			if err != nil {
+				responses[i] = err
+			} else {
+				responses[i] = sim
+			}
+		}()
+	}
+	return responses, nil
+}
+
+func (h *Handler) simulateTx(ctx context.Context, tpl *txbuilder.Template) (*simulation, error) {
+	if tpl.Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	tx := bc.NewTx(*tpl.Transaction)
+	sim := &simulation{
+		ID:             tx.Hash,
+		Errors:         []string{},
+		Inputs:         []simulatedInput{},
+		MissingOutputs: []missingOutput{},
+		TimeErrors:     []string{},
+		BalanceChanges: balanceChanges{},
+	}
+
+	for i := range tx.Inputs {
+		ok, err := vm.VerifyTxInput(tx, i)
+		in := simulatedInput{Index: i, VMResult: ok}
+		if err != nil {
+			in.VMError = err.Error()
+		}
+		sim.Inputs = append(sim.Inputs, in)
+	}
+	err := sim.addError(validation.CheckTxWellFormed(tx))
+	if err != nil {
+		return nil, err
+	}
+	err = h.Chain.CheckIssuanceWindow(tx)
+	if err != nil {
+		sim.TimeErrors = append(sim.TimeErrors, errors.Detail(err))
+	}
+	err = sim.addError(err)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the transaction against the current state as
+	// the generator would if it made a block now.
+	prev, snapshot := h.Chain.State()
+	if prev == nil {
+		return nil, errors.New("no blockchain state")
+	}
+	now := bc.Millis(time.Now())
+	if now < prev.TimestampMS {
+		now = prev.TimestampMS
+	}
+	block := &bc.Block{BlockHeader: bc.BlockHeader{
+		Version:     bc.NewBlockVersion,
+		Height:      prev.Height + 1,
+		TimestampMS: now,
+	}}
+	snapshot = state.Copy(snapshot)
+	snapshot.PruneIssuances(now)
+	err = sim.addError(validation.ConfirmTx(snapshot, h.Chain.InitialBlockHash, block, tx))
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.MinTime > now {
+		sim.TimeErrors = append(sim.TimeErrors, "transaction min time is after the current time")
+	}
+	if tx.MaxTime > 0 && tx.MaxTime < now {
+		sim.TimeErrors = append(sim.TimeErrors, "transaction max time has passed")
+	}
+	for i, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
+		}
+		k, val := state.OutputTreeItem(state.Prevout(in))
+		ok, err := snapshot.Tree.Contains(k, val)
+		if err != nil {
+			return nil, errors.Wrap(err, "looking up prevout")
+		}
+		if !ok {
+			o := in.Outpoint()
+			sim.MissingOutputs = append(sim.MissingOutputs, missingOutput{i, o.Hash, o.Index})
+		}
+	}
+	sim.Valid = len(sim.Errors) == 0
+
+	annotated, err := h.Indexer.AnnotatePendingTxs(ctx, []*bc.Tx{tx})
+	if err != nil {
+		return nil, errors.Wrap(err, "annotating transaction")
+	}
+	sim.BalanceChanges = accountBalanceChanges(annotated[0])
+	return sim, nil
+}
+
+// addError records err, if it is a validation error.
+// It returns any other error.
+func (sim *simulation) addError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Root(err) != validation.ErrBadTx {
+		return err
+	}
+	sim.Errors = append(sim.Errors, errors.Detail(err))
+	return nil
+}
+
+// accountBalanceChanges sums the change in the balance of each
+// asset in each local account made by an annotated transaction.
+func accountBalanceChanges(tx map[string]interface{}) balanceChanges {
+	changes := make(map[[2]string]*balanceChange)
+	add := func(items interface{}, sign int64) {
+		list, _ := items.([]interface{})
+		for _, item := range list {
+			m, _ := item.(map[string]interface{})
+			accountID, _ := m["account_id"].(string)
+			if accountID == "" {
+				continue
+			}
+			assetID, _ := m["asset_id"].(string)
+			amount, _ := m["amount"].(uint64)
+			key := [2]string{accountID, assetID}
+			c := changes[key]
+			if c == nil {
+				c = &balanceChange{AccountID: accountID, AssetID: assetID}
+				c.AccountAlias, _ = m["account_alias"].(string)
+				changes[key] = c
+			}
+			c.Amount += sign * int64(amount)
+		}
+	}
+	add(tx["inputs"], -1)
+	add(tx["outputs"], 1)
+
+	result := balanceChanges{}
+	for _, c := range changes {
+		result = append(result, c)
+	}
+	sort.Sort(result)
+	return result
+}
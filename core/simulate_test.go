@@ -0,0 +1,28 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccountBalanceChanges(t *testing.T) {
+	tx := map[string]interface{}{
+		"inputs": []interface{}{
+			map[string]interface{}{"account_id": "acc1", "account_alias": "alice", "asset_id": "a1", "amount": uint64(10)},
+			map[string]interface{}{"asset_id": "a2", "amount": uint64(5)},
+		},
+		"outputs": []interface{}{
+			map[string]interface{}{"account_id": "acc2", "asset_id": "a1", "amount": uint64(7)},
+			map[string]interface{}{"account_id": "acc1", "account_alias": "alice", "asset_id": "a1", "amount": uint64(3)},
+			map[string]interface{}{"asset_id": "a2", "amount": uint64(5)},
+		},
+	}
+	got := accountBalanceChanges(tx)
+	want := balanceChanges{
+		{AccountID: "acc1", AccountAlias: "alice", AssetID: "a1", Amount: -7},
+		{AccountID: "acc2", AssetID: "a1", Amount: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
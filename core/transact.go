@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"chain/net/http/reqid"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/state"
 )
 
 var defaultTxTTL = 5 * time.Minute
@@ -71,15 +73,6 @@ func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilde
 
 // POST /build-transaction
 func (h *Handler) build(ctx context.Context, buildReqs []*buildRequest) (interface{}, error) {
-	// If we're not the leader, we don't have access to the current
-	// reservations. Forward the build call to the leader process.
-	// TODO(jackson): Distribute reservations across cored processes.
-	if !leader.IsLeading() {
-		var resp map[string]interface{}
-		err := h.forwardToLeader(ctx, "/build-transaction", buildReqs, &resp)
-		return resp, err
-	}
-
 	responses := make([]interface{}, len(buildReqs))
 	var wg sync.WaitGroup
 	wg.Add(len(responses))
@@ -224,6 +217,13 @@ func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Temp
 }
 
 func waitForTxInBlock(ctx context.Context, c *protocol.Chain, tx *bc.Tx, height uint64) (uint64, error) {
+	// Remember which of the tx's prevouts are unspent now, so we
+	// can tell when one has been spent by a conflicting tx.
+	since, unspent, err := unspentPrevouts(c, tx)
+	if err != nil {
+		return 0, err
+	}
+
 	for {
 		height++
 		select {
@@ -246,6 +246,11 @@ func waitForTxInBlock(ctx context.Context, c *protocol.Chain, tx *bc.Tx, height
 				return 0, errors.Wrap(txbuilder.ErrRejected, "transaction max time exceeded")
 			}
 
+			err = checkRejected(ctx, c, b, tx, unspent, since)
+			if err != nil {
+				return 0, err
+			}
+
 			// might still be in pool or might be rejected; we can't
 			// tell definitively until its max time elapses.
 
@@ -254,11 +259,129 @@ func waitForTxInBlock(ctx context.Context, c *protocol.Chain, tx *bc.Tx, height
 			if err != nil {
 				return 0, err
 			}
+		}
+	}
+}
+
+// checkRejected returns ErrRejected if tx can no longer be included
+// in a block after b, because a tx in b conflicts with it, or because
+// one of the outputs in unspent has been spent since the block at
+// height since. The conflicting tx's ID is in the error's data.
+func checkRejected(ctx context.Context, c *protocol.Chain, b *bc.Block, tx *bc.Tx, unspent []bc.Outpoint, since uint64) error {
+	spends := make(map[bc.Outpoint]bool)
+	issuances := make(map[bc.Hash]bool)
+	for i, in := range tx.Inputs {
+		if in.IsIssuance() {
+			if h, ok := uniqueIssuanceHash(tx, i); ok {
+				issuances[h] = true
+			}
+			continue
+		}
+		spends[in.Outpoint()] = true
+	}
+
+	for _, other := range b.Transactions {
+		for i, in := range other.Inputs {
+			if in.IsIssuance() {
+				if h, ok := uniqueIssuanceHash(other, i); ok && issuances[h] {
+					err := errors.WithDetailf(txbuilder.ErrRejected, "issuance was already used by transaction %s", other.Hash)
+					return errors.WithData(err, "conflicting_transaction_id", other.Hash)
+				}
+				continue
+			}
+			if spends[in.Outpoint()] {
+				err := errors.WithDetailf(txbuilder.ErrRejected, "output %s was spent by transaction %s", in.Outpoint(), other.Hash)
+				return errors.WithData(err, "conflicting_transaction_id", other.Hash)
+			}
+		}
+	}
+
+	if len(unspent) == 0 {
+		return nil
+	}
+	_, snapshot := c.State()
+	if snapshot == nil {
+		return nil
+	}
+	for _, o := range unspent {
+		ok, err := snapshot.Tree.ContainsKey(state.OutputKey(o))
+		if err != nil {
+			return errors.Wrap(err, "looking up prevout")
+		}
+		if ok {
+			continue
+		}
+		// o has left the state tree, so the
+		// block that spent it has landed.
+		spender, err := findSpender(ctx, c, o, since)
+		if err != nil {
+			return err
+		}
+		if spender == tx.Hash {
+			continue
+		}
+		err = errors.WithDetailf(txbuilder.ErrRejected, "output %s was spent by transaction %s", o, spender)
+		return errors.WithData(err, "conflicting_transaction_id", spender)
+	}
+	return nil
+}
 
-			// TODO(jackson): Do simple rejection checks like checking if
-			// the tx's blockchain prevouts still exist in the state tree.
+// unspentPrevouts returns the height of the current state and
+// the prevouts of tx that are unspent outputs in its state tree.
+// The other prevouts are either outputs of pending txs or outputs
+// that were spent before tx was submitted; the two can't be told
+// apart, so a tx spending an output that was already spent is
+// only rejected once its max time passes.
+func unspentPrevouts(c *protocol.Chain, tx *bc.Tx) (uint64, []bc.Outpoint, error) {
+	b, snapshot := c.State()
+	if b == nil || snapshot == nil {
+		return 0, nil, nil
+	}
+	var unspent []bc.Outpoint
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
 		}
+		ok, err := snapshot.Tree.ContainsKey(state.OutputKey(in.Outpoint()))
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "looking up prevout")
+		}
+		if ok {
+			unspent = append(unspent, in.Outpoint())
+		}
+	}
+	return b.Height, unspent, nil
+}
+
+// findSpender returns the ID of the tx that spent o,
+// in a block after the one at height since.
+func findSpender(ctx context.Context, c *protocol.Chain, o bc.Outpoint, since uint64) (bc.Hash, error) {
+	for height := since + 1; height <= c.Height(); height++ {
+		b, err := c.GetBlock(ctx, height)
+		if err != nil {
+			return bc.Hash{}, errors.Wrap(err, "getting block")
+		}
+		for _, tx := range b.Transactions {
+			for _, in := range tx.Inputs {
+				if !in.IsIssuance() && in.Outpoint() == o {
+					return tx.Hash, nil
+				}
+			}
+		}
+	}
+	return bc.Hash{}, fmt.Errorf("no transaction after height %d spends %s", since, o)
+}
+
+// uniqueIssuanceHash returns the issuance hash of input i of tx,
+// if the blockchain ensures the issuance is only used once.
+func uniqueIssuanceHash(tx *bc.Tx, i int) (bc.Hash, bool) {
+	in := tx.Inputs[i]
+	ii, ok := in.TypedInput.(*bc.IssuanceInput)
+	if !ok || in.AssetVersion != 1 || len(ii.Nonce) == 0 {
+		return bc.Hash{}, false
 	}
+	h, err := tx.IssuanceHash(i)
+	return h, err == nil
 }
 
 type submitArg struct {
@@ -12,6 +12,7 @@ import (
 	"chain/core/query"
 	"chain/core/txbuilder"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -98,3 +99,149 @@ func TestRecordSubmittedTxs(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckRejected(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	spent := bc.Outpoint{Hash: bc.Hash{1}}
+	spend := func(o bc.Outpoint, refData string) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version:       1,
+			Inputs:        []*bc.TxInput{bc.NewSpendInput(o.Hash, o.Index, nil, bc.AssetID{}, 1, nil, nil)},
+			ReferenceData: []byte(refData),
+		})
+	}
+	issue := func(nonce, refData string) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version:       1,
+			Inputs:        []*bc.TxInput{bc.NewIssuanceInput([]byte(nonce), 1, nil, bc.Hash{}, nil, nil)},
+			MinTime:       1,
+			MaxTime:       2,
+			ReferenceData: []byte(refData),
+		})
+	}
+
+	tx := spend(spent, "a")
+	other := spend(spent, "b")
+	unrelated := spend(bc.Outpoint{Hash: bc.Hash{2}}, "c")
+	iss := issue("nonce", "a")
+	otherIss := issue("nonce", "b")
+	noNonce := issue("", "a")
+
+	cases := []struct {
+		tx       *bc.Tx
+		block    []*bc.Tx
+		conflict *bc.Tx
+	}{
+		{tx, []*bc.Tx{unrelated}, nil},
+		{tx, []*bc.Tx{unrelated, other}, other},
+		{iss, []*bc.Tx{otherIss}, otherIss},
+		{noNonce, []*bc.Tx{issue("", "b")}, nil},
+	}
+	for i, tc := range cases {
+		b := &bc.Block{BlockHeader: bc.BlockHeader{Height: 100}, Transactions: tc.block}
+		err := checkRejected(ctx, c, b, tc.tx, nil, 0)
+		if tc.conflict == nil {
+			if err != nil {
+				t.Errorf("case %d: unexpected error %v", i, err)
+			}
+			continue
+		}
+		if errors.Root(err) != txbuilder.ErrRejected {
+			t.Errorf("case %d: got error %v, want %v", i, err, txbuilder.ErrRejected)
+			continue
+		}
+		if got := errors.Data(err)["conflicting_transaction_id"]; got != tc.conflict.Hash {
+			t.Errorf("case %d: got conflicting tx %v, want %v", i, got, tc.conflict.Hash)
+		}
+	}
+}
+
+func TestCheckRejectedSpentPrevout(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	go accounts.ProcessBlocks(ctx)
+
+	acc, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	out := coretest.IssueAssets(ctx, t, c, assets, accounts, assetID, 100, acc.ID).Outpoint
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	// tx spends the issued output, as does a conflicting tx
+	// that lands first.
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(out.Hash, out.Index, nil, assetID, 100, nil, nil)},
+	})
+	since, unspent, err := unspentPrevouts(c, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unspent) != 1 || unspent[0] != out {
+		t.Fatalf("got unspent prevouts %v, want [%v]", unspent, out)
+	}
+
+	assetAmt := bc.AssetAmount{AssetID: assetID, Amount: 100}
+	other := coretest.Transfer(ctx, t, c, []txbuilder.Action{
+		accounts.NewSpendAction(assetAmt, acc.ID, nil, nil),
+		accounts.NewControlAction(assetAmt, acc.ID, nil),
+	})
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	// The conflict is found through the state tree,
+	// though it isn't in the block checked.
+	b := prottest.MakeBlock(t, c)
+	err = checkRejected(ctx, c, b, tx, unspent, since)
+	if errors.Root(err) != txbuilder.ErrRejected {
+		t.Fatalf("got error %v, want %v", err, txbuilder.ErrRejected)
+	}
+	if got := errors.Data(err)["conflicting_transaction_id"]; got != other.Hash {
+		t.Errorf("got conflicting tx %v, want %v", got, other.Hash)
+	}
+}
+
+func TestUnspentPrevouts(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	iss := prottest.NewIssuanceTx(t, c)
+	err := c.AddTx(ctx, iss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, c)
+
+	// Only prevouts in the state tree are checked for later spends.
+	// A prevout that isn't, such as an output that was spent before
+	// the tx was submitted, can't be told apart from an output of a
+	// pending tx.
+	out := bc.Outpoint{Hash: iss.Hash, Index: 0}
+	missing := bc.Outpoint{Hash: bc.Hash{1}}
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(out.Hash, out.Index, nil, bc.AssetID{}, 100, nil, nil),
+			bc.NewSpendInput(missing.Hash, missing.Index, nil, bc.AssetID{}, 100, nil, nil),
+		},
+	})
+	since, unspent, err := unspentPrevouts(c, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since != c.Height() {
+		t.Errorf("got height %d, want %d", since, c.Height())
+	}
+	if len(unspent) != 1 || unspent[0] != out {
+		t.Errorf("got unspent prevouts %v, want [%v]", unspent, out)
+	}
+}
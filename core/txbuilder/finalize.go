@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"chain/core/rpc"
+	"chain/crypto/ed25519"
 	"chain/errors"
 	chainlog "chain/log"
 	"chain/protocol"
@@ -53,7 +54,9 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 	if Generator != nil {
 		// If this transaction is valid, ValidateTxCached will store it in the cache.
 		err := c.ValidateTxCached(msg)
-		if err != nil {
+		if errors.Root(err) == validation.ErrBadTx {
+			return rejected(err)
+		} else if err != nil {
 			return errors.Wrap(err, "tx rejected")
 		}
 
@@ -69,9 +72,7 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 	} else {
 		err = c.AddTx(ctx, msg)
 		if errors.Root(err) == validation.ErrBadTx {
-			detail := errors.Detail(err)
-			err = errors.Wrap(ErrRejected, err)
-			return errors.WithDetail(err, detail)
+			return rejected(err)
 		} else if err != nil {
 			return errors.Wrap(err, "add tx to blockchain")
 		}
@@ -79,6 +80,19 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 	return nil
 }
 
+// rejected returns ErrRejected with the detail and data of err,
+// a validation error. The data describes, for a transaction whose
+// program failed, the input and instruction responsible.
+func rejected(err error) error {
+	detail := errors.Detail(err)
+	data := errors.Data(err)
+	err = errors.WithDetail(errors.Wrap(ErrRejected, err), detail)
+	for k, v := range data {
+		err = errors.WithData(err, k, v)
+	}
+	return err
+}
+
 // To permit idempotence of transaction submission, we require at
 // least one input to commit to the complete transaction (what you get
 // when you build a transaction with allow_additional_actions=false).
@@ -94,6 +108,9 @@ func checkTxSighashCommitment(tx *bc.Tx) error {
 		case *bc.SpendInput:
 			args = t.Arguments
 			allIssuances = false
+			if contractCommitsToSighash(t.ControlProgram, args, sigHasher.Hash(i)) {
+				return nil
+			}
 		case *bc.IssuanceInput:
 			args = t.Arguments
 		}
@@ -128,3 +145,42 @@ func checkTxSighashCommitment(tx *bc.Tx) error {
 
 	return nil
 }
+
+// contractCommitsToSighash returns whether args, satisfying a
+// contract compiled by package contract (see ContractWitness),
+// include a signature of the tx sighash h by one of the public
+// keys in prog, which checks such signatures with checksig.
+func contractCommitsToSighash(prog []byte, args [][]byte, h bc.Hash) bool {
+	insts, err := vm.ParseProgram(prog)
+	if err != nil {
+		return false
+	}
+	var (
+		keys                []ed25519.PublicKey
+		txsighash, checksig bool
+	)
+	for _, inst := range insts {
+		switch {
+		case inst.Op == vm.OP_TXSIGHASH:
+			txsighash = true
+		case inst.Op == vm.OP_CHECKSIG:
+			checksig = true
+		case len(inst.Data) == ed25519.PublicKeySize:
+			keys = append(keys, ed25519.PublicKey(inst.Data))
+		}
+	}
+	if !txsighash || !checksig {
+		return false
+	}
+	for _, arg := range args {
+		if len(arg) != ed25519.SignatureSize {
+			continue
+		}
+		for _, key := range keys {
+			if ed25519.Verify(key, h[:], arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -47,11 +47,8 @@ type SigningInstruction struct {
 func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 	var pre struct {
 		bc.AssetAmount
-		Position          int `json:"position"`
-		WitnessComponents []struct {
-			Type string
-			SignatureWitness
-		} `json:"witness_components"`
+		Position          int               `json:"position"`
+		WitnessComponents []json.RawMessage `json:"witness_components"`
 	}
 	err := json.Unmarshal(b, &pre)
 	if err != nil {
@@ -61,11 +58,28 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 	si.AssetAmount = pre.AssetAmount
 	si.Position = pre.Position
 	si.WitnessComponents = make([]WitnessComponent, 0, len(pre.WitnessComponents))
-	for i, w := range pre.WitnessComponents {
-		if w.Type != "signature" {
+	for i, raw := range pre.WitnessComponents {
+		var w struct {
+			Type string
+		}
+		err = json.Unmarshal(raw, &w)
+		if err != nil {
+			return err
+		}
+		var c WitnessComponent
+		switch w.Type {
+		case "signature":
+			c = new(SignatureWitness)
+		case "contract":
+			c = new(ContractWitness)
+		default:
 			return errors.WithDetailf(ErrBadWitnessComponent, "witness component %d has unknown type '%s'", i, w.Type)
 		}
-		si.WitnessComponents = append(si.WitnessComponents, &w.SignatureWitness)
+		err = json.Unmarshal(raw, c)
+		if err != nil {
+			return err
+		}
+		si.WitnessComponents = append(si.WitnessComponents, c)
 	}
 	return nil
 }
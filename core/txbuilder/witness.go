@@ -8,6 +8,7 @@ import (
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/contract"
 	"chain/protocol/vm"
 	"chain/protocol/vmutil"
 )
@@ -105,7 +106,7 @@ func (sw *SignatureWitness) Sign(ctx context.Context, tpl *Template, index int,
 	// and no further changes are allowed) or a program enforcing
 	// constraints derived from the existing outputs and current input.
 	if len(sw.Program) == 0 {
-		sw.Program = buildSigProgram(tpl, tpl.SigningInstructions[index].Position)
+		sw.Program = BuildSigProgram(tpl, tpl.SigningInstructions[index].Position)
 		if len(sw.Program) == 0 {
 			return ErrEmptyProgram
 		}
@@ -150,7 +151,9 @@ func contains(list []string, key string) bool {
 	return false
 }
 
-func buildSigProgram(tpl *Template, index int) []byte {
+// BuildSigProgram returns the predicate that a SignatureWitness
+// for the input at position index signs when its Program is empty.
+func BuildSigProgram(tpl *Template, index int) []byte {
 	if !tpl.AllowAdditional {
 		h := tpl.Hash(index)
 		builder := vmutil.NewBuilder()
@@ -241,3 +244,90 @@ func (si *SigningInstruction) AddWitnessKeys(keys []KeyID, quorum int) {
 	}
 	si.WitnessComponents = append(si.WitnessComponents, sw)
 }
+
+// ContractWitness satisfies a clause of a contract compiled by
+// package contract. It produces the clause's arguments, in
+// order, followed by the clause's selector, if any.
+type ContractWitness struct {
+	Args []ContractArg `json:"arguments"`
+}
+
+// ContractArg is an argument to a contract clause.
+type ContractArg struct {
+	// Data is the value of the argument,
+	// as encoded by contract.Type.Encode.
+	Data chainjson.HexBytes `json:"data"`
+
+	// Key, if set, identifies the key that signs the transaction
+	// for the input during Sign, producing Data. The signature
+	// commits to the whole transaction.
+	Key *KeyID `json:"key,omitempty"`
+}
+
+// Sign signs the transaction with each of the keys
+// in cw.Args that is in xpubs and hasn't already signed.
+func (cw *ContractWitness) Sign(ctx context.Context, tpl *Template, index int, xpubs []string, signFn SignFunc) error {
+	h := tpl.Hash(tpl.SigningInstructions[index].Position)
+	for i, arg := range cw.Args {
+		if arg.Key == nil || len(arg.Data) > 0 || !contains(xpubs, arg.Key.XPub) {
+			continue
+		}
+		var path [][]byte
+		for _, p := range arg.Key.DerivationPath {
+			path = append(path, p)
+		}
+		sigBytes, err := signFn(ctx, arg.Key.XPub, path, h)
+		if err != nil {
+			return errors.WithDetailf(err, "computing signature for argument %d", i)
+		}
+		cw.Args[i].Data = sigBytes
+	}
+	return nil
+}
+
+func (cw ContractWitness) Materialize(tpl *Template, index int, args *[][]byte) error {
+	for i, arg := range cw.Args {
+		if arg.Key != nil && len(arg.Data) == 0 {
+			return errors.WithDetailf(ErrBadWitnessComponent, "missing signature for argument %d", i)
+		}
+		*args = append(*args, arg.Data)
+	}
+	return nil
+}
+
+func (cw ContractWitness) MarshalJSON() ([]byte, error) {
+	obj := struct {
+		Type string        `json:"type"`
+		Args []ContractArg `json:"arguments"`
+	}{
+		Type: "contract",
+		Args: cw.Args,
+	}
+	return json.Marshal(obj)
+}
+
+// AddContractWitness adds a witness component satisfying clause
+// cl, given the clause's arguments by name. Signature arguments
+// may be given by key rather than by value, to be signed during
+// Sign.
+func (si *SigningInstruction) AddContractWitness(cl *contract.Clause, args map[string]ContractArg) error {
+	cw := new(ContractWitness)
+	for _, p := range cl.Args {
+		arg, ok := args[p.Name]
+		if !ok {
+			return errors.WithDetailf(ErrMissingFields, "missing argument %s to clause %s", p.Name, cl.Name)
+		}
+		if arg.Key != nil && p.Type != contract.Signature {
+			return errors.WithDetailf(ErrBadWitnessComponent, "argument %s to clause %s is %s, not Signature", p.Name, cl.Name, p.Type)
+		}
+		cw.Args = append(cw.Args, arg)
+	}
+	if len(args) > len(cl.Args) {
+		return errors.WithDetailf(ErrBadWitnessComponent, "clause %s takes %d arguments, got %d", cl.Name, len(cl.Args), len(args))
+	}
+	if cl.Selector != nil {
+		cw.Args = append(cw.Args, ContractArg{Data: vm.Int64Bytes(*cl.Selector)})
+	}
+	si.WitnessComponents = append(si.WitnessComponents, cw)
+	return nil
+}
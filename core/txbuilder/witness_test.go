@@ -2,14 +2,18 @@ package txbuilder
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/crypto/ed25519/chainkd"
 	chainjson "chain/encoding/json"
 	"chain/protocol/bc"
+	"chain/protocol/contract"
+	"chain/protocol/prottest"
 	"chain/protocol/vm"
 )
 
@@ -27,7 +31,7 @@ func TestInferConstraints(t *testing.T) {
 		},
 		AllowAdditional: true,
 	}
-	prog := buildSigProgram(tpl, 0)
+	prog := BuildSigProgram(tpl, 0)
 	want, err := vm.Assemble("MINTIME 1 GREATERTHANOREQUAL VERIFY MAXTIME 2 LESSTHANOREQUAL VERIFY 0x0000000000000000000000000000000000000000000000000000000000000000 1 OUTPOINT ROT NUMEQUAL VERIFY EQUAL VERIFY 0x2767f15c8af2f2c7225d5273fdd683edc714110a987d1054697c348aed4e6cc7 REFDATAHASH EQUAL VERIFY 0 0 123 0x0000000000000000000000000000000000000000000000000000000000000000 1 0x0a0b0c CHECKOUTPUT")
 	if err != nil {
 		t.Fatal(err)
@@ -71,3 +75,97 @@ func TestWitnessJSON(t *testing.T) {
 		t.Errorf("got:\n%s\nwant:\n%s\nJSON was: %s", spew.Sdump(&got), spew.Sdump(si), string(b))
 	}
 }
+
+func TestContractWitness(t *testing.T) {
+	c, err := contract.Compile(`
+		contract LockWithTimeout(owner: PublicKey, recipient: PublicKey, deadline: Time) {
+			clause spend(sig: Signature) {
+				verify checksig(owner, sig)
+			}
+			clause expire(sig: Signature) {
+				verify after(deadline)
+				verify checksig(recipient, sig)
+			}
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := c.Program(other.PublicKey(), xpub.PublicKey(), vm.Int64Bytes(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{2}, 5, prog, nil),
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(bc.AssetID{2}, 5, []byte{3}, nil),
+			},
+			MinTime: 1000,
+		},
+		SigningInstructions: []*SigningInstruction{{Position: 0}},
+	}
+	si := tpl.SigningInstructions[0]
+	err = si.AddContractWitness(c.Clause("expire"), map[string]ContractArg{
+		"sig": {Key: &KeyID{XPub: xpub.String()}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The component survives a round trip through JSON.
+	b, err := json.Marshal(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got SigningInstruction
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := json.Marshal(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Errorf("got JSON %s after round trip, want %s", b2, b)
+	}
+
+	signFn := func(_ context.Context, _ string, _ [][]byte, h [32]byte) ([]byte, error) {
+		return xprv.Sign(h[:]), nil
+	}
+	err = si.WitnessComponents[0].Sign(context.Background(), tpl, 0, []string{xpub.String()}, signFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = materializeWitnesses(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := vm.VerifyTxInput(bc.NewTx(*tpl.Transaction), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("contract witness did not satisfy the expire clause")
+	}
+
+	// The signature commits to the transaction,
+	// so it can be finalized.
+	err = FinalizeTx(context.Background(), prottest.NewChain(t), bc.NewTx(*tpl.Transaction))
+	if err != nil {
+		t.Errorf("FinalizeTx: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package txdb
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+
+	"chain/core/txdb/internal/storage"
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/patricia"
+	"chain/protocol/state"
+)
+
+// DecodeDelta decodes a state delta from the Chain Core's
+// binary, protobuf representation of the delta.
+func DecodeDelta(data []byte) (*state.Delta, error) {
+	var storedDelta storage.Delta
+	err := proto.Unmarshal(data, &storedDelta)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshaling state delta proto")
+	}
+
+	d := &state.Delta{
+		Inserts:   make([]patricia.Leaf, len(storedDelta.Inserts)),
+		Deletes:   storedDelta.Deletes,
+		Issuances: make(state.PriorIssuances, len(storedDelta.Issuances)),
+		PruneMS:   storedDelta.PruneMs,
+	}
+	for i, node := range storedDelta.Inserts {
+		d.Inserts[i].Key = node.Key
+		copy(d.Inserts[i].Hash[:], node.Hash)
+	}
+	for _, issuance := range storedDelta.Issuances {
+		var hash bc.Hash
+		copy(hash[:], issuance.Hash)
+		d.Issuances[hash] = issuance.ExpiryMs
+	}
+	return d, nil
+}
+
+func encodeDelta(d *state.Delta) ([]byte, error) {
+	storedDelta := storage.Delta{
+		Inserts:   make([]*storage.Snapshot_StateTreeNode, 0, len(d.Inserts)),
+		Deletes:   d.Deletes,
+		Issuances: encodeIssuances(d.Issuances),
+		PruneMs:   d.PruneMS,
+	}
+	for _, l := range d.Inserts {
+		hash := l.Hash
+		storedDelta.Inserts = append(storedDelta.Inserts, &storage.Snapshot_StateTreeNode{
+			Key:  l.Key,
+			Hash: hash[:],
+		})
+	}
+	b, err := proto.Marshal(&storedDelta)
+	return b, errors.Wrap(err, "marshaling state delta")
+}
+
+func storeDelta(ctx context.Context, db pg.DB, d *state.Delta, height uint64) error {
+	b, err := encodeDelta(d)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		INSERT INTO state_deltas (height, data) VALUES ($1, $2)
+		ON CONFLICT (height) DO UPDATE SET data = $2
+	`
+	_, err = db.Exec(ctx, q, height, b)
+	if err != nil {
+		return errors.Wrap(err, "writing state delta to database")
+	}
+
+	const pruneQ = `DELETE FROM state_deltas WHERE height <= $1`
+	if height > keepDeltas {
+		_, err = db.Exec(ctx, pruneQ, height-keepDeltas)
+	}
+	return errors.Wrap(err, "deleting old state deltas")
+}
+
+func getDelta(ctx context.Context, db pg.DB, height uint64) (*state.Delta, error) {
+	const q = `SELECT data FROM state_deltas WHERE height = $1`
+	var data []byte
+	err := db.QueryRow(ctx, q, height).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving state delta")
+	}
+	return DecodeDelta(data)
+}
+
+// getRawDeltas returns the raw, protobuf-encoded deltas of up
+// to limit consecutive blocks after the provided height.
+func getRawDeltas(ctx context.Context, db pg.DB, after uint64, limit int) ([][]byte, error) {
+	const q = `
+		SELECT height, data FROM state_deltas
+		WHERE height > $1 ORDER BY height LIMIT $2
+	`
+	var deltas [][]byte
+	err := pg.ForQueryRows(ctx, db, q, after, limit, func(height uint64, data []byte) error {
+		if height != after+uint64(len(deltas))+1 {
+			return errMissingDelta
+		}
+		deltas = append(deltas, data)
+		return nil
+	})
+	if errors.Root(err) == errMissingDelta {
+		if len(deltas) == 0 {
+			return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no state delta after height %d", after)
+		}
+		err = nil
+	}
+	return deltas, errors.Wrap(err, "retrieving state deltas")
+}
+
+var errMissingDelta = errors.New("missing state delta")
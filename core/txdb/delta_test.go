@@ -0,0 +1,73 @@
+package txdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/patricia"
+	"chain/protocol/state"
+)
+
+func TestDeltaEncoding(t *testing.T) {
+	d := &state.Delta{
+		Inserts: []patricia.Leaf{
+			patricia.NewLeaf([]byte("key1"), []byte("val1")),
+			patricia.NewLeaf([]byte("key2"), []byte("val2")),
+		},
+		Deletes:   [][]byte{[]byte("key3")},
+		Issuances: state.PriorIssuances{bc.Hash{0x01}: 1000},
+		PruneMS:   500,
+	}
+	b, err := encodeDelta(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeDelta(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("DecodeDelta(encodeDelta(%+v)) = %+v", d, got)
+	}
+}
+
+func TestGetRawDeltas(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+
+	for _, height := range []uint64{1, 2, 3, 5} {
+		err := storeDelta(ctx, dbtx, &state.Delta{PruneMS: height}, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deltas, err := getRawDeltas(ctx, dbtx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 3 {
+		t.Errorf("got %d deltas after 0, want 3 (up to the gap)", len(deltas))
+	}
+	deltas, err = getRawDeltas(ctx, dbtx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 1 {
+		t.Errorf("got %d deltas with limit 1, want 1", len(deltas))
+	}
+	deltas, err = getRawDeltas(ctx, dbtx, 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 0 {
+		t.Errorf("got %d deltas after the latest, want 0", len(deltas))
+	}
+	_, err = getRawDeltas(ctx, dbtx, 3, 10)
+	if err == nil {
+		t.Error("expected error for missing delta")
+	}
+}
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Snapshot
+	Delta
 */
 package storage
 
@@ -78,29 +79,67 @@ func (m *Snapshot_StateTreeNode) String() string            { return proto.Compa
 func (*Snapshot_StateTreeNode) ProtoMessage()               {}
 func (*Snapshot_StateTreeNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
 
+// Delta represents the changes a block makes to the state: the state
+// tree nodes it inserts and deletes, and the issuances it adds to the
+// issuance memory after removing those that expired before it.
+type Delta struct {
+	// Inserts contains the leaves inserted into the state tree.
+	Inserts []*Snapshot_StateTreeNode `protobuf:"bytes,1,rep,name=inserts" json:"inserts,omitempty"`
+	// Deletes contains the keys of the leaves deleted from the state tree.
+	Deletes [][]byte `protobuf:"bytes,2,rep,name=deletes,proto3" json:"deletes,omitempty"`
+	// Issuances contains the issuances added to the issuance memory.
+	Issuances []*Snapshot_Issuance `protobuf:"bytes,3,rep,name=issuances" json:"issuances,omitempty"`
+	// PruneMs is the block timestamp. Issuances that expired before it
+	// are removed from the issuance memory.
+	PruneMs uint64 `protobuf:"varint,4,opt,name=prune_ms,json=pruneMs" json:"prune_ms,omitempty"`
+}
+
+func (m *Delta) Reset()                    { *m = Delta{} }
+func (m *Delta) String() string            { return proto.CompactTextString(m) }
+func (*Delta) ProtoMessage()               {}
+func (*Delta) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Delta) GetInserts() []*Snapshot_StateTreeNode {
+	if m != nil {
+		return m.Inserts
+	}
+	return nil
+}
+
+func (m *Delta) GetIssuances() []*Snapshot_Issuance {
+	if m != nil {
+		return m.Issuances
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Snapshot)(nil), "chain.core.txdb.internal.storage.Snapshot")
 	proto.RegisterType((*Snapshot_Issuance)(nil), "chain.core.txdb.internal.storage.Snapshot.Issuance")
 	proto.RegisterType((*Snapshot_StateTreeNode)(nil), "chain.core.txdb.internal.storage.Snapshot.StateTreeNode")
+	proto.RegisterType((*Delta)(nil), "chain.core.txdb.internal.storage.Delta")
 }
 
 func init() { proto.RegisterFile("snapshot.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 227 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0xce, 0x4b, 0x2c,
-	0x28, 0xce, 0xc8, 0x2f, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x52, 0x48, 0xce, 0x48, 0xcc,
-	0xcc, 0xd3, 0x4b, 0xce, 0x2f, 0x4a, 0xd5, 0x2b, 0xa9, 0x48, 0x49, 0xd2, 0xcb, 0xcc, 0x2b, 0x49,
-	0x2d, 0xca, 0x4b, 0xcc, 0xd1, 0x2b, 0x2e, 0xc9, 0x2f, 0x4a, 0x4c, 0x4f, 0x55, 0x5a, 0xc8, 0xc4,
-	0xc5, 0x11, 0x0c, 0xd5, 0x24, 0xe4, 0xc7, 0xc5, 0x9a, 0x97, 0x9f, 0x92, 0x5a, 0x2c, 0xc1, 0xa8,
-	0xc0, 0xac, 0xc1, 0x6d, 0x64, 0xa1, 0x47, 0x48, 0xbb, 0x1e, 0x4c, 0xab, 0x5e, 0x70, 0x49, 0x62,
-	0x49, 0x6a, 0x48, 0x51, 0x6a, 0xaa, 0x1f, 0xd0, 0x80, 0x20, 0x88, 0x31, 0x42, 0x81, 0x5c, 0x9c,
-	0x99, 0xc5, 0xc5, 0xa5, 0x89, 0x79, 0xc9, 0x40, 0x33, 0x99, 0xc0, 0x66, 0x1a, 0x93, 0x60, 0xa6,
-	0x27, 0x54, 0x6f, 0x10, 0xc2, 0x14, 0x29, 0x6b, 0x2e, 0x0e, 0x98, 0xb0, 0x90, 0x10, 0x17, 0x4b,
-	0x46, 0x62, 0x71, 0x06, 0xd0, 0xb5, 0x8c, 0x1a, 0x3c, 0x41, 0x60, 0xb6, 0x90, 0x34, 0x17, 0x67,
-	0x6a, 0x45, 0x41, 0x66, 0x51, 0x65, 0x7c, 0x2e, 0xc8, 0x4a, 0x46, 0x0d, 0x96, 0x20, 0x0e, 0x88,
-	0x80, 0x6f, 0xb1, 0x94, 0x29, 0x17, 0x2f, 0x8a, 0x3b, 0x85, 0x04, 0xb8, 0x98, 0xb3, 0x53, 0x2b,
-	0xa1, 0x06, 0x80, 0x98, 0x70, 0x33, 0x99, 0x10, 0x66, 0x3a, 0x71, 0x46, 0xb1, 0x43, 0xdd, 0x96,
-	0xc4, 0x06, 0x0e, 0x57, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0x2c, 0x20, 0xad, 0x97, 0x69,
-	0x01, 0x00, 0x00,
+	// 281 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x92, 0xcf, 0x4a, 0xc3, 0x40,
+	0x10, 0xc6, 0xc9, 0x9f, 0x9a, 0x64, 0xac, 0x22, 0x7b, 0x8a, 0xf5, 0x12, 0x7a, 0xca, 0x69, 0x0f,
+	0x16, 0x41, 0xf0, 0x26, 0x5e, 0x3c, 0xb4, 0xe0, 0xd6, 0x93, 0x17, 0xd9, 0x26, 0x83, 0x09, 0xc6,
+	0xdd, 0xb0, 0xb3, 0x85, 0xf6, 0x71, 0x7c, 0x36, 0x5f, 0x44, 0x92, 0x6e, 0x2c, 0x3d, 0x89, 0xd8,
+	0xdb, 0xcc, 0x07, 0xdf, 0x6f, 0x97, 0x1f, 0x03, 0xe7, 0xa4, 0x64, 0x4b, 0x95, 0xb6, 0xbc, 0x35,
+	0xda, 0x6a, 0x96, 0x15, 0x95, 0xac, 0x15, 0x2f, 0xb4, 0x41, 0x6e, 0x37, 0xe5, 0x8a, 0xd7, 0xca,
+	0xa2, 0x51, 0xb2, 0xe1, 0x64, 0xb5, 0x91, 0x6f, 0x38, 0xfd, 0xf4, 0x21, 0x5e, 0xba, 0x12, 0x5b,
+	0xc0, 0x48, 0xe9, 0x12, 0x29, 0xf5, 0xb2, 0x20, 0x3f, 0xbd, 0xbe, 0xe5, 0xbf, 0xd5, 0xf9, 0x50,
+	0xe5, 0x4b, 0x2b, 0x2d, 0x3e, 0x1b, 0xc4, 0x85, 0x2e, 0x51, 0xec, 0x30, 0xec, 0x09, 0x92, 0x9a,
+	0x68, 0x2d, 0x55, 0x81, 0x94, 0xfa, 0x3d, 0x73, 0xf6, 0x07, 0xe6, 0xa3, 0xeb, 0x8a, 0x3d, 0x65,
+	0x72, 0x07, 0xf1, 0x10, 0x33, 0x06, 0x61, 0x25, 0xa9, 0x4a, 0xbd, 0xcc, 0xcb, 0xc7, 0xa2, 0x9f,
+	0xd9, 0x15, 0x24, 0xb8, 0x69, 0x6b, 0xb3, 0x7d, 0xfd, 0xe8, 0x9e, 0xf4, 0xf2, 0x50, 0xc4, 0xbb,
+	0x60, 0x4e, 0x93, 0x1b, 0x38, 0x3b, 0xf8, 0x27, 0xbb, 0x80, 0xe0, 0x1d, 0xb7, 0x0e, 0xd0, 0x8d,
+	0x3f, 0x4c, 0x7f, 0xcf, 0x9c, 0x7e, 0x79, 0x30, 0x7a, 0xc0, 0xc6, 0x4a, 0x26, 0x20, 0xaa, 0x15,
+	0xa1, 0xb1, 0xff, 0x57, 0x34, 0x80, 0x58, 0x0a, 0x51, 0x89, 0x0d, 0x5a, 0xa7, 0x68, 0x2c, 0x86,
+	0xf5, 0x50, 0x5f, 0x70, 0x0c, 0x7d, 0xec, 0x12, 0xe2, 0xd6, 0xac, 0x15, 0x76, 0x76, 0xc2, 0xde,
+	0x4e, 0xd4, 0xef, 0x73, 0xba, 0x4f, 0x5e, 0x22, 0x87, 0x58, 0x9d, 0xf4, 0xd7, 0x33, 0xfb, 0x1e,
+	0x00, 0xbf, 0x46, 0x13, 0xc0, 0x4f, 0x02, 0x00, 0x00,
 }
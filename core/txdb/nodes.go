@@ -0,0 +1,183 @@
+package txdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+	"chain/protocol/patricia"
+)
+
+const (
+	maxCachedNodes = 1 << 18
+	nodeBatchSize  = 10000
+)
+
+// nodeStore is a patricia.NodeStore backed by the state_tree_nodes
+// table. It caches recently loaded and saved nodes in memory.
+type nodeStore struct {
+	db pg.DB
+
+	mu  sync.Mutex
+	lru *lru.Cache
+
+	// saveMu serializes calls to saveTree. It
+	// protects height, which insertNodes records
+	// with each node it saves.
+	saveMu sync.Mutex
+	height uint64
+}
+
+var _ patricia.NodeStore = (*nodeStore)(nil)
+
+func newNodeStore(db pg.DB) *nodeStore {
+	return &nodeStore{
+		db:  db,
+		lru: lru.New(maxCachedNodes),
+	}
+}
+
+// Node returns the encoding of the tree node with the given ID.
+func (s *nodeStore) Node(id bc.Hash) ([]byte, error) {
+	s.mu.Lock()
+	data, ok := s.lru.Get(id)
+	s.mu.Unlock()
+	if ok {
+		return data.([]byte), nil
+	}
+
+	const q = `SELECT data FROM state_tree_nodes WHERE id = $1`
+	var b []byte
+	err := s.db.QueryRow(context.Background(), q, id[:]).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(err, "state tree node %x not found", id[:])
+	} else if err != nil {
+		return nil, errors.Wrap(err, "select query")
+	}
+
+	s.mu.Lock()
+	s.lru.Add(id, b)
+	s.mu.Unlock()
+	return b, nil
+}
+
+// saveTree saves the nodes of t, the state tree at the
+// given block height, that are not already stored.
+// It returns the ID of the tree's root node.
+func (s *nodeStore) saveTree(t *patricia.Tree, height uint64) (bc.Hash, error) {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.height = height
+	return t.Save(s)
+}
+
+// SaveNodes inserts the given tree nodes. Nodes already
+// stored are kept, but their height is raised to the
+// height of the tree being saved, so that collectNodes
+// doesn't delete them while the tree is in use.
+func (s *nodeStore) SaveNodes(nodes []*patricia.EncodedNode) error {
+	for len(nodes) > 0 {
+		n := len(nodes)
+		if n > nodeBatchSize {
+			n = nodeBatchSize
+		}
+		err := s.insertNodes(nodes[:n])
+		if err != nil {
+			return err
+		}
+		nodes = nodes[n:]
+	}
+	return nil
+}
+
+func (s *nodeStore) insertNodes(nodes []*patricia.EncodedNode) error {
+	var ids, data, lefts, rights pq.ByteaArray
+	for _, n := range nodes {
+		var left, right []byte
+		if len(n.Children) == 2 {
+			left, right = n.Children[0][:], n.Children[1][:]
+		}
+		ids = append(ids, n.ID[:])
+		data = append(data, n.Data)
+		lefts = append(lefts, left)
+		rights = append(rights, right)
+	}
+
+	const q = `
+		INSERT INTO state_tree_nodes (id, data, left_id, right_id, height)
+		SELECT id, data, NULLIF(left_id, ''), NULLIF(right_id, ''), $5
+		FROM unnest($1::bytea[], $2::bytea[], $3::bytea[], $4::bytea[])
+			AS u(id, data, left_id, right_id)
+		ON CONFLICT (id) DO UPDATE SET height = EXCLUDED.height
+		WHERE state_tree_nodes.height < EXCLUDED.height
+	`
+	_, err := s.db.Exec(context.Background(), q, ids, data, lefts, rights, s.height)
+	if err != nil {
+		return errors.Wrap(err, "insert tree nodes")
+	}
+
+	s.mu.Lock()
+	for _, n := range nodes {
+		s.lru.Add(n.ID, n.Data)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// CollectNodes periodically deletes the state tree nodes
+// no longer in use. It blocks until the context is canceled.
+// It must run only in the leader process, which saves the
+// state tree.
+func (s *Store) CollectNodes(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, CollectNodes exiting")
+			return
+		case <-ticks:
+			err := collectNodes(ctx, s.db)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+// collectNodes deletes the tree nodes not reachable from the
+// root of any snapshot. It keeps the nodes saved since the
+// latest base snapshot, whether reachable or not: they belong
+// to the tree in use, and SaveNodes raises the height of any
+// older node the tree saves again. So it is safe to run while
+// the tree is being saved.
+//
+// Finding the reachable nodes reads every node of the stored
+// snapshots, so this should run in the background, not each
+// time a snapshot is saved.
+func collectNodes(ctx context.Context, db pg.DB) error {
+	const q = `
+		WITH RECURSIVE live(id) AS (
+			SELECT tree_root FROM snapshots WHERE tree_root IS NOT NULL
+			UNION
+			SELECT child.id FROM live
+			JOIN state_tree_nodes n ON n.id = live.id,
+			LATERAL (VALUES (n.left_id), (n.right_id)) AS child(id)
+			WHERE child.id IS NOT NULL
+		)
+		DELETE FROM state_tree_nodes n
+		WHERE n.height <= (
+			SELECT COALESCE(MAX(height), 0) FROM snapshots WHERE tree_root IS NOT NULL
+		)
+		AND NOT EXISTS (SELECT 1 FROM live WHERE live.id = n.id)
+	`
+	_, err := db.Exec(ctx, q)
+	return errors.Wrap(err, "delete unreachable tree nodes")
+}
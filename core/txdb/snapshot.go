@@ -2,6 +2,7 @@ package txdb
 
 import (
 	"context"
+	"io"
 
 	"github.com/golang/protobuf/proto"
 
@@ -46,7 +47,9 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 	}, nil
 }
 
-func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
+// encodeSnapshot returns the Chain Core's binary, protobuf
+// representation of the snapshot, the inverse of DecodeSnapshot.
+func encodeSnapshot(snapshot *state.Snapshot) ([]byte, error) {
 	var storedSnapshot storage.Snapshot
 	err := patricia.Walk(snapshot.Tree, func(l patricia.Leaf) error {
 		storedSnapshot.Nodes = append(storedSnapshot.Nodes, &storage.Snapshot_StateTreeNode{
@@ -56,42 +59,87 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		return nil
 	})
 	if err != nil {
-		return errors.Wrap(err, "walking patricia tree")
-	}
-
-	storedSnapshot.Issuances = make([]*storage.Snapshot_Issuance, 0, len(snapshot.Issuances))
-	for k, v := range snapshot.Issuances {
-		hash := k
-		storedSnapshot.Issuances = append(storedSnapshot.Issuances, &storage.Snapshot_Issuance{
-			Hash:     hash[:],
-			ExpiryMs: v,
-		})
+		return nil, errors.Wrap(err, "walking patricia tree")
 	}
+	storedSnapshot.Issuances = encodeIssuances(snapshot.Issuances)
 
 	b, err := proto.Marshal(&storedSnapshot)
+	return b, errors.Wrap(err, "marshaling state snapshot")
+}
+
+func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
+	b, err := encodeSnapshot(snapshot)
 	if err != nil {
-		return errors.Wrap(err, "marshaling state snapshot")
+		return err
 	}
 
 	const insertQ = `
 		INSERT INTO snapshots (height, data) VALUES($1, $2)
-		ON CONFLICT (height) DO UPDATE SET data = $2
+		ON CONFLICT (height) DO UPDATE SET data = $2, tree_root = NULL
 	`
 
 	_, err = db.Exec(ctx, insertQ, blockHeight, b)
 	return errors.Wrap(err, "writing state snapshot to database")
 }
 
-func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, error) {
+// storeTreeSnapshot saves the nodes of the snapshot's tree that
+// are not yet in nodes, and a snapshot row with the ID of the
+// tree's root. The row's data holds only the issuances.
+func storeTreeSnapshot(ctx context.Context, db pg.DB, nodes *nodeStore, snapshot *state.Snapshot, blockHeight uint64) error {
+	root, err := nodes.saveTree(snapshot.Tree, blockHeight)
+	if err != nil {
+		return errors.Wrap(err, "saving state tree nodes")
+	}
+
+	b, err := proto.Marshal(&storage.Snapshot{Issuances: encodeIssuances(snapshot.Issuances)})
+	if err != nil {
+		return errors.Wrap(err, "marshaling state snapshot")
+	}
+
+	const insertQ = `
+		INSERT INTO snapshots (height, data, tree_root) VALUES($1, $2, $3)
+		ON CONFLICT (height) DO UPDATE SET data = $2, tree_root = $3
+	`
+	_, err = db.Exec(ctx, insertQ, blockHeight, b, root[:])
+	return errors.Wrap(err, "writing state snapshot to database")
+}
+
+// pruneSnapshots deletes all but the latest n snapshots.
+func pruneSnapshots(ctx context.Context, db pg.DB, n int) error {
 	const q = `
-		SELECT data, height FROM snapshots ORDER BY height DESC LIMIT 1
+		DELETE FROM snapshots WHERE height < (
+			SELECT COALESCE(MIN(height), 0) FROM (
+				SELECT height FROM snapshots ORDER BY height DESC LIMIT $1
+			) AS latest
+		)
+	`
+	_, err := db.Exec(ctx, q, n)
+	return errors.Wrap(err, "deleting old snapshots")
+}
+
+func encodeIssuances(issuances state.PriorIssuances) []*storage.Snapshot_Issuance {
+	stored := make([]*storage.Snapshot_Issuance, 0, len(issuances))
+	for k, v := range issuances {
+		hash := k
+		stored = append(stored, &storage.Snapshot_Issuance{
+			Hash:     hash[:],
+			ExpiryMs: v,
+		})
+	}
+	return stored
+}
+
+func getStateSnapshot(ctx context.Context, db pg.DB, nodes *nodeStore) (*state.Snapshot, uint64, error) {
+	const q = `
+		SELECT data, tree_root, height FROM snapshots ORDER BY height DESC LIMIT 1
 	`
 	var (
 		data   []byte
+		root   []byte
 		height uint64
 	)
 
-	err := db.QueryRow(ctx, q).Scan(&data, &height)
+	err := db.QueryRow(ctx, q).Scan(&data, &root, &height)
 	if err == sql.ErrNoRows {
 		return state.Empty(), 0, nil
 	} else if err != nil {
@@ -102,16 +150,92 @@ func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, e
 	if err != nil {
 		return nil, height, errors.Wrap(err, "decoding snapshot")
 	}
+	if root != nil {
+		// The tree is kept in nodes; data holds only the issuances.
+		var rootID bc.Hash
+		copy(rootID[:], root)
+		snapshot.Tree, err = patricia.Load(nodes, rootID)
+		if err != nil {
+			return nil, height, errors.Wrap(err, "loading state tree")
+		}
+	}
 	return snapshot, height, nil
 }
 
-// getRawSnapshot returns the raw, protobuf-encoded snapshot data at the
-// provided height.
-func getRawSnapshot(ctx context.Context, db pg.DB, height uint64) (data []byte, err error) {
-	const q = `SELECT data FROM snapshots WHERE height = $1`
-	err = db.QueryRow(ctx, q, height).Scan(&data)
+// A RawSnapshot is a state snapshot in the Chain Core's binary,
+// protobuf representation. If its tree is kept in a node store,
+// the tree's leaves are encoded as they are read, so the full
+// encoding is never held in memory.
+type RawSnapshot struct {
+	data []byte         // the full encoding, or only the issuances if tree is set
+	tree *patricia.Tree // nil unless the tree is kept in a node store
+}
+
+// snapshotNodesKey is the protobuf key of the
+// nodes field of a storage.Snapshot.
+const snapshotNodesKey = 1<<3 | proto.WireBytes
+
+// Size returns the length of the snapshot's encoding.
+func (r *RawSnapshot) Size() (uint64, error) {
+	size := uint64(len(r.data))
+	if r.tree == nil {
+		return size, nil
+	}
+	err := patricia.Walk(r.tree, func(l patricia.Leaf) error {
+		n := proto.Size(&storage.Snapshot_StateTreeNode{Key: l.Key, Hash: l.Hash[:]})
+		size += uint64(proto.SizeVarint(snapshotNodesKey) + proto.SizeVarint(uint64(n)) + n)
+		return nil
+	})
+	return size, errors.Wrap(err, "walking patricia tree")
+}
+
+// WriteTo writes the snapshot's encoding to w.
+func (r *RawSnapshot) WriteTo(w io.Writer) (n int64, err error) {
+	if r.tree != nil {
+		buf := proto.NewBuffer(nil)
+		err = patricia.Walk(r.tree, func(l patricia.Leaf) error {
+			buf.Reset()
+			err := buf.EncodeVarint(snapshotNodesKey)
+			if err != nil {
+				return err
+			}
+			err = buf.EncodeMessage(&storage.Snapshot_StateTreeNode{Key: l.Key, Hash: l.Hash[:]})
+			if err != nil {
+				return err
+			}
+			m, err := w.Write(buf.Bytes())
+			n += int64(m)
+			return err
+		})
+		if err != nil {
+			return n, errors.Wrap(err, "writing state tree")
+		}
+	}
+	m, err := w.Write(r.data)
+	return n + int64(m), errors.Wrap(err, "writing issuances")
+}
+
+// getRawSnapshot returns the snapshot at the provided height,
+// to be encoded as it is written.
+func getRawSnapshot(ctx context.Context, db pg.DB, nodes *nodeStore, height uint64) (*RawSnapshot, error) {
+	const q = `SELECT data, tree_root FROM snapshots WHERE height = $1`
+	var (
+		r    RawSnapshot
+		root []byte
+	)
+	err := db.QueryRow(ctx, q, height).Scan(&r.data, &root)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving state snapshot")
+	}
+	if root != nil {
+		var rootID bc.Hash
+		copy(rootID[:], root)
+		r.tree, err = patricia.Load(nodes, rootID)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading state tree")
+		}
 	}
-	return data, err
+	return &r, nil
 }
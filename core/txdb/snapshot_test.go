@@ -1,13 +1,19 @@
 package txdb
 
 import (
+	"bytes"
 	"context"
 	"math/rand"
 	"reflect"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+
+	"chain/core/txdb/internal/storage"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/protocol/bc"
+	"chain/protocol/patricia"
 	"chain/protocol/state"
 )
 
@@ -97,13 +103,17 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 			t.Fatalf("Error writing state snapshot to db: %s\n", err)
 		}
 
-		loadedSnapshot, height, err := getStateSnapshot(ctx, dbtx)
+		loadedSnapshot, height, err := getStateSnapshot(ctx, dbtx, newNodeStore(dbtx))
 		if err != nil {
 			t.Fatalf("Error reading state snapshot from db: %s\n", err)
 		}
 
 		for _, lookup := range changeset.lookups {
-			if !snapshot.Tree.Contains([]byte(lookup.key), lookup.hash) {
+			ok, err := snapshot.Tree.Contains([]byte(lookup.key), lookup.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
 				t.Errorf("Lookup(%s, %s) = false, want true", lookup.key, lookup.hash)
 			}
 		}
@@ -121,6 +131,151 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 	}
 }
 
+func TestRawSnapshot(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	snapshot := state.Empty()
+	for i := 0; i < 100; i++ {
+		k := make([]byte, 32)
+		r.Read(k)
+		err := snapshot.Tree.Insert(k, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot.Issuances[bc.Hash{1}] = 1
+
+	want, err := encodeSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuances, err := proto.Marshal(&storage.Snapshot{Issuances: encodeIssuances(snapshot.Issuances)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := &RawSnapshot{data: issuances, tree: snapshot.Tree}
+
+	var buf bytes.Buffer
+	n, err := raw.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteTo wrote %x want %x", buf.Bytes(), want)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("WriteTo returned %d want %d", n, len(want))
+	}
+	size, err := raw.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(want)) {
+		t.Errorf("Size() = %d want %d", size, len(want))
+	}
+}
+
+func TestReadWriteTreeSnapshot(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(12345))
+
+	nodes := newNodeStore(dbtx)
+	snapshot := state.Empty()
+	var keys [][]byte
+	for i := uint64(1); i <= 5; i++ {
+		for j := 0; j < 100; j++ {
+			k := make([]byte, 32)
+			r.Read(k)
+			keys = append(keys, k)
+			err := snapshot.Tree.Insert(k, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := snapshot.Tree.Delete(keys[r.Intn(len(keys))])
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot.Issuances[bc.Hash{byte(i)}] = i
+
+		want := snapshot.Tree.RootHash()
+		err = storeTreeSnapshot(ctx, dbtx, nodes, snapshot, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Load with a fresh node store to bypass the cache.
+		loaded, height, err := getStateSnapshot(ctx, dbtx, newNodeStore(dbtx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height != i {
+			t.Errorf("%d: height = %d want %d", i, height, i)
+		}
+		if got := loaded.Tree.RootHash(); got != want {
+			t.Fatalf("%d: loaded root hash = %x want %x", i, got[:], want[:])
+		}
+		if !reflect.DeepEqual(loaded.Issuances, snapshot.Issuances) {
+			t.Errorf("%d: issuances = %v want %v", i, loaded.Issuances, snapshot.Issuances)
+		}
+
+		raw, err := getRawSnapshot(ctx, dbtx, nodes, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		_, err = raw.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := raw.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != uint64(buf.Len()) {
+			t.Errorf("%d: raw snapshot size = %d want %d", i, size, buf.Len())
+		}
+		decoded, err := DecodeSnapshot(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decoded.Tree.RootHash(); got != want {
+			t.Fatalf("%d: raw snapshot root hash = %x want %x", i, got[:], want[:])
+		}
+		snapshot = state.Copy(snapshot)
+	}
+
+	err := pruneSnapshots(ctx, dbtx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before, after int
+	err = dbtx.QueryRow(ctx, `SELECT count(*) FROM state_tree_nodes`).Scan(&before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = collectNodes(ctx, dbtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbtx.QueryRow(ctx, `SELECT count(*) FROM state_tree_nodes`).Scan(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("collectNodes left %d of %d nodes, want fewer", after, before)
+	}
+
+	loaded, _, err := getStateSnapshot(ctx, dbtx, newNodeStore(dbtx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = patricia.Walk(loaded.Tree, func(patricia.Leaf) error { return nil })
+	if err != nil {
+		t.Fatal("walking tree after collection:", err)
+	}
+}
+
 func BenchmarkStoreSnapshot100(b *testing.B) {
 	benchmarkStoreSnapshot(100, 100, b)
 }
@@ -174,3 +329,54 @@ func benchmarkStoreSnapshot(nodes, issuances int, b *testing.B) {
 		}
 	}
 }
+
+func TestSaveTreeSnapshotBase(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	store := NewStore(dbtx)
+
+	snapshot := state.Empty()
+	for _, h := range []uint64{1, baseSnapshotFrequency, baseSnapshotFrequency + 1, 2 * baseSnapshotFrequency, 3 * baseSnapshotFrequency, 3*baseSnapshotFrequency + 1} {
+		err := snapshot.Tree.Insert([]byte{byte(h), byte(h >> 8)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.SaveTreeSnapshot(ctx, h, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot = state.Copy(snapshot)
+	}
+
+	// Only the latest base snapshots are kept.
+	var heights []uint64
+	err := pg.ForQueryRows(ctx, dbtx, `SELECT height FROM snapshots ORDER BY height`, func(h uint64) {
+		heights = append(heights, h)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{2 * baseSnapshotFrequency, 3 * baseSnapshotFrequency}
+	if !reflect.DeepEqual(heights, want) {
+		t.Errorf("snapshot heights = %v want %v", heights, want)
+	}
+
+	// The tree saved since the latest base snapshot is intact.
+	err = collectNodes(ctx, dbtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := store.nodes.saveTree(snapshot.Tree, 3*baseSnapshotFrequency+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Load with a fresh node store to bypass the cache.
+	tree, err := patricia.Load(newNodeStore(dbtx), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = patricia.Walk(tree, func(patricia.Leaf) error { return nil })
+	if err != nil {
+		t.Fatal("walking tree after collection:", err)
+	}
+}
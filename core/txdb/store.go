@@ -17,9 +17,30 @@ type Store struct {
 	db pg.DB
 
 	cache blockCache
+	nodes *nodeStore
 }
 
-var _ protocol.Store = (*Store)(nil)
+var (
+	_ protocol.TreeStore  = (*Store)(nil)
+	_ protocol.DeltaStore = (*Store)(nil)
+)
+
+const (
+	// baseSnapshotFrequency is how often, in blocks, SaveTreeSnapshot
+	// stores a base snapshot. Recover, and peers bootstrapping from
+	// the latest base snapshot, catch up from it with state deltas.
+	baseSnapshotFrequency = 1000
+
+	// keepSnapshots is the number of base snapshots retained, so
+	// that peers still downloading the previous one can finish.
+	keepSnapshots = 2
+
+	// keepDeltas is the number of state deltas retained.
+	keepDeltas = 2 * baseSnapshotFrequency
+
+	// maxDeltas is the most state deltas returned by GetRawDeltas.
+	maxDeltas = 1000
+)
 
 // NewStore creates and returns a new Store object.
 //
@@ -28,7 +49,8 @@ var _ protocol.Store = (*Store)(nil)
 // instead.
 func NewStore(db pg.DB) *Store {
 	return &Store{
-		db: db,
+		db:    db,
+		nodes: newNodeStore(db),
 		cache: newBlockCache(func(height uint64) (*bc.Block, error) {
 			const q = `SELECT data FROM blocks WHERE height = $1`
 			var b bc.Block
@@ -59,24 +81,31 @@ func (s *Store) GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
 // LatestSnapshot returns the most recent state snapshot stored in
 // the database and its corresponding block height.
 func (s *Store) LatestSnapshot(ctx context.Context) (*state.Snapshot, uint64, error) {
-	return getStateSnapshot(ctx, s.db)
+	return getStateSnapshot(ctx, s.db, s.nodes)
 }
 
-// LatestSnapshotInfo returns the height and size of the most recent
-// state snapshot stored in the database.
+// LatestSnapshotInfo returns the height and size of the
+// most recent state snapshot stored in the database.
 func (s *Store) LatestSnapshotInfo(ctx context.Context) (height uint64, size uint64, err error) {
-	const q = `
-		SELECT height, octet_length(data) FROM snapshots ORDER BY height DESC LIMIT 1
-	`
-	err = s.db.QueryRow(ctx, q).Scan(&height, &size)
+	const q = `SELECT height FROM snapshots ORDER BY height DESC LIMIT 1`
+	err = s.db.QueryRow(ctx, q).Scan(&height)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	snapshot, err := s.GetSnapshot(ctx, height)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err = snapshot.Size()
 	return height, size, err
 }
 
 // GetSnapshot returns the state snapshot stored at the provided height,
-// in Chain Core's binary protobuf representation. If no snapshot exists
-// at the provided height, an error is returned.
-func (s *Store) GetSnapshot(ctx context.Context, height uint64) ([]byte, error) {
-	return getRawSnapshot(ctx, s.db, height)
+// to be written in Chain Core's binary protobuf representation. If no
+// snapshot exists at the provided height, an error is returned.
+func (s *Store) GetSnapshot(ctx context.Context, height uint64) (*RawSnapshot, error) {
+	return getRawSnapshot(ctx, s.db, s.nodes, height)
 }
 
 // SaveBlock persists a new block in the database.
@@ -101,6 +130,44 @@ func (s *Store) SaveSnapshot(ctx context.Context, height uint64, snapshot *state
 	return errors.Wrap(err, "saving state tree")
 }
 
+// SaveTreeSnapshot writes the nodes of the snapshot's tree that
+// are not already stored. Every baseSnapshotFrequency blocks, it
+// also saves the snapshot as a base snapshot, deleting all but the
+// latest few base snapshots. The tree nodes no longer in use are
+// deleted by CollectNodes.
+func (s *Store) SaveTreeSnapshot(ctx context.Context, height uint64, snapshot *state.Snapshot) error {
+	if height%baseSnapshotFrequency != 0 {
+		_, err := s.nodes.saveTree(snapshot.Tree, height)
+		return errors.Wrap(err, "saving state tree nodes")
+	}
+
+	err := storeTreeSnapshot(ctx, s.db, s.nodes, snapshot, height)
+	if err != nil {
+		return errors.Wrap(err, "saving state tree")
+	}
+	return pruneSnapshots(ctx, s.db, keepSnapshots)
+}
+
+// SaveDelta saves the state delta of the block at
+// the provided height, deleting the oldest deltas.
+func (s *Store) SaveDelta(ctx context.Context, height uint64, d *state.Delta) error {
+	return storeDelta(ctx, s.db, d, height)
+}
+
+// GetDelta returns the state delta of the block at the
+// provided height, or nil if it is not stored.
+func (s *Store) GetDelta(ctx context.Context, height uint64) (*state.Delta, error) {
+	return getDelta(ctx, s.db, height)
+}
+
+// GetRawDeltas returns the state deltas of the blocks after the
+// provided height, in Chain Core's binary protobuf representation.
+// It returns the deltas of at most maxDeltas consecutive blocks,
+// and none if there are no blocks after height.
+func (s *Store) GetRawDeltas(ctx context.Context, after uint64) ([][]byte, error) {
+	return getRawDeltas(ctx, s.db, after, maxDeltas)
+}
+
 func (s *Store) FinalizeBlock(ctx context.Context, height uint64) error {
 	_, err := s.db.Exec(ctx, `SELECT pg_notify('newblock', $1)`, height)
 	return err
@@ -4,14 +4,20 @@ package txfeed
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"net/url"
 
 	"chain/core/query/filter"
 	"chain/database/pg"
 	"chain/errors"
 )
 
-var ErrDuplicateAlias = errors.New("duplicate feed alias")
+var (
+	ErrDuplicateAlias = errors.New("duplicate feed alias")
+	ErrBadWebhookURL  = errors.New("invalid webhook url")
+)
 
 type Tracker struct {
 	DB pg.DB
@@ -22,9 +28,17 @@ type TxFeed struct {
 	Alias  *string `json:"alias"`
 	Filter string  `json:"filter,omitempty"`
 	After  string  `json:"after,omitempty"`
+
+	// WebhookURL, if set, is the URL to which Core POSTs each
+	// transaction in the feed. Each request is signed with
+	// WebhookSecret; see Sign. The secret is never included
+	// in the feed's JSON; Core returns it only when the feed
+	// is created.
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"-"`
 }
 
-func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientToken *string) (*TxFeed, error) {
+func (t *Tracker) Create(ctx context.Context, alias, fil, after, webhookURL string, clientToken *string) (*TxFeed, error) {
 	// Validate the filter.
 	_, err := filter.Parse(fil)
 	if err != nil {
@@ -41,6 +55,19 @@ func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientTo
 		Filter: fil,
 		After:  after,
 	}
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.WithDetailf(ErrBadWebhookURL, "%q is not an absolute http or https URL", webhookURL)
+		}
+		var secret [32]byte
+		_, err = rand.Read(secret[:])
+		if err != nil {
+			return nil, errors.Wrap(err, "generating webhook secret")
+		}
+		feed.WebhookURL = webhookURL
+		feed.WebhookSecret = hex.EncodeToString(secret[:])
+	}
 	return insertTxFeed(ctx, t.DB, feed, clientToken)
 }
 
@@ -49,8 +76,8 @@ func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientTo
 // lookup and return the existing txfeed instead.
 func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken *string) (*TxFeed, error) {
 	const q = `
-		INSERT INTO txfeeds (alias, filter, after, client_token)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO txfeeds (alias, filter, after, client_token, webhook_url, webhook_secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING id
 	`
@@ -62,7 +89,7 @@ func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken *stri
 
 	err := db.QueryRow(
 		ctx, q, alias, feed.Filter, feed.After,
-		clientToken).Scan(&feed.ID)
+		clientToken, nullString(feed.WebhookURL), nullString(feed.WebhookSecret)).Scan(&feed.ID)
 
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetail(ErrDuplicateAlias, "a transaction feed with the provided alias already exists")
@@ -82,16 +109,17 @@ func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken *stri
 
 func txfeedByClientToken(ctx context.Context, db pg.DB, clientToken string) (*TxFeed, error) {
 	const q = `
-		SELECT id, alias, filter, after
+		SELECT id, alias, filter, after, webhook_url, webhook_secret
 		FROM txfeeds
 		WHERE client_token=$1
 	`
 
 	var (
-		feed  TxFeed
-		alias sql.NullString
+		feed                      TxFeed
+		alias                     sql.NullString
+		webhookURL, webhookSecret sql.NullString
 	)
-	err := db.QueryRow(ctx, q, clientToken).Scan(&feed.ID, &alias, &feed.Filter, &feed.After)
+	err := db.QueryRow(ctx, q, clientToken).Scan(&feed.ID, &alias, &feed.Filter, &feed.After, &webhookURL, &webhookSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -99,6 +127,8 @@ func txfeedByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Tx
 	if alias.Valid {
 		feed.Alias = &alias.String
 	}
+	feed.WebhookURL = webhookURL.String
+	feed.WebhookSecret = webhookSecret.String
 
 	return &feed, nil
 }
@@ -107,7 +137,7 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 	var q bytes.Buffer
 
 	q.WriteString(`
-		SELECT id, alias, filter, after
+		SELECT id, alias, filter, after, webhook_url, webhook_secret
 		FROM txfeeds
 		WHERE
 	`)
@@ -120,11 +150,12 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 	}
 
 	var (
-		feed     TxFeed
-		sqlAlias sql.NullString
+		feed                      TxFeed
+		sqlAlias                  sql.NullString
+		webhookURL, webhookSecret sql.NullString
 	)
 
-	err := t.DB.QueryRow(ctx, q.String(), id).Scan(&feed.ID, &sqlAlias, &feed.Filter, &feed.After)
+	err := t.DB.QueryRow(ctx, q.String(), id).Scan(&feed.ID, &sqlAlias, &feed.Filter, &feed.After, &webhookURL, &webhookSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -132,6 +163,8 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 	if sqlAlias.Valid {
 		feed.Alias = &sqlAlias.String
 	}
+	feed.WebhookURL = webhookURL.String
+	feed.WebhookSecret = webhookSecret.String
 
 	return &feed, nil
 }
@@ -168,7 +201,7 @@ func (t *Tracker) Delete(ctx context.Context, id, alias string) error {
 func (t *Tracker) Update(ctx context.Context, id, alias, after, prev string) (*TxFeed, error) {
 	var q bytes.Buffer
 
-	q.WriteString(`UPDATE txfeeds SET after=$1 WHERE `)
+	q.WriteString(`UPDATE txfeeds SET after=$1, webhook_attempts=0, webhook_last_error=NULL WHERE `)
 
 	if id != "" {
 		q.WriteString(`id=$2`)
@@ -199,3 +232,7 @@ func (t *Tracker) Update(ctx context.Context, id, alias, after, prev string) (*T
 		After: after,
 	}, nil
 }
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{Valid: s != "", String: s}
+}
@@ -1,7 +1,9 @@
 package txfeed
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -92,8 +94,19 @@ func TestCreateTxFeedBadFilter(t *testing.T) {
 	token := "test_token_0"
 	alias := "test_txfeed"
 	fil := "lol i'm not a ~real~ filter"
-	_, err := tracker.Create(ctx, alias, fil, "", &token)
+	_, err := tracker.Create(ctx, alias, fil, "", "", &token)
 	if errors.Root(err) != filter.ErrBadFilter {
 		t.Errorf("expected ErrBadFilter, got %s", errors.Root(err))
 	}
 }
+
+func TestTxFeedJSONOmitsSecret(t *testing.T) {
+	feed := &TxFeed{ID: "feed1", WebhookURL: "https://example.com/hook", WebhookSecret: "secret"}
+	b, err := json.Marshal(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Errorf("feed JSON %s includes the webhook secret", b)
+	}
+}
@@ -0,0 +1,383 @@
+package txfeed
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+// SignatureHeader is the HTTP header carrying the signature
+// of a webhook request body. See Sign.
+const SignatureHeader = "Chain-Signature"
+
+const (
+	defaultMaxAttempts = 10
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+	webhookTimeout     = 30 * time.Second
+	webhookPageSize    = 100
+)
+
+// errFeedMoved is returned when a feed's after changes while
+// its transactions are being delivered, for example because it
+// was updated with /update-transaction-feed.
+var errFeedMoved = errors.New("feed position changed during delivery")
+
+// Sign returns the signature of a webhook request body for a feed
+// with the given secret. Receivers should compute the signature of
+// each request body they receive and compare it with the value of
+// SignatureHeader, using a constant-time comparison such as
+// VerifySignature.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature returns whether sig is the
+// signature of body for the given secret.
+func VerifySignature(secret string, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(sig))
+}
+
+// Tx is a transaction to be delivered to a feed's webhook.
+type Tx struct {
+	ID    string          // transaction ID
+	After string          // the feed position immediately following the transaction
+	Data  json.RawMessage // the annotated transaction
+}
+
+// A TxSource returns up to limit of the transactions matching p that
+// follow the feed position after, in ascending order. It blocks until
+// at least one such transaction exists or ctx is done.
+type TxSource func(ctx context.Context, p filter.Predicate, after string, limit int) ([]Tx, error)
+
+// Dispatcher delivers the transactions in feeds that have a webhook
+// URL. It POSTs each transaction to the feed's webhook, in order, and
+// advances the feed's after once the webhook responds with a 2xx
+// status. Failed deliveries are retried with exponential backoff;
+// after MaxAttempts failures, the transaction is moved to the feed's
+// dead letters and delivery continues with the next transaction.
+//
+// Delivery state is stored with the feed, so a new leader resumes
+// where the old one left off.
+type Dispatcher struct {
+	Tracker *Tracker
+	Source  TxSource
+	Client  *http.Client
+
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewDispatcher returns a new Dispatcher that reads
+// transactions from source, with default retry settings.
+func NewDispatcher(t *Tracker, source TxSource) *Dispatcher {
+	return &Dispatcher{
+		Tracker:     t,
+		Source:      source,
+		Client:      &http.Client{Timeout: webhookTimeout},
+		MaxAttempts: defaultMaxAttempts,
+		MinBackoff:  defaultMinBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+	}
+}
+
+// Run delivers webhook feeds until ctx is canceled.
+// Every period, it starts delivering any new feeds
+// and restarts delivery of any feeds that failed.
+func (d *Dispatcher) Run(ctx context.Context, period time.Duration) {
+	running := make(map[string]bool)
+	done := make(chan string)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		ids, err := d.Tracker.webhookFeedIDs(ctx)
+		if err != nil {
+			log.Error(ctx, err)
+		}
+		for _, id := range ids {
+			if running[id] {
+				continue
+			}
+			running[id] = true
+			go func(id string) {
+				err := d.deliverFeed(ctx, id)
+				if err != nil && ctx.Err() == nil {
+					log.Error(ctx, err, "feed", id)
+				}
+				done <- id
+			}(id)
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				for len(running) > 0 {
+					delete(running, <-done)
+				}
+				return
+			case id := <-done:
+				delete(running, id)
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// deliverFeed delivers the transactions in a feed until
+// the feed is deleted, an error occurs, or ctx is canceled.
+func (d *Dispatcher) deliverFeed(ctx context.Context, id string) error {
+	for {
+		feed, err := d.Tracker.Find(ctx, id, "")
+		if err == sql.ErrNoRows {
+			return nil // the feed was deleted
+		}
+		if err != nil {
+			return errors.Wrap(err, "looking up feed")
+		}
+		if feed.WebhookURL == "" {
+			return nil
+		}
+		p, err := filter.Parse(feed.Filter)
+		if err != nil {
+			return err
+		}
+
+		txs, err := d.Source(ctx, p, feed.After, webhookPageSize)
+		if err != nil {
+			return errors.Wrap(err, "fetching transactions")
+		}
+		for _, tx := range txs {
+			err = d.deliverTx(ctx, feed, tx)
+			if errors.Root(err) == errFeedMoved {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			feed.After = tx.After
+		}
+	}
+}
+
+// deliverTx delivers tx, the next transaction in feed, retrying
+// until it succeeds or is moved to the dead letters.
+func (d *Dispatcher) deliverTx(ctx context.Context, feed *TxFeed, tx Tx) error {
+	attempts, err := d.Tracker.webhookAttempts(ctx, feed.ID, feed.After)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(d.backoff(attempts)):
+			}
+		}
+
+		postErr := d.post(ctx, feed, tx, attempts+1)
+		if postErr == nil {
+			return d.Tracker.recordDelivery(ctx, feed.ID, feed.After, tx.After)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		attempts, err = d.Tracker.recordFailure(ctx, feed.ID, feed.After, postErr.Error())
+		if err != nil {
+			return err
+		}
+		if attempts >= d.MaxAttempts {
+			return d.Tracker.deadLetter(ctx, feed.ID, feed.After, tx, attempts, postErr.Error())
+		}
+	}
+}
+
+// backoff returns how long to wait before the
+// next attempt, after the given number of failures.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	b := d.MinBackoff
+	for i := 1; i < attempts && b < d.MaxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.MaxBackoff {
+		b = d.MaxBackoff
+	}
+	return b
+}
+
+func (d *Dispatcher) post(ctx context.Context, feed *TxFeed, tx Tx, attempt int) error {
+	req, err := http.NewRequest("POST", feed.WebhookURL, bytes.NewReader(tx.Data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Chain-Transaction-Feed-ID", feed.ID)
+	req.Header.Set("Chain-Delivery-Attempt", strconv.Itoa(attempt))
+	req.Header.Set(SignatureHeader, Sign(feed.WebhookSecret, tx.Data))
+
+	resp, err := d.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16)) // allow connection reuse
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DeadLetter is a transaction that could not be
+// delivered to a feed's webhook.
+type DeadLetter struct {
+	ID            string          `json:"id"`
+	FeedID        string          `json:"transaction_feed_id"`
+	TransactionID string          `json:"transaction_id"`
+	After         string          `json:"after"`
+	Transaction   json.RawMessage `json:"transaction"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// DeadLetters lists up to limit of the dead letters of the feed with
+// the given ID, oldest first, starting after the dead letter with ID
+// after. It returns the after for the next page.
+func (t *Tracker) DeadLetters(ctx context.Context, feedID string, after int64, limit int) ([]*DeadLetter, int64, error) {
+	const q = `
+		SELECT id, feed_id, tx_id, after, payload, attempts, last_error, created_at
+		FROM txfeed_dead_letters
+		WHERE feed_id=$1 AND id>$2
+		ORDER BY id
+		LIMIT $3
+	`
+	letters := make([]*DeadLetter, 0, limit)
+	err := pg.ForQueryRows(ctx, t.DB, q, feedID, after, limit,
+		func(id int64, feedID, txID, after string, payload []byte, attempts int, lastError string, createdAt time.Time) {
+			letters = append(letters, &DeadLetter{
+				ID:            strconv.FormatInt(id, 10),
+				FeedID:        feedID,
+				TransactionID: txID,
+				After:         after,
+				Transaction:   payload,
+				Attempts:      attempts,
+				LastError:     lastError,
+				CreatedAt:     createdAt,
+			})
+		})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "listing dead letters")
+	}
+	if len(letters) > 0 {
+		after, _ = strconv.ParseInt(letters[len(letters)-1].ID, 10, 64)
+	}
+	return letters, after, nil
+}
+
+func (t *Tracker) webhookFeedIDs(ctx context.Context) ([]string, error) {
+	const q = `SELECT id FROM txfeeds WHERE webhook_url IS NOT NULL`
+	var ids []string
+	err := pg.ForQueryRows(ctx, t.DB, q, func(id string) {
+		ids = append(ids, id)
+	})
+	return ids, errors.Wrap(err, "listing webhook feeds")
+}
+
+// webhookAttempts returns the number of failed attempts to
+// deliver the transaction following position after.
+func (t *Tracker) webhookAttempts(ctx context.Context, id, after string) (int, error) {
+	const q = `SELECT webhook_attempts FROM txfeeds WHERE id=$1 AND after=$2`
+	var attempts int
+	err := t.DB.QueryRow(ctx, q, id, after).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, errors.Wrap(errFeedMoved)
+	}
+	return attempts, errors.Wrap(err, "looking up webhook attempts")
+}
+
+// recordDelivery advances the feed from prev to after
+// and resets its delivery state.
+func (t *Tracker) recordDelivery(ctx context.Context, id, prev, after string) error {
+	const q = `
+		UPDATE txfeeds SET after=$3, webhook_attempts=0, webhook_last_error=NULL
+		WHERE id=$1 AND after=$2
+	`
+	res, err := t.DB.Exec(ctx, q, id, prev, after)
+	if err != nil {
+		return errors.Wrap(err, "recording webhook delivery")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.Wrap(errFeedMoved)
+	}
+	return nil
+}
+
+// recordFailure records a failed attempt to deliver the transaction
+// following position after, and returns the number of failures.
+func (t *Tracker) recordFailure(ctx context.Context, id, after, msg string) (int, error) {
+	const q = `
+		UPDATE txfeeds SET webhook_attempts=webhook_attempts+1, webhook_last_error=$3
+		WHERE id=$1 AND after=$2
+		RETURNING webhook_attempts
+	`
+	var attempts int
+	err := t.DB.QueryRow(ctx, q, id, after, msg).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, errors.Wrap(errFeedMoved)
+	}
+	return attempts, errors.Wrap(err, "recording webhook failure")
+}
+
+// deadLetter moves tx, the transaction following position prev,
+// to the feed's dead letters, and advances the feed past it.
+func (t *Tracker) deadLetter(ctx context.Context, id, prev string, tx Tx, attempts int, msg string) error {
+	const q = `
+		WITH feed AS (
+			UPDATE txfeeds SET after=$3, webhook_attempts=0, webhook_last_error=NULL
+			WHERE id=$1 AND after=$2
+			RETURNING id
+		)
+		INSERT INTO txfeed_dead_letters (feed_id, tx_id, after, payload, attempts, last_error)
+		SELECT id, $4, $2, $5, $6, $7 FROM feed
+	`
+	res, err := t.DB.Exec(ctx, q, id, prev, tx.After, tx.ID, []byte(tx.Data), attempts, msg)
+	if err != nil {
+		return errors.Wrap(err, "inserting dead letter")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.Wrap(errFeedMoved)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package txfeed
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"abc"}`)
+	sig := Sign("secret", body)
+	if !VerifySignature("secret", body, sig) {
+		t.Errorf("VerifySignature(%q) = false want true", sig)
+	}
+	if VerifySignature("other-secret", body, sig) {
+		t.Errorf("VerifySignature with wrong secret = true want false")
+	}
+	if VerifySignature("secret", []byte(`{"id":"abd"}`), sig) {
+		t.Errorf("VerifySignature with wrong body = true want false")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, c := range cases {
+		got := d.backoff(c.attempts)
+		if got != c.want {
+			t.Errorf("backoff(%d) = %s want %s", c.attempts, got, c.want)
+		}
+	}
+}
+
+func TestPost(t *testing.T) {
+	var (
+		gotBody []byte
+		gotSig  string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotBody, _ = ioutil.ReadAll(req.Body)
+		gotSig = req.Header.Get(SignatureHeader)
+		if req.Header.Get("Chain-Delivery-Attempt") != "1" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, nil)
+	feed := &TxFeed{ID: "feed1", WebhookURL: srv.URL, WebhookSecret: "secret"}
+	tx := Tx{ID: "tx1", After: "1:0-2", Data: []byte(`{"id":"tx1"}`)}
+
+	err := d.post(context.Background(), feed, tx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotBody) != string(tx.Data) {
+		t.Errorf("got body %s want %s", gotBody, tx.Data)
+	}
+	if !VerifySignature("secret", gotBody, gotSig) {
+		t.Errorf("got invalid signature %q", gotSig)
+	}
+
+	err = d.post(context.Background(), feed, tx, 2)
+	if err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
+
+func TestDeliverTx(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	tracker := &Tracker{DB: db}
+	feed, err := tracker.Create(ctx, "", "", "1:0-2", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(tracker, nil)
+	d.MinBackoff = time.Millisecond
+	tx := Tx{ID: "tx1", After: "1:1-2", Data: []byte(`{"id":"tx1"}`)}
+	err = d.deliverTx(ctx, feed, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls want 3", calls)
+	}
+
+	got, err := tracker.Find(ctx, feed.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.After != tx.After {
+		t.Errorf("got after %s want %s", got.After, tx.After)
+	}
+	attempts, err := tracker.webhookAttempts(ctx, feed.ID, tx.After)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 0 {
+		t.Errorf("got %d attempts after delivery want 0", attempts)
+	}
+}
+
+func TestDeliverTxDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	tracker := &Tracker{DB: db}
+	feed, err := tracker.Create(ctx, "", "", "1:0-2", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(tracker, nil)
+	d.MaxAttempts = 2
+	d.MinBackoff = time.Millisecond
+	tx := Tx{ID: "tx1", After: "1:1-2", Data: []byte(`{"id":"tx1"}`)}
+	err = d.deliverTx(ctx, feed, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tracker.Find(ctx, feed.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.After != tx.After {
+		t.Errorf("got after %s want %s", got.After, tx.After)
+	}
+
+	letters, _, err := tracker.DeadLetters(ctx, feed.ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("got %d dead letters want 1", len(letters))
+	}
+	l := letters[0]
+	if l.TransactionID != tx.ID || l.After != "1:0-2" || l.Attempts != 2 {
+		t.Errorf("got dead letter %+v", l)
+	}
+}
@@ -2,11 +2,15 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 
 	"chain/core/query"
+	"chain/core/query/filter"
 	"chain/core/txfeed"
+	"chain/database/pg"
 	"chain/errors"
 	"chain/net/http/httpjson"
 )
@@ -21,9 +25,25 @@ func (h *Handler) createTxFeed(ctx context.Context, in struct {
 	// idempotency of create txfeed requests. Duplicate create txfeed requests
 	// with the same client_token will only create one txfeed.
 	ClientToken *string `json:"client_token"`
-}) (*txfeed.TxFeed, error) {
+
+	// WebhookURL, if set, is a URL to which Core will POST each
+	// transaction in the feed. The response includes the secret
+	// used to sign each request.
+	WebhookURL string `json:"webhook_url"`
+}) (*createTxFeedResponse, error) {
 	after := fmt.Sprintf("%d:%d-%d", h.Chain.Height(), math.MaxInt32, uint64(math.MaxInt64))
-	return h.TxFeeds.Create(ctx, in.Alias, in.Filter, after, in.ClientToken)
+	feed, err := h.TxFeeds.Create(ctx, in.Alias, in.Filter, after, in.WebhookURL, in.ClientToken)
+	if err != nil {
+		return nil, err
+	}
+	return &createTxFeedResponse{TxFeed: feed, WebhookSecret: feed.WebhookSecret}, nil
+}
+
+// createTxFeedResponse is a txfeed with its webhook secret,
+// which no other response includes.
+type createTxFeedResponse struct {
+	*txfeed.TxFeed
+	WebhookSecret string `json:"webhook_secret,omitempty"`
 }
 
 // POST /get-transaction-feed
@@ -64,6 +84,129 @@ func (h *Handler) updateTxFeed(ctx context.Context, in struct {
 	return h.TxFeeds.Update(ctx, in.ID, in.Alias, in.After, in.Prev)
 }
 
+// POST /acknowledge-transaction-feed
+//
+// ackTxFeed records that a client has processed the feed's
+// transactions up to and including the one identified by After.
+// Unlike /update-transaction-feed, it doesn't require the previous
+// after, and it never moves the feed's after backward, so it is
+// safe to call with acknowledgements that arrive out of order.
+func (h *Handler) ackTxFeed(ctx context.Context, in struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+	After string `json:"after"`
+}) (*txfeed.TxFeed, error) {
+	for {
+		feed, err := h.TxFeeds.Find(ctx, in.ID, in.Alias)
+		if err != nil {
+			return nil, err
+		}
+
+		stale, err := txAfterIsBefore(in.After, feed.After)
+		if err != nil {
+			return nil, err
+		}
+		if stale || in.After == feed.After {
+			return feed, nil
+		}
+
+		_, err = h.TxFeeds.Update(ctx, feed.ID, "", in.After, feed.After)
+		if errors.Root(err) == pg.ErrUserInputNotFound {
+			// The feed's after changed since we looked it up.
+			// Try again with the new value.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		feed.After = in.After
+		return feed, nil
+	}
+}
+
+type deadLettersQuery struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+	After string `json:"after"`
+}
+
+type deadLettersPage struct {
+	Items    interface{}      `json:"items"`
+	Next     deadLettersQuery `json:"next"`
+	LastPage bool             `json:"last_page"`
+}
+
+// POST /list-transaction-feed-dead-letters
+//
+// listTxFeedDeadLetters lists the transactions that could
+// not be delivered to a feed's webhook, oldest first.
+func (h *Handler) listTxFeedDeadLetters(ctx context.Context, in deadLettersQuery) (*deadLettersPage, error) {
+	feed, err := h.TxFeeds.Find(ctx, in.ID, in.Alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var after int64
+	if in.After != "" {
+		after, err = strconv.ParseInt(in.After, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(query.ErrBadAfter, err.Error())
+		}
+	}
+
+	limit := defGenericPageSize
+	letters, after, err := h.TxFeeds.DeadLetters(ctx, feed.ID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	out := in
+	out.After = strconv.FormatInt(after, 10)
+	return &deadLettersPage{
+		Items:    httpjson.Array(letters),
+		LastPage: len(letters) < limit,
+		Next:     out,
+	}, nil
+}
+
+// TxFeedSource returns a txfeed.TxSource that reads transactions
+// from ind, for delivering them to transaction feed webhooks.
+func TxFeedSource(ind *query.Indexer) txfeed.TxSource {
+	return func(ctx context.Context, p filter.Predicate, after string, limit int) ([]txfeed.Tx, error) {
+		cursor, err := query.DecodeTxAfter(after)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding `after`")
+		}
+		txs, _, err := ind.Transactions(ctx, p, nil, cursor, limit, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "running tx query")
+		}
+
+		res := make([]txfeed.Tx, 0, len(txs))
+		for _, t := range txs {
+			next, err := txCursor(t, cursor)
+			if err != nil {
+				return nil, err
+			}
+			r, err := txResponse(t)
+			if err != nil {
+				return nil, err
+			}
+			data, err := json.Marshal(r)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, txfeed.Tx{
+				ID:    fmt.Sprint(r.ID),
+				After: next.String(),
+				Data:  data,
+			})
+			cursor = next
+		}
+		return res, nil
+	}
+}
+
 // txAfterIsBefore returns true if a is before b. It returns an error if either
 // a or b are not valid query.TxAfters.
 func txAfterIsBefore(a, b string) (bool, error) {
@@ -77,7 +220,12 @@ func txAfterIsBefore(a, b string) (bool, error) {
 		return false, err
 	}
 
-	return aAfter.FromBlockHeight < bAfter.FromBlockHeight ||
-		(aAfter.FromBlockHeight == bAfter.FromBlockHeight &&
-			aAfter.FromPosition < bAfter.FromPosition), nil
+	return txAfterLess(aAfter, bAfter), nil
+}
+
+// txAfterLess returns true if a is before b.
+func txAfterLess(a, b query.TxAfter) bool {
+	return a.FromBlockHeight < b.FromBlockHeight ||
+		(a.FromBlockHeight == b.FromBlockHeight &&
+			a.FromPosition < b.FromPosition)
 }
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+const (
+	// streamPageSize is the maximum number of transactions
+	// fetched from the index at a time.
+	streamPageSize = 100
+
+	// streamKeepalive is how long a stream can be idle before
+	// a comment is sent to keep intermediaries from closing it.
+	streamKeepalive = 15 * time.Second
+
+	// streamAckPoll is how often a stream that has reached its
+	// limit of unacknowledged transactions checks for new
+	// acknowledgements.
+	streamAckPoll = time.Second
+)
+
+var errStreamingUnsupported = errors.New("streaming unsupported")
+
+type streamTxFeedRequest struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+
+	// After overrides the feed's stored after, so a client can
+	// resume a stream from the last transaction it received.
+	After string `json:"after,omitempty"`
+
+	// MaxUnacknowledged limits how far the stream may run ahead
+	// of the feed's acknowledged after. If it is zero, the stream
+	// is limited only by how quickly the client reads it.
+	MaxUnacknowledged int `json:"max_unacknowledged,omitempty"`
+}
+
+// GET or POST /stream-transaction-feed
+//
+// streamTxFeed sends the transactions matching a feed's filter as
+// Server-Sent Events as soon as they are indexed. The id of each
+// event is the `after` immediately following its transaction, so a
+// client can resume an interrupted stream using the standard
+// Last-Event-ID header or the after parameter, and can acknowledge
+// the transactions it has processed using
+// /acknowledge-transaction-feed.
+//
+// The stream only fetches more transactions once the previous ones
+// have been written to the client, so a slow client slows the
+// stream rather than accumulating transactions in memory.
+func (h *Handler) streamTxFeed(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(w, req)
+		return
+	}
+
+	in, err := decodeStreamTxFeedRequest(req)
+	if err != nil {
+		WriteHTTPError(ctx, w, err)
+		return
+	}
+
+	feed, err := h.TxFeeds.Find(ctx, in.ID, in.Alias)
+	if err != nil {
+		WriteHTTPError(ctx, w, err)
+		return
+	}
+	p, err := filter.Parse(feed.Filter)
+	if err != nil {
+		WriteHTTPError(ctx, w, err)
+		return
+	}
+
+	after := feed.After
+	if in.After != "" {
+		after = in.After
+	}
+	if id := req.Header.Get("Last-Event-ID"); id != "" {
+		after = id
+	}
+	cursor, err := query.DecodeTxAfter(after)
+	if err != nil {
+		WriteHTTPError(ctx, w, errors.Wrap(err, "decoding `after`"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteHTTPError(ctx, w, errStreamingUnsupported)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// unacked holds the cursors of the transactions sent
+	// but not yet acknowledged, in order.
+	var unacked []query.TxAfter
+
+	for {
+		limit := streamPageSize
+		if in.MaxUnacknowledged > 0 {
+			unacked, err = h.waitForTxFeedAck(ctx, feed.ID, unacked, in.MaxUnacknowledged)
+			if err != nil {
+				writeStreamError(ctx, w, err)
+				return
+			}
+			if n := in.MaxUnacknowledged - len(unacked); n < limit {
+				limit = n
+			}
+		}
+
+		pollCtx, cancel := context.WithTimeout(ctx, streamKeepalive)
+		txs, _, err := h.Indexer.Transactions(pollCtx, p, nil, cursor, limit, true)
+		cancel()
+		if ctx.Err() != nil {
+			// The client went away.
+			return
+		}
+		if errors.Root(err) == context.DeadlineExceeded {
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+			continue
+		}
+		if err != nil {
+			writeStreamError(ctx, w, errors.Wrap(err, "running tx query"))
+			return
+		}
+
+		for _, t := range txs {
+			next, err := txCursor(t, cursor)
+			if err != nil {
+				writeStreamError(ctx, w, err)
+				return
+			}
+			r, err := txResponse(t)
+			if err != nil {
+				writeStreamError(ctx, w, err)
+				return
+			}
+			data, err := json.Marshal(r)
+			if err != nil {
+				writeStreamError(ctx, w, err)
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", next, data)
+			if err != nil {
+				return
+			}
+			cursor = next
+			if in.MaxUnacknowledged > 0 {
+				unacked = append(unacked, next)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func decodeStreamTxFeedRequest(req *http.Request) (in streamTxFeedRequest, err error) {
+	if req.Method != "GET" {
+		err = json.NewDecoder(req.Body).Decode(&in)
+		if err != nil {
+			return in, errors.WithDetail(httpjson.ErrBadRequest, err.Error())
+		}
+		return in, nil
+	}
+
+	// EventSource clients can only make GET requests,
+	// so accept the parameters in the query string too.
+	q := req.URL.Query()
+	in.ID = q.Get("id")
+	in.Alias = q.Get("alias")
+	in.After = q.Get("after")
+	if s := q.Get("max_unacknowledged"); s != "" {
+		in.MaxUnacknowledged, err = strconv.Atoi(s)
+		if err != nil {
+			return in, errors.WithDetail(httpjson.ErrBadRequest, "invalid max_unacknowledged")
+		}
+	}
+	return in, nil
+}
+
+// waitForTxFeedAck removes the acknowledged cursors from unacked
+// and waits until fewer than max remain.
+func (h *Handler) waitForTxFeedAck(ctx context.Context, feedID string, unacked []query.TxAfter, max int) ([]query.TxAfter, error) {
+	for {
+		feed, err := h.TxFeeds.Find(ctx, feedID, "")
+		if err != nil {
+			return nil, err
+		}
+		acked, err := query.DecodeTxAfter(feed.After)
+		if err != nil {
+			return nil, err
+		}
+		for len(unacked) > 0 && !txAfterLess(acked, unacked[0]) {
+			unacked = unacked[1:]
+		}
+		if len(unacked) < max {
+			return unacked, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(streamAckPoll):
+		}
+	}
+}
+
+// txCursor returns the after that immediately follows the
+// transaction t in a stream that began at start.
+func txCursor(t interface{}, start query.TxAfter) (query.TxAfter, error) {
+	raw, ok := t.(*json.RawMessage)
+	if !ok || raw == nil {
+		return start, fmt.Errorf("unexpected type %T in Indexer.Transactions output", t)
+	}
+	var pos struct {
+		BlockHeight uint64 `json:"block_height"`
+		Position    uint32 `json:"position"`
+	}
+	err := json.Unmarshal(*raw, &pos)
+	if err != nil {
+		return start, errors.Wrap(err, "decoding Indexer.Transactions output")
+	}
+	return query.TxAfter{
+		FromBlockHeight: pos.BlockHeight,
+		FromPosition:    pos.Position,
+		StopBlockHeight: start.StopBlockHeight,
+	}, nil
+}
+
+// writeStreamError sends err as the final event of a stream.
+// The response status has already been written, so the error
+// is sent as an event of type "error" instead.
+func writeStreamError(ctx context.Context, w http.ResponseWriter, err error) {
+	logHTTPError(ctx, err)
+	body, _ := errInfo(err)
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Error(ctx, err)
+		return
+	}
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,10 +1,14 @@
 package core
 
 import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"chain/core/query"
 	"chain/errors"
+	"chain/net/http/httpjson"
 )
 
 func TestTxFeedIsBefore(t *testing.T) {
@@ -31,3 +35,48 @@ func TestTxFeedIsBefore(t *testing.T) {
 		}
 	}
 }
+
+func TestTxCursor(t *testing.T) {
+	raw := json.RawMessage(`{"id":"abc","block_height":7,"position":3}`)
+	start := query.TxAfter{FromBlockHeight: 5, FromPosition: 1, StopBlockHeight: 100}
+
+	got, err := txCursor(&raw, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query.TxAfter{FromBlockHeight: 7, FromPosition: 3, StopBlockHeight: 100}
+	if got != want {
+		t.Errorf("txCursor() = %s, want %s", got, want)
+	}
+	if !txAfterLess(start, got) {
+		t.Errorf("txAfterLess(%s, %s) = false, want true", start, got)
+	}
+}
+
+func TestDecodeStreamTxFeedRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/stream-transaction-feed?alias=foo&after=1:2-3&max_unacknowledged=10", nil)
+	got, err := decodeStreamTxFeedRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := streamTxFeedRequest{Alias: "foo", After: "1:2-3", MaxUnacknowledged: 10}
+	if got != want {
+		t.Errorf("decodeStreamTxFeedRequest(GET) = %+v, want %+v", got, want)
+	}
+
+	req = httptest.NewRequest("POST", "/stream-transaction-feed", strings.NewReader(`{"id":"txfeed1","max_unacknowledged":5}`))
+	got, err = decodeStreamTxFeedRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = streamTxFeedRequest{ID: "txfeed1", MaxUnacknowledged: 5}
+	if got != want {
+		t.Errorf("decodeStreamTxFeedRequest(POST) = %+v, want %+v", got, want)
+	}
+
+	req = httptest.NewRequest("GET", "/stream-transaction-feed?max_unacknowledged=many", nil)
+	_, err = decodeStreamTxFeedRequest(req)
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("decodeStreamTxFeedRequest(bad max) error = %v, want %s", err, httpjson.ErrBadRequest)
+	}
+}
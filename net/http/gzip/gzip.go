@@ -43,15 +43,25 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 type responseWriter struct {
-	w                   io.Writer // w wraps only method Write
-	http.ResponseWriter           // embedded for the other methods
+	w                   *gzip.Writer // w wraps only methods Write and Flush
+	http.ResponseWriter              // embedded for the other methods
 }
 
 var _ http.ResponseWriter = (*responseWriter)(nil)
 var _ http.Hijacker = (*responseWriter)(nil)
+var _ http.Flusher = (*responseWriter)(nil)
 
 func (w *responseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
 
+// Flush writes any buffered compressed data to the underlying
+// ResponseWriter and flushes it, for streaming responses.
+func (w *responseWriter) Flush() {
+	w.w.Flush()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	h, ok := w.ResponseWriter.(http.Hijacker)
 	if !ok {
@@ -1,6 +1,8 @@
 package gzip
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -91,3 +93,32 @@ func TestNoGzip(t *testing.T) {
 		t.Error("unexpected gzip")
 	}
 }
+
+func TestGzipFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	r.Header.Set("accept-encoding", "gzip")
+	h := Handler{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello, world")
+		w.(http.Flusher).Flush()
+
+		// The data written so far must be readable
+		// before the handler returns.
+		gz, err := gzip.NewReader(bytes.NewReader(w.(*responseWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len("hello, world"))
+		_, err = io.ReadFull(gz, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != "hello, world" {
+			t.Errorf("flushed data = %q want %q", buf, "hello, world")
+		}
+	})}
+	h.ServeHTTP(w, r)
+	if !w.Flushed {
+		t.Error("w.Flushed = false want true")
+	}
+}
@@ -39,8 +39,9 @@ func (c *Chain) GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
 // the current pending transaction pool. It returns the new block and
 // a snapshot of what the state snapshot is if the block is applied.
 //
-// After generating the block, the pending transaction pool will be
-// empty.
+// Pending transactions that don't fit in the block are returned
+// to the pending transaction pool for the next block. Those that
+// are invalid against the current state are dropped.
 func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *state.Snapshot, now time.Time) (b *bc.Block, result *state.Snapshot, err error) {
 	timestampMS := bc.Millis(now)
 	if timestampMS < prev.TimestampMS {
@@ -66,8 +67,9 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 		},
 	}
 
-	for _, tx := range txs {
+	for i, tx := range txs {
 		if len(b.Transactions) >= maxBlockTxs {
+			c.returnToPool(ctx, txs[i:])
 			break
 		}
 
@@ -81,6 +83,22 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 	return b, result, nil
 }
 
+// returnToPool puts txs that didn't fit in a block back in the
+// pending transaction pool. Txs the pool won't take back, because
+// they conflict with txs inserted since it was dumped, are dropped.
+func (c *Chain) returnToPool(ctx context.Context, txs []*bc.Tx) {
+	var dropped int
+	for _, tx := range txs {
+		err := c.pool.Insert(ctx, tx)
+		if err != nil {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.Messagef(ctx, "dropped %d of %d pending txs that didn't fit in block", dropped, len(txs))
+	}
+}
+
 // ValidateBlock performs validation on an incoming block, in advance
 // of committing the block. ValidateBlock returns the state after
 // the block has been applied.
@@ -101,6 +119,7 @@ func (c *Chain) ValidateBlock(ctx context.Context, prevState *state.Snapshot, pr
 //
 // This function:
 //   * saves the block to the store.
+//   * saves the block's state delta to the store (optionally).
 //   * saves the state tree to the store (optionally).
 //   * executes all new-block callbacks.
 //
@@ -114,7 +133,24 @@ func (c *Chain) CommitBlock(ctx context.Context, block *bc.Block, snapshot *stat
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
-	if block.Time().After(c.lastQueuedSnapshot.Add(saveSnapshotFrequency)) {
+	if ds, ok := c.store.(DeltaStore); ok {
+		delta, err := validation.BlockDelta(block)
+		if err != nil {
+			return errors.Wrap(err, "computing state delta")
+		}
+		err = ds.SaveDelta(ctx, block.Height, delta)
+		if err != nil {
+			return errors.Wrap(err, "storing state delta")
+		}
+	}
+	if ts, ok := c.store.(TreeStore); ok {
+		// The snapshot isn't shared until c.setState,
+		// so it's safe for the store to modify its tree.
+		err = ts.SaveTreeSnapshot(ctx, block.Height, snapshot)
+		if err != nil {
+			return errors.Wrap(err, "saving state tree")
+		}
+	} else if block.Time().After(c.lastQueuedSnapshot.Add(saveSnapshotFrequency)) {
 		c.queueSnapshot(ctx, block.Height, block.Time(), snapshot)
 	}
 
@@ -0,0 +1,342 @@
+package contract
+
+import (
+	"fmt"
+	"strings"
+
+	"chain/errors"
+)
+
+// builtin describes a function callable from contract source.
+type builtin struct {
+	params []Type
+	result Type
+
+	// code lists the assembly for a call. An int n stands for the
+	// code of argument n. Every string but the last is an op that
+	// pushes one item; the last consumes all the items pushed
+	// before it and pushes the result.
+	code []interface{}
+}
+
+var builtins = map[string]builtin{
+	"checksig":    {[]Type{PublicKey, Signature}, Boolean, []interface{}{1, "TXSIGHASH", 0, "CHECKSIG"}},
+	"after":       {[]Type{Time}, Boolean, []interface{}{"MINTIME", 0, "GREATERTHANOREQUAL"}},
+	"before":      {[]Type{Time}, Boolean, []interface{}{"MAXTIME", 0, "LESSTHANOREQUAL"}},
+	"sha3":        {[]Type{Bytes}, Hash, []interface{}{0, "SHA3"}},
+	"checkoutput": {[]Type{Integer, Amount, Asset, Program}, Boolean, []interface{}{0, "0", 1, 2, "1", 3, "CHECKOUTPUT"}},
+}
+
+// contextValues are the names that refer to
+// the input being spent, and the ops that push them.
+var contextValues = map[string]struct {
+	typ Type
+	op  string
+}{
+	"asset":   {Asset, "ASSET"},
+	"amount":  {Amount, "AMOUNT"},
+	"program": {Program, "PROGRAM"},
+}
+
+// Compile compiles the source of a contract.
+func Compile(src string) (*Contract, error) {
+	decl, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Contract{Name: decl.name, Params: decl.params}
+	err = checkNames(decl.params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []string
+	n := len(decl.clauses)
+	if n > 1 {
+		// The selector is below the contract arguments;
+		// bring it to the top and jump to its clause.
+		if len(decl.params) > 0 {
+			body = append(body, fmt.Sprint(len(decl.params)), "ROLL")
+		}
+		for i := n - 1; i > 0; i-- {
+			body = append(body, "DUP", fmt.Sprint(i), "NUMEQUAL", fmt.Sprintf("JUMPIF:$clause%d", i))
+		}
+		body = append(body, "0", "NUMEQUALVERIFY")
+	}
+
+	seen := make(map[string]bool)
+	for i, cl := range decl.clauses {
+		if seen[cl.name] {
+			return nil, errors.WithDetailf(ErrType, "line %d: clause %s redeclared", cl.line, cl.name)
+		}
+		seen[cl.name] = true
+
+		err = checkNames(cl.params, decl.params)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			// Jumps to this clause leave the selector on the stack.
+			body = append(body, fmt.Sprintf("$clause%d", i), "DROP")
+		}
+		code, err := compileClause(cl, decl.params)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, code...)
+		if n > 1 && i < n-1 {
+			body = append(body, "JUMP:$end")
+		}
+
+		clause := &Clause{Name: cl.name, Args: cl.params}
+		if n > 1 {
+			sel := int64(i)
+			clause.Selector = &sel
+		}
+		c.Clauses = append(c.Clauses, clause)
+	}
+	if n > 1 {
+		body = append(body, "$end")
+	}
+	c.Body = strings.Join(body, " ")
+	return c, nil
+}
+
+// checkNames checks that the names of params are distinct from
+// each other, from the names in outer, and from the builtins.
+func checkNames(params, outer []*Param) error {
+	seen := make(map[string]bool)
+	for _, p := range outer {
+		seen[p.Name] = true
+	}
+	for _, p := range params {
+		if _, ok := contextValues[p.Name]; ok || builtins[p.Name].code != nil {
+			return errors.WithDetailf(ErrType, "parameter %s has the name of a builtin", p.Name)
+		}
+		if seen[p.Name] {
+			return errors.WithDetailf(ErrType, "parameter %s redeclared", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+// clauseCompiler compiles the statements of one clause,
+// keeping track of where each parameter is on the data stack.
+type clauseCompiler struct {
+	types map[string]Type
+
+	// stack holds the name of each item on the data stack,
+	// or "" for intermediate values, bottom first.
+	stack []string
+
+	code []string
+}
+
+func compileClause(cl *clauseDecl, contractParams []*Param) ([]string, error) {
+	c := &clauseCompiler{types: make(map[string]Type)}
+	for _, p := range cl.params {
+		c.types[p.Name] = p.Type
+		c.stack = append(c.stack, p.Name)
+	}
+	for _, p := range contractParams {
+		c.types[p.Name] = p.Type
+		c.stack = append(c.stack, p.Name)
+	}
+
+	if len(cl.stmts) == 0 {
+		return []string{"TRUE"}, nil
+	}
+	for i, stmt := range cl.stmts {
+		typ, err := c.expr(stmt)
+		if err != nil {
+			return nil, err
+		}
+		if typ != Boolean {
+			return nil, errors.WithDetailf(ErrType, "line %d: verify requires a Boolean, got %s", stmt.exprLine(), typ)
+		}
+		// Leave the last result on top of the stack
+		// as the result of the program.
+		if i < len(cl.stmts)-1 {
+			c.emit("VERIFY")
+			c.pop(1)
+		}
+	}
+	return c.code, nil
+}
+
+func (c *clauseCompiler) emit(code ...string) {
+	c.code = append(c.code, code...)
+}
+
+func (c *clauseCompiler) push() {
+	c.stack = append(c.stack, "")
+}
+
+func (c *clauseCompiler) pop(n int) {
+	c.stack = c.stack[:len(c.stack)-n]
+}
+
+// expr emits code that pushes the value of e,
+// and returns its type.
+func (c *clauseCompiler) expr(e expr) (Type, error) {
+	switch e := e.(type) {
+	case *intExpr:
+		c.emit(fmt.Sprint(e.val))
+		c.push()
+		return Integer, nil
+
+	case *bytesExpr:
+		c.emit(fmt.Sprintf("0x%x", e.val))
+		c.push()
+		return Bytes, nil
+
+	case *identExpr:
+		if v, ok := contextValues[e.name]; ok {
+			c.emit(v.op)
+			c.push()
+			return v.typ, nil
+		}
+		typ, ok := c.types[e.name]
+		if !ok {
+			return "", errors.WithDetailf(ErrType, "line %d: undefined name %s", e.line, e.name)
+		}
+		var depth int
+		for i := len(c.stack) - 1; c.stack[i] != e.name; i-- {
+			depth++
+		}
+		switch depth {
+		case 0:
+			c.emit("DUP")
+		case 1:
+			c.emit("OVER")
+		default:
+			c.emit(fmt.Sprint(depth), "PICK")
+		}
+		c.push()
+		return typ, nil
+
+	case *callExpr:
+		b, ok := builtins[e.fn]
+		if !ok {
+			return "", errors.WithDetailf(ErrType, "line %d: undefined function %s", e.line, e.fn)
+		}
+		if len(e.args) != len(b.params) {
+			return "", errors.WithDetailf(ErrType, "line %d: %s takes %d arguments, got %d", e.line, e.fn, len(b.params), len(e.args))
+		}
+		for i, arg := range e.args {
+			err := c.check(arg, b.params[i], fmt.Sprintf("argument %d of %s", i+1, e.fn))
+			if err != nil {
+				return "", err
+			}
+		}
+		depth := len(c.stack)
+		for i, item := range b.code {
+			switch item := item.(type) {
+			case int:
+				_, err := c.expr(e.args[item])
+				if err != nil {
+					return "", err
+				}
+			case string:
+				c.emit(item)
+				if i < len(b.code)-1 {
+					c.push()
+				}
+			}
+		}
+		c.pop(len(c.stack) - depth)
+		c.push()
+		return b.result, nil
+
+	case *binaryExpr:
+		left, err := c.typeOf(e.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.typeOf(e.right)
+		if err != nil {
+			return "", err
+		}
+		op, err := compareOp(e.op, left, right)
+		if err != nil {
+			return "", errors.WithDetailf(ErrType, "line %d: %s", e.line, err)
+		}
+		_, err = c.expr(e.left)
+		if err != nil {
+			return "", err
+		}
+		_, err = c.expr(e.right)
+		if err != nil {
+			return "", err
+		}
+		c.emit(op...)
+		c.pop(2)
+		c.push()
+		return Boolean, nil
+	}
+	return "", errors.WithDetailf(ErrType, "line %d: unexpected expression", e.exprLine())
+}
+
+// typeOf returns the type of e without emitting code.
+func (c *clauseCompiler) typeOf(e expr) (Type, error) {
+	saved := *c
+	saved.stack = append([]string(nil), c.stack...)
+	typ, err := c.expr(e)
+	*c = saved
+	return typ, err
+}
+
+// check checks that e may be used where a value of type want
+// is expected, with what describing the use.
+func (c *clauseCompiler) check(e expr, want Type, what string) error {
+	got, err := c.typeOf(e)
+	if err != nil {
+		return err
+	}
+	if !assignable(got, want) {
+		return errors.WithDetailf(ErrType, "line %d: %s is %s, want %s", e.exprLine(), what, got, want)
+	}
+	return nil
+}
+
+// assignable reports whether a value of type got
+// may be used where a value of type want is expected.
+func assignable(got, want Type) bool {
+	switch {
+	case got == want:
+		return true
+	case got == Integer:
+		return want.numeric()
+	case got == Bytes:
+		return want.bytes()
+	case want == Bytes:
+		return got.bytes()
+	}
+	return false
+}
+
+// compareOp returns the ops that compare values
+// of the given types with relational operator op.
+func compareOp(op string, left, right Type) ([]string, error) {
+	if !assignable(left, right) && !assignable(right, left) {
+		return nil, fmt.Errorf("cannot compare %s with %s", left, right)
+	}
+	switch {
+	case left.numeric():
+		return map[string][]string{
+			"==": {"NUMEQUAL"},
+			"!=": {"NUMNOTEQUAL"},
+			"<":  {"LESSTHAN"},
+			"<=": {"LESSTHANOREQUAL"},
+			">":  {"GREATERTHAN"},
+			">=": {"GREATERTHANOREQUAL"},
+		}[op], nil
+	case left.bytes() && op == "==":
+		return []string{"EQUAL"}, nil
+	case left.bytes() && op == "!=":
+		return []string{"EQUAL", "NOT"}, nil
+	}
+	return nil, fmt.Errorf("operator %s not defined on %s", op, left)
+}
@@ -0,0 +1,152 @@
+package contract
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+const lockWithTimeout = `
+// LockWithTimeout may be spent by its owner at any time,
+// or by the recipient once the deadline has passed.
+contract LockWithTimeout(owner: PublicKey, recipient: PublicKey, deadline: Time) {
+	clause spend(sig: Signature) {
+		verify checksig(owner, sig)
+	}
+	clause expire(sig: Signature) {
+		verify after(deadline)
+		verify checksig(recipient, sig)
+	}
+}
+`
+
+const payTo = `
+contract PayTo(price: Amount, currency: Asset, seller: Program) {
+	clause buy() {
+		verify amount >= 10
+		verify checkoutput(0, price, currency, seller)
+	}
+}
+`
+
+// spendTx returns a tx spending an output with the given control
+// program, with the given mintime and outputs, and signs its only
+// input with the witness built by witnessFn.
+func spendTx(prog []byte, minTime uint64, outs []*bc.TxOutput, witnessFn func(sighash bc.Hash) [][]byte) *bc.Tx {
+	data := bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{2}, 100, prog, nil)},
+		Outputs: outs,
+		MinTime: minTime,
+	}
+	h := bc.NewSigHasher(&data).Hash(0)
+	data.Inputs[0].SetArguments(witnessFn(h))
+	return bc.NewTx(data)
+}
+
+func TestLockWithTimeout(t *testing.T) {
+	c, err := Compile(lockWithTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerPub, ownerPriv, _ := ed25519.GenerateKey(nil)
+	recipPub, recipPriv, _ := ed25519.GenerateKey(nil)
+	prog, err := c.Program(ownerPub, recipPub, vm.Int64Bytes(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		clause  string
+		key     ed25519.PrivateKey
+		minTime uint64
+		want    bool
+	}{
+		{"spend", ownerPriv, 0, true},
+		{"spend", recipPriv, 0, false},
+		{"expire", recipPriv, 999, false},
+		{"expire", recipPriv, 1000, true},
+		{"expire", ownerPriv, 1000, false},
+	}
+	for _, test := range cases {
+		tx := spendTx(prog, test.minTime, nil, func(h bc.Hash) [][]byte {
+			w, err := c.Clause(test.clause).Witness(ed25519.Sign(test.key, h[:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return w
+		})
+		ok, err := vm.VerifyTxInput(tx, 0)
+		if err != nil && test.want {
+			t.Errorf("%s at %d: unexpected error %v", test.clause, test.minTime, err)
+		}
+		if ok != test.want {
+			t.Errorf("%s at %d: got %v, want %v", test.clause, test.minTime, ok, test.want)
+		}
+	}
+}
+
+func TestPayTo(t *testing.T) {
+	c, err := Compile(payTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Clauses[0].Selector != nil {
+		t.Errorf("got selector %d for only clause, want none", *c.Clauses[0].Selector)
+	}
+	currency := bc.AssetID{3}
+	prog, err := c.Program(vm.Int64Bytes(50), currency[:], []byte{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		out  *bc.TxOutput
+		want bool
+	}{
+		{bc.NewTxOutput(bc.AssetID{3}, 50, []byte{4}, nil), true},
+		{bc.NewTxOutput(bc.AssetID{3}, 49, []byte{4}, nil), false},
+		{bc.NewTxOutput(bc.AssetID{2}, 50, []byte{4}, nil), false},
+		{bc.NewTxOutput(bc.AssetID{3}, 50, []byte{5}, nil), false},
+	}
+	for i, test := range cases {
+		tx := spendTx(prog, 0, []*bc.TxOutput{test.out}, func(bc.Hash) [][]byte { return nil })
+		ok, err := vm.VerifyTxInput(tx, 0)
+		if err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+		}
+		if ok != test.want {
+			t.Errorf("case %d: got %v, want %v", i, ok, test.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want error
+	}{
+		{`contract C() {}`, ErrSyntax},
+		{`contract C() { clause c() { verify } }`, ErrSyntax},
+		{`contract C(x: Float) { clause c() {} }`, ErrSyntax},
+		{`contract C() { clause c() { verify 1 == 2 } } extra`, ErrSyntax},
+		{`contract C(x: Integer) { clause c(x: Integer) {} }`, ErrType},
+		{`contract C() { clause c() {} clause c() {} }`, ErrType},
+		{`contract C() { clause c() { verify y } }`, ErrType},
+		{`contract C(t: Time) { clause c() { verify t } }`, ErrType},
+		{`contract C(t: Time, n: Amount) { clause c() { verify t < n } }`, ErrType},
+		{`contract C(k: PublicKey) { clause c(s: Signature) { verify checksig(s, k) } }`, ErrType},
+		{`contract C(a: Asset) { clause c() { verify a < asset } }`, ErrType},
+		{`contract C(asset: Asset) { clause c() {} }`, ErrType},
+		{`contract C() { clause c() { verify after(1, 2) } }`, ErrType},
+	}
+	for _, test := range cases {
+		_, err := Compile(test.src)
+		if errors.Root(err) != test.want {
+			t.Errorf("Compile(%q) error = %v, want %v", test.src, err, test.want)
+		}
+	}
+}
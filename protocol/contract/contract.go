@@ -0,0 +1,163 @@
+package contract
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+var (
+	// ErrSyntax is returned when compiling source
+	// that is not a well-formed contract.
+	ErrSyntax = errors.New("syntax error")
+
+	// ErrType is returned when compiling a contract that
+	// misuses a name or a value of the wrong type.
+	ErrType = errors.New("type error")
+
+	// ErrArgs is returned when instantiating a contract or
+	// satisfying a clause with the wrong number of arguments,
+	// or with a value that can't be parsed as its type.
+	ErrArgs = errors.New("bad arguments")
+)
+
+// Type is the type of a contract or clause parameter.
+type Type string
+
+const (
+	Integer   Type = "Integer"
+	Amount    Type = "Amount"
+	Time      Type = "Time"
+	Boolean   Type = "Boolean"
+	Asset     Type = "Asset"
+	PublicKey Type = "PublicKey"
+	Signature Type = "Signature"
+	Program   Type = "Program"
+	Hash      Type = "Hash"
+	Bytes     Type = "Bytes"
+)
+
+func (t Type) valid() bool {
+	return t == Boolean || t.numeric() || t.bytes()
+}
+
+// numeric reports whether values of type t are
+// integers, compared with the VM's numeric ops.
+func (t Type) numeric() bool {
+	return t == Integer || t == Amount || t == Time
+}
+
+// bytes reports whether values of type t are
+// byte strings, compared with EQUAL.
+func (t Type) bytes() bool {
+	switch t {
+	case Asset, PublicKey, Signature, Program, Hash, Bytes:
+		return true
+	}
+	return false
+}
+
+// Encode parses s as a value of type t and returns
+// it as the VM represents it on the data stack.
+// Integer, Amount and Time values are given in decimal,
+// Boolean values as true or false, and all others in hex.
+func (t Type) Encode(s string) ([]byte, error) {
+	switch {
+	case t.numeric():
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrArgs, "bad %s value %q", t, s)
+		}
+		return vm.Int64Bytes(n), nil
+	case t == Boolean:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrArgs, "bad %s value %q", t, s)
+		}
+		return vm.BoolBytes(b), nil
+	case t.bytes():
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrArgs, "bad %s value %q", t, s)
+		}
+		return b, nil
+	}
+	return nil, errors.WithDetailf(ErrArgs, "unknown type %s", t)
+}
+
+// Param is a named, typed parameter of a contract or clause.
+type Param struct {
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+}
+
+// Contract is a compiled contract. Its JSON encoding
+// describes the arguments expected by the contract and
+// each of its clauses, so that control programs and
+// input witnesses can be built without the source.
+type Contract struct {
+	Name    string    `json:"name"`
+	Params  []*Param  `json:"params"`
+	Clauses []*Clause `json:"clauses"`
+
+	// Body is the contract's program in the syntax of
+	// vm.Assemble, excluding the contract arguments.
+	Body string `json:"body"`
+}
+
+// Clause describes the input witness
+// expected by a clause of a contract.
+type Clause struct {
+	Name string   `json:"name"`
+	Args []*Param `json:"args"`
+
+	// Selector, if set, is the number that follows the clause
+	// arguments in the input witness to choose this clause. It
+	// is set only if the contract has more than one clause.
+	Selector *int64 `json:"selector,omitempty"`
+}
+
+// Program returns the control program for the contract with the
+// given arguments, one for each of c.Params, as encoded by
+// Type.Encode.
+func (c *Contract) Program(args ...[]byte) ([]byte, error) {
+	if len(args) != len(c.Params) {
+		return nil, errors.WithDetailf(ErrArgs, "contract %s takes %d arguments, got %d", c.Name, len(c.Params), len(args))
+	}
+	var src []string
+	for _, arg := range args {
+		src = append(src, fmt.Sprintf("0x%x", arg))
+	}
+	src = append(src, c.Body)
+	prog, err := vm.Assemble(strings.Join(src, " "))
+	return prog, errors.Wrap(err, "assembling contract program")
+}
+
+// Clause returns the clause with the given name,
+// or nil if there is no such clause.
+func (c *Contract) Clause(name string) *Clause {
+	for _, cl := range c.Clauses {
+		if cl.Name == name {
+			return cl
+		}
+	}
+	return nil
+}
+
+// Witness returns the input witness arguments that satisfy the
+// clause, given a value for each of cl.Args, as encoded by
+// Type.Encode.
+func (cl *Clause) Witness(args ...[]byte) ([][]byte, error) {
+	if len(args) != len(cl.Args) {
+		return nil, errors.WithDetailf(ErrArgs, "clause %s takes %d arguments, got %d", cl.Name, len(cl.Args), len(args))
+	}
+	witness := append([][]byte{}, args...)
+	if cl.Selector != nil {
+		witness = append(witness, vm.Int64Bytes(*cl.Selector))
+	}
+	return witness, nil
+}
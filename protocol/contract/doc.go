@@ -0,0 +1,59 @@
+/*
+Package contract compiles a small, typed contract language
+into control programs for the Chain VM.
+
+A contract has named, typed parameters, fixed when the contract
+is instantiated as a control program, and one or more clauses.
+Each clause has its own named, typed parameters, supplied as the
+arguments of the input witness when the output is spent, and a
+list of conditions that must all hold for the clause to succeed:
+
+	// LockWithTimeout may be spent by its owner at any time,
+	// or by the recipient once the deadline has passed.
+	contract LockWithTimeout(owner: PublicKey, recipient: PublicKey, deadline: Time) {
+		clause spend(sig: Signature) {
+			verify checksig(owner, sig)
+		}
+		clause expire(sig: Signature) {
+			verify after(deadline)
+			verify checksig(recipient, sig)
+		}
+	}
+
+# Types
+
+The types are Integer, Amount, Time (in milliseconds since the
+Unix epoch), Boolean, Asset, PublicKey, Signature, Program, Hash
+and Bytes. Amount and Time values may be compared with values of
+the same type or with Integer values. Asset, PublicKey, Signature,
+Program and Hash values may be compared for equality with values
+of the same type or with Bytes values.
+
+# Expressions
+
+Expressions are parameter names, decimal integer literals, hex
+literals such as 0x0102, comparisons using ==, !=, <, <=, > and
+>=, parenthesized expressions, and the following:
+
+	asset                 Asset      the asset of the value being spent
+	amount                Amount     the amount of the value being spent
+	program               Program    the control program being satisfied
+	checksig(k, s)        Boolean    s is k's signature of the transaction
+	after(t)              Boolean    the transaction's mintime is at or after t
+	before(t)             Boolean    the transaction's maxtime is at or before t
+	sha3(b)               Hash       the SHA3-256 hash of b
+	checkoutput(i, n, a, p)
+	                      Boolean    output i pays n units of asset a to
+	                                 control program p
+
+Every statement is "verify" followed by a Boolean expression.
+
+# Calling conventions
+
+The compiled program begins by pushing the contract arguments, in
+order, onto the data stack. The input witness for a clause holds
+the clause arguments in order, followed by the clause's selector
+if the contract has more than one clause. See Contract.Program and
+Clause.Witness.
+*/
+package contract
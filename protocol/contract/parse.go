@@ -0,0 +1,320 @@
+package contract
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	"chain/errors"
+)
+
+type (
+	contractDecl struct {
+		name    string
+		params  []*Param
+		clauses []*clauseDecl
+	}
+
+	clauseDecl struct {
+		name   string
+		params []*Param
+		stmts  []expr // each is the condition of a verify statement
+		line   int
+	}
+
+	expr interface {
+		exprLine() int
+	}
+
+	identExpr struct {
+		name string
+		line int
+	}
+
+	intExpr struct {
+		val  int64
+		line int
+	}
+
+	bytesExpr struct {
+		val  []byte
+		line int
+	}
+
+	callExpr struct {
+		fn   string
+		args []expr
+		line int
+	}
+
+	binaryExpr struct {
+		op          string
+		left, right expr
+		line        int
+	}
+)
+
+func (e *identExpr) exprLine() int  { return e.line }
+func (e *intExpr) exprLine() int    { return e.line }
+func (e *bytesExpr) exprLine() int  { return e.line }
+func (e *callExpr) exprLine() int   { return e.line }
+func (e *binaryExpr) exprLine() int { return e.line }
+
+var relOps = map[string]bool{
+	"==": true,
+	"!=": true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses the source of a single contract.
+func parse(src string) (*contractDecl, error) {
+	toks, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	c, err := p.contract()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s after contract", tok)
+	}
+	return c, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return errors.WithDetailf(ErrSyntax, "line %d: "+format, append([]interface{}{tok.line}, args...)...)
+}
+
+// expect consumes the next token, which must be the given
+// keyword or punctuation.
+func (p *parser) expect(text string) error {
+	tok := p.next()
+	if (tok.kind != tokPunct && tok.kind != tokIdent) || tok.text != text {
+		return p.errorf(tok, "expected %q, got %s", text, tok)
+	}
+	return nil
+}
+
+// accept consumes the next token if it is the
+// given keyword or punctuation.
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokPunct || tok.kind == tokIdent) && tok.text == text {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) ident() (token, error) {
+	tok := p.next()
+	if tok.kind != tokIdent {
+		return tok, p.errorf(tok, "expected name, got %s", tok)
+	}
+	if keywords[tok.text] {
+		return tok, p.errorf(tok, "%q is a keyword", tok.text)
+	}
+	return tok, nil
+}
+
+var keywords = map[string]bool{
+	"contract": true,
+	"clause":   true,
+	"verify":   true,
+}
+
+// contract = "contract" name "(" params ")" "{" clause { clause } "}"
+func (p *parser) contract() (*contractDecl, error) {
+	err := p.expect("contract")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	params, err := p.params()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	c := &contractDecl{name: name.text, params: params}
+	for !p.accept("}") {
+		cl, err := p.clause()
+		if err != nil {
+			return nil, err
+		}
+		c.clauses = append(c.clauses, cl)
+	}
+	if len(c.clauses) == 0 {
+		return nil, p.errorf(name, "contract %s has no clauses", c.name)
+	}
+	return c, nil
+}
+
+// clause = "clause" name "(" params ")" "{" { "verify" expr } "}"
+func (p *parser) clause() (*clauseDecl, error) {
+	err := p.expect("clause")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	params, err := p.params()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	cl := &clauseDecl{name: name.text, params: params, line: name.line}
+	for !p.accept("}") {
+		err = p.expect("verify")
+		if err != nil {
+			return nil, err
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		cl.stmts = append(cl.stmts, e)
+	}
+	return cl, nil
+}
+
+// params = "(" [ name ":" type { "," name ":" type } ] ")"
+func (p *parser) params() ([]*Param, error) {
+	err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+	var params []*Param
+	if p.accept(")") {
+		return nil, nil
+	}
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(":")
+		if err != nil {
+			return nil, err
+		}
+		tok := p.next()
+		typ := Type(tok.text)
+		if tok.kind != tokIdent || !typ.valid() {
+			return nil, p.errorf(tok, "expected type, got %s", tok)
+		}
+		params = append(params, &Param{Name: name.text, Type: typ})
+		if p.accept(")") {
+			return params, nil
+		}
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// expr = operand [ relop operand ]
+func (p *parser) expr() (expr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind != tokPunct || !relOps[tok.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: tok.text, left: left, right: right, line: tok.line}, nil
+}
+
+// operand = name | name "(" [ expr { "," expr } ] ")" | int | hex | "(" expr ")"
+func (p *parser) operand() (expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "bad integer %s", tok.text)
+		}
+		return &intExpr{val: n, line: tok.line}, nil
+	case tokHex:
+		b, err := hex.DecodeString(tok.text[2:])
+		if err != nil {
+			return nil, p.errorf(tok, "bad hex literal %s", tok.text)
+		}
+		return &bytesExpr{val: b, line: tok.line}, nil
+	case tokIdent:
+		if keywords[tok.text] {
+			return nil, p.errorf(tok, "unexpected keyword %q", tok.text)
+		}
+		if !p.accept("(") {
+			return &identExpr{name: tok.text, line: tok.line}, nil
+		}
+		call := &callExpr{fn: tok.text, line: tok.line}
+		if p.accept(")") {
+			return call, nil
+		}
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(")") {
+				return call, nil
+			}
+			err = p.expect(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+	case tokPunct:
+		if tok.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect(")")
+			if err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+	return nil, p.errorf(tok, "expected expression, got %s", tok)
+}
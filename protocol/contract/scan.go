@@ -0,0 +1,107 @@
+package contract
+
+import (
+	"strings"
+	"unicode"
+
+	"chain/errors"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokHex
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return `"` + t.text + `"`
+}
+
+// twoCharPunct lists the punctuation made of two characters.
+// All other punctuation is one character.
+var twoCharPunct = map[string]bool{
+	"==": true,
+	"!=": true,
+	"<=": true,
+	">=": true,
+}
+
+const oneCharPunct = "(){},:<>"
+
+// scan splits src into tokens.
+func scan(src string) ([]token, error) {
+	var (
+		toks []token
+		line = 1
+	)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '0' && i+1 < len(src) && src[i+1] == 'x':
+			j := i + 2
+			for j < len(src) && isHexDigit(src[j]) {
+				j++
+			}
+			toks = append(toks, token{tokHex, src[i:j], line})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			toks = append(toks, token{tokInt, src[i:j], line})
+			i = j
+		case isLetter(c):
+			j := i
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], line})
+			i = j
+		case i+1 < len(src) && twoCharPunct[src[i:i+2]]:
+			toks = append(toks, token{tokPunct, src[i : i+2], line})
+			i += 2
+		case strings.IndexByte(oneCharPunct, c) >= 0:
+			toks = append(toks, token{tokPunct, src[i : i+1], line})
+			i++
+		default:
+			return nil, errors.WithDetailf(ErrSyntax, "line %d: unexpected character %q", line, c)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, line: line})
+	return toks, nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"context"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// RecoverFiltered is like Recover, for a light client that
+// stores filtered blocks and keeps no state snapshot.
+// It returns the latest stored block, or nil if the
+// blockchain is empty.
+//
+// See validation.ValidateFilteredBlock.
+func (c *Chain) RecoverFiltered(ctx context.Context) (*bc.Block, error) {
+	height, err := c.store.Height(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting blockchain height")
+	}
+	var b *bc.Block
+	if height > 0 {
+		b, err = c.store.GetBlock(ctx, height)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting block")
+		}
+		err = c.store.FinalizeBlock(ctx, height)
+		if err != nil {
+			return nil, errors.Wrap(err, "finalizing block")
+		}
+		c.setHeight(height)
+	}
+
+	close(c.ready)
+
+	return b, nil
+}
+
+// CommitFilteredBlock commits a filtered block,
+// which must already have been validated, to the
+// blockchain. Unlike CommitBlock, it leaves the
+// blockchain state unset.
+func (c *Chain) CommitFilteredBlock(ctx context.Context, block *bc.Block) error {
+	err := c.store.SaveBlock(ctx, block)
+	if err != nil {
+		return errors.Wrap(err, "storing block")
+	}
+	err = c.store.FinalizeBlock(ctx, block.Height)
+	if err != nil {
+		return errors.Wrap(err, "finalizing block")
+	}
+	c.setHeight(block.Height)
+	return nil
+}
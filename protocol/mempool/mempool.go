@@ -1,57 +1,296 @@
 // Package mempool provides a Pool implementation that keeps
 // all pending transactions in memory.
 //
-// It is used in tests to avoid needing a database and is not
-// safe for concurrent access.
+// Transactions are dumped in order of priority, then in the
+// order they were inserted, subject to each transaction
+// following any pending transactions whose outputs it spends.
 package mempool
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
 	"sync"
+	"time"
 
-	"chain/log"
+	"chain/errors"
 	"chain/protocol/bc"
 )
 
+var (
+	// ErrConflict is returned when inserting a transaction that
+	// spends an output already spent by a pending transaction.
+	ErrConflict = errors.New("transaction conflicts with a pending transaction")
+
+	// ErrPoolFull is returned when the pool holds MaxSize
+	// transactions, none of which has a lower priority
+	// than the one being inserted.
+	ErrPoolFull = errors.New("pending transaction pool is full")
+
+	// ErrNotFound is returned when getting, removing or
+	// replacing a transaction that is not in the pool.
+	ErrNotFound = errors.New("pending transaction not found")
+)
+
 // MemPool satisfies the protocol.Pool interface.
 type MemPool struct {
+	// MaxSize is the largest number of transactions the pool holds.
+	// When it is full, inserting a transaction evicts the pending
+	// transaction with the lowest priority, along with any pending
+	// transactions that spend its outputs. A pending transaction
+	// whose outputs the inserted one spends, directly or through
+	// other pending transactions, is never evicted. Zero means no
+	// limit.
+	MaxSize int
+
+	// Priority returns the priority of a transaction. Transactions
+	// with higher priority are dumped first. If Priority is nil,
+	// every transaction has priority 0, and transactions are dumped
+	// oldest first.
+	Priority func(*bc.Tx) int64
+
 	mu     sync.Mutex
-	pool   []*bc.Tx // in topological order
-	hashes map[bc.Hash]bool
+	seq    uint64
+	txs    map[bc.Hash]*poolTx
+	spends map[bc.Outpoint]bc.Hash // outpoint -> hash of pending tx spending it
+
+	// dumped holds the insertion times of the txs returned by
+	// the last Dump, so that txs returned to the pool because
+	// they didn't fit in a block keep their insertion times.
+	dumped map[bc.Hash]time.Time
+}
+
+// poolTx is a pending transaction and its place in the pool.
+type poolTx struct {
+	tx       *bc.Tx
+	priority int64
+	seq      uint64 // insertion order
+	added    time.Time
 }
 
 // New returns a new MemPool.
 func New() *MemPool {
-	return &MemPool{hashes: make(map[bc.Hash]bool)}
+	return &MemPool{
+		txs:    make(map[bc.Hash]*poolTx),
+		spends: make(map[bc.Outpoint]bc.Hash),
+	}
 }
 
 // Insert adds a new pending tx to the pending tx pool.
+// It returns ErrConflict if tx spends an output already
+// spent by a pending tx, and ErrPoolFull if the pool is
+// full of txs with priority at least that of tx.
 func (m *MemPool) Insert(ctx context.Context, tx *bc.Tx) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.insert(tx)
+}
 
-	if m.hashes[tx.Hash] {
-		return nil
+// Replace removes the pending tx with hash old, along with any
+// pending txs that spend its outputs, and inserts tx in its place.
+// Tx may spend the same outputs as the tx it replaces. If tx can't
+// be inserted, the pool is left unchanged.
+func (m *MemPool) Replace(ctx context.Context, old bc.Hash, tx *bc.Tx) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.txs[old] == nil {
+		return errors.WithDetailf(ErrNotFound, "transaction %x", old[:])
 	}
+	removed := m.remove(old)
+	err := m.insert(tx)
+	if err != nil {
+		// Put back what we removed.
+		for _, ptx := range removed {
+			m.add(ptx)
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove removes the pending tx with hash h, along with
+// any pending txs that spend its outputs.
+func (m *MemPool) Remove(ctx context.Context, h bc.Hash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	m.hashes[tx.Hash] = true
-	m.pool = append(m.pool, tx)
+	if m.remove(h) == nil {
+		return errors.WithDetailf(ErrNotFound, "transaction %x", h[:])
+	}
 	return nil
 }
 
+// Pending returns the hashes of the pending txs, oldest first.
+func (m *MemPool) Pending(ctx context.Context) ([]bc.Hash, error) {
+	m.mu.Lock()
+	ptxs := make([]*poolTx, 0, len(m.txs))
+	for _, ptx := range m.txs {
+		ptxs = append(ptxs, ptx)
+	}
+	m.mu.Unlock()
+
+	sort.Sort(byAge(ptxs))
+	hashes := make([]bc.Hash, 0, len(ptxs))
+	for _, ptx := range ptxs {
+		hashes = append(hashes, ptx.tx.Hash)
+	}
+	return hashes, nil
+}
+
+// Get returns the pending tx with hash h
+// and the time it was inserted into the pool.
+func (m *MemPool) Get(ctx context.Context, h bc.Hash) (*bc.Tx, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ptx := m.txs[h]
+	if ptx == nil {
+		return nil, time.Time{}, errors.WithDetailf(ErrNotFound, "transaction %x", h[:])
+	}
+	return ptx.tx, ptx.added, nil
+}
+
 // Dump returns all pending transactions in the pool and
 // empties the pool.
 func (m *MemPool) Dump(ctx context.Context) ([]*bc.Tx, error) {
 	m.mu.Lock()
-	txs := m.pool
-	m.pool = nil
-	m.hashes = make(map[bc.Hash]bool)
+	ptxs := make([]*poolTx, 0, len(m.txs))
+	m.dumped = make(map[bc.Hash]time.Time, len(m.txs))
+	for _, ptx := range m.txs {
+		ptxs = append(ptxs, ptx)
+		m.dumped[ptx.tx.Hash] = ptx.added
+	}
+	m.txs = make(map[bc.Hash]*poolTx)
+	m.spends = make(map[bc.Outpoint]bc.Hash)
 	m.mu.Unlock()
 
-	if !isTopSorted(txs) {
-		log.Messagef(ctx, "set of %d txs not in topo order; sorting", len(txs))
-		txs = topSort(txs)
+	return prioritySort(ptxs), nil
+}
+
+// insert adds tx to the pool, evicting lower-priority
+// txs if necessary. The caller must hold m.mu.
+func (m *MemPool) insert(tx *bc.Tx) error {
+	if m.txs[tx.Hash] != nil {
+		return nil
+	}
+
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
+		}
+		if h, ok := m.spends[in.Outpoint()]; ok {
+			return errors.WithDetailf(ErrConflict, "output %v is spent by pending transaction %x", in.Outpoint(), h[:])
+		}
 	}
 
-	return txs, nil
+	var priority int64
+	if m.Priority != nil {
+		priority = m.Priority(tx)
+	}
+	if m.MaxSize > 0 && len(m.txs) >= m.MaxSize {
+		// Evicting a pending tx whose outputs tx
+		// spends would leave tx orphaned in the pool.
+		victim := m.lowest(m.ancestors(tx))
+		if victim == nil || victim.priority >= priority {
+			return errors.WithDetailf(ErrPoolFull, "pool holds %d transactions", len(m.txs))
+		}
+		m.remove(victim.tx.Hash)
+	}
+
+	added, ok := m.dumped[tx.Hash]
+	if !ok {
+		added = time.Now()
+	}
+	m.seq++
+	m.add(&poolTx{tx: tx, priority: priority, seq: m.seq, added: added})
+	return nil
+}
+
+// add adds ptx to the pool's indexes.
+// The caller must hold m.mu.
+func (m *MemPool) add(ptx *poolTx) {
+	m.txs[ptx.tx.Hash] = ptx
+	for _, in := range ptx.tx.Inputs {
+		if !in.IsIssuance() {
+			m.spends[in.Outpoint()] = ptx.tx.Hash
+		}
+	}
+}
+
+// remove removes the tx with hash h from the pool, along with all
+// pending txs that descend from it. It returns the removed txs.
+// The caller must hold m.mu.
+func (m *MemPool) remove(h bc.Hash) []*poolTx {
+	ptx := m.txs[h]
+	if ptx == nil {
+		return nil
+	}
+	delete(m.txs, h)
+	for _, in := range ptx.tx.Inputs {
+		if !in.IsIssuance() {
+			delete(m.spends, in.Outpoint())
+		}
+	}
+
+	removed := []*poolTx{ptx}
+	for i := range ptx.tx.Outputs {
+		child, ok := m.spends[bc.Outpoint{Hash: h, Index: uint32(i)}]
+		if ok {
+			removed = append(removed, m.remove(child)...)
+		}
+	}
+	return removed
+}
+
+// lowest returns the pending tx with the lowest priority, the
+// newest among equals, other than the txs in exclude. It returns
+// nil if there is none. The caller must hold m.mu.
+func (m *MemPool) lowest(exclude map[bc.Hash]bool) *poolTx {
+	var low *poolTx
+	for h, ptx := range m.txs {
+		if exclude[h] {
+			continue
+		}
+		if low == nil || ptx.priority < low.priority || (ptx.priority == low.priority && ptx.seq > low.seq) {
+			low = ptx
+		}
+	}
+	return low
+}
+
+// ancestors returns the hashes of the pending txs whose outputs
+// tx spends, and of their ancestors. The caller must hold m.mu.
+func (m *MemPool) ancestors(tx *bc.Tx) map[bc.Hash]bool {
+	anc := make(map[bc.Hash]bool)
+	stack := []*bc.Tx{tx}
+	for len(stack) > 0 {
+		tx, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			h := in.Outpoint().Hash
+			if ptx := m.txs[h]; ptx != nil && !anc[h] {
+				anc[h] = true
+				stack = append(stack, ptx.tx)
+			}
+		}
+	}
+	return anc
+}
+
+// ReferenceDataPriority is a priority function for MemPool that
+// reads a transaction's priority from the "priority" field of its
+// reference data, if the reference data is a JSON object with an
+// integer "priority" field. Other transactions have priority 0.
+func ReferenceDataPriority(tx *bc.Tx) int64 {
+	var refData struct {
+		Priority int64 `json:"priority"`
+	}
+	err := json.Unmarshal(tx.ReferenceData, &refData)
+	if err != nil {
+		return 0
+	}
+	return refData.Priority
 }
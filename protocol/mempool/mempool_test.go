@@ -0,0 +1,213 @@
+package mempool
+
+import (
+	"context"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// spend returns a tx spending the given outpoints,
+// with one output and the given reference data.
+func spend(refData string, outs ...bc.Outpoint) *bc.Tx {
+	var ins []*bc.TxInput
+	for _, o := range outs {
+		ins = append(ins, bc.NewSpendInput(o.Hash, o.Index, nil, bc.AssetID{}, 1, nil, nil))
+	}
+	return bc.NewTx(bc.TxData{
+		Version:       1,
+		Inputs:        ins,
+		Outputs:       []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, nil, nil)},
+		ReferenceData: []byte(refData),
+	})
+}
+
+func out(h byte) bc.Outpoint {
+	return bc.Outpoint{Hash: bc.Hash{h}}
+}
+
+func outOf(tx *bc.Tx) bc.Outpoint {
+	return bc.Outpoint{Hash: tx.Hash}
+}
+
+func mustInsert(t *testing.T, m *MemPool, txs ...*bc.Tx) {
+	for _, tx := range txs {
+		err := m.Insert(context.Background(), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkDump(t *testing.T, m *MemPool, want ...*bc.Tx) {
+	got, err := m.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("dumped %d txs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Hash != want[i].Hash {
+			t.Errorf("dumped tx %d = %x, want %x", i, got[i].Hash[:4], want[i].Hash[:4])
+		}
+	}
+}
+
+func TestDumpOrder(t *testing.T) {
+	m := New()
+	m.Priority = ReferenceDataPriority
+
+	low := spend(`{"priority": -1}`, out(1))
+	parent := spend(``, out(2))
+	high := spend(`{"priority": 5}`, out(3))
+	child := spend(`{"priority": 9}`, outOf(parent))
+	mustInsert(t, m, low, child, parent, high)
+
+	// The child has the highest priority,
+	// but must follow its parent.
+	checkDump(t, m, high, parent, child, low)
+	checkDump(t, m)
+}
+
+func TestInsertConflict(t *testing.T) {
+	m := New()
+	tx1 := spend(`1`, out(1))
+	tx2 := spend(`2`, out(2), out(1))
+	mustInsert(t, m, tx1, tx1)
+
+	err := m.Insert(context.Background(), tx2)
+	if errors.Root(err) != ErrConflict {
+		t.Errorf("got error %v, want %v", err, ErrConflict)
+	}
+	checkDump(t, m, tx1)
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	tx1 := spend(`1`, out(1))
+	child := spend(``, outOf(tx1))
+	other := spend(``, out(2))
+	mustInsert(t, m, tx1, child, other)
+
+	tx2 := spend(`2`, out(1))
+	err := m.Replace(ctx, tx1.Hash, tx2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed replacement leaves the pool unchanged.
+	err = m.Replace(ctx, tx2.Hash, spend(`3`, out(2)))
+	if errors.Root(err) != ErrConflict {
+		t.Errorf("got error %v, want %v", err, ErrConflict)
+	}
+	err = m.Replace(ctx, tx1.Hash, spend(`4`, out(3)))
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	checkDump(t, m, other, tx2)
+}
+
+func TestEviction(t *testing.T) {
+	m := New()
+	m.MaxSize = 2
+	m.Priority = ReferenceDataPriority
+
+	low := spend(`{"priority": 1}`, out(1))
+	lowChild := spend(`{"priority": 8}`, outOf(low))
+	mustInsert(t, m, low, lowChild)
+
+	err := m.Insert(context.Background(), spend(`{"priority": 1}`, out(2)))
+	if errors.Root(err) != ErrPoolFull {
+		t.Errorf("got error %v, want %v", err, ErrPoolFull)
+	}
+
+	// Evicting low also evicts its child.
+	high := spend(`{"priority": 2}`, out(3))
+	mustInsert(t, m, high)
+	checkDump(t, m, high)
+}
+
+func TestEvictionSparesAncestors(t *testing.T) {
+	m := New()
+	m.MaxSize = 2
+	m.Priority = ReferenceDataPriority
+
+	parent := spend(`{"priority": 1}`, out(1))
+	other := spend(`{"priority": 3}`, out(2))
+	mustInsert(t, m, parent, other)
+
+	// The parent has the lowest priority, but a
+	// child of it can only evict the other tx.
+	child := spend(`{"priority": 4}`, outOf(parent))
+	mustInsert(t, m, child)
+	checkDump(t, m, parent, child)
+
+	// With only ancestors to evict, the pool is full.
+	mustInsert(t, m, parent, child)
+	grandchild := spend(`{"priority": 9}`, outOf(child))
+	err := m.Insert(context.Background(), grandchild)
+	if errors.Root(err) != ErrPoolFull {
+		t.Errorf("got error %v, want %v", err, ErrPoolFull)
+	}
+	checkDump(t, m, parent, child)
+}
+
+func TestPendingGetRemove(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	parent := spend(``, out(1))
+	child := spend(``, outOf(parent))
+	other := spend(``, out(2))
+	mustInsert(t, m, parent, child, other)
+
+	got, err := m.Pending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[bc.Hash]bool{parent.Hash: true, child.Hash: true, other.Hash: true}
+	if len(got) != len(want) || !want[got[0]] || !want[got[1]] || !want[got[2]] {
+		t.Errorf("got pending %x, want %x, %x and %x", got, parent.Hash, child.Hash, other.Hash)
+	}
+
+	tx, added, err := m.Get(ctx, child.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx != child || added.IsZero() {
+		t.Errorf("Get(child) = %x, %s", tx.Hash[:4], added)
+	}
+
+	// Removing the parent also removes the child.
+	err = m.Remove(ctx, parent.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = m.Get(ctx, child.Hash)
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+	err = m.Remove(ctx, parent.Hash)
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	// Txs returned to the pool after a dump
+	// keep the time they were first added.
+	_, added, err = m.Get(ctx, other.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDump(t, m, other)
+	mustInsert(t, m, other)
+	_, readded, err := m.Get(ctx, other.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !readded.Equal(added) {
+		t.Errorf("got added time %s after dump, want %s", readded, added)
+	}
+}
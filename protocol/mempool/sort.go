@@ -1,81 +1,101 @@
 package mempool
 
-import "chain/protocol/bc"
+import (
+	"bytes"
+	"container/heap"
 
-func topSort(txs []*bc.Tx) []*bc.Tx {
-	if len(txs) == 1 {
-		return txs
-	}
+	"chain/protocol/bc"
+)
 
-	nodes := make(map[bc.Hash]*bc.Tx)
-	for _, tx := range txs {
-		nodes[tx.Hash] = tx
+// prioritySort returns the txs of ptxs in topological order,
+// choosing among txs whose pending parents have already been
+// emitted by highest priority, then lowest sequence number.
+func prioritySort(ptxs []*poolTx) []*bc.Tx {
+	nodes := make(map[bc.Hash]*poolTx, len(ptxs))
+	for _, ptx := range ptxs {
+		nodes[ptx.tx.Hash] = ptx
 	}
 
 	incomingEdges := make(map[bc.Hash]int)
-	children := make(map[bc.Hash][]bc.Hash)
-	for node, tx := range nodes {
-		for _, in := range tx.Inputs {
+	children := make(map[bc.Hash][]*poolTx)
+	for _, ptx := range ptxs {
+		parents := make(map[bc.Hash]bool)
+		for _, in := range ptx.tx.Inputs {
 			if in.IsIssuance() {
 				continue
 			}
-			if prev := in.Outpoint().Hash; nodes[prev] != nil {
-				if children[prev] == nil {
-					children[prev] = make([]bc.Hash, 0, 1)
-				}
-				children[prev] = append(children[prev], node)
-				incomingEdges[node]++
+			prev := in.Outpoint().Hash
+			if nodes[prev] == nil || parents[prev] {
+				continue
 			}
+			parents[prev] = true
+			children[prev] = append(children[prev], ptx)
+			incomingEdges[ptx.tx.Hash]++
 		}
 	}
 
-	var s []bc.Hash
-	for node := range nodes {
-		if incomingEdges[node] == 0 {
-			s = append(s, node)
+	var ready byPriority
+	for _, ptx := range ptxs {
+		if incomingEdges[ptx.tx.Hash] == 0 {
+			ready = append(ready, ptx)
 		}
 	}
+	heap.Init(&ready)
 
 	// https://en.wikipedia.org/wiki/Topological_sorting#Algorithms
-	var l []*bc.Tx
-	for len(s) > 0 {
-		n := s[0]
-		s = s[1:]
-		l = append(l, nodes[n])
+	l := make([]*bc.Tx, 0, len(ptxs))
+	for ready.Len() > 0 {
+		n := heap.Pop(&ready).(*poolTx)
+		l = append(l, n.tx)
 
-		for _, m := range children[n] {
-			incomingEdges[m]--
-			if incomingEdges[m] == 0 {
-				delete(incomingEdges, m)
-				s = append(s, m)
+		for _, m := range children[n.tx.Hash] {
+			incomingEdges[m.tx.Hash]--
+			if incomingEdges[m.tx.Hash] == 0 {
+				delete(incomingEdges, m.tx.Hash)
+				heap.Push(&ready, m)
 			}
 		}
 	}
 
-	if len(incomingEdges) > 0 { // should be impossible
+	if len(l) < len(ptxs) { // should be impossible
 		panic("cyclical tx ordering")
 	}
 
 	return l
 }
 
-func isTopSorted(txs []*bc.Tx) bool {
-	exists := make(map[bc.Hash]bool)
-	seen := make(map[bc.Hash]bool)
-	for _, tx := range txs {
-		exists[tx.Hash] = true
+// byPriority is a heap of pending txs,
+// highest priority and oldest first.
+type byPriority []*poolTx
+
+func (a byPriority) Len() int      { return len(a) }
+func (a byPriority) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byPriority) Less(i, j int) bool {
+	if a[i].priority != a[j].priority {
+		return a[i].priority > a[j].priority
 	}
-	for _, tx := range txs {
-		for _, in := range tx.Inputs {
-			if in.IsIssuance() {
-				continue
-			}
-			h := in.Outpoint().Hash
-			if exists[h] && !seen[h] {
-				return false
-			}
-		}
-		seen[tx.Hash] = true
+	return a[i].seq < a[j].seq
+}
+
+func (a *byPriority) Push(x interface{}) { *a = append(*a, x.(*poolTx)) }
+
+func (a *byPriority) Pop() interface{} {
+	old := *a
+	n := len(old)
+	x := old[n-1]
+	*a = old[:n-1]
+	return x
+}
+
+// byAge sorts pending txs oldest first,
+// then by hash.
+type byAge []*poolTx
+
+func (a byAge) Len() int      { return len(a) }
+func (a byAge) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAge) Less(i, j int) bool {
+	if !a[i].added.Equal(a[j].added) {
+		return a[i].added.Before(a[j].added)
 	}
-	return true
+	return bytes.Compare(a[i].tx.Hash[:], a[j].tx.Hash[:]) < 0
 }
@@ -13,17 +13,21 @@ import (
 	"chain/protocol/state"
 )
 
-// MemStore satisfies the protocol.Store interface.
+// MemStore satisfies the protocol.DeltaStore interface.
 type MemStore struct {
 	mu          sync.Mutex
 	Blocks      map[uint64]*bc.Block
+	Deltas      map[uint64]*state.Delta
 	State       *state.Snapshot
 	StateHeight uint64
 }
 
 // New returns a new MemStore
 func New() *MemStore {
-	return &MemStore{Blocks: make(map[uint64]*bc.Block)}
+	return &MemStore{
+		Blocks: make(map[uint64]*bc.Block),
+		Deltas: make(map[uint64]*state.Delta),
+	}
 }
 
 func (m *MemStore) Height(context.Context) (uint64, error) {
@@ -54,6 +58,21 @@ func (m *MemStore) SaveSnapshot(ctx context.Context, height uint64, snapshot *st
 	return nil
 }
 
+func (m *MemStore) SaveDelta(ctx context.Context, height uint64, d *state.Delta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Deltas[height] = d
+	return nil
+}
+
+func (m *MemStore) GetDelta(ctx context.Context, height uint64) (*state.Delta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Deltas[height], nil
+}
+
 func (m *MemStore) GetBlock(ctx context.Context, height uint64) (*bc.Block, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -9,6 +9,9 @@
 //
 // The nodes in the tree form an immutable persistent data
 // structure, therefore Copy is a O(1) operation.
+//
+// A tree too large to keep in memory can be kept in a NodeStore.
+// See Load and Tree.Save.
 package patricia
 
 import (
@@ -30,7 +33,9 @@ var (
 
 // Tree implements a patricia tree.
 type Tree struct {
-	root *node
+	root  *node
+	store NodeStore // nil unless the tree was loaded or saved
+	cache *nodeCache
 }
 
 // Leaf describes a key and its corresponding hash of a
@@ -65,6 +70,8 @@ func Reconstruct(vals []Leaf) (*Tree, error) {
 func Copy(t *Tree) *Tree {
 	newT := new(Tree)
 	newT.root = t.root
+	newT.store = t.store
+	newT.cache = t.cache
 	return newT
 }
 
@@ -79,41 +86,51 @@ func Walk(t *Tree, walkFn WalkFunc) error {
 	if t.root == nil {
 		return nil
 	}
-	return walk(t.root, walkFn)
+	return t.walk(t.root, walkFn)
 }
 
-func walk(n *node, walkFn WalkFunc) error {
+func (t *Tree) walk(n *node, walkFn WalkFunc) error {
+	n, err := t.load(n)
+	if err != nil {
+		return err
+	}
 	if n.isLeaf {
 		return walkFn(Leaf{Key: n.Key(), Hash: *n.hash})
 	}
 
-	err := walk(n.children[0], walkFn)
+	err = t.walk(n.children[0], walkFn)
 	if err != nil {
 		return err
 	}
 
-	err = walk(n.children[1], walkFn)
+	err = t.walk(n.children[1], walkFn)
 	return err
 }
 
 // ContainsKey returns true if the key contains the provided
 // key, without checking its corresponding hash.
-func (t *Tree) ContainsKey(bkey []byte) bool {
+// It returns an error only if the tree's NodeStore does.
+func (t *Tree) ContainsKey(bkey []byte) (bool, error) {
 	if t.root == nil {
-		return false
+		return false, nil
 	}
-	return t.lookup(t.root, bitKey(bkey)) != nil
+	n, err := t.lookup(t.root, bitKey(bkey))
+	return n != nil, err
 }
 
 // Contains returns true if the tree contains the provided
 // key, value pair.
-func (t *Tree) Contains(bkey, val []byte) bool {
+// It returns an error only if the tree's NodeStore does.
+func (t *Tree) Contains(bkey, val []byte) (bool, error) {
 	if t.root == nil {
-		return false
+		return false, nil
 	}
 
 	key := bitKey(bkey)
-	n := t.lookup(t.root, key)
+	n, err := t.lookup(t.root, key)
+	if err != nil {
+		return false, err
+	}
 
 	var hash bc.Hash
 	h := sha3pool.Get256()
@@ -121,18 +138,22 @@ func (t *Tree) Contains(bkey, val []byte) bool {
 	h.Write(val[:])
 	h.Read(hash[:])
 	sha3pool.Put256(h)
-	return n != nil && n.Hash() == hash
+	return n != nil && n.Hash() == hash, nil
 }
 
-func (t *Tree) lookup(n *node, key []uint8) *node {
+func (t *Tree) lookup(n *node, key []uint8) (*node, error) {
+	n, err := t.load(n)
+	if err != nil {
+		return nil, err
+	}
 	if bytes.Equal(n.key, key) {
 		if !n.isLeaf {
-			return nil
+			return nil, nil
 		}
-		return n
+		return n, nil
 	}
-	if !bytes.HasPrefix(key, n.key) {
-		return nil
+	if n.isLeaf || !bytes.HasPrefix(key, n.key) {
+		return nil, nil
 	}
 
 	bit := key[len(n.key)]
@@ -147,15 +168,15 @@ func (t *Tree) lookup(n *node, key []uint8) *node {
 // and its value is updated, leaving the structure of
 // the tree alone.
 func (t *Tree) Insert(bkey, val []byte) error {
-	key := bitKey(bkey)
-
-	var hash bc.Hash
-	h := sha3pool.Get256()
-	h.Write(leafPrefix)
-	h.Write(val)
-	h.Read(hash[:])
-	sha3pool.Put256(h)
+	return t.InsertLeaf(NewLeaf(bkey, val))
+}
 
+// InsertLeaf is like Insert, but takes the hash of
+// the value, as recorded in a Leaf, in place of the
+// value itself.
+func (t *Tree) InsertLeaf(l Leaf) error {
+	key := bitKey(l.Key)
+	hash := l.Hash
 	if t.root == nil {
 		t.root = &node{key: key, hash: &hash, isLeaf: true}
 		return nil
@@ -166,7 +187,23 @@ func (t *Tree) Insert(bkey, val []byte) error {
 	return err
 }
 
+// NewLeaf returns the leaf that Insert
+// adds to a tree for the given key and value.
+func NewLeaf(bkey, val []byte) Leaf {
+	l := Leaf{Key: bkey}
+	h := sha3pool.Get256()
+	h.Write(leafPrefix)
+	h.Write(val)
+	h.Read(l.Hash[:])
+	sha3pool.Put256(h)
+	return l
+}
+
 func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
+	n, err := t.load(n)
+	if err != nil {
+		return nil, err
+	}
 	if bytes.Equal(n.key, key) {
 		if !n.isLeaf {
 			return n, errors.Wrap(ErrPrefix)
@@ -191,10 +228,8 @@ func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
 		if err != nil {
 			return n, err
 		}
-		newNode := new(node)
-		*newNode = *n
+		newNode := &node{key: n.key, children: n.children}
 		newNode.children[bit] = child // mutation is ok because newNode hasn't escaped yet
-		newNode.hash = nil
 		return newNode, nil
 	}
 
@@ -227,6 +262,10 @@ func (t *Tree) Delete(bkey []byte) error {
 }
 
 func (t *Tree) delete(n *node, key []uint8) (*node, error) {
+	n, err := t.load(n)
+	if err != nil {
+		return nil, err
+	}
 	if bytes.Equal(key, n.key) {
 		if !n.isLeaf {
 			return n, errors.Wrap(ErrPrefix)
@@ -234,7 +273,7 @@ func (t *Tree) delete(n *node, key []uint8) (*node, error) {
 		return nil, nil
 	}
 
-	if !bytes.HasPrefix(key, n.key) {
+	if n.isLeaf || !bytes.HasPrefix(key, n.key) {
 		return n, nil
 	}
 
@@ -248,11 +287,11 @@ func (t *Tree) delete(n *node, key []uint8) (*node, error) {
 		return n.children[1-bit], nil
 	}
 
-	newNode := new(node)
-	*newNode = *n
-	newNode.key = newChild.key[:len(n.key)] // only use slices of leaf node keys
+	newNode := &node{key: n.key, children: n.children}
+	if !newChild.stub {
+		newNode.key = newChild.key[:len(n.key)] // only use slices of leaf node keys
+	}
 	newNode.children[bit] = newChild
-	newNode.hash = nil
 
 	return newNode, nil
 }
@@ -309,6 +348,14 @@ type node struct {
 	hash     *bc.Hash
 	isLeaf   bool
 	children [2]*node
+
+	// stub is set for a node in the tree's NodeStore that
+	// has not been loaded. Only its hash and id are set.
+	stub bool
+
+	// id is the ID of the node in a NodeStore, set for
+	// stubs and nodes loaded from one. It is never modified.
+	id *bc.Hash
 }
 
 // Key returns the key for the current node as bytes, as it
@@ -137,7 +137,7 @@ func TestLookup(t *testing.T) {
 	tr := &Tree{
 		root: &node{key: bools("11111111"), hash: &hashes[0], isLeaf: true},
 	}
-	got := tr.lookup(tr.root, bitKey(bits("11111111")))
+	got, _ := tr.lookup(tr.root, bitKey(bits("11111111")))
 	if !reflect.DeepEqual(got, tr.root) {
 		t.Log("lookup on 1-node tree")
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root, 0))
@@ -146,7 +146,7 @@ func TestLookup(t *testing.T) {
 	tr = &Tree{
 		root: &node{key: bools("11111110"), hash: &hashes[1], isLeaf: true},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11111111")))
+	got, _ = tr.lookup(tr.root, bitKey(bits("11111111")))
 	if got != nil {
 		t.Log("lookup nonexistent key on 1-node tree")
 		t.Fatalf("got:\n%swant nil", prettyNode(got, 0))
@@ -162,7 +162,7 @@ func TestLookup(t *testing.T) {
 			},
 		},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11110000")))
+	got, _ = tr.lookup(tr.root, bitKey(bits("11110000")))
 	if !reflect.DeepEqual(got, tr.root.children[0]) {
 		t.Log("lookup root's first child")
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[0], 0))
@@ -185,7 +185,7 @@ func TestLookup(t *testing.T) {
 			},
 		},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11111100")))
+	got, _ = tr.lookup(tr.root, bitKey(bits("11111100")))
 	if !reflect.DeepEqual(got, tr.root.children[1].children[0]) {
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[1].children[0], 0))
 	}
@@ -210,12 +210,18 @@ func TestContains(t *testing.T) {
 			},
 		},
 	}
-	contains := tr.Contains(bits("11111100"), vals[3])
+	contains, err := tr.Contains(bits("11111100"), vals[3])
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !contains {
 		t.Errorf("expected tree to contain %v, %x, but did not", bits("11111100"), vals[3])
 	}
 
-	contains = tr.Contains(bits("11111111"), vals[3])
+	contains, err = tr.Contains(bits("11111111"), vals[3])
+	if err != nil {
+		t.Fatal(err)
+	}
 	if contains {
 		t.Errorf("expected tree to not contain %v, %x, but did", bits("11111111"), vals[3])
 	}
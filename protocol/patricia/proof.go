@@ -0,0 +1,51 @@
+package patricia
+
+import (
+	"bytes"
+
+	"chain/protocol/proof"
+)
+
+// Prove returns a proof that the tree contains the
+// provided key, or nil if it doesn't. The proof can be
+// checked against the tree's root hash with
+// proof.VerifyIncluded.
+// It returns an error only if the tree's NodeStore does.
+func (t *Tree) Prove(bkey []byte) (*proof.TreeProof, error) {
+	if t.root == nil {
+		return nil, nil
+	}
+	p := &proof.TreeProof{Key: bkey, Path: []proof.TreeStep{}}
+
+	key := bitKey(bkey)
+	n := t.root
+	for {
+		var err error
+		n, err = t.load(n)
+		if err != nil {
+			return nil, err
+		}
+		if n.isLeaf && bytes.Equal(n.key, key) {
+			return p, nil
+		}
+		if n.isLeaf || len(n.key) >= len(key) || !bytes.HasPrefix(key, n.key) {
+			return nil, nil
+		}
+		bit := key[len(n.key)]
+		p.Path = append(p.Path, proof.TreeStep{
+			Bits:    len(n.key),
+			Sibling: n.children[1-bit].Hash(),
+		})
+		n = n.children[bit]
+	}
+}
+
+// packBits is like byteKey, but pads the
+// last byte of the key with zeros.
+func packBits(bitKey []uint8) []byte {
+	key := make([]byte, (len(bitKey)+7)/8)
+	for i, bit := range bitKey {
+		key[i/8] |= bit << uint(7-i%8)
+	}
+	return key
+}
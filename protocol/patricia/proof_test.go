@@ -0,0 +1,54 @@
+package patricia
+
+import (
+	"testing"
+
+	"chain/protocol/proof"
+)
+
+func TestProve(t *testing.T) {
+	tr := new(Tree)
+	missing := [][]byte{{0x00}, {0x0f}, {0xf0}, {0xff}}
+	for _, k := range missing {
+		if p := mustProve(t, tr, k); p != nil {
+			t.Errorf("empty tree: Prove(%x) = %v, want nil", k, p)
+		}
+	}
+
+	keys := [][]byte{{0x01}, {0x10}, {0x11}, {0x80}, {0xfe}}
+	for _, k := range keys {
+		err := tr.Insert(k, append([]byte("v"), k...))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := tr.RootHash()
+	for _, k := range keys {
+		p := mustProve(t, tr, k)
+		if p == nil {
+			t.Fatalf("Prove(%x) = nil, want proof", k)
+		}
+		val := append([]byte("v"), k...)
+		ok, err := proof.VerifyIncluded(root, k, val, p)
+		if err != nil || !ok {
+			t.Errorf("VerifyIncluded(%x) = %v, %v, want true", k, ok, err)
+		}
+		ok, _ = proof.VerifyIncluded(root, k, []byte("other"), p)
+		if ok {
+			t.Errorf("VerifyIncluded(%x) with wrong value = true, want false", k)
+		}
+	}
+	for _, k := range missing {
+		if p := mustProve(t, tr, k); p != nil {
+			t.Errorf("Prove(%x) of missing key = %v, want nil", k, p)
+		}
+	}
+}
+
+func mustProve(t *testing.T, tr *Tree, key []byte) *proof.TreeProof {
+	p, err := tr.Prove(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,232 @@
+package patricia
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+
+	"chain/crypto/sha3pool"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// A NodeStore stores the nodes of trees too large to keep in
+// memory. Nodes are named by the SHA3-256 hash of their encoding,
+// which, unlike their hash in the tree, commits to their keys.
+// Trees share the nodes they have in common, and a tree is loaded
+// from a NodeStore a node at a time, as each is needed.
+type NodeStore interface {
+	// Node returns the encoding of the node with the given ID.
+	Node(id bc.Hash) ([]byte, error)
+
+	// SaveNodes adds the given nodes to the store.
+	SaveNodes([]*EncodedNode) error
+}
+
+// EncodedNode is a node of a tree, encoded for a NodeStore.
+type EncodedNode struct {
+	ID   bc.Hash
+	Data []byte
+
+	// Children holds the IDs of the children
+	// of an interior node.
+	Children []bc.Hash
+}
+
+// ErrBadNode is returned when a node
+// from a NodeStore can't be decoded.
+var ErrBadNode = errors.New("malformed tree node")
+
+// maxCachedNodes is the number of decoded
+// nodes kept by a tree's node cache.
+const maxCachedNodes = 1 << 16
+
+// nodeCache holds recently loaded nodes of trees kept in a
+// NodeStore, already decoded, so that lookups don't decode
+// every node on their path again. Decoded nodes are never
+// modified, so copies of a tree share its cache.
+type nodeCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+}
+
+func newNodeCache() *nodeCache {
+	return &nodeCache{lru: lru.New(maxCachedNodes)}
+}
+
+func (c *nodeCache) get(id bc.Hash) *node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.lru.Get(id)
+	if !ok {
+		return nil
+	}
+	return n.(*node)
+}
+
+func (c *nodeCache) add(n *node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Add(*n.id, n)
+}
+
+// Load returns the tree in s with the root node
+// having the given ID. The zero ID is the empty tree.
+// Nodes are loaded from s as they are needed.
+func Load(s NodeStore, root bc.Hash) (*Tree, error) {
+	t := &Tree{store: s, cache: newNodeCache()}
+	if root == (bc.Hash{}) {
+		return t, nil
+	}
+	n, err := t.load(&node{stub: true, id: &root})
+	if err != nil {
+		return nil, err
+	}
+	t.root = &node{stub: true, id: &root, hash: n.hash}
+	return t, nil
+}
+
+// Save adds the nodes of the tree that are not yet in s to s,
+// and returns the ID of the tree's root node. Afterward, t is
+// kept in s: nodes are loaded from s as they are needed, and
+// nodes added to t are saved by the next call to Save.
+//
+// Save doesn't modify the nodes of t, which copies of t share,
+// except to compute their hashes, as RootHash does. Once the
+// root hash of t is known, its copies may be used while it is
+// being saved.
+func (t *Tree) Save(s NodeStore) (root bc.Hash, err error) {
+	if t.store != nil && t.store != s {
+		return root, errors.New("tree is kept in another store")
+	}
+	if t.store == nil {
+		t.store, t.cache = s, newNodeCache()
+	}
+	if t.root == nil {
+		return root, nil
+	}
+
+	var (
+		nodes []*EncodedNode
+		saved []*node
+	)
+	root = encodeNew(t.root, &nodes, &saved)
+	err = s.SaveNodes(nodes)
+	if err != nil {
+		return root, errors.Wrap(err, "saving tree nodes")
+	}
+	for _, n := range saved {
+		t.cache.add(n)
+	}
+	t.root = &node{stub: true, id: &root, hash: t.root.hash}
+	return root, nil
+}
+
+// encodeNew appends to nodes the encodings of n and its
+// descendants that have not been saved, and to saved those
+// nodes as they will be loaded from the store, without
+// modifying n. It returns the ID of n.
+func encodeNew(n *node, nodes *[]*EncodedNode, saved *[]*node) bc.Hash {
+	if n.id != nil {
+		return *n.id
+	}
+	n.calcHash()
+
+	var (
+		buf      bytes.Buffer
+		children []bc.Hash
+		tmp      [binary.MaxVarintLen64]byte
+	)
+	stored := &node{key: n.key, hash: n.hash, isLeaf: n.isLeaf}
+	if n.isLeaf {
+		buf.WriteByte(0)
+	} else {
+		buf.WriteByte(1)
+	}
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(n.key)))])
+	buf.Write(packBits(n.key))
+	if n.isLeaf {
+		buf.Write(n.hash[:])
+	} else {
+		for i, c := range n.children {
+			id := encodeNew(c, nodes, saved)
+			children = append(children, id)
+			buf.Write(id[:])
+			buf.Write(c.hash[:])
+			stored.children[i] = &node{stub: true, id: &id, hash: c.hash}
+		}
+	}
+
+	var id bc.Hash
+	sha3pool.Sum256(id[:], buf.Bytes())
+	stored.id = &id
+	*nodes = append(*nodes, &EncodedNode{ID: id, Data: buf.Bytes(), Children: children})
+	*saved = append(*saved, stored)
+	return id
+}
+
+// load returns n, loading it from the tree's cache
+// or store if it is a stub.
+func (t *Tree) load(n *node) (*node, error) {
+	if !n.stub {
+		return n, nil
+	}
+	if loaded := t.cache.get(*n.id); loaded != nil {
+		return loaded, nil
+	}
+	data, err := t.store.Node(*n.id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading tree node %x", n.id[:])
+	}
+	loaded, err := decodeNode(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tree node %x", n.id[:])
+	}
+	loaded.id = n.id
+	t.cache.add(loaded)
+	return loaded, nil
+}
+
+// decodeNode decodes a node written by encodeNew.
+// The children of an interior node are stubs.
+func decodeNode(data []byte) (*node, error) {
+	if len(data) < 1 || data[0] > 1 {
+		return nil, errors.WithDetail(ErrBadNode, "bad node type")
+	}
+	n := &node{isLeaf: data[0] == 0}
+	bits, m := binary.Uvarint(data[1:])
+	if m <= 0 {
+		return nil, errors.WithDetail(ErrBadNode, "bad key length")
+	}
+	data = data[1+m:]
+	if bits > uint64(len(data))*8 {
+		return nil, errors.WithDetail(ErrBadNode, "short key")
+	}
+	keyLen := (int(bits) + 7) / 8
+	n.key = bitKey(data[:keyLen])[:bits]
+	data = data[keyLen:]
+
+	var hash bc.Hash
+	if n.isLeaf {
+		if len(data) != len(hash) {
+			return nil, errors.WithDetail(ErrBadNode, "bad leaf hash")
+		}
+		copy(hash[:], data)
+		n.hash = &hash
+		return n, nil
+	}
+
+	if len(data) != 4*len(hash) {
+		return nil, errors.WithDetail(ErrBadNode, "bad children")
+	}
+	for i := range n.children {
+		var id, h bc.Hash
+		copy(id[:], data[64*i:])
+		copy(h[:], data[64*i+32:])
+		n.children[i] = &node{stub: true, id: &id, hash: &h}
+	}
+	n.calcHash()
+	return n, nil
+}
@@ -0,0 +1,261 @@
+package patricia
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+type memNodeStore struct {
+	mu    sync.Mutex
+	nodes map[bc.Hash][]byte
+	loads int
+	saves int
+}
+
+func newMemNodeStore() *memNodeStore {
+	return &memNodeStore{nodes: make(map[bc.Hash][]byte)}
+}
+
+func (s *memNodeStore) Node(id bc.Hash) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.nodes[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	s.loads++
+	return data, nil
+}
+
+func (s *memNodeStore) SaveNodes(nodes []*EncodedNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range nodes {
+		s.nodes[n.ID] = n.Data
+	}
+	s.saves += len(nodes)
+	return nil
+}
+
+func TestSaveLoad(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	s := newMemNodeStore()
+
+	mem := new(Tree) // the same tree, kept only in memory
+	tr := new(Tree)
+	var keys [][]byte
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 50; j++ {
+			k := make([]byte, 32)
+			r.Read(k)
+			keys = append(keys, k)
+			insertBoth(t, mem, tr, k)
+		}
+		k := keys[r.Intn(len(keys))]
+		err := mem.Delete(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tr.Delete(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.saves = 0
+		root, err := tr.Save(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && s.saves >= 2*len(keys)-1 {
+			t.Errorf("%d: saved %d nodes, want only the changed ones", i, s.saves)
+		}
+		if got, want := tr.RootHash(), mem.RootHash(); got != want {
+			t.Fatalf("%d: saved root hash = %x want %x", i, got[:], want[:])
+		}
+
+		loaded, err := Load(s, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := loaded.RootHash(), mem.RootHash(); got != want {
+			t.Fatalf("%d: loaded root hash = %x want %x", i, got[:], want[:])
+		}
+		tr = Copy(loaded)
+	}
+
+	// Lookups load only the nodes they need.
+	loaded, err := Load(s, mustSave(t, tr, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.loads = 0
+	for _, k := range keys[:10] {
+		want, err := mem.ContainsKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := loaded.ContainsKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ContainsKey(%x) = %v want %v", k, got, want)
+		}
+	}
+	if s.loads >= len(s.nodes) {
+		t.Errorf("loaded %d of %d nodes, want fewer", s.loads, len(s.nodes))
+	}
+
+	// Repeated lookups use the nodes already decoded.
+	s.loads = 0
+	for _, k := range keys[:10] {
+		_, err := Copy(loaded).ContainsKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.loads != 0 {
+		t.Errorf("repeated lookups loaded %d nodes, want 0", s.loads)
+	}
+
+	// Modifying a loaded tree leaves the stored tree alone.
+	root := mustSave(t, tr, s)
+	k := make([]byte, 32)
+	r.Read(k)
+	insertBoth(t, mem, tr, k)
+	stored, err := Load(s, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RootHash() == tr.RootHash() {
+		t.Error("modifying a saved tree changed the stored tree")
+	}
+	if got, want := tr.RootHash(), mem.RootHash(); got != want {
+		t.Errorf("modified root hash = %x want %x", got[:], want[:])
+	}
+
+	var n int
+	err = Walk(stored, func(Leaf) error { n++; return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want int
+	Walk(mem, func(Leaf) error { want++; return nil })
+	if n != want-1 {
+		t.Errorf("stored tree has %d leaves, want %d", n, want-1)
+	}
+}
+
+func TestSaveWhileCopiesInUse(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	s := newMemNodeStore()
+
+	tr := new(Tree)
+	var keys [][]byte
+	for i := 0; i < 200; i++ {
+		k := make([]byte, 32)
+		r.Read(k)
+		keys = append(keys, k)
+		err := tr.Insert(k, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 99 {
+			mustSave(t, tr, s)
+		}
+	}
+	want := tr.RootHash()
+
+	// The copies share the nodes inserted since tr was
+	// last saved. Run with -race to check that saving tr
+	// doesn't modify them.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		c := Copy(tr)
+		k := make([]byte, 32)
+		r.Read(k)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, k := range keys {
+				ok, err := c.ContainsKey(k)
+				if err != nil || !ok {
+					t.Errorf("ContainsKey(%x) = %v, %v want true, nil", k, ok, err)
+				}
+			}
+			err := c.Insert(k, k)
+			if err != nil {
+				t.Error(err)
+			}
+			_, err = c.Save(s)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	root := mustSave(t, tr, s)
+	wg.Wait()
+
+	loaded, err := Load(s, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.RootHash(); got != want {
+		t.Errorf("loaded root hash = %x want %x", got[:], want[:])
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	s := newMemNodeStore()
+	root, err := new(Tree).Save(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := Load(s, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.RootHash() != (bc.Hash{}) {
+		t.Errorf("empty tree root hash = %x", tr.RootHash())
+	}
+}
+
+func TestDecodeNodeErrors(t *testing.T) {
+	cases := [][]byte{
+		nil,
+		{2},
+		{0},
+		{0, 16, 1},
+		{0, 0, 1, 2, 3},
+		{1, 0, 1, 2, 3},
+	}
+	for _, c := range cases {
+		_, err := decodeNode(c)
+		if err == nil {
+			t.Errorf("decodeNode(%x) = nil error, want error", c)
+		}
+	}
+}
+
+func insertBoth(t *testing.T, a, b *Tree, k []byte) {
+	err := a.Insert(k, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Insert(k, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustSave(t *testing.T, tr *Tree, s NodeStore) bc.Hash {
+	root, err := tr.Save(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
@@ -0,0 +1,184 @@
+// Package proof verifies compact proofs that a transaction is in a
+// block, and that an output is in the blockchain state, without the
+// rest of the block or state. It needs no access to a Chain Core, so
+// light clients and auditors can use it to check a payment given
+// only trusted block headers.
+//
+// Transaction proofs are made by validation.TxProof and checked
+// against a block's TransactionsMerkleRoot. Output proofs are made
+// by patricia.Tree.Prove and checked against a block's
+// AssetsMerkleRoot.
+//
+// The hashes of the state tree commit to the values of its leaves
+// and to the shape of the tree, but not to the keys along each
+// path. An output proof therefore shows that an output with the
+// given contents is in the state at a position consistent with its
+// outpoint, relying on the prover for the keys of the nodes it
+// names. For the same reason, there are no proofs that an output
+// is absent from the state (see ErrNoAbsenceProof).
+package proof
+
+import (
+	"bytes"
+
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	leafPrefix     = []byte{0x00}
+	interiorPrefix = []byte{0x01}
+)
+
+// TxProof proves that a transaction is
+// one of the transactions in a block.
+type TxProof struct {
+	// Path holds the siblings of the nodes on the path
+	// from the transaction to the root, bottom first.
+	Path []TxStep `json:"path"`
+}
+
+// TxStep is a sibling of a node on the path
+// from a transaction to the root.
+type TxStep struct {
+	Hash bc.Hash `json:"hash"`
+
+	// Left reports whether the sibling
+	// is the left child of its parent.
+	Left bool `json:"left"`
+}
+
+// VerifyTx reports whether p proves that tx
+// is in the block with the given transactions
+// merkle root.
+func VerifyTx(tx *bc.Tx, root bc.Hash, p *TxProof) bool {
+	wh := tx.WitnessHash()
+	h := leafHash(wh[:])
+	for _, step := range p.Path {
+		if step.Left {
+			h = interiorHash(step.Hash, h)
+		} else {
+			h = interiorHash(h, step.Hash)
+		}
+	}
+	return h == root
+}
+
+// TreeProof proves that a key is in a
+// patricia tree, such as the state tree.
+type TreeProof struct {
+	Key chainjson.HexBytes `json:"key"`
+
+	// Path holds the branches on the path
+	// from the root to the key, top first.
+	Path []TreeStep `json:"path"`
+}
+
+// TreeStep is a branch in a patricia tree
+// on the path to a key.
+type TreeStep struct {
+	// Bits is the length, in bits, of the key prefix of the
+	// branch. The path continues to the child selected by the
+	// bit of the key at this position.
+	Bits int `json:"bits"`
+
+	// Sibling is the hash of the
+	// child not on the path.
+	Sibling bc.Hash `json:"sibling"`
+}
+
+// ErrBadProof is returned by the Verify functions
+// when a proof is not well formed.
+var ErrBadProof = errors.New("malformed proof")
+
+// ErrNoAbsenceProof is returned when asked to prove that
+// a key, such as a spent output's, is absent from a patricia
+// tree. Since the tree's hashes don't commit to the keys of
+// its nodes, a prover could name keys that lead away from a
+// key that is present, so no such proof would be sound.
+// Supporting them would require the state tree to hash its
+// keys, changing the assets merkle root of every block.
+var ErrNoAbsenceProof = errors.New("absence from the state tree can't be proven")
+
+// VerifyIncluded reports whether p proves that the
+// tree with the given root hash maps key to value.
+func VerifyIncluded(root bc.Hash, key, value []byte, p *TreeProof) (bool, error) {
+	if !bytes.Equal(p.Key, key) {
+		return false, nil
+	}
+	err := p.check()
+	if err != nil {
+		return false, err
+	}
+	return p.root(leafHash(value)) == root, nil
+}
+
+// check checks that the path of p is well formed.
+func (p *TreeProof) check() error {
+	last := -1
+	for _, step := range p.Path {
+		if step.Bits <= last || step.Bits >= len(p.Key)*8 {
+			return errors.WithDetail(ErrBadProof, "bad path")
+		}
+		last = step.Bits
+	}
+	return nil
+}
+
+// root returns the root hash of the tree with the
+// node at the end of the path having hash h.
+func (p *TreeProof) root(h bc.Hash) bc.Hash {
+	for i := len(p.Path) - 1; i >= 0; i-- {
+		step := p.Path[i]
+		if bit(p.Key, step.Bits) == 0 {
+			h = interiorHash(h, step.Sibling)
+		} else {
+			h = interiorHash(step.Sibling, h)
+		}
+	}
+	return h
+}
+
+// OutputKey and OutputValue return the key and value of
+// an output in the state tree, for use with VerifyIncluded.
+// They must agree with
+// state.OutputTreeItem.
+func OutputKey(o bc.Outpoint) []byte {
+	var b bytes.Buffer
+	o.WriteTo(errors.NewWriter(&b))
+	return b.Bytes()
+}
+
+// OutputValue returns the value in the state tree
+// of an output with the given contents.
+func OutputValue(out *bc.TxOutput) []byte {
+	var b bytes.Buffer
+	out.WriteCommitment(&b)
+	return b.Bytes()
+}
+
+// bit returns bit i of key, most significant first.
+func bit(key []byte, i int) byte {
+	return (key[i/8] >> uint(7-i%8)) & 1
+}
+
+func leafHash(b []byte) (h bc.Hash) {
+	sha := sha3pool.Get256()
+	defer sha3pool.Put256(sha)
+	sha.Write(leafPrefix)
+	sha.Write(b)
+	sha.Read(h[:])
+	return h
+}
+
+func interiorHash(left, right bc.Hash) (h bc.Hash) {
+	sha := sha3pool.Get256()
+	defer sha3pool.Put256(sha)
+	sha.Write(interiorPrefix)
+	sha.Write(left[:])
+	sha.Write(right[:])
+	sha.Read(h[:])
+	return h
+}
@@ -0,0 +1,148 @@
+package proof_test
+
+import (
+	"testing"
+
+	"chain/protocol/bc"
+	"chain/protocol/patricia"
+	"chain/protocol/proof"
+	"chain/protocol/validation"
+)
+
+func TestVerifyTx(t *testing.T) {
+	var txs []*bc.Tx
+	for i := 0; i < 5; i++ {
+		txs = append(txs, bc.NewTx(bc.TxData{Version: 1, ReferenceData: []byte{byte(i)}}))
+	}
+	root := validation.CalcMerkleRoot(txs)
+
+	for i, tx := range txs {
+		p := validation.TxProof(txs, i)
+		if !proof.VerifyTx(tx, root, p) {
+			t.Errorf("VerifyTx(tx %d) = false, want true", i)
+		}
+
+		// Another transaction's proof.
+		other := validation.TxProof(txs, (i+1)%len(txs))
+		if proof.VerifyTx(tx, root, other) {
+			t.Errorf("VerifyTx(tx %d) with proof of tx %d = true, want false", i, (i+1)%len(txs))
+		}
+
+		// A forged sibling.
+		forged := validation.TxProof(txs, i)
+		forged.Path[0].Hash[0] ^= 1
+		if proof.VerifyTx(tx, root, forged) {
+			t.Errorf("VerifyTx(tx %d) with forged sibling = true, want false", i)
+		}
+
+		// A step on the wrong side.
+		flipped := validation.TxProof(txs, i)
+		flipped.Path[0].Left = !flipped.Path[0].Left
+		if proof.VerifyTx(tx, root, flipped) {
+			t.Errorf("VerifyTx(tx %d) with flipped step = true, want false", i)
+		}
+	}
+
+	notInBlock := bc.NewTx(bc.TxData{Version: 1, ReferenceData: []byte("other")})
+	if proof.VerifyTx(notInBlock, root, validation.TxProof(txs, 0)) {
+		t.Error("VerifyTx(tx not in block) = true, want false")
+	}
+}
+
+func TestVerifyIncluded(t *testing.T) {
+	tr := new(patricia.Tree)
+	keys := [][]byte{{0x01}, {0x10}, {0x11}, {0x80}, {0xfe}}
+	for _, k := range keys {
+		err := tr.Insert(k, append([]byte("v"), k...))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := tr.RootHash()
+
+	var ntampered int
+	for _, k := range keys {
+		val := append([]byte("v"), k...)
+		p := mustProve(t, tr, k)
+		ok, err := proof.VerifyIncluded(root, k, val, p)
+		if err != nil || !ok {
+			t.Errorf("VerifyIncluded(%x) = %v, %v, want true", k, ok, err)
+		}
+
+		ok, _ = proof.VerifyIncluded(root, k, []byte("other"), p)
+		if ok {
+			t.Errorf("VerifyIncluded(%x) with wrong value = true, want false", k)
+		}
+
+		ok, _ = proof.VerifyIncluded(bc.Hash{1}, k, val, p)
+		if ok {
+			t.Errorf("VerifyIncluded(%x) with wrong root = true, want false", k)
+		}
+
+		// A forged sibling.
+		forged := mustProve(t, tr, k)
+		forged.Path[len(forged.Path)-1].Sibling[0] ^= 1
+		ok, _ = proof.VerifyIncluded(root, k, val, forged)
+		if ok {
+			t.Errorf("VerifyIncluded(%x) with forged sibling = true, want false", k)
+		}
+
+		// A path that branches at another bit, leading
+		// to the other side of the branch.
+		tampered := mustProve(t, tr, k)
+		last := &tampered.Path[len(tampered.Path)-1]
+		for b := last.Bits + 1; b < 8; b++ {
+			if bitOf(k, b) != bitOf(k, last.Bits) {
+				last.Bits = b
+				ntampered++
+				ok, _ = proof.VerifyIncluded(root, k, val, tampered)
+				if ok {
+					t.Errorf("VerifyIncluded(%x) with tampered path = true, want false", k)
+				}
+				break
+			}
+		}
+
+		// Another key's proof.
+		other := append([]byte(nil), k...)
+		other[0] ^= 0x40
+		ok, _ = proof.VerifyIncluded(root, other, val, p)
+		if ok {
+			t.Errorf("VerifyIncluded(%x) with proof of %x = true, want false", other, k)
+		}
+	}
+	if ntampered == 0 {
+		t.Error("no path could be tampered with")
+	}
+}
+
+func TestVerifyIncludedMalformed(t *testing.T) {
+	key := []byte{0x10}
+	cases := []*proof.TreeProof{
+		{Key: key, Path: []proof.TreeStep{{Bits: 8}}},
+		{Key: key, Path: []proof.TreeStep{{Bits: -1}}},
+		{Key: key, Path: []proof.TreeStep{{Bits: 3}, {Bits: 3}}},
+		{Key: key, Path: []proof.TreeStep{{Bits: 4}, {Bits: 2}}},
+	}
+	for i, p := range cases {
+		ok, err := proof.VerifyIncluded(bc.Hash{}, key, nil, p)
+		if ok || err == nil {
+			t.Errorf("case %d: VerifyIncluded = %v, %v, want false, error", i, ok, err)
+		}
+	}
+}
+
+func mustProve(t *testing.T, tr *patricia.Tree, key []byte) *proof.TreeProof {
+	p, err := tr.Prove(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatalf("Prove(%x) = nil, want proof", key)
+	}
+	return p
+}
+
+func bitOf(key []byte, i int) byte {
+	return (key[i/8] >> uint(7-i%8)) & 1
+}
@@ -82,15 +82,62 @@ type Store interface {
 	SaveSnapshot(context.Context, uint64, *state.Snapshot) error
 }
 
+// A TreeStore is a Store that keeps the state tree node by
+// node, so that saving a snapshot writes only the nodes changed
+// since the last one. A Chain passes the snapshot of every block
+// to a TreeStore, instead of saving one periodically.
+type TreeStore interface {
+	Store
+
+	// SaveTreeSnapshot writes the nodes of a snapshot's tree not
+	// already in the store. Afterward, the snapshot's tree is loaded
+	// from the store as it is needed, so it need not be kept in
+	// memory. The store may also keep the snapshot itself, to
+	// recover from; if it is a DeltaStore, it need not keep every
+	// one, since Recover replays deltas from the latest.
+	SaveTreeSnapshot(context.Context, uint64, *state.Snapshot) error
+}
+
+// A DeltaStore is a Store that also keeps the change each block
+// makes to the state. A Chain saves a delta for each block it
+// commits, and Recover applies the stored deltas to the latest
+// snapshot instead of reading and applying whole blocks.
+type DeltaStore interface {
+	Store
+
+	SaveDelta(context.Context, uint64, *state.Delta) error
+
+	// GetDelta returns the delta of the block at the given
+	// height, or nil if there is none.
+	GetDelta(context.Context, uint64) (*state.Delta, error)
+}
+
 // Pool provides storage for transactions in the pending
 // transaction pool.
 type Pool interface {
 	// Insert adds a transaction to the pool.
-	// It doesn't check for validity, or whether the transaction
-	// conflicts with another.
+	// It doesn't check for validity, but may reject a transaction
+	// that conflicts with another in the pool, or that doesn't fit.
 	// It is required to be idempotent.
 	Insert(context.Context, *bc.Tx) error
 
+	// Replace removes the transaction with the given hash from
+	// the pool, along with any that depend on it, and inserts
+	// the given transaction in its place.
+	Replace(context.Context, bc.Hash, *bc.Tx) error
+
+	// Remove removes the transaction with the given hash from
+	// the pool, along with any that depend on it.
+	Remove(context.Context, bc.Hash) error
+
+	// Pending returns the hashes of the transactions
+	// in the pool, oldest first.
+	Pending(context.Context) ([]bc.Hash, error)
+
+	// Get returns the transaction in the pool with the given
+	// hash, and the time it was inserted into the pool.
+	Get(context.Context, bc.Hash) (*bc.Tx, time.Time, error)
+
 	// Dump wipes the pending transaction pool and returns all
 	// transactions that were in the pool.
 	Dump(context.Context) ([]*bc.Tx, error)
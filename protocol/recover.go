@@ -41,13 +41,38 @@ func (c *Chain) Recover(ctx context.Context) (*bc.Block, *state.Snapshot, error)
 		return nil, nil, errors.Wrap(err, "getting blockchain height")
 	}
 
-	// Bring the snapshot up to date with the latest block
+	// Bring the snapshot up to date with the latest block. A
+	// TreeStore that is also a DeltaStore keeps only periodic base
+	// snapshots, so this replays up to a base period of blocks.
+	// Where the store has the delta of a block, apply it instead
+	// of the block; the root hash is checked against the latest
+	// block.
+	ds, _ := c.store.(DeltaStore)
 	for h := snapshotHeight + 1; h <= height; h++ {
+		var delta *state.Delta
+		if ds != nil {
+			delta, err = ds.GetDelta(ctx, h)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "getting state delta")
+			}
+		}
+		if delta != nil && h < height {
+			err = snapshot.ApplyDelta(delta)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "applying state delta")
+			}
+			continue
+		}
+
 		b, err = c.store.GetBlock(ctx, h)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "getting block")
 		}
-		err = validation.ApplyBlock(snapshot, b)
+		if delta != nil {
+			err = snapshot.ApplyDelta(delta)
+		} else {
+			err = validation.ApplyBlock(snapshot, b)
+		}
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "applying block")
 		}
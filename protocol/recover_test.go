@@ -56,6 +56,88 @@ func TestRecoverSnapshotNoAdditionalBlocks(t *testing.T) {
 	}
 }
 
+func TestRecoverDeltas(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	b1, err := NewInitialBlock(nil, 0, time.Now())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c1, err := NewChain(ctx, b1.Hash(), store, mempool.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.MaxIssuanceWindow = 48 * time.Hour
+	err = c1.CommitBlock(ctx, b1, state.Empty())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for {
+		_, height, _ := store.LatestSnapshot(ctx)
+		if height > 0 {
+			break
+		}
+	}
+
+	// Commit two more blocks. No snapshots are
+	// taken, so recovery must replay both.
+	issueTx, _, dest := issue(t, nil, nil, 1)
+	b2, s2 := applyTestBlock(t, b1, state.Empty(), issueTx)
+	err = c1.CommitBlock(ctx, b2, s2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	transferTx := transfer(t, stateOut(issueTx, 0), dest, newDest(t))
+	b3, s3 := applyTestBlock(t, b2, s2, transferTx)
+	err = c1.CommitBlock(ctx, b3, s3)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Remove the transactions of block 2, so that
+	// recovery succeeds only if it applies the delta.
+	store.Blocks[2] = &bc.Block{BlockHeader: b2.BlockHeader}
+
+	c2, err := NewChain(ctx, b1.Hash(), store, mempool.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, snapshot, err := c2.Recover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Height != 3 {
+		t.Fatalf("block.Height = %d, want 3", block.Height)
+	}
+	if snapshot.Tree.RootHash() != s3.Tree.RootHash() {
+		t.Errorf("recovered root hash = %x want %x", snapshot.Tree.RootHash(), s3.Tree.RootHash())
+	}
+}
+
+// applyTestBlock returns a block after prev with the given
+// transactions, and the result of applying it to snapshot.
+// The transactions are not validated.
+func applyTestBlock(t testing.TB, prev *bc.Block, snapshot *state.Snapshot, txs ...*bc.Tx) (*bc.Block, *state.Snapshot) {
+	b := &bc.Block{
+		BlockHeader: bc.BlockHeader{
+			Version:                bc.NewBlockVersion,
+			Height:                 prev.Height + 1,
+			PreviousBlockHash:      prev.Hash(),
+			TimestampMS:            prev.TimestampMS + 1,
+			ConsensusProgram:       prev.ConsensusProgram,
+			TransactionsMerkleRoot: validation.CalcMerkleRoot(txs),
+		},
+		Transactions: txs,
+	}
+	result := state.Copy(snapshot)
+	err := validation.ApplyBlock(result, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b.AssetsMerkleRoot = result.Tree.RootHash()
+	return b, result
+}
+
 func createEmptyBlock(block *bc.Block, snapshot *state.Snapshot) *bc.Block {
 	return &bc.Block{
 		BlockHeader: bc.BlockHeader{
@@ -0,0 +1,41 @@
+package state
+
+import "chain/protocol/patricia"
+
+// A Delta is the change a block makes to the blockchain state.
+// Applying a block's delta to the snapshot of the previous block
+// gives the snapshot of the block, without the work of reading
+// and applying the block's transactions.
+type Delta struct {
+	// Inserts and Deletes are the leaves inserted into and
+	// the keys deleted from the state tree. Outputs both
+	// created and spent within the block appear in neither.
+	Inserts []patricia.Leaf
+	Deletes [][]byte
+
+	// Issuances are added to the issuance memory after those
+	// expiring before PruneMS, the block's timestamp, are removed.
+	Issuances PriorIssuances
+	PruneMS   uint64
+}
+
+// ApplyDelta modifies a Snapshot, applying the changes in d.
+func (s *Snapshot) ApplyDelta(d *Delta) error {
+	s.PruneIssuances(d.PruneMS)
+	for _, key := range d.Deletes {
+		err := s.Tree.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range d.Inserts {
+		err := s.Tree.InsertLeaf(l)
+		if err != nil {
+			return err
+		}
+	}
+	for hash, expiryMS := range d.Issuances {
+		s.Issuances[hash] = expiryMS
+	}
+	return nil
+}
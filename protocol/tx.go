@@ -3,6 +3,7 @@ package protocol
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/golang/groupcache/lru"
 
@@ -19,9 +20,10 @@ import (
 // against the current state tree.
 //
 // It is okay to add the same transaction more than once; subsequent
-// attempts will have no effect and return a nil error. It is also okay
-// to add conflicting transactions to the pool. The conflict will be
-// resolved when a block lands.
+// attempts will have no effect and return a nil error. The pool may
+// reject a transaction that conflicts with a pending one; use ReplaceTx
+// to replace a pending transaction. Conflicts with transactions the
+// pool accepts will be resolved when a block lands.
 //
 // It is an error to call AddTx before the initial block has landed.
 // Use BlockWaiter to guarantee this.
@@ -31,7 +33,7 @@ func (c *Chain) AddTx(ctx context.Context, tx *bc.Tx) error {
 		return errors.Wrap(err, "tx rejected")
 	}
 
-	err = c.checkIssuanceWindow(tx)
+	err = c.CheckIssuanceWindow(tx)
 	if err != nil {
 		return errors.Wrap(err, "tx rejected")
 	}
@@ -41,6 +43,45 @@ func (c *Chain) AddTx(ctx context.Context, tx *bc.Tx) error {
 	return errors.Wrap(err, "applying tx to store")
 }
 
+// ReplaceTx replaces the pending transaction with hash old, and any
+// pending transactions that spend its outputs, with tx. Tx may spend
+// the same outputs as the transaction it replaces. Like AddTx, it
+// should only be called by the Generator.
+func (c *Chain) ReplaceTx(ctx context.Context, old bc.Hash, tx *bc.Tx) error {
+	err := c.ValidateTxCached(tx)
+	if err != nil {
+		return errors.Wrap(err, "tx rejected")
+	}
+
+	err = c.CheckIssuanceWindow(tx)
+	if err != nil {
+		return errors.Wrap(err, "tx rejected")
+	}
+
+	err = c.pool.Replace(ctx, old, tx)
+	return errors.Wrap(err, "replacing tx in pool")
+}
+
+// PendingTxs returns the hashes of the transactions
+// in the pending transaction pool, oldest first.
+func (c *Chain) PendingTxs(ctx context.Context) ([]bc.Hash, error) {
+	return c.pool.Pending(ctx)
+}
+
+// PendingTx returns the pending transaction with the given hash,
+// and the time it was added to the pending transaction pool.
+func (c *Chain) PendingTx(ctx context.Context, hash bc.Hash) (*bc.Tx, time.Time, error) {
+	return c.pool.Get(ctx, hash)
+}
+
+// RemovePendingTx removes the transaction with the given hash from
+// the pending transaction pool, along with any pending transactions
+// that spend its outputs. They will not be included in a block
+// unless they are added again.
+func (c *Chain) RemovePendingTx(ctx context.Context, hash bc.Hash) error {
+	return c.pool.Remove(ctx, hash)
+}
+
 // ValidateTxCached checks a cache of prevalidated transactions
 // before attempting to perform a context-free validation of the tx.
 func (c *Chain) ValidateTxCached(tx *bc.Tx) error {
@@ -79,7 +120,10 @@ func (c *prevalidatedTxsCache) cache(txID bc.Hash, err error) {
 	c.mu.Unlock()
 }
 
-func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
+// CheckIssuanceWindow checks that the time window of a
+// transaction with issuance inputs is no larger than the
+// network allows.
+func (c *Chain) CheckIssuanceWindow(tx *bc.Tx) error {
 	for _, txi := range tx.Inputs {
 		if _, ok := txi.TypedInput.(*bc.IssuanceInput); ok {
 			// TODO(tessr): consider removing 0 check once we can configure this
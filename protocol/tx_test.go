@@ -11,6 +11,7 @@ import (
 	"chain/crypto/ed25519"
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 	"chain/protocol/state"
 	"chain/protocol/validation"
 	"chain/protocol/vm"
@@ -76,6 +77,42 @@ func TestAddTxBadMaxIssuanceWindow(t *testing.T) {
 	}
 }
 
+func TestReplaceTx(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestChain(t, time.Now())
+
+	issueTx, _, dest1 := issue(t, nil, nil, 1)
+	tx1 := transfer(t, stateOut(issueTx, 0), dest1, newDest(t))
+	tx2 := transfer(t, stateOut(issueTx, 0), dest1, newDest(t))
+	for _, tx := range []*bc.Tx{issueTx, tx1} {
+		err := c.AddTx(ctx, tx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	err := c.AddTx(ctx, tx2)
+	if errors.Root(err) != mempool.ErrConflict {
+		t.Fatalf("AddTx(conflicting tx) error = %v want %v", err, mempool.ErrConflict)
+	}
+	err = c.ReplaceTx(ctx, tx1.Hash, tx2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := c.PendingTxs(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 2 || got[0] != issueTx.Hash || got[1] != tx2.Hash {
+		t.Errorf("pending txs = %x want [%x %x]", got, issueTx.Hash, tx2.Hash)
+	}
+
+	err = c.ReplaceTx(ctx, tx1.Hash, tx1)
+	if errors.Root(err) != mempool.ErrNotFound {
+		t.Errorf("ReplaceTx(removed tx) error = %v want %v", err, mempool.ErrNotFound)
+	}
+}
+
 type testDest struct {
 	privKey ed25519.PrivateKey
 }
@@ -11,6 +11,8 @@ import (
 
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/patricia"
+	"chain/protocol/proof"
 	"chain/protocol/state"
 	"chain/protocol/vm"
 	"chain/protocol/vmutil"
@@ -25,6 +27,8 @@ var (
 	ErrBadSig       = errors.New("invalid signature script")
 	ErrBadTxRoot    = errors.New("invalid transaction merkle root")
 	ErrBadStateRoot = errors.New("invalid state merkle root")
+	ErrBadInitial   = errors.New("invalid initial block")
+	ErrBadTxProof   = errors.New("invalid transaction proof")
 )
 
 // ValidateBlockForAccept performs steps 1 and 2
@@ -34,24 +38,75 @@ var (
 // then calls ValidateBlock.
 func ValidateBlockForAccept(ctx context.Context, snapshot *state.Snapshot, initialBlockHash bc.Hash, prevBlock, block *bc.Block, validateTx func(*bc.Tx) error) error {
 	if prevBlock != nil {
-		ok, err := vm.VerifyBlockHeader(&prevBlock.BlockHeader, block)
-		if err == nil && !ok {
-			err = ErrFalseVMResult
-		}
+		err := checkBlockSig(prevBlock, block)
 		if err != nil {
-			pkScriptStr, _ := vm.Disassemble(prevBlock.ConsensusProgram)
-			witnessStrs := make([]string, 0, len(block.Witness))
-			for _, w := range block.Witness {
-				witnessStrs = append(witnessStrs, hex.EncodeToString(w))
-			}
-			witnessStr := strings.Join(witnessStrs, "; ")
-			return errors.Wrapf(ErrBadSig, "validation failed in script execution in block (program [%s] witness [%s]): %s", pkScriptStr, witnessStr, err)
+			return err
 		}
 	}
 
 	return ValidateBlock(ctx, snapshot, initialBlockHash, prevBlock, block, validateTx)
 }
 
+// ValidateFilteredBlock validates a block received by a light
+// client, which holds only some of the block's transactions, each
+// with the proof at the same index in proofs that it is in the
+// block. It evaluates prevBlock's consensus program, checks the
+// block header, and checks that each transaction is well formed
+// and in the block. It cannot check the transactions against the
+// blockchain state, nor that no other transactions of interest
+// were left out.
+//
+// If prevBlock is nil, block must be the initial block.
+func ValidateFilteredBlock(initialBlockHash bc.Hash, prevBlock, block *bc.Block, proofs []*proof.TxProof) error {
+	var prev *bc.BlockHeader
+	if prevBlock != nil {
+		err := checkBlockSig(prevBlock, block)
+		if err != nil {
+			return err
+		}
+		prev = &prevBlock.BlockHeader
+	} else if block.Hash() != initialBlockHash {
+		return errors.WithDetailf(ErrBadInitial, "block hash %s, want %s", block.Hash(), initialBlockHash)
+	}
+	err := validateHeader(prev, block)
+	if err != nil {
+		return err
+	}
+
+	if len(proofs) != len(block.Transactions) {
+		return errors.WithDetailf(ErrBadTxProof, "%d proofs for %d transactions", len(proofs), len(block.Transactions))
+	}
+	for i, tx := range block.Transactions {
+		if !proof.VerifyTx(tx, block.TransactionsMerkleRoot, proofs[i]) {
+			return errors.WithDetailf(ErrBadTxProof, "transaction %s", tx.Hash)
+		}
+		err = CheckTxWellFormed(tx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlockSig evaluates the consensus program
+// of prevBlock with the witness of block.
+func checkBlockSig(prevBlock, block *bc.Block) error {
+	ok, err := vm.VerifyBlockHeader(&prevBlock.BlockHeader, block)
+	if err == nil && !ok {
+		err = ErrFalseVMResult
+	}
+	if err != nil {
+		pkScriptStr, _ := vm.Disassemble(prevBlock.ConsensusProgram)
+		witnessStrs := make([]string, 0, len(block.Witness))
+		for _, w := range block.Witness {
+			witnessStrs = append(witnessStrs, hex.EncodeToString(w))
+		}
+		witnessStr := strings.Join(witnessStrs, "; ")
+		return errors.Wrapf(ErrBadSig, "validation failed in script execution in block (program [%s] witness [%s]): %s", pkScriptStr, witnessStr, err)
+	}
+	return nil
+}
+
 // ValidateBlock performs the "validate block" procedure from the spec,
 // yielding a new state (recorded in the 'snapshot' argument).
 // See $CHAIN/protocol/doc/spec/validation.md#validate-block.
@@ -124,7 +179,73 @@ func ApplyBlock(snapshot *state.Snapshot, block *bc.Block) error {
 	return nil
 }
 
+// BlockDelta returns the change the block makes to the state.
+// Applying it to a snapshot has the same effect as ApplyBlock.
+func BlockDelta(block *bc.Block) (*state.Delta, error) {
+	d := &state.Delta{
+		Issuances: make(state.PriorIssuances),
+		PruneMS:   block.TimestampMS,
+	}
+	// created holds the keys of outputs created in
+	// the block, and whether each is also spent in it.
+	created := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		for i, in := range tx.Inputs {
+			if ii, ok := in.TypedInput.(*bc.IssuanceInput); ok {
+				if len(ii.Nonce) > 0 {
+					iHash, err := tx.IssuanceHash(i)
+					if err != nil {
+						return nil, err
+					}
+					d.Issuances[iHash] = tx.MaxTime
+				}
+				continue
+			}
+			key := state.OutputKey(in.Outpoint())
+			if _, ok := created[string(key)]; ok {
+				created[string(key)] = true
+			} else {
+				d.Deletes = append(d.Deletes, key)
+			}
+		}
+		for i, out := range tx.Outputs {
+			if vmutil.IsUnspendable(out.ControlProgram) {
+				continue
+			}
+			o := state.NewOutput(*out, bc.Outpoint{Hash: tx.Hash, Index: uint32(i)})
+			l := patricia.NewLeaf(state.OutputTreeItem(o))
+			created[string(l.Key)] = false
+			d.Inserts = append(d.Inserts, l)
+		}
+	}
+
+	inserts := d.Inserts[:0]
+	for _, l := range d.Inserts {
+		if !created[string(l.Key)] {
+			inserts = append(inserts, l)
+		}
+	}
+	d.Inserts = inserts
+	return d, nil
+}
+
 func validateBlockHeader(prev *bc.BlockHeader, block *bc.Block) error {
+	err := validateHeader(prev, block)
+	if err != nil {
+		return err
+	}
+
+	txMerkleRoot := CalcMerkleRoot(block.Transactions)
+	// can be modified to allow soft fork
+	if block.TransactionsMerkleRoot != txMerkleRoot {
+		return ErrBadTxRoot
+	}
+	return nil
+}
+
+// validateHeader checks the fields of the block header
+// that don't depend on the block's transactions.
+func validateHeader(prev *bc.BlockHeader, block *bc.Block) error {
 	if prev == nil && block.Height != 1 {
 		return ErrBadHeight
 	}
@@ -141,12 +262,6 @@ func validateBlockHeader(prev *bc.BlockHeader, block *bc.Block) error {
 		}
 	}
 
-	txMerkleRoot := CalcMerkleRoot(block.Transactions)
-	// can be modified to allow soft fork
-	if block.TransactionsMerkleRoot != txMerkleRoot {
-		return ErrBadTxRoot
-	}
-
 	if vmutil.IsUnspendable(block.ConsensusProgram) {
 		return ErrBadScript
 	}
@@ -2,10 +2,12 @@ package validation
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/proof"
 	"chain/protocol/state"
 	"chain/protocol/vm"
 )
@@ -108,3 +110,127 @@ func TestValidateBlockHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateFilteredBlock(t *testing.T) {
+	prog := []byte{byte(vm.OP_5), byte(vm.OP_ADD), byte(vm.OP_9), byte(vm.OP_EQUAL)}
+	initial := &bc.Block{BlockHeader: bc.BlockHeader{
+		Height:                 1,
+		TimestampMS:            5,
+		TransactionsMerkleRoot: emptyMerkleRoot,
+		ConsensusProgram:       prog,
+	}}
+
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.ComputeAssetID(trueProg, initial.Hash(), 1)
+	var txs []*bc.Tx
+	for i := 0; i < 3; i++ {
+		txs = append(txs, bc.NewTx(bc.TxData{
+			Version: 1,
+			MinTime: 1,
+			MaxTime: 10,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{byte(i)}, 1, nil, initial.Hash(), trueProg, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, trueProg, nil)},
+		}))
+	}
+	full := &bc.Block{
+		BlockHeader: bc.BlockHeader{
+			PreviousBlockHash:      initial.Hash(),
+			TransactionsMerkleRoot: CalcMerkleRoot(txs),
+			Height:                 2,
+			TimestampMS:            6,
+			ConsensusProgram:       prog,
+			Witness:                [][]byte{{0x04}},
+		},
+		Transactions: txs,
+	}
+	filtered := &bc.Block{BlockHeader: full.BlockHeader, Transactions: txs[1:2]}
+	p := TxProof(txs, 1)
+
+	err := ValidateFilteredBlock(initial.Hash(), nil, initial, nil)
+	if err != nil {
+		t.Errorf("initial block: got error %s", err)
+	}
+	err = ValidateFilteredBlock(bc.Hash{}, nil, initial, nil)
+	if errors.Root(err) != ErrBadInitial {
+		t.Errorf("wrong initial block: got error %v, want %s", err, ErrBadInitial)
+	}
+
+	err = ValidateFilteredBlock(initial.Hash(), initial, filtered, []*proof.TxProof{p})
+	if err != nil {
+		t.Errorf("filtered block: got error %s", err)
+	}
+	err = ValidateFilteredBlock(initial.Hash(), initial, filtered, []*proof.TxProof{TxProof(txs, 0)})
+	if errors.Root(err) != ErrBadTxProof {
+		t.Errorf("bad proof: got error %v, want %s", err, ErrBadTxProof)
+	}
+
+	forged := &bc.Block{BlockHeader: filtered.BlockHeader, Transactions: filtered.Transactions}
+	forged.Witness = [][]byte{{0x03}}
+	err = ValidateFilteredBlock(initial.Hash(), initial, forged, []*proof.TxProof{p})
+	if errors.Root(err) != ErrBadSig {
+		t.Errorf("bad signature: got error %v, want %s", err, ErrBadSig)
+	}
+}
+
+func TestBlockDelta(t *testing.T) {
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.ComputeAssetID(trueProg, bc.Hash{}, 1)
+
+	prev := bc.NewTx(bc.TxData{
+		Version: 1,
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, trueProg, nil)},
+	})
+	issue := bc.NewTx(bc.TxData{
+		Version: 1,
+		MinTime: 1,
+		MaxTime: 10,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{1}, 1, nil, bc.Hash{}, trueProg, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, trueProg, nil)},
+	})
+	spendIssued := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(issue.Hash, 0, nil, assetID, 1, trueProg, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, trueProg, nil)},
+	})
+	spendPrev := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(prev.Hash, 0, nil, assetID, 1, trueProg, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, []byte{byte(vm.OP_FAIL)}, nil)},
+	})
+	block := &bc.Block{
+		BlockHeader:  bc.BlockHeader{Height: 2, TimestampMS: 6},
+		Transactions: []*bc.Tx{issue, spendIssued, spendPrev},
+	}
+
+	before := state.Empty()
+	err := ApplyTx(before, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before.Issuances[bc.Hash{1}] = 5 // expires before the block
+
+	want := state.Copy(before)
+	err = ApplyBlock(want, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := BlockDelta(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Deletes) != 1 || len(d.Inserts) != 1 {
+		t.Errorf("got %d deletes and %d inserts, want 1 and 1", len(d.Deletes), len(d.Inserts))
+	}
+	got := state.Copy(before)
+	err = got.ApplyDelta(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tree.RootHash() != want.Tree.RootHash() {
+		t.Errorf("root hash = %x want %x", got.Tree.RootHash(), want.Tree.RootHash())
+	}
+	if !reflect.DeepEqual(got.Issuances, want.Issuances) {
+		t.Errorf("issuances = %v want %v", got.Issuances, want.Issuances)
+	}
+}
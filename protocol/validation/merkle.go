@@ -5,6 +5,7 @@ import (
 
 	"chain/crypto/sha3pool"
 	"chain/protocol/bc"
+	"chain/protocol/proof"
 )
 
 var (
@@ -45,6 +46,32 @@ func CalcMerkleRoot(transactions []*bc.Tx) (root bc.Hash) {
 	}
 }
 
+// TxProof returns a proof that transactions[i] is in the
+// merkle tree of transactions, whose root is given by
+// CalcMerkleRoot. The proof can be checked with
+// proof.VerifyTx.
+func TxProof(transactions []*bc.Tx, i int) *proof.TxProof {
+	p := &proof.TxProof{Path: []proof.TxStep{}}
+	txProof(p, transactions, i)
+	return p
+}
+
+// txProof appends to p the path from transactions[i]
+// to the root of the merkle tree of transactions.
+func txProof(p *proof.TxProof, transactions []*bc.Tx, i int) {
+	if len(transactions) <= 1 {
+		return
+	}
+	k := prevPowerOfTwo(len(transactions))
+	if i < k {
+		txProof(p, transactions[:k], i)
+		p.Path = append(p.Path, proof.TxStep{Hash: CalcMerkleRoot(transactions[k:])})
+	} else {
+		txProof(p, transactions[k:], i-k)
+		p.Path = append(p.Path, proof.TxStep{Hash: CalcMerkleRoot(transactions[:k]), Left: true})
+	}
+}
+
 // prevPowerOfTwo returns the largest power of two that is smaller than a given number.
 // In other words, for some input n, the prevPowerOfTwo k is a power of two such that
 // k < n <= 2k. This is a helper function used during the calculation of a merkle tree.
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"chain/protocol/bc"
+	"chain/protocol/proof"
 	"chain/protocol/vm"
 )
 
@@ -131,3 +132,27 @@ func mustParseHash(s string) bc.Hash {
 	}
 	return h
 }
+
+func TestTxProof(t *testing.T) {
+	var initialBlockHash bc.Hash
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.ComputeAssetID(trueProg, initialBlockHash, 1)
+	var txs []*bc.Tx
+	for n := 1; n <= 7; n++ {
+		txs = append(txs, bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{byte(n)}, uint64(n), nil, initialBlockHash, trueProg, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, uint64(n), trueProg, nil)},
+		}))
+		root := CalcMerkleRoot(txs)
+		for i, tx := range txs {
+			p := TxProof(txs, i)
+			if !proof.VerifyTx(tx, root, p) {
+				t.Errorf("%d txs: proof of tx %d did not verify", n, i)
+			}
+			if n > 1 && proof.VerifyTx(txs[(i+1)%n], root, p) {
+				t.Errorf("%d txs: proof of tx %d verified another tx", n, i)
+			}
+		}
+	}
+}
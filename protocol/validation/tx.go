@@ -71,7 +71,11 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 
 		// Lookup the prevout in the blockchain state tree.
 		k, val := state.OutputTreeItem(state.Prevout(txin))
-		if !snapshot.Tree.Contains(k, val) {
+		ok, err := snapshot.Tree.Contains(k, val)
+		if err != nil {
+			return errors.Wrap(err, "looking up prevout")
+		}
+		if !ok {
 			return errors.WithDetailf(ErrBadTx, "output %s for input %d is invalid", txin.Outpoint().String(), i)
 		}
 	}
@@ -237,12 +241,56 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 			for _, arg := range args {
 				hexArgs = append(hexArgs, hex.EncodeToString(arg))
 			}
-			return errors.WithDetailf(ErrBadTx, "validation failed in script execution, input %d (program [%s] args [%s]): %s", i, scriptStr, strings.Join(hexArgs, " "), err)
+			err = errors.WithDetailf(ErrBadTx, "validation failed in script execution, input %d (program [%s] args [%s]): %s", i, scriptStr, strings.Join(hexArgs, " "), err)
+			return vmFailure(err, tx, i)
 		}
 	}
 	return nil
 }
 
+// vmFailure adds to err a description of how the program of
+// input i failed, for callers that want to know which
+// instruction failed and why. The program is run again with
+// tracing, so validating valid transactions costs nothing extra.
+//
+// The data items are input_index, the index of the input;
+// vm_error, the error the program returned, or the false result;
+// pc, op and checkpredicate_depth, describing the last instruction
+// executed, including those run by CHECKPREDICATE; and run_limit,
+// data_stack and alt_stack, the state of the VM just before it
+// executed that instruction. The stacks are hex-encoded, top last.
+func vmFailure(err error, tx *bc.Tx, i int) error {
+	var last *vm.Step
+	ok, vmErr := vm.VerifyTxInputTrace(tx, i, func(s vm.Step) { last = &s })
+	if vmErr == nil && !ok {
+		vmErr = ErrFalseVMResult
+	}
+	if vmErr == nil {
+		// Should be impossible; programs are deterministic.
+		return err
+	}
+	err = errors.WithData(err, "input_index", i, "vm_error", vmErr.Error())
+	if last == nil {
+		return err
+	}
+	return errors.WithData(err,
+		"pc", last.PC,
+		"op", last.Op.String(),
+		"checkpredicate_depth", last.Depth,
+		"run_limit", last.RunLimit,
+		"data_stack", hexItems(last.DataStack),
+		"alt_stack", hexItems(last.AltStack),
+	)
+}
+
+func hexItems(items [][]byte) []string {
+	strs := make([]string, 0, len(items))
+	for _, item := range items {
+		strs = append(strs, hex.EncodeToString(item))
+	}
+	return strs
+}
+
 // ApplyTx updates the state tree with all the changes to the ledger.
 func ApplyTx(snapshot *state.Snapshot, tx *bc.Tx) error {
 	for i, in := range tx.Inputs {
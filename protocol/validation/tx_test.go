@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -701,6 +702,61 @@ func TestTxWellFormed(t *testing.T) {
 	}
 }
 
+func TestTxWellFormedVMFailure(t *testing.T) {
+	aid := bc.AssetID([32]byte{2})
+	trueProg := []byte{byte(vm.OP_TRUE)}
+
+	cases := []struct {
+		prog    string
+		wantErr string
+		wantPC  uint32
+		wantOp  string
+		wantStk []string
+	}{
+		{"1 2 ADD 4 NUMEQUAL", ErrFalseVMResult.Error(), 4, "NUMEQUAL", []string{"03", "04"}},
+		{"1 0 VERIFY 1", vm.ErrVerifyFailed.Error(), 2, "VERIFY", []string{"01", ""}},
+	}
+	for _, c := range cases {
+		prog, err := vm.Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{10}, 0, nil, aid, 500, trueProg, nil),
+				bc.NewSpendInput(bc.Hash{11}, 0, nil, aid, 500, prog, nil),
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(aid, 1000, nil, nil),
+			},
+		})
+		err = CheckTxWellFormed(tx)
+		if errors.Root(err) != ErrBadTx {
+			t.Errorf("%s: got error %v, want ErrBadTx", c.prog, err)
+			continue
+		}
+		data := errors.Data(err)
+		want := map[string]interface{}{
+			"input_index":          1,
+			"vm_error":             c.wantErr,
+			"pc":                   c.wantPC,
+			"op":                   c.wantOp,
+			"checkpredicate_depth": 0,
+			"data_stack":           c.wantStk,
+			"alt_stack":            []string{},
+		}
+		for k, v := range want {
+			if !reflect.DeepEqual(data[k], v) {
+				t.Errorf("%s: data[%s] = %#v, want %#v", c.prog, k, data[k], v)
+			}
+		}
+		if _, ok := data["run_limit"].(int64); !ok {
+			t.Errorf("%s: data[run_limit] = %#v, want int64", c.prog, data["run_limit"])
+		}
+	}
+}
+
 func TestValidateInvalidIssuances(t *testing.T) {
 	var initialBlockHash bc.Hash
 	issuanceProg := []byte{1}
@@ -0,0 +1,626 @@
+// Package analysis examines VM programs without running them,
+// to vet control programs and issuance programs before value is
+// locked with them.
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"chain/errors"
+	"chain/math/checked"
+	"chain/protocol/vm"
+)
+
+// Analysis describes what a program can do when run.
+type Analysis struct {
+	// Ops lists the ops the program can reach, in numeric order.
+	Ops []vm.Op
+
+	// Disallowed lists the expansion ops in Ops. Transactions
+	// of version 1 fail if they reach them.
+	Disallowed []vm.Op
+
+	// MaxStackDepth is the most items the program can have
+	// on its data stack in addition to its arguments. If
+	// StackUnbounded is true, it can loop adding items until
+	// it exhausts its run limit.
+	MaxStackDepth  int
+	StackUnbounded bool
+
+	// MaxCost is the most run limit the program can use along
+	// any path, counting the fixed cost of each op and the
+	// memory cost of the items it adds to the data stack, with
+	// items whose size isn't known counted as empty. If
+	// DataDependentCost is true, some ops reached also cost an
+	// amount that depends on their arguments, such as the size
+	// of the data they hash or the run limit they give to a
+	// predicate. If CostUnbounded is true, the program can loop
+	// until it exhausts its run limit.
+	MaxCost           int64
+	DataDependentCost bool
+	CostUnbounded     bool
+
+	// CanSucceed is false if no arguments can satisfy the
+	// program: every path through it fails or leaves a false
+	// value on top of the stack.
+	CanSucceed bool
+
+	// Template, if not nil, is the standard program
+	// the program was recognized as.
+	Template *Template
+}
+
+// value is what is known statically
+// about an item on the data stack.
+type value struct {
+	known bool
+	data  []byte
+}
+
+func (v value) equal(w value) bool {
+	return v.known == w.known && bytes.Equal(v.data, w.data)
+}
+
+// state is what is known about the data
+// stack just before an instruction runs.
+type state struct {
+	// depth is the number of items on the stack in addition
+	// to the program's arguments. It is negative once the
+	// program has consumed some of its arguments.
+	depth int
+
+	// stack holds the topmost items, top last.
+	// Any items below them are unknown.
+	stack []value
+}
+
+func (s *state) copy() *state {
+	return &state{depth: s.depth, stack: append([]value(nil), s.stack...)}
+}
+
+func (s *state) push(v value) {
+	s.depth++
+	s.stack = append(s.stack, v)
+}
+
+func (s *state) pushUnknown(n int) {
+	for i := 0; i < n; i++ {
+		s.push(value{})
+	}
+}
+
+func (s *state) pop() value {
+	s.depth--
+	if len(s.stack) == 0 {
+		return value{}
+	}
+	v := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	return v
+}
+
+func (s *state) drop(n int) {
+	for i := 0; i < n; i++ {
+		s.pop()
+	}
+}
+
+// peek returns the item n places below the top.
+func (s *state) peek(n int) value {
+	if n >= len(s.stack) {
+		return value{}
+	}
+	return s.stack[len(s.stack)-1-n]
+}
+
+// popInt pops an item, reporting its value
+// as a number if it is known.
+func (s *state) popInt() (int64, bool) {
+	v := s.pop()
+	if !v.known {
+		return 0, false
+	}
+	n, err := vm.AsInt64(v.data)
+	return n, err == nil
+}
+
+// merge merges t, the state along another path to the
+// same instruction, into s. It reports whether s changed
+// and whether its depth increased.
+func (s *state) merge(t *state) (changed, deeper bool) {
+	if t.depth != s.depth {
+		if t.depth > s.depth {
+			s.depth = t.depth
+			deeper = true
+		}
+		changed = deeper || len(s.stack) > 0
+		s.stack = nil
+		return changed, deeper
+	}
+	if len(t.stack) < len(s.stack) {
+		s.stack = s.stack[len(s.stack)-len(t.stack):]
+		changed = true
+	}
+	off := len(t.stack) - len(s.stack)
+	for i := range s.stack {
+		if s.stack[i].known && !s.stack[i].equal(t.stack[off+i]) {
+			s.stack[i] = value{}
+			changed = true
+		}
+	}
+	return changed, false
+}
+
+// effect is the result of running one instruction
+// from a given state.
+type effect struct {
+	// next holds the states after the instruction, keyed by
+	// the location of the instruction that runs next.
+	// A location past the end of the program means the
+	// program finishes. If next is empty, the instruction
+	// always fails.
+	next map[uint32]*state
+
+	cost          int64
+	dataDependent bool
+}
+
+// Analyze analyzes prog. It returns an error
+// if an instruction the program can reach
+// cannot be parsed.
+func Analyze(prog []byte) (*Analysis, error) {
+	in := map[uint32]*state{0: new(state)}
+	if len(prog) == 0 {
+		return analyze(prog, in, false)
+	}
+
+	// Find the state on entry to each reachable instruction,
+	// breadth first. Without loops that push items, the depth at
+	// an instruction can increase at most once for each other
+	// instruction; past that, stop tracking it.
+	var (
+		queue     = []uint32{0}
+		queued    = map[uint32]bool{0: true}
+		increases = make(map[uint32]int)
+		unbounded bool
+	)
+	for len(queue) > 0 {
+		pc := queue[0]
+		queue = queue[1:]
+		queued[pc] = false
+
+		inst, err := vm.ParseOp(prog, pc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing instruction at %d", pc)
+		}
+		eff := step(prog, pc, inst, in[pc].copy())
+		for next, t := range eff.next {
+			if next >= uint32(len(prog)) {
+				continue
+			}
+			s, ok := in[next]
+			if !ok {
+				in[next] = t
+			} else {
+				if unbounded {
+					t.depth = s.depth
+				}
+				changed, deeper := s.merge(t)
+				if deeper {
+					increases[next]++
+					if increases[next] > len(prog) {
+						unbounded = true
+					}
+				}
+				if !changed {
+					continue
+				}
+			}
+			if !queued[next] {
+				queue = append(queue, next)
+				queued[next] = true
+			}
+		}
+	}
+	return analyze(prog, in, unbounded)
+}
+
+// analyze computes the analysis of prog from the state
+// on entry to each reachable instruction.
+func analyze(prog []byte, in map[uint32]*state, stackUnbounded bool) (*Analysis, error) {
+	a := &Analysis{StackUnbounded: stackUnbounded}
+	var (
+		reached [256]bool
+		effects = make(map[uint32]*effect)
+	)
+	if len(prog) == 0 {
+		// The result is the top argument, if any.
+		a.CanSucceed = true
+	}
+	for pc, s := range in {
+		if pc >= uint32(len(prog)) {
+			continue
+		}
+		inst, err := vm.ParseOp(prog, pc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing instruction at %d", pc)
+		}
+		reached[inst.Op] = true
+		eff := step(prog, pc, inst, s.copy())
+		effects[pc] = eff
+		if s.depth > a.MaxStackDepth {
+			a.MaxStackDepth = s.depth
+		}
+		for next, t := range eff.next {
+			if t.depth > a.MaxStackDepth {
+				a.MaxStackDepth = t.depth
+			}
+			if next >= uint32(len(prog)) {
+				top := t.peek(0)
+				if !top.known || vm.AsBool(top.data) {
+					a.CanSucceed = true
+				}
+			}
+		}
+		a.DataDependentCost = a.DataDependentCost || eff.dataDependent
+	}
+	for i, ok := range reached {
+		if !ok {
+			continue
+		}
+		op := vm.Op(i)
+		a.Ops = append(a.Ops, op)
+		if op.IsExpansion() {
+			a.Disallowed = append(a.Disallowed, op)
+		}
+	}
+
+	if len(prog) > 0 {
+		cost, ok := maxCost(0, uint32(len(prog)), effects, make(map[uint32]int64), make(map[uint32]bool))
+		a.MaxCost = cost
+		a.CostUnbounded = !ok
+	}
+
+	a.Template = recognize(prog)
+	return a, nil
+}
+
+// maxCost returns the greatest cost of running the program from
+// pc to its end or to failure. It returns false if the program can
+// loop. Memo holds the results for instructions already visited,
+// and active the instructions on the current path.
+func maxCost(pc, end uint32, effects map[uint32]*effect, memo map[uint32]int64, active map[uint32]bool) (int64, bool) {
+	if pc >= end {
+		return 0, true
+	}
+	if active[pc] {
+		return 0, false
+	}
+	if cost, ok := memo[pc]; ok {
+		return cost, true
+	}
+	active[pc] = true
+	defer delete(active, pc)
+
+	eff := effects[pc]
+	var most int64
+	for next := range eff.next {
+		cost, ok := maxCost(next, end, effects, memo, active)
+		if !ok {
+			return 0, false
+		}
+		if cost > most {
+			most = cost
+		}
+	}
+	cost, ok := checked.AddInt64(eff.cost, most)
+	if !ok {
+		return 0, false
+	}
+	memo[pc] = cost
+	return cost, true
+}
+
+// fixedCost gives the cost of each op that doesn't
+// depend on its arguments, not counting the memory
+// cost of the items it pushes.
+var fixedCost = map[vm.Op]int64{
+	vm.OP_TOALTSTACK:         2,
+	vm.OP_FROMALTSTACK:       2,
+	vm.OP_2DROP:              2,
+	vm.OP_2DUP:               2,
+	vm.OP_3DUP:               3,
+	vm.OP_2OVER:              2,
+	vm.OP_2ROT:               2,
+	vm.OP_2SWAP:              2,
+	vm.OP_PICK:               2,
+	vm.OP_ROLL:               2,
+	vm.OP_ROT:                2,
+	vm.OP_CAT:                4,
+	vm.OP_SUBSTR:             4,
+	vm.OP_LEFT:               4,
+	vm.OP_RIGHT:              4,
+	vm.OP_CATPUSHDATA:        4,
+	vm.OP_1ADD:               2,
+	vm.OP_1SUB:               2,
+	vm.OP_2MUL:               2,
+	vm.OP_2DIV:               2,
+	vm.OP_NEGATE:             2,
+	vm.OP_ABS:                2,
+	vm.OP_NOT:                2,
+	vm.OP_0NOTEQUAL:          2,
+	vm.OP_ADD:                2,
+	vm.OP_SUB:                2,
+	vm.OP_MUL:                8,
+	vm.OP_DIV:                8,
+	vm.OP_MOD:                8,
+	vm.OP_LSHIFT:             8,
+	vm.OP_RSHIFT:             8,
+	vm.OP_BOOLAND:            2,
+	vm.OP_BOOLOR:             2,
+	vm.OP_NUMEQUAL:           2,
+	vm.OP_NUMEQUALVERIFY:     2,
+	vm.OP_NUMNOTEQUAL:        2,
+	vm.OP_LESSTHAN:           2,
+	vm.OP_GREATERTHAN:        2,
+	vm.OP_LESSTHANOREQUAL:    2,
+	vm.OP_GREATERTHANOREQUAL: 2,
+	vm.OP_MIN:                2,
+	vm.OP_MAX:                2,
+	vm.OP_WITHIN:             4,
+	vm.OP_RIPEMD160:          64,
+	vm.OP_SHA1:               64,
+	vm.OP_SHA256:             64,
+	vm.OP_SHA3:               64,
+	vm.OP_CHECKSIG:           1024,
+	vm.OP_CHECKMULTISIG:      0,
+	vm.OP_TXSIGHASH:          256,
+	vm.OP_BLOCKSIGHASH:       128,
+	vm.OP_CHECKOUTPUT:        16,
+	vm.OP_CHECKPREDICATE:     64,
+}
+
+// stackEffect gives the number of items popped and
+// pushed by ops that don't need special treatment.
+var stackEffect = map[vm.Op][2]int{
+	vm.OP_2DROP:              {2, 0},
+	vm.OP_2DUP:               {2, 4},
+	vm.OP_3DUP:               {3, 6},
+	vm.OP_2OVER:              {4, 6},
+	vm.OP_2ROT:               {6, 6},
+	vm.OP_2SWAP:              {4, 4},
+	vm.OP_IFDUP:              {1, 2},
+	vm.OP_DEPTH:              {0, 1},
+	vm.OP_NIP:                {2, 1},
+	vm.OP_OVER:               {2, 3},
+	vm.OP_ROT:                {3, 3},
+	vm.OP_TUCK:               {2, 3},
+	vm.OP_CAT:                {2, 1},
+	vm.OP_SUBSTR:             {3, 1},
+	vm.OP_LEFT:               {2, 1},
+	vm.OP_RIGHT:              {2, 1},
+	vm.OP_SIZE:               {1, 2},
+	vm.OP_CATPUSHDATA:        {2, 1},
+	vm.OP_INVERT:             {1, 1},
+	vm.OP_AND:                {2, 1},
+	vm.OP_OR:                 {2, 1},
+	vm.OP_XOR:                {2, 1},
+	vm.OP_EQUAL:              {2, 1},
+	vm.OP_1ADD:               {1, 1},
+	vm.OP_1SUB:               {1, 1},
+	vm.OP_2MUL:               {1, 1},
+	vm.OP_2DIV:               {1, 1},
+	vm.OP_NEGATE:             {1, 1},
+	vm.OP_ABS:                {1, 1},
+	vm.OP_NOT:                {1, 1},
+	vm.OP_0NOTEQUAL:          {1, 1},
+	vm.OP_ADD:                {2, 1},
+	vm.OP_SUB:                {2, 1},
+	vm.OP_MUL:                {2, 1},
+	vm.OP_DIV:                {2, 1},
+	vm.OP_MOD:                {2, 1},
+	vm.OP_LSHIFT:             {2, 1},
+	vm.OP_RSHIFT:             {2, 1},
+	vm.OP_BOOLAND:            {2, 1},
+	vm.OP_BOOLOR:             {2, 1},
+	vm.OP_NUMEQUAL:           {2, 1},
+	vm.OP_NUMNOTEQUAL:        {2, 1},
+	vm.OP_LESSTHAN:           {2, 1},
+	vm.OP_GREATERTHAN:        {2, 1},
+	vm.OP_LESSTHANOREQUAL:    {2, 1},
+	vm.OP_GREATERTHANOREQUAL: {2, 1},
+	vm.OP_MIN:                {2, 1},
+	vm.OP_MAX:                {2, 1},
+	vm.OP_WITHIN:             {3, 1},
+	vm.OP_RIPEMD160:          {1, 1},
+	vm.OP_SHA1:               {1, 1},
+	vm.OP_SHA256:             {1, 1},
+	vm.OP_SHA3:               {1, 1},
+	vm.OP_CHECKSIG:           {3, 1},
+	vm.OP_TXSIGHASH:          {0, 1},
+	vm.OP_BLOCKSIGHASH:       {0, 1},
+	vm.OP_CHECKOUTPUT:        {6, 1},
+	vm.OP_ASSET:              {0, 1},
+	vm.OP_AMOUNT:             {0, 1},
+	vm.OP_PROGRAM:            {0, 1},
+	vm.OP_MINTIME:            {0, 1},
+	vm.OP_MAXTIME:            {0, 1},
+	vm.OP_TXREFDATAHASH:      {0, 1},
+	vm.OP_REFDATAHASH:        {0, 1},
+	vm.OP_INDEX:              {0, 1},
+	vm.OP_OUTPOINT:           {0, 2},
+	vm.OP_NONCE:              {0, 1},
+	vm.OP_NEXTPROGRAM:        {0, 1},
+	vm.OP_BLOCKTIME:          {0, 1},
+}
+
+// dataDependent lists the ops whose cost
+// depends on the values of their arguments.
+var dataDependent = map[vm.Op]bool{
+	vm.OP_CAT:         true,
+	vm.OP_SUBSTR:      true,
+	vm.OP_LEFT:        true,
+	vm.OP_RIGHT:       true,
+	vm.OP_CATPUSHDATA: true,
+	vm.OP_INVERT:      true,
+	vm.OP_AND:         true,
+	vm.OP_OR:          true,
+	vm.OP_XOR:         true,
+	vm.OP_EQUAL:       true,
+	vm.OP_EQUALVERIFY: true,
+	vm.OP_RIPEMD160:   true,
+	vm.OP_SHA1:        true,
+	vm.OP_SHA256:      true,
+	vm.OP_SHA3:        true,
+}
+
+// step runs inst, the instruction at pc in prog,
+// on the abstract state s.
+func step(prog []byte, pc uint32, inst vm.Instruction, s *state) *effect {
+	eff := &effect{
+		next:          make(map[uint32]*state),
+		cost:          1,
+		dataDependent: dataDependent[inst.Op],
+	}
+	if c, ok := fixedCost[inst.Op]; ok {
+		eff.cost = c
+	}
+	nextPC := pc + inst.Len
+	depth := s.depth
+
+	switch op := inst.Op; {
+	case op == vm.OP_FALSE || op == vm.OP_1NEGATE || (op >= vm.OP_1 && op <= vm.OP_16) ||
+		(op >= vm.OP_DATA_1 && op <= vm.OP_PUSHDATA4):
+		data := inst.Data
+		if op == vm.OP_FALSE {
+			data = []byte{}
+		} else if op == vm.OP_1NEGATE {
+			data = vm.Int64Bytes(-1)
+		}
+		s.push(value{known: true, data: data})
+		eff.cost += int64(len(data))
+
+	case op == vm.OP_JUMP:
+		eff.next[jumpTarget(inst)] = s
+		return eff
+
+	case op == vm.OP_JUMPIF:
+		cond := s.pop()
+		target := jumpTarget(inst)
+		if !cond.known || vm.AsBool(cond.data) {
+			eff.next[target] = s
+		}
+		if !cond.known || !vm.AsBool(cond.data) {
+			eff.next[nextPC] = s.copy()
+		}
+		return eff
+
+	case op == vm.OP_FAIL:
+		return eff
+
+	case op == vm.OP_VERIFY:
+		v := s.pop()
+		if v.known && !vm.AsBool(v.data) {
+			return eff
+		}
+
+	case op == vm.OP_EQUALVERIFY:
+		b, a := s.pop(), s.pop()
+		if a.known && b.known && !bytes.Equal(a.data, b.data) {
+			return eff
+		}
+
+	case op == vm.OP_NUMEQUALVERIFY:
+		b, bok := s.popInt()
+		a, aok := s.popInt()
+		if aok && bok && a != b {
+			return eff
+		}
+
+	case op == vm.OP_TOALTSTACK || op == vm.OP_DROP:
+		s.pop()
+
+	case op == vm.OP_FROMALTSTACK:
+		s.push(value{})
+
+	case op == vm.OP_DUP:
+		s.push(s.peek(0))
+
+	case op == vm.OP_SWAP:
+		b, a := s.pop(), s.pop()
+		s.push(b)
+		s.push(a)
+
+	case op == vm.OP_PICK:
+		n, ok := s.popInt()
+		if ok && n >= 0 && n < int64(len(s.stack)) {
+			s.push(s.peek(int(n)))
+		} else {
+			s.push(value{})
+		}
+
+	case op == vm.OP_ROLL:
+		n, ok := s.popInt()
+		if ok && n >= 0 && n < int64(len(s.stack)) {
+			i := len(s.stack) - 1 - int(n)
+			v := s.stack[i]
+			s.stack = append(s.stack[:i], s.stack[i+1:]...)
+			s.stack = append(s.stack, v)
+		} else {
+			// The rolled item and all those above it
+			// are now in unknown places.
+			s.stack = nil
+		}
+
+	case op == vm.OP_CHECKMULTISIG:
+		npub, ok := s.popInt()
+		nsig, ok2 := s.popInt()
+		if ok && ok2 && npub >= 0 && nsig >= 0 && nsig <= npub {
+			eff.cost = 1024 * npub
+			s.drop(int(npub) + 1 + int(nsig))
+		} else {
+			eff.dataDependent = true
+			s.drop(1)
+			s.stack = nil
+		}
+		s.push(value{})
+
+	case op == vm.OP_CHECKPREDICATE:
+		limit, lok := s.popInt()
+		s.pop()
+		n, nok := s.popInt()
+		if lok {
+			eff.cost += limit
+		}
+		if !lok || limit == 0 {
+			// A limit of 0 gives the predicate
+			// all the remaining run limit.
+			eff.dataDependent = true
+		}
+		if nok && n >= 0 {
+			s.drop(int(n))
+		} else {
+			s.stack = nil
+		}
+		s.push(value{})
+
+	default:
+		if e, ok := stackEffect[op]; ok {
+			s.drop(e[0])
+			s.pushUnknown(e[1])
+		}
+	}
+
+	// Count the memory cost of the
+	// items the instruction pushed.
+	if s.depth > depth {
+		eff.cost += 8 * int64(s.depth-depth)
+	}
+	eff.next[nextPC] = s
+	return eff
+}
+
+func jumpTarget(inst vm.Instruction) uint32 {
+	return binary.LittleEndian.Uint32(inst.Data)
+}
@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		src        string
+		ops        []vm.Op
+		depth      int
+		cost       int64
+		canSucceed bool
+	}{
+		{"", nil, 0, 0, true},
+		{"TRUE", []vm.Op{vm.OP_1}, 1, 10, true},
+		{"FALSE", []vm.Op{vm.OP_FALSE}, 1, 9, false},
+		{"FAIL", []vm.Op{vm.OP_FAIL}, 0, 1, false},
+		{"0 VERIFY 1", []vm.Op{vm.OP_FALSE, vm.OP_VERIFY}, 1, 10, false},
+		{"2 3 ADD 5 NUMEQUAL", []vm.Op{vm.OP_2, vm.OP_3, vm.OP_5, vm.OP_ADD, vm.OP_NUMEQUAL}, 2, 34, true},
+		{"TXSIGHASH 0x01 CHECKSIG", []vm.Op{vm.OP_DATA_1, vm.OP_CHECKSIG, vm.OP_TXSIGHASH}, 2, 1298, true},
+
+		// The ELSE branch is unreachable.
+		{"1 IF 2 ELSE FAIL ENDIF", []vm.Op{vm.OP_1, vm.OP_2, vm.OP_JUMP, vm.OP_JUMPIF}, 1, 22, true},
+
+		// Witness-dependent branches.
+		{"IF 0 ELSE 0 ENDIF", []vm.Op{vm.OP_FALSE, vm.OP_JUMP, vm.OP_JUMPIF}, 0, 11, false},
+		{"IF 1 ELSE 0 ENDIF", []vm.Op{vm.OP_FALSE, vm.OP_1, vm.OP_JUMP, vm.OP_JUMPIF}, 0, 12, true},
+	}
+	for _, c := range cases {
+		prog, err := vm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := Analyze(prog)
+		if err != nil {
+			t.Errorf("Analyze(%s) error: %s", c.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(a.Ops, c.ops) {
+			t.Errorf("Analyze(%s).Ops = %v, want %v", c.src, a.Ops, c.ops)
+		}
+		if a.MaxStackDepth != c.depth || a.StackUnbounded {
+			t.Errorf("Analyze(%s) stack depth = %d (unbounded %v), want %d", c.src, a.MaxStackDepth, a.StackUnbounded, c.depth)
+		}
+		if a.MaxCost != c.cost || a.CostUnbounded {
+			t.Errorf("Analyze(%s) cost = %d (unbounded %v), want %d", c.src, a.MaxCost, a.CostUnbounded, c.cost)
+		}
+		if a.CanSucceed != c.canSucceed {
+			t.Errorf("Analyze(%s).CanSucceed = %v, want %v", c.src, a.CanSucceed, c.canSucceed)
+		}
+	}
+}
+
+func TestAnalyzeLoops(t *testing.T) {
+	// Pushes an item each time around.
+	prog, err := vm.Assemble("BEGIN 1 DUP WHILE REPEAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := Analyze(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.StackUnbounded || !a.CostUnbounded {
+		t.Errorf("got unbounded stack %v, cost %v, want true, true", a.StackUnbounded, a.CostUnbounded)
+	}
+
+	// Consumes its arguments.
+	prog, err = vm.Assemble("BEGIN WHILE REPEAT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err = Analyze(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.StackUnbounded || !a.CostUnbounded || !a.CanSucceed {
+		t.Errorf("got unbounded stack %v, cost %v, can succeed %v, want false, true, true", a.StackUnbounded, a.CostUnbounded, a.CanSucceed)
+	}
+}
+
+func TestAnalyzeDisallowed(t *testing.T) {
+	a, err := Analyze([]byte{0x50, byte(vm.OP_TRUE)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Disallowed, []vm.Op{0x50}) {
+		t.Errorf("got disallowed %v, want [NOPx50]", a.Disallowed)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	pub1, _, _ := ed25519.GenerateKey(nil)
+	pub2, _, _ := ed25519.GenerateKey(nil)
+	pubkeys := []ed25519.PublicKey{pub1, pub2}
+
+	p2sp, err := vmutil.P2SPMultiSigProgram(pubkeys, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetProg := append(vmutil.NewBuilder().AddData([]byte(`{"a":1}`)).AddOp(vm.OP_DROP).Program, p2sp...)
+	blockProg, err := vmutil.BlockMultiSigProgram(pubkeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		prog []byte
+		want *Template
+	}{
+		{p2sp, &Template{Kind: P2SPMultiSig, PublicKeys: pubkeys, Quorum: 1}},
+		{assetProg, &Template{Kind: AssetDefinition, PublicKeys: pubkeys, Quorum: 1, Definition: []byte(`{"a":1}`)}},
+		{blockProg, &Template{Kind: BlockMultiSig, PublicKeys: pubkeys, Quorum: 2}},
+		{append([]byte{byte(vm.OP_NOP)}, p2sp...), nil},
+		{[]byte{byte(vm.OP_TRUE)}, nil},
+	}
+	for i, c := range cases {
+		a, err := Analyze(c.prog)
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(a.Template, c.want) {
+			t.Errorf("case %d: got template %+v, want %+v", i, a.Template, c.want)
+		}
+	}
+}
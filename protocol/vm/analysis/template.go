@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"bytes"
+
+	"chain/crypto/ed25519"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// Kinds of standard program.
+const (
+	// P2SPMultiSig is a pay-to-signed-predicate program, as made by
+	// vmutil.P2SPMultiSigProgram, satisfied by a predicate signed
+	// by a quorum of its keys. Accounts use these as control
+	// programs.
+	P2SPMultiSig = "p2sp_multisig"
+
+	// AssetDefinition is an issuance program that commits to an
+	// asset definition and is otherwise a P2SPMultiSig program.
+	// Assets created by Chain Core use these.
+	AssetDefinition = "asset_definition"
+
+	// BlockMultiSig is a consensus program, as made by
+	// vmutil.BlockMultiSigProgram, satisfied by the signatures
+	// of a quorum of its keys on a block.
+	BlockMultiSig = "block_multisig"
+)
+
+// Template describes a standard program.
+type Template struct {
+	Kind       string
+	PublicKeys []ed25519.PublicKey
+	Quorum     int
+
+	// Definition is the asset definition
+	// committed to by an AssetDefinition program.
+	Definition []byte
+}
+
+// recognize returns the template of prog,
+// or nil if it is not a standard program.
+func recognize(prog []byte) *Template {
+	pubkeys, quorum, err := vmutil.ParseP2SPMultiSigProgram(prog)
+	if err == nil {
+		want, err := vmutil.P2SPMultiSigProgram(pubkeys, quorum)
+		if err != nil {
+			return nil
+		}
+		if bytes.Equal(prog, want) {
+			return &Template{Kind: P2SPMultiSig, PublicKeys: pubkeys, Quorum: quorum}
+		}
+
+		// An asset definition is pushed
+		// and dropped before the multisig.
+		insts, err := vm.ParseProgram(prog)
+		if err != nil || len(insts) < 2 || insts[1].Op != vm.OP_DROP {
+			return nil
+		}
+		prefix := vmutil.NewBuilder().AddData(insts[0].Data).AddOp(vm.OP_DROP).Program
+		if bytes.Equal(prog, append(prefix, want...)) {
+			return &Template{Kind: AssetDefinition, PublicKeys: pubkeys, Quorum: quorum, Definition: insts[0].Data}
+		}
+		return nil
+	}
+
+	pubkeys, quorum, err = vmutil.ParseBlockMultiSigProgram(prog)
+	if err == nil {
+		want, err := vmutil.BlockMultiSigProgram(pubkeys, quorum)
+		if err == nil && bytes.Equal(prog, want) {
+			return &Template{Kind: BlockMultiSig, PublicKeys: pubkeys, Quorum: quorum}
+		}
+	}
+	return nil
+}
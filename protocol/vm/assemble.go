@@ -18,6 +18,19 @@ import (
 // be inferred.
 // Input may include jump-target labels of the form $foo, which can
 // then be used as JUMP:$foo or JUMPIF:$foo.
+//
+// Input may also include the structured control-flow words
+//
+//	cond IF ... [ELSE ...] ENDIF
+//	BEGIN ... cond WHILE ... REPEAT
+//
+// which are compiled into JUMP and JUMPIF ops. IF consumes
+// the top item of the data stack, running the code up to ELSE
+// or ENDIF if it is true and the code after ELSE (if any)
+// otherwise. WHILE consumes the top item of the data stack,
+// running the code up to REPEAT and then jumping back to BEGIN
+// if it is true, and continuing after REPEAT otherwise. Blocks
+// may be nested.
 func Assemble(s string) (res []byte, err error) {
 	// maps labels to the location each refers to
 	locations := make(map[string]uint32)
@@ -25,6 +38,9 @@ func Assemble(s string) (res []byte, err error) {
 	// maps unresolved uses of labels to the locations that need to be filled in
 	unresolved := make(map[string][]int)
 
+	// the open IF and BEGIN blocks, innermost last
+	var blocks []*asmBlock
+
 	handleJump := func(addrStr string, opcode Op) error {
 		res = append(res, byte(opcode))
 		l := len(res)
@@ -45,17 +61,86 @@ func Assemble(s string) (res []byte, err error) {
 		return nil
 	}
 
+	// jump appends a jump to addr and returns
+	// the location of the address, for patching.
+	jump := func(opcode Op, addr uint32) int {
+		res = append(res, byte(opcode))
+		l := len(res)
+		var fourBytes [4]byte
+		binary.LittleEndian.PutUint32(fourBytes[:], addr)
+		res = append(res, fourBytes[:]...)
+		return l
+	}
+
+	// patch points the jump address at l to the end of res.
+	patch := func(l int) {
+		binary.LittleEndian.PutUint32(res[l:], uint32(len(res)))
+	}
+
+	// top returns the innermost open block if it was
+	// last continued by one of the given words.
+	top := func(word string, ntok int, want ...string) (*asmBlock, error) {
+		if len(blocks) == 0 {
+			return nil, errors.Wrapf(ErrNesting, "%s at token %d has no matching %s", word, ntok, want[0])
+		}
+		b := blocks[len(blocks)-1]
+		for _, w := range want {
+			if b.word == w {
+				return b, nil
+			}
+		}
+		return nil, errors.Wrapf(ErrNesting, "%s at token %d does not match %s at token %d", word, ntok, b.word, b.token)
+	}
+
+	var ntok int
 	scanner := bufio.NewScanner(strings.NewReader(s))
 	scanner.Split(split)
 	for scanner.Scan() {
 		token := scanner.Text()
+		ntok++
 		if info, ok := opsByName[token]; ok {
 			if strings.HasPrefix(token, "PUSHDATA") || strings.HasPrefix(token, "JUMP") {
 				return nil, errors.Wrap(ErrToken, token)
 			}
 			res = append(res, byte(info.op))
+		} else if token == "IF" {
+			// Jump over the jump that skips the block
+			// if the condition is true.
+			jump(OP_JUMPIF, uint32(len(res)+10))
+			blocks = append(blocks, &asmBlock{word: token, token: ntok, fixup: jump(OP_JUMP, 0)})
+		} else if token == "ELSE" {
+			b, err := top(token, ntok, "IF")
+			if err != nil {
+				return nil, err
+			}
+			fixup := jump(OP_JUMP, 0)
+			patch(b.fixup)
+			b.word, b.token, b.fixup = token, ntok, fixup
+		} else if token == "ENDIF" {
+			b, err := top(token, ntok, "IF", "ELSE")
+			if err != nil {
+				return nil, err
+			}
+			patch(b.fixup)
+			blocks = blocks[:len(blocks)-1]
+		} else if token == "BEGIN" {
+			blocks = append(blocks, &asmBlock{word: token, token: ntok, begin: uint32(len(res))})
+		} else if token == "WHILE" {
+			b, err := top(token, ntok, "BEGIN")
+			if err != nil {
+				return nil, err
+			}
+			jump(OP_JUMPIF, uint32(len(res)+10))
+			b.word, b.token, b.fixup = token, ntok, jump(OP_JUMP, 0)
+		} else if token == "REPEAT" {
+			b, err := top(token, ntok, "WHILE")
+			if err != nil {
+				return nil, err
+			}
+			jump(OP_JUMP, b.begin)
+			patch(b.fixup)
+			blocks = blocks[:len(blocks)-1]
 		} else if strings.HasPrefix(token, "JUMP:") {
-			// TODO (Dan): add IF/ELSE/ENDIF and BEGIN/WHILE/REPEAT
 			err = handleJump(strings.TrimPrefix(token, "JUMP:"), OP_JUMP)
 			if err != nil {
 				return nil, err
@@ -101,6 +186,12 @@ func Assemble(s string) (res []byte, err error) {
 		return nil, err
 	}
 
+	if len(blocks) > 0 {
+		b := blocks[len(blocks)-1]
+		closer := map[string]string{"IF": "ENDIF", "ELSE": "ENDIF", "BEGIN": "WHILE", "WHILE": "REPEAT"}[b.word]
+		return nil, errors.Wrapf(ErrNesting, "%s at token %d has no matching %s", b.word, b.token, closer)
+	}
+
 	for label, uses := range unresolved {
 		location, ok := locations[label]
 		if !ok {
@@ -114,22 +205,203 @@ func Assemble(s string) (res []byte, err error) {
 	return res, nil
 }
 
+// asmBlock is an open IF or BEGIN block in Assemble.
+type asmBlock struct {
+	word  string // the last control-flow word of the block: IF, ELSE, BEGIN or WHILE
+	token int    // the token number of word, for error messages
+	fixup int    // the location of the jump address to point at the end of the current part
+	begin uint32 // for loops, the location of BEGIN
+}
+
+// Disassemble converts a program into the syntax of Assemble.
+// Jumps that have the form of IF, ELSE, ENDIF, BEGIN, WHILE and
+// REPEAT are shown as those words; other jump targets are given
+// labels.
 func Disassemble(prog []byte) (string, error) {
+	lines, err := disassemble(prog)
+	if err != nil {
+		return "", err
+	}
+	var strs []string
+	for _, l := range lines {
+		strs = append(strs, l.words...)
+	}
+	return strings.Join(strs, " "), nil
+}
+
+// DisassembleIndent is like Disassemble, but puts each
+// control-flow word on a line of its own and indents the
+// body of each block with a tab.
+func DisassembleIndent(prog []byte) (string, error) {
+	lines, err := disassemble(prog)
+	if err != nil {
+		return "", err
+	}
+	var strs []string
+	for _, l := range lines {
+		strs = append(strs, strings.Repeat("\t", l.depth)+strings.Join(l.words, " "))
+	}
+	return strings.Join(strs, "\n"), nil
+}
+
+type disLine struct {
+	depth int
+	words []string
+}
+
+// disBlock is an IF or BEGIN block found in a program by
+// disassemble. For IF blocks, start is the location of IF,
+// mid that of ELSE, if hasElse is set, and end is the location
+// after ENDIF. For loops, start is the location of BEGIN, test
+// that of WHILE, mid that of REPEAT, and end the location after
+// REPEAT.
+type disBlock struct {
+	loop    bool
+	hasElse bool
+	start   uint32
+	test    uint32
+	mid     uint32
+	end     uint32
+}
+
+// parts returns the ranges of the program
+// between the block's control-flow words.
+func (b *disBlock) parts() [][2]uint32 {
+	if b.loop {
+		return [][2]uint32{{b.start, b.test}, {b.test + 10, b.mid}}
+	}
+	if b.hasElse {
+		return [][2]uint32{{b.start + 10, b.mid}, {b.mid + 5, b.end}}
+	}
+	return [][2]uint32{{b.start + 10, b.end}}
+}
+
+// inside reports whether b lies within one of the parts of c.
+func (b *disBlock) inside(c *disBlock) bool {
+	for _, p := range c.parts() {
+		if p[0] <= b.start && b.end <= p[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// jumps returns the locations of the jumps
+// that make up the block's control-flow words.
+func (b *disBlock) jumps() []uint32 {
+	if b.loop {
+		return []uint32{b.test, b.test + 5, b.mid}
+	}
+	if b.hasElse {
+		return []uint32{b.start, b.start + 5, b.mid}
+	}
+	return []uint32{b.start, b.start + 5}
+}
+
+func disassemble(prog []byte) ([]disLine, error) {
 	var (
 		insts []Instruction
+		locs  = make(map[uint32]Instruction)
 
 		// maps program locations (used as jump targets) to a label for each
 		labels = make(map[uint32]string)
 	)
 
-	// first pass: look for jumps
+	// first pass: parse the program
 	for i := uint32(0); i < uint32(len(prog)); {
 		inst, err := ParseOp(prog, i)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		switch inst.Op {
-		case OP_JUMP, OP_JUMPIF:
+		insts = append(insts, inst)
+		locs[i] = inst
+		i += inst.Len
+	}
+	jumpAt := func(loc uint32, op Op) (addr uint32, ok bool) {
+		inst, ok := locs[loc]
+		if !ok || inst.Op != op {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint32(inst.Data), true
+	}
+	isLoc := func(loc uint32) bool {
+		_, ok := locs[loc]
+		return ok || loc == uint32(len(prog))
+	}
+
+	// second pass: find the jumps that
+	// have the form of structured blocks
+	var (
+		blocks []*disBlock
+		loc    uint32
+	)
+	for _, inst := range insts {
+		p := loc
+		loc += inst.Len
+		if addr, ok := jumpAt(p, OP_JUMPIF); !ok || addr != p+10 {
+			continue
+		}
+		x, ok := jumpAt(p+5, OP_JUMP)
+		if !ok || x < p+10 || !isLoc(x) {
+			continue
+		}
+		b := &disBlock{start: p, end: x}
+		if x >= p+15 {
+			if y, ok := jumpAt(x-5, OP_JUMP); ok && y <= p && isLoc(y) {
+				b = &disBlock{loop: true, start: y, test: p, mid: x - 5, end: x}
+			} else if ok && y >= x && isLoc(y) {
+				b = &disBlock{hasElse: true, start: p, mid: x - 5, end: y}
+			}
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Keep the blocks that nest properly and don't contain
+	// the target of some other jump inside their words.
+	var (
+		kept    []*disBlock
+		blockOf = make(map[uint32]*disBlock) // jump location -> block
+	)
+	targets := make(map[uint32]int)
+	for _, inst := range locs {
+		if inst.Op == OP_JUMP || inst.Op == OP_JUMPIF {
+			targets[binary.LittleEndian.Uint32(inst.Data)]++
+		}
+	}
+	for _, b := range blocks {
+		ok := true
+		for _, c := range kept {
+			disjoint := b.end <= c.start || c.end <= b.start
+			if !disjoint && !b.inside(c) && !c.inside(b) {
+				ok = false
+				break
+			}
+		}
+		wordStart := b.start
+		if b.loop {
+			wordStart = b.test
+		}
+		if targets[wordStart+5] > 0 {
+			ok = false
+		}
+		for _, j := range b.jumps() {
+			if blockOf[j] != nil {
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+		kept = append(kept, b)
+		for _, j := range b.jumps() {
+			blockOf[j] = b
+		}
+	}
+
+	// third pass: label the targets of the remaining jumps
+	loc = 0
+	for _, inst := range insts {
+		if blockOf[loc] == nil && (inst.Op == OP_JUMP || inst.Op == OP_JUMPIF) {
 			addr := binary.LittleEndian.Uint32(inst.Data)
 			if _, ok := labels[addr]; !ok {
 				labelNum := len(labels)
@@ -140,42 +412,82 @@ func Disassemble(prog []byte) (string, error) {
 				labels[addr] = label
 			}
 		}
-		insts = append(insts, inst)
-		i += inst.Len
+		loc += inst.Len
 	}
 
 	var (
-		loc  uint32
-		strs []string
+		lines []disLine
+		open  []*disBlock
+		cur   = -1 // index of the line to add words to, if any
 	)
+	add := func(word string) {
+		if cur < 0 {
+			lines = append(lines, disLine{depth: len(open)})
+			cur = len(lines) - 1
+		}
+		lines[cur].words = append(lines[cur].words, word)
+	}
+	addLine := func(word string, depth int) {
+		lines = append(lines, disLine{depth: depth, words: []string{word}})
+		cur = -1
+	}
 
-	for _, inst := range insts {
+	for loc = 0; ; {
+		for len(open) > 0 && !open[len(open)-1].loop && open[len(open)-1].end == loc {
+			open = open[:len(open)-1]
+			addLine("ENDIF", len(open))
+		}
 		if label, ok := labels[loc]; ok {
-			strs = append(strs, "$"+label)
+			add("$" + label)
+		}
+		// Loops are kept in order of WHILE, so outer
+		// loops beginning here come after inner ones.
+		for i := len(kept) - 1; i >= 0; i-- {
+			if b := kept[i]; b.loop && b.start == loc {
+				addLine("BEGIN", len(open))
+				open = append(open, b)
+			}
+		}
+		if loc >= uint32(len(prog)) {
+			break
 		}
 
-		var str string
+		if b := blockOf[loc]; b != nil {
+			switch {
+			case !b.loop && loc == b.start:
+				addLine("IF", len(open))
+				open = append(open, b)
+				loc += 10
+			case b.loop && loc == b.test:
+				addLine("WHILE", len(open)-1)
+				loc += 10
+			case !b.loop:
+				addLine("ELSE", len(open)-1)
+				loc += 5
+			default:
+				open = open[:len(open)-1]
+				addLine("REPEAT", len(open))
+				loc += 5
+			}
+			continue
+		}
+
+		inst := locs[loc]
 		switch inst.Op {
 		case OP_JUMP, OP_JUMPIF:
 			addr := binary.LittleEndian.Uint32(inst.Data)
-			str = fmt.Sprintf("%s:$%s", inst.Op.String(), labels[addr])
+			add(fmt.Sprintf("%s:$%s", inst.Op.String(), labels[addr]))
 		default:
 			if len(inst.Data) > 0 {
-				str = fmt.Sprintf("0x%x", inst.Data)
+				add(fmt.Sprintf("0x%x", inst.Data))
 			} else {
-				str = inst.Op.String()
+				add(inst.Op.String())
 			}
 		}
-		strs = append(strs, str)
-
 		loc += inst.Len
 	}
 
-	if label, ok := labels[loc]; ok {
-		strs = append(strs, "$"+label)
-	}
-
-	return strings.Join(strs, " "), nil
+	return lines, nil
 }
 
 // split is a bufio.SplitFunc for scanning the input to Compile.
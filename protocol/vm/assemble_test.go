@@ -23,6 +23,15 @@ func TestAssemble(t *testing.T) {
 		{`0x1`, nil, hex.ErrLength},
 		{`BADTOKEN`, nil, ErrToken},
 		{`'Unterminated quote`, nil, ErrToken},
+		{"1 IF 2 ELSE 3 ENDIF", mustDecodeHex("51640b000000631100000052631200000053"), nil},
+		{"BEGIN 1 WHILE REPEAT", mustDecodeHex("51640b00000063100000006300000000"), nil},
+		{"ELSE", nil, ErrNesting},
+		{"1 IF", nil, ErrNesting},
+		{"1 IF ELSE ELSE ENDIF", nil, ErrNesting},
+		{"BEGIN REPEAT", nil, ErrNesting},
+		{"BEGIN 1 WHILE ENDIF", nil, ErrNesting},
+		{"1 IF BEGIN ENDIF", nil, ErrNesting},
+		{"1 WHILE", nil, ErrNesting},
 	}
 
 	for _, c := range cases {
@@ -52,6 +61,7 @@ func TestDisassemble(t *testing.T) {
 		{mustDecodeHex("525393559c"), "0x02 0x03 ADD 0x05 NUMEQUAL", nil},
 		{mustDecodeHex("01135e94559c"), "0x13 0x0e SUB 0x05 NUMEQUAL", nil},
 		{mustDecodeHex("6300000000"), "$alpha JUMP:$alpha", nil},
+		{mustDecodeHex("6400000000630a00000075"), "$alpha JUMPIF:$alpha JUMP:$bravo $bravo DROP", nil},
 		{[]byte{0xff}, "NOPxff", nil},
 	}
 
@@ -72,3 +82,51 @@ func TestDisassemble(t *testing.T) {
 		}
 	}
 }
+
+func TestDisassembleControlFlow(t *testing.T) {
+	cases := []struct {
+		src      string
+		indented string
+	}{{
+		"0x01 IF 0x02 ELSE 0x03 ENDIF",
+		"0x01\nIF\n\t0x02\nELSE\n\t0x03\nENDIF",
+	}, {
+		"0x01 IF ENDIF",
+		"0x01\nIF\nENDIF",
+	}, {
+		"BEGIN DUP 0x0a LESSTHAN WHILE 0x0b IF 1ADD ENDIF REPEAT",
+		"BEGIN\n\tDUP 0x0a LESSTHAN\nWHILE\n\t0x0b\n\tIF\n\t\t1ADD\n\tENDIF\nREPEAT",
+	}, {
+		"BEGIN BEGIN 0x01 WHILE REPEAT 0x01 WHILE REPEAT",
+		"BEGIN\n\tBEGIN\n\t\t0x01\n\tWHILE\n\tREPEAT\n\t0x01\nWHILE\nREPEAT",
+	}, {
+		// A jump into the middle of IF
+		// can't be shown as structured.
+		"0x01 JUMPIF:$alpha $charlie JUMP:$bravo $alpha JUMP:$charlie $bravo",
+		"0x01 JUMPIF:$alpha $charlie JUMP:$bravo $alpha JUMP:$charlie $bravo",
+	}, {
+		"JUMP:$alpha 0x01 IF $alpha DROP ENDIF",
+		"JUMP:$alpha 0x01\nIF\n\t$alpha DROP\nENDIF",
+	}}
+
+	for _, c := range cases {
+		prog, err := Assemble(c.src)
+		if err != nil {
+			t.Fatalf("Assemble(%s): %s", c.src, err)
+		}
+		got, err := Disassemble(prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.src {
+			t.Errorf("Disassemble(%x) = %s want %s", prog, got, c.src)
+		}
+		got, err = DisassembleIndent(prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.indented {
+			t.Errorf("DisassembleIndent(%x) = %q want %q", prog, got, c.indented)
+		}
+	}
+}
@@ -68,6 +68,7 @@ func opCheckPredicate(vm *virtualMachine) error {
 		tx:         vm.tx,
 		inputIndex: vm.inputIndex,
 		sigHasher:  vm.sigHasher,
+		trace:      vm.trace,
 	}
 	vm.dataStack = vm.dataStack[:l-n]
 
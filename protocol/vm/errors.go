@@ -10,6 +10,7 @@ var (
 	ErrDisallowedOpcode   = errors.New("disallowed opcode")
 	ErrDivZero            = errors.New("division by zero")
 	ErrLongProgram        = errors.New("program size exceeds maxint32")
+	ErrNesting            = errors.New("unbalanced control flow")
 	ErrRange              = errors.New("range error")
 	ErrReturn             = errors.New("RETURN executed")
 	ErrRunLimitExceeded   = errors.New("run limit exceeded")
@@ -458,6 +458,13 @@ func ParseProgram(prog []byte) ([]Instruction, error) {
 
 var isExpansion [256]bool
 
+// IsExpansion reports whether op is reserved for future
+// expansion. Expansion ops are no-ops, but transactions
+// of version 1 may not use them.
+func (op Op) IsExpansion() bool {
+	return isExpansion[op]
+}
+
 func init() {
 	for i := 1; i <= 75; i++ {
 		ops[i] = opInfo{Op(i), fmt.Sprintf("DATA_%d", i), opPushdata}
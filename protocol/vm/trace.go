@@ -0,0 +1,31 @@
+package vm
+
+// Step is the state of the VM
+// just before it executes an instruction.
+type Step struct {
+	// Depth is the number of CHECKPREDICATE
+	// calls that led to the running program.
+	Depth int
+
+	// Program is the running program,
+	// and PC the location of the instruction in it.
+	Program []byte
+	PC      uint32
+
+	// Op and Data are the instruction's
+	// opcode and the data it pushes, if any.
+	Op   Op
+	Data []byte
+
+	RunLimit int64
+
+	// DataStack and AltStack are copies of the
+	// VM's stacks, with the top item last.
+	DataStack [][]byte
+	AltStack  [][]byte
+}
+
+// Tracer is called with each step of a program
+// run by VerifyTxInputTrace or VerifyBlockHeaderTrace.
+// It must not modify the items of the stacks.
+type Tracer func(Step)
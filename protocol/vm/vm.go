@@ -32,6 +32,9 @@ type virtualMachine struct {
 	sigHasher  *bc.SigHasher
 
 	block *bc.Block
+
+	// trace, if non-nil, is called before each step
+	trace Tracer
 }
 
 // TraceOut - if non-nil - will receive trace output during
@@ -45,10 +48,23 @@ func VerifyTxInput(tx *bc.Tx, inputIndex int) (ok bool, err error) {
 			err = ErrUnexpected
 		}
 	}()
-	return verifyTxInput(tx, inputIndex)
+	return verifyTxInput(tx, inputIndex, nil)
+}
+
+// VerifyTxInputTrace is like VerifyTxInput, but calls trace
+// before each step of the program, including the steps of
+// predicates run by CHECKPREDICATE.
+func VerifyTxInputTrace(tx *bc.Tx, inputIndex int, trace Tracer) (ok bool, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	return verifyTxInput(tx, inputIndex, trace)
 }
 
-func verifyTxInput(tx *bc.Tx, inputIndex int) (bool, error) {
+func verifyTxInput(tx *bc.Tx, inputIndex int, trace Tracer) (bool, error) {
 	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
 		return false, ErrBadValue
 	}
@@ -78,6 +94,7 @@ func verifyTxInput(tx *bc.Tx, inputIndex int) (bool, error) {
 
 		program:  program,
 		runLimit: initialRunLimit,
+		trace:    trace,
 	}
 
 	for _, arg := range txinput.Arguments() {
@@ -97,15 +114,28 @@ func VerifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (ok bool, err erro
 			err = ErrUnexpected
 		}
 	}()
-	return verifyBlockHeader(prev, block)
+	return verifyBlockHeader(prev, block, nil)
 }
 
-func verifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (bool, error) {
+// VerifyBlockHeaderTrace is like VerifyBlockHeader,
+// but calls trace before each step of the program.
+func VerifyBlockHeaderTrace(prev *bc.BlockHeader, block *bc.Block, trace Tracer) (ok bool, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	return verifyBlockHeader(prev, block, trace)
+}
+
+func verifyBlockHeader(prev *bc.BlockHeader, block *bc.Block, trace Tracer) (bool, error) {
 	vm := virtualMachine{
 		block: block,
 
 		program:  prev.ConsensusProgram,
 		runLimit: initialRunLimit,
+		trace:    trace,
 	}
 
 	for _, arg := range block.Witness {
@@ -142,6 +172,19 @@ func (vm *virtualMachine) step() error {
 
 	vm.nextPC = vm.pc + inst.Len
 
+	if vm.trace != nil {
+		vm.trace(Step{
+			Depth:     vm.depth,
+			Program:   vm.program,
+			PC:        vm.pc,
+			Op:        inst.Op,
+			Data:      inst.Data,
+			RunLimit:  vm.runLimit,
+			DataStack: append([][]byte(nil), vm.dataStack...),
+			AltStack:  append([][]byte(nil), vm.altStack...),
+		})
+	}
+
 	if TraceOut != nil {
 		opname := inst.Op.String()
 		fmt.Fprintf(TraceOut, "vm %d pc %d limit %d %s", vm.depth, vm.pc, vm.runLimit, opname)
@@ -139,6 +139,14 @@ func doOKNotOK(t *testing.T, expectOK bool) {
 		{"0 JUMP:7 1ADD DUP 10 LESSTHAN JUMPIF:6 10 NUMEQUAL", nil},                       // fixed version of "0 1 WHILE DROP 1ADD DUP 10 LESSTHAN ENDWHILE 10 NUMEQUAL"
 		{"0 JUMP:$dup $add 1ADD $dup DUP 10 LESSTHAN JUMPIF:$add 10 NUMEQUAL", nil},       // fixed version of "0 1 WHILE DROP 1ADD DUP 10 LESSTHAN ENDWHILE 10 NUMEQUAL"
 
+		// structured control flow
+		{"1 IF 4 ELSE 5 ENDIF 4 NUMEQUAL", nil},
+		{"0 IF 4 ELSE 5 ENDIF 5 NUMEQUAL", nil},
+		{"0x0000000000000000000000000000000000000000000000000000000000000001 IF 4 ELSE 5 ENDIF 4 NUMEQUAL", nil},
+		{"4 0 IF 1ADD ENDIF 4 NUMEQUAL", nil},
+		{"1 IF 0 IF 3 ELSE 4 ENDIF ELSE 5 ENDIF 4 NUMEQUAL", nil},
+		{"0 BEGIN DUP 10 LESSTHAN WHILE 1ADD REPEAT 10 NUMEQUAL", nil},
+		{"0 0 BEGIN DUP 3 LESSTHAN WHILE SWAP 0 BEGIN DUP 3 LESSTHAN WHILE SWAP 1ADD SWAP 1ADD REPEAT DROP SWAP 1ADD REPEAT DROP 9 NUMEQUAL", nil},
 	}
 	for i, c := range cases {
 		progSrc := c.prog
@@ -454,7 +462,7 @@ func TestVerifyTxInputQuickCheck(t *testing.T) {
 		tx := bc.NewTx(bc.TxData{
 			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, witnesses, bc.AssetID{}, 10, program, nil)},
 		})
-		verifyTxInput(tx, 0)
+		verifyTxInput(tx, 0, nil)
 		return true
 	}
 	if err := quick.Check(f, nil); err != nil {
@@ -476,10 +484,58 @@ func TestVerifyBlockHeaderQuickCheck(t *testing.T) {
 		}()
 		prev := &bc.BlockHeader{ConsensusProgram: program}
 		block := &bc.Block{BlockHeader: bc.BlockHeader{Witness: witnesses}}
-		verifyBlockHeader(prev, block)
+		verifyBlockHeader(prev, block, nil)
 		return true
 	}
 	if err := quick.Check(f, nil); err != nil {
 		t.Error(err)
 	}
 }
+
+func TestVerifyTxInputTrace(t *testing.T) {
+	prog, err := Assemble("0 0x5152935387 0 CHECKPREDICATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, [][]byte{{7}}, bc.AssetID{}, 1, prog, nil)},
+	})
+
+	var steps []Step
+	ok, err := VerifyTxInputTrace(tx, 0, func(s Step) { steps = append(steps, s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("got false result, want true")
+	}
+
+	type stepSummary struct {
+		depth int
+		pc    uint32
+		op    Op
+		stack int
+	}
+	want := []stepSummary{
+		{0, 0, OP_0, 1},
+		{0, 1, OP_DATA_5, 2},
+		{0, 7, OP_0, 3},
+		{0, 8, OP_CHECKPREDICATE, 4},
+		{1, 0, OP_1, 0},
+		{1, 1, OP_2, 1},
+		{1, 2, OP_ADD, 2},
+		{1, 3, OP_3, 1},
+		{1, 4, OP_EQUAL, 2},
+	}
+	var got []stepSummary
+	for _, s := range steps {
+		got = append(got, stepSummary{s.Depth, s.PC, s.Op, len(s.DataStack)})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got steps %v, want %v", got, want)
+	}
+	if len(steps) > 3 && !bytes.Equal(steps[3].DataStack[2], []byte{0x51, 0x52, 0x93, 0x53, 0x87}) {
+		t.Errorf("got predicate %x on stack at CHECKPREDICATE, want 5152935387", steps[3].DataStack[2])
+	}
+}